@@ -42,6 +42,11 @@ type VirtualHost struct {
 
 	// Filters attached to this VirtualHost
 	Filters []HostAttachedFilter `json:"filters,omitempty"`
+
+	// The policy for managing request headers of this VirtualHost
+	RequestHeadersPolicy *HeadersPolicy `json:"requestHeadersPolicy,omitempty"`
+	// The policy for managing response headers of this VirtualHost
+	ResponseHeadersPolicy *HeadersPolicy `json:"responseHeadersPolicy,omitempty"`
 }
 
 // TLS describes tls properties. The CNI names that will be matched on
@@ -59,8 +64,8 @@ type TLS struct {
 }
 
 // HeaderCondition specifies the header condition to match.
-// Name is required. Only one of Present or Contains must
-// be provided.
+// Name is required. Only one of Present, NotPresent, Contains, NotContains,
+// Exact, NotExact, Prefix, Suffix or Regex must be provided.
 type HeaderCondition struct {
 
 	// Name is the name of the header to match on. Name is required.
@@ -90,18 +95,90 @@ type HeaderCondition struct {
 	// in the request.
 	// +optional
 	NotExact string `json:"notexact,omitempty"`
+
+	// Prefix is true if the Header starting with this string is present
+	// in the request.
+	// +optional
+	Prefix string `json:"prefix,omitempty"`
+
+	// Suffix is true if the Header ending with this string is present
+	// in the request.
+	// +optional
+	Suffix string `json:"suffix,omitempty"`
+
+	// Regex is true if the Header matching this regular expression is present
+	// in the request. The expression must match the entire value.
+	// +optional
+	Regex string `json:"regex,omitempty"`
+
+	// NotPresent is true if the Header is not present in the request.
+	// +optional
+	NotPresent bool `json:"notpresent,omitempty"`
+
+	// IgnoreCase is true if the value of the Header is compared without
+	// regard to case.
+	// +optional
+	IgnoreCase bool `json:"ignorecase,omitempty"`
+}
+
+// QueryParameterCondition specifies the query parameter condition to match.
+// Name is required. Only one of Exact, Prefix, Regex, Present or NotPresent
+// must be provided.
+type QueryParameterCondition struct {
+
+	// Name is the name of the query parameter to match on. Name is required.
+	// Query parameter names are case sensitive.
+	Name string `json:"name"`
+
+	// Exact is true if the query parameter has this value.
+	// +optional
+	Exact string `json:"exact,omitempty"`
+
+	// Prefix is true if the value of the query parameter starts with this string.
+	// +optional
+	Prefix string `json:"prefix,omitempty"`
+
+	// Regex is true if the value of the query parameter matches this
+	// regular expression.
+	// +optional
+	Regex string `json:"regex,omitempty"`
+
+	// Present is true if the query parameter is present in the request.
+	// +optional
+	Present bool `json:"present,omitempty"`
+
+	// NotPresent is true if the query parameter is not present in the request.
+	// +optional
+	NotPresent bool `json:"notpresent,omitempty"`
 }
 
 // Condition are policies that are applied on top of GatewayHost.
-// One of Prefix or Header must be provided.
+// One of Prefix, Exact, Regex, Method, Header or QueryParameter must be provided.
 type Condition struct {
 	// Prefix defines a prefix match for a request.
 	// +optional
 	Prefix string `json:"prefix,omitempty"`
 
+	// Exact defines an exact match for the path of a request.
+	// +optional
+	Exact string `json:"exact,omitempty"`
+
+	// Regex defines a regular expression match for the path of a request.
+	// The expression must match the entire path.
+	// +optional
+	Regex string `json:"regex,omitempty"`
+
+	// Method defines a match for the HTTP method of a request.
+	// +optional
+	Method string `json:"method,omitempty"`
+
 	// Header specifies the header condition to match.
 	// +optional
 	Header *HeaderCondition `json:"header,omitempty"`
+
+	// QueryParameter specifies the query parameter condition to match.
+	// +optional
+	QueryParameter *QueryParameterCondition `json:"queryParameter,omitempty"`
 }
 
 // Route contains the set of routes for a virtual host
@@ -130,6 +207,15 @@ type Route struct {
 
 	// Disable external authorization for this route
 	DisableExtAuthz bool `json:"disableExtauth,omitempty"`
+
+	// The policy for managing request headers of this route
+	RequestHeadersPolicy *HeadersPolicy `json:"requestHeadersPolicy,omitempty"`
+	// The policy for managing response headers of this route
+	ResponseHeadersPolicy *HeadersPolicy `json:"responseHeadersPolicy,omitempty"`
+
+	// Mirror sends a copy of the requests of this route to other services
+	// +optional
+	Mirror *MirrorPolicy `json:"mirror,omitempty"`
 }
 
 // TCPProxy contains the set of services to proxy TCP connections.
@@ -225,6 +311,62 @@ type TimeoutPolicy struct {
 	ClusterMaxConnectionDuration string `json:"cluster_max_duration,omitempty"`
 }
 
+// HeaderValue represents a header name/value pair
+type HeaderValue struct {
+	// Name represents a key of a header
+	Name string `json:"name"`
+	// Value represents the value of a header. It can use the command
+	// operators of Envoy, e.g. %DOWNSTREAM_REMOTE_ADDRESS_WITHOUT_PORT%
+	// for the client IP or %REQUESTED_SERVER_NAME% for the downstream
+	// TLS SNI. Any other % is sent as is.
+	Value string `json:"value"`
+}
+
+// HeadersPolicy defines how headers are managed during forwarding.
+// Pseudo headers and the Host header of requests cannot be managed.
+type HeadersPolicy struct {
+	// Set replaces the value of a header, adding the header if absent
+	// +optional
+	Set []HeaderValue `json:"set,omitempty"`
+	// Add appends a value to a header, adding the header if absent
+	// +optional
+	Add []HeaderValue `json:"add,omitempty"`
+	// Remove removes headers
+	// +optional
+	Remove []string `json:"remove,omitempty"`
+}
+
+// MirrorService defines a service requests are mirrored to
+type MirrorService struct {
+	// Name is the name of Kubernetes service requests are mirrored to
+	Name string `json:"name"`
+	// Port (defined as Integer) requests are mirrored to
+	//
+	// +required
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65536
+	// +kubebuilder:validation:ExclusiveMinimum=false
+	// +kubebuilder:validation:ExclusiveMaximum=true
+	Port int `json:"port"`
+	// Protocol may be used to specify (or override) the protocol used to reach this Service.
+	// Values may be tls, h2, h2c. If omitted, protocol-selection falls back on Service annotations.
+	// +kubebuilder:validation:Enum=h2;h2c;tls
+	// +optional
+	Protocol string `json:"protocol,omitempty"`
+}
+
+// MirrorPolicy defines how the requests of a route are mirrored. Mirrored
+// requests are fire and forget, their responses are discarded.
+type MirrorPolicy struct {
+	// Services requests are mirrored to
+	Services []MirrorService `json:"services"`
+	// Percent of requests mirrored to each service, defaults to 100
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=100
+	// +optional
+	Percent *uint32 `json:"percent,omitempty"`
+}
+
 // RetryPolicy define the attributes associated with retrying policy
 type RetryPolicy struct {
 	// NumRetries is maximum allowed number of retries.
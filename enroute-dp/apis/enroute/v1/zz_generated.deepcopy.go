@@ -41,6 +41,11 @@ func (in *Condition) DeepCopyInto(out *Condition) {
 		*out = new(HeaderCondition)
 		**out = **in
 	}
+	if in.QueryParameter != nil {
+		in, out := &in.QueryParameter, &out.QueryParameter
+		*out = new(QueryParameterCondition)
+		**out = **in
+	}
 	return
 }
 
@@ -304,6 +309,53 @@ func (in *HeaderCondition) DeepCopy() *HeaderCondition {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HeaderValue) DeepCopyInto(out *HeaderValue) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HeaderValue.
+func (in *HeaderValue) DeepCopy() *HeaderValue {
+	if in == nil {
+		return nil
+	}
+	out := new(HeaderValue)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HeadersPolicy) DeepCopyInto(out *HeadersPolicy) {
+	*out = *in
+	if in.Set != nil {
+		in, out := &in.Set, &out.Set
+		*out = make([]HeaderValue, len(*in))
+		copy(*out, *in)
+	}
+	if in.Add != nil {
+		in, out := &in.Add, &out.Add
+		*out = make([]HeaderValue, len(*in))
+		copy(*out, *in)
+	}
+	if in.Remove != nil {
+		in, out := &in.Remove, &out.Remove
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HeadersPolicy.
+func (in *HeadersPolicy) DeepCopy() *HeadersPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(HeadersPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *HealthCheck) DeepCopyInto(out *HealthCheck) {
 	*out = *in
@@ -414,6 +466,48 @@ func (in *HttpFilterSpec) DeepCopy() *HttpFilterSpec {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MirrorPolicy) DeepCopyInto(out *MirrorPolicy) {
+	*out = *in
+	if in.Services != nil {
+		in, out := &in.Services, &out.Services
+		*out = make([]MirrorService, len(*in))
+		copy(*out, *in)
+	}
+	if in.Percent != nil {
+		in, out := &in.Percent, &out.Percent
+		*out = new(uint32)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MirrorPolicy.
+func (in *MirrorPolicy) DeepCopy() *MirrorPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(MirrorPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MirrorService) DeepCopyInto(out *MirrorService) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MirrorService.
+func (in *MirrorService) DeepCopy() *MirrorService {
+	if in == nil {
+		return nil
+	}
+	out := new(MirrorService)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *PolicyOverlay) DeepCopyInto(out *PolicyOverlay) {
 	*out = *in
@@ -493,6 +587,22 @@ func (in *PolicyOverlaySpec) DeepCopy() *PolicyOverlaySpec {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *QueryParameterCondition) DeepCopyInto(out *QueryParameterCondition) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new QueryParameterCondition.
+func (in *QueryParameterCondition) DeepCopy() *QueryParameterCondition {
+	if in == nil {
+		return nil
+	}
+	out := new(QueryParameterCondition)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *RetryPolicy) DeepCopyInto(out *RetryPolicy) {
 	*out = *in
@@ -551,6 +661,21 @@ func (in *Route) DeepCopyInto(out *Route) {
 		*out = make([]RouteAttachedFilter, len(*in))
 		copy(*out, *in)
 	}
+	if in.RequestHeadersPolicy != nil {
+		in, out := &in.RequestHeadersPolicy, &out.RequestHeadersPolicy
+		*out = new(HeadersPolicy)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ResponseHeadersPolicy != nil {
+		in, out := &in.ResponseHeadersPolicy, &out.ResponseHeadersPolicy
+		*out = new(HeadersPolicy)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Mirror != nil {
+		in, out := &in.Mirror, &out.Mirror
+		*out = new(MirrorPolicy)
+		(*in).DeepCopyInto(*out)
+	}
 	return
 }
 
@@ -954,6 +1079,16 @@ func (in *VirtualHost) DeepCopyInto(out *VirtualHost) {
 		*out = make([]HostAttachedFilter, len(*in))
 		copy(*out, *in)
 	}
+	if in.RequestHeadersPolicy != nil {
+		in, out := &in.RequestHeadersPolicy, &out.RequestHeadersPolicy
+		*out = new(HeadersPolicy)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ResponseHeadersPolicy != nil {
+		in, out := &in.ResponseHeadersPolicy, &out.ResponseHeadersPolicy
+		*out = new(HeadersPolicy)
+		(*in).DeepCopyInto(*out)
+	}
 	return
 }
 
@@ -33,8 +33,14 @@ func registerBootstrap(app *kingpin.Application) (*kingpin.CmdClause, *bootstrap
 	bootstrap.Flag("admin-port", "Envoy admin interface port").IntVar(&ctx.config.AdminPort)
 	bootstrap.Flag("xds-address", "xDS gRPC API address").StringVar(&ctx.config.XDSAddress)
 	bootstrap.Flag("xds-port", "xDS gRPC API port").IntVar(&ctx.config.XDSGRPCPort)
+	bootstrap.Flag("xds-unix-socket", "xDS gRPC API Unix domain socket path, replaces --xds-address and --xds-port").StringVar(&ctx.config.XDSUnixSocket)
 	bootstrap.Flag("rl-address", "xDS gRPC API address").StringVar(&ctx.config.RLAddress)
 	bootstrap.Flag("rl-port", "xDS gRPC API port").IntVar(&ctx.config.RLPort)
+	bootstrap.Flag("ads", "Fetch all resources over a single aggregated xDS stream").BoolVar(&ctx.config.ADS)
+	bootstrap.Flag("load-reporting", "Report upstream load to enroute over LRS").BoolVar(&ctx.config.LoadReporting)
+	bootstrap.Flag("trace-collector-address", "Trace collector address").StringVar(&ctx.config.TraceCollectorAddress)
+	bootstrap.Flag("trace-collector-port", "Trace collector port").IntVar(&ctx.config.TraceCollectorPort)
+	bootstrap.Flag("trace-collector-grpc", "Reach the trace collector over gRPC, as OpenTelemetry collectors are").BoolVar(&ctx.config.TraceCollectorGRPC)
 	bootstrap.Flag("envoy-cafile", "gRPC CA Filename for Envoy to load").Envar("ENVOY_CAFILE").StringVar(&ctx.config.GrpcCABundle)
 	bootstrap.Flag("envoy-cert-file", "gRPC Client cert filename for Envoy to load").Envar("ENVOY_CERT_FILE").StringVar(&ctx.config.GrpcClientCert)
 	bootstrap.Flag("envoy-key-file", "gRPC Client key filename for Envoy to load").Envar("ENVOY_KEY_FILE").StringVar(&ctx.config.GrpcClientKey)
@@ -18,6 +18,8 @@ import (
 	"crypto/tls"
 	"crypto/x509"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io/ioutil"
 	"log"
 	"net"
@@ -26,6 +28,7 @@ import (
 	"reflect"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 	clientset "github.com/saarasio/enroute/enroute-dp/apis/generated/clientset/versioned"
@@ -90,12 +93,22 @@ func registerServe(app *kingpin.Application) (*kingpin.CmdClause, *serveContext)
 
 	serve.Flag("xds-address", "xDS gRPC API address").Default("127.0.0.1").StringVar(&ctx.xdsAddr)
 	serve.Flag("xds-port", "xDS gRPC API port").Default("8001").IntVar(&ctx.xdsPort)
+	serve.Flag("xds-unix-socket", "xDS gRPC API Unix domain socket path, replaces --xds-address and --xds-port").StringVar(&ctx.xdsUnixSocket)
+	serve.Flag("load-reporting-interval", "How often Envoy reports load over LRS").Default("10s").DurationVar(&ctx.loadReportingInterval)
+	serve.Flag("access-log-service-sink", "Write access logs Envoy streams over ALS to stdout, file or webhook, may be repeated").EnumsVar(&ctx.alsSinks, "stdout", "file", "webhook")
+	serve.Flag("access-log-service-file", "File access logs received over ALS are written to").Default("/var/log/enroute/access.log").StringVar(&ctx.alsFile)
+	serve.Flag("access-log-service-file-max-size", "Size in megabytes at which the ALS access log file is rotated").Default("100").Int64Var(&ctx.alsFileMaxSize)
+	serve.Flag("access-log-service-file-max-backups", "Number of rotated ALS access log files kept").Default("5").IntVar(&ctx.alsFileMaxBackups)
+	serve.Flag("access-log-service-webhook-url", "URL access logs received over ALS are posted to").StringVar(&ctx.alsWebhookURL)
 
 	serve.Flag("stats-address", "Envoy /stats interface address").Default("0.0.0.0").StringVar(&ctx.statsAddr)
 	serve.Flag("stats-port", "Envoy /stats interface port").Default("8002").IntVar(&ctx.statsPort)
 
 	serve.Flag("rl-address", "Rate Limit gRPC API address").Default("127.0.0.1").StringVar(&ctx.rlAddr)
 	serve.Flag("rl-port", "Rate Limit gRPC API port").Default("8003").IntVar(&ctx.rlPort)
+	serve.Flag("rl-redis-address", "Redis address (host:port) used to share rate limit counters between replicas").Envar("RL_REDIS_ADDRESS").StringVar(&ctx.rlRedisAddr)
+	serve.Flag("rl-redis-password", "Redis password used to share rate limit counters between replicas").Envar("RL_REDIS_PASSWORD").StringVar(&ctx.rlRedisPassword)
+	serve.Flag("rl-replicas", "Number of replicas dividing rate limits between them when Redis is not used").Default("1").Uint32Var(&ctx.rlReplicas)
 
 
 	serve.Flag("debug-http-address", "address the debug http endpoint will bind to").Default("127.0.0.1").StringVar(&ctx.debugAddr)
@@ -109,6 +122,8 @@ func registerServe(app *kingpin.Application) (*kingpin.CmdClause, *serveContext)
 
 	serve.Flag("ingress-class-name", "EnRoute IngressClass name").StringVar(&ctx.ingressClass)
 
+	serve.Flag("gateway-controller-name", "GatewayClass controllerName handled by EnRoute").Default(dag.DEFAULT_GATEWAY_CONTROLLER_NAME).StringVar(&ctx.gatewayControllerName)
+
 	serve.Flag("envoy-http-access-log", "Envoy HTTP access log").Default(contour.DEFAULT_HTTP_ACCESS_LOG).StringVar(&ctx.httpAccessLog)
 	serve.Flag("envoy-https-access-log", "Envoy HTTPS access log").Default(contour.DEFAULT_HTTPS_ACCESS_LOG).StringVar(&ctx.httpsAccessLog)
 	serve.Flag("envoy-service-http-address", "Kubernetes Service address for HTTP requests").Default("0.0.0.0").StringVar(&ctx.httpAddr)
@@ -139,10 +154,31 @@ type serveContext struct {
 	xdsPort                         int
 	caFile, contourCert, contourKey string
 
+	// if set, the xds service listens on this Unix domain socket
+	// rather than xdsAddr and xdsPort.
+	xdsUnixSocket string
+
+	// how often Envoy reports load to the xds service
+	loadReportingInterval time.Duration
+
+	// access logs Envoy streams to the xds service are written to
+	// alsSinks, the service is disabled if there are none.
+	alsSinks          []string
+	alsFile           string
+	alsFileMaxSize    int64
+	alsFileMaxBackups int
+	alsWebhookURL     string
+
 	// enroute's rate-limit service parameters
 	rlAddr string
 	rlPort int
 
+	// rate limit counters are kept in redis if rlRedisAddr is set,
+	// otherwise each replica enforces 1/rlReplicas of every limit.
+	rlRedisAddr     string
+	rlRedisPassword string
+	rlReplicas      uint32
+
 	aclAddr string
 	aclPort int
 
@@ -160,6 +196,9 @@ type serveContext struct {
 	// ingress class
 	ingressClass string
 
+	// GatewayClass controllerName
+	gatewayControllerName string
+
 	// envoy's stats listener parameters
 	statsAddr string
 	statsPort int
@@ -182,6 +221,33 @@ type serveContext struct {
 	aclEnabled       bool
 }
 
+// accessLogs returns the access log service writing to the sinks of the
+// context, nil if it has none.
+func (ctx *serveContext) accessLogs() (*grpc.AccessLogs, error) {
+	if len(ctx.alsSinks) == 0 {
+		return nil, nil
+	}
+	als := grpc.NewAccessLogs()
+	for _, sink := range ctx.alsSinks {
+		switch sink {
+		case "stdout":
+			als.Sinks = append(als.Sinks, grpc.NewJSONSink(os.Stdout))
+		case "file":
+			f, err := grpc.NewRotatingFileSink(ctx.alsFile, ctx.alsFileMaxSize<<20, ctx.alsFileMaxBackups)
+			if err != nil {
+				return nil, err
+			}
+			als.Sinks = append(als.Sinks, f)
+		case "webhook":
+			if ctx.alsWebhookURL == "" {
+				return nil, fmt.Errorf("--access-log-service-webhook-url is required by the webhook sink")
+			}
+			als.Sinks = append(als.Sinks, &grpc.WebhookSink{URL: ctx.alsWebhookURL})
+		}
+	}
+	return als, nil
+}
+
 // tlsconfig returns a new *tls.Config. If the context is not properly configured
 // for tls communication, tlsconfig returns nil.
 func (ctx *serveContext) tlsconfig() *tls.Config {
@@ -213,6 +279,39 @@ func (ctx *serveContext) tlsconfig() *tls.Config {
 	}
 }
 
+// xdsListener returns a listener for the xDS gRPC API. If xdsUnixSocket is
+// set the listener is a Unix domain socket, replacing a stale socket left
+// by a previous instance, otherwise it is a TCP listener, with TLS if it is
+// configured.
+func (ctx *serveContext) xdsListener(log logrus.FieldLogger) (net.Listener, error) {
+	tlsconfig := ctx.tlsconfig()
+	if ctx.xdsUnixSocket != "" {
+		if tlsconfig != nil {
+			return nil, errors.New("--xds-unix-socket cannot be used with --contour-cafile, --contour-cert-file and --contour-key-file")
+		}
+		fi, err := os.Lstat(ctx.xdsUnixSocket)
+		switch {
+		case err == nil && fi.Mode()&os.ModeSocket == 0:
+			return nil, fmt.Errorf("%s exists and is not a socket", ctx.xdsUnixSocket)
+		case err == nil:
+			if err := os.Remove(ctx.xdsUnixSocket); err != nil {
+				return nil, err
+			}
+		case !os.IsNotExist(err):
+			return nil, err
+		}
+		log.WithField("path", ctx.xdsUnixSocket).Info("Listening on Unix domain socket")
+		return net.Listen("unix", ctx.xdsUnixSocket)
+	}
+
+	addr := net.JoinHostPort(ctx.xdsAddr, strconv.Itoa(ctx.xdsPort))
+	if tlsconfig != nil {
+		log.Info("Setting up TLS for gRPC")
+		return tls.Listen("tcp", addr, tlsconfig)
+	}
+	return net.Listen("tcp", addr)
+}
+
 // gatewayHostRootNamespaces returns a slice of namespaces restricting where
 // contour should look for gatewayhost roots.
 func (ctx *serveContext) gatewayHostRootNamespaces() []string {
@@ -286,10 +385,31 @@ func doServe(log logrus.FieldLogger, ctx *serveContext) error {
 		FieldLogger:       log.WithField("context", "CacheHandler"),
 		GatewayHostStatus: &k8s.GatewayHostStatus{
 			Client: enrouteClient,
-			GatewayClient: gwClient,
 		},
 	}
 
+	if gwClient != nil {
+		ch.GatewayHostStatus.GatewayClient = gwClient
+	}
+
+	// xDS ACK/NACK tracking, rejected responses are surfaced in the
+	// status of the GatewayHosts they were generated from.
+	acks := grpc.NewAckTracker()
+	ch.Rejections = acks
+
+	// load reported by Envoy over LRS, per cluster and locality.
+	lrs := grpc.NewLoadStats()
+	lrs.Interval = ctx.loadReportingInterval
+
+	// access logs streamed by Envoy over ALS, attributed to the
+	// GatewayHosts that served them.
+	als, err := ctx.accessLogs()
+	if err != nil {
+		return err
+	}
+	if als != nil {
+		ch.AccessLogRoutes = als
+	}
 
 	// step 4. wrap the gRPC cache handler in a k8s resource event handler.
 	reh := contour.ResourceEventHandler{
@@ -299,6 +419,7 @@ func doServe(log logrus.FieldLogger, ctx *serveContext) error {
 		},
 		KubernetesCache: dag.KubernetesCache{
 			GatewayHostRootNamespaces: ctx.gatewayHostRootNamespaces(),
+			GatewayControllerName:     ctx.gatewayControllerName,
 			FieldLogger:               log.WithField("context", "KubernetesCache"),
 		},
 		IngressClass: ctx.ingressClass,
@@ -334,7 +455,7 @@ func doServe(log logrus.FieldLogger, ctx *serveContext) error {
 
 	gateway_api := true
 
-	if gateway_api {
+	if mode_ingress && gateway_api {
 		// pkg/mod/sigs.k8s.io/gateway-api@v0.5.1/apis/v1beta1/gateway_types.go
 		// https://github.com/kubernetes-sigs/gateway-api/blob/8a57d9a71583dba2e7c433bfbd04e8f4a98bf84f/pkg/client/informers/externalversions/generic.go
 		// https://pkg.go.dev/sigs.k8s.io/gateway-api@v0.5.1/apis/v1beta1
@@ -355,10 +476,15 @@ func doServe(log logrus.FieldLogger, ctx *serveContext) error {
 
 	pct := &contour.GlobalConfigTranslator{
 		FieldLogger: log.WithField("context", "proxyconfigtranslator"),
-		C:           c,
 		C2:          c2,
 	}
 
+	// rate limit configs are only consumed by the ratelimit service,
+	// sending them without a receiver would block the translator.
+	if ctx.ratelimitEnabled {
+		pct.C = c
+	}
+
 	if mode_ingress {
 		coreInformers.Core().V1().Endpoints().Informer().AddEventHandler(et)
 	}
@@ -407,6 +533,8 @@ func doServe(log logrus.FieldLogger, ctx *serveContext) error {
 			FieldLogger: log.WithField("context", "debugsvc"),
 		},
 		KubernetesCache: &reh.KubernetesCache,
+		XDSStatus:       acks,
+		LoadStats:       lrs,
 	}
 	g.Add(debugsvc.Start)
 
@@ -415,26 +543,30 @@ func doServe(log logrus.FieldLogger, ctx *serveContext) error {
 	metrics := metrics.NewMetrics(registry)
 	ch.Metrics = metrics
 	reh.Metrics = metrics
+	acks.Metrics = metrics
+	lrs.Metrics = metrics
+
+	// write the access logs Envoy streams to the sinks.
+	if als != nil {
+		als.FieldLogger = log.WithField("context", "als")
+		als.Metrics = metrics
+		g.Add(als.Start)
+	}
+
+	// rebuild the DAG to update GatewayHost status when the set of
+	// rejected responses changes.
+	acks.OnRejectionChange = func() {
+		reh.Notifier.OnChange(&reh.KubernetesCache)
+	}
+	g.Add(acks.Start)
 
 	// step 12. create grpc handler and register with workgroup.
 	g.Add(func(stop <-chan struct{}) error {
 		log := log.WithField("context", "grpc")
-		addr := net.JoinHostPort(ctx.xdsAddr, strconv.Itoa(ctx.xdsPort))
 
-		var l net.Listener
-		var err error
-		tlsconfig := ctx.tlsconfig()
-		if tlsconfig != nil {
-			log.Info("Setting up TLS for gRPC")
-			l, err = tls.Listen("tcp", addr, tlsconfig)
-			if err != nil {
-				return err
-			}
-		} else {
-			l, err = net.Listen("tcp", addr)
-			if err != nil {
-				return err
-			}
+		l, err := ctx.xdsListener(log)
+		if err != nil {
+			return err
 		}
 
 		s := grpc.NewAPI(log, map[string]grpc.Resource{
@@ -443,7 +575,7 @@ func doServe(log logrus.FieldLogger, ctx *serveContext) error {
 			ch.ListenerCache.TypeURL(): &ch.ListenerCache,
 			et.TypeURL():               et,
 			ch.SecretCache.TypeURL():   &ch.SecretCache,
-		})
+		}, acks, lrs, als)
 		log.Println("started")
 		defer log.Println("stopped")
 		return s.Serve(l)
@@ -14,8 +14,14 @@
 package main
 
 import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
 	"reflect"
 	"testing"
+
+	"github.com/sirupsen/logrus"
 )
 
 func TestServeContextGatewayHostRootNamespaces(t *testing.T) {
@@ -64,3 +70,56 @@ func TestServeContextGatewayHostRootNamespaces(t *testing.T) {
 		})
 	}
 }
+
+func TestServeContextXDSListenerUnixSocket(t *testing.T) {
+	log := logrus.New()
+	log.SetOutput(ioutil.Discard)
+
+	tests := map[string]func(t *testing.T, path string){
+		"new socket": func(t *testing.T, path string) {},
+		"stale socket": func(t *testing.T, path string) {
+			l, err := net.Listen("unix", path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// leave the socket file behind as a crashed process would.
+			l.(*net.UnixListener).SetUnlinkOnClose(false)
+			l.Close()
+		},
+	}
+
+	for name, setup := range tests {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "xds.sock")
+			setup(t, path)
+			ctx := serveContext{xdsUnixSocket: path}
+			l, err := ctx.xdsListener(log)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer l.Close()
+			if l.Addr().Network() != "unix" {
+				t.Fatalf("expected unix listener, got %q", l.Addr().Network())
+			}
+			c, err := net.Dial("unix", path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			c.Close()
+		})
+	}
+
+	t.Run("not a socket", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "xds.sock")
+		if err := os.WriteFile(path, nil, 0600); err != nil {
+			t.Fatal(err)
+		}
+		ctx := serveContext{xdsUnixSocket: path}
+		if _, err := ctx.xdsListener(log); err == nil {
+			t.Fatal("expected error")
+		}
+		if _, err := os.Stat(path); err != nil {
+			t.Fatalf("expected %s to be left in place: %v", path, err)
+		}
+	})
+}
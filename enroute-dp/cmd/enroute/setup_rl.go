@@ -2,13 +2,32 @@ package main
 
 import (
 	"crypto/tls"
+	"github.com/redis/go-redis/v9"
 	"github.com/saarasio/enroute/enroute-dp/internal/grpc"
 	"github.com/saarasio/enroute/enroute-dp/internal/workgroup"
+	"github.com/saarasio/enroute/enroute-dp/ratelim"
 	"github.com/sirupsen/logrus"
 	"net"
 	"strconv"
 )
 
+// rateLimiter returns the limiter used by the rate limit service, backed by
+// redis if an address is configured and by memory otherwise.
+func rateLimiter(log logrus.FieldLogger, ctx *serveContext) *ratelim.Limiter {
+	if ctx.rlRedisAddr != "" {
+		log.Infof("Sharing rate limit counters through redis at %s", ctx.rlRedisAddr)
+		client := redis.NewClient(&redis.Options{
+			Addr:     ctx.rlRedisAddr,
+			Password: ctx.rlRedisPassword,
+		})
+		return ratelim.NewLimiter(ratelim.NewRedisBackend(client), 1)
+	}
+	if ctx.rlReplicas > 1 {
+		log.Infof("Dividing rate limits between %d replicas", ctx.rlReplicas)
+	}
+	return ratelim.NewLimiter(nil, ctx.rlReplicas)
+}
+
 func SetupRateLimit(g *workgroup.Group, log logrus.FieldLogger, ctx *serveContext, c chan string) {
 	log.Println("SetupRateLimit():\n")
 	g.Add(func(stop <-chan struct{}) error {
@@ -31,7 +50,7 @@ func SetupRateLimit(g *workgroup.Group, log logrus.FieldLogger, ctx *serveContex
 			}
 		}
 
-		s := grpc.NewAPIRateLimit(log, c)
+		s := grpc.NewAPIRateLimit(log, c, rateLimiter(log, ctx))
 		log.Println("started")
 		defer log.Println("stopped")
 		return s.Serve(l)
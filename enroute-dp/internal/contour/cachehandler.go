@@ -20,6 +20,8 @@
 package contour
 
 import (
+	"fmt"
+	"strings"
 	"time"
 	//"os"
 
@@ -40,29 +42,128 @@ type CacheHandler struct {
 	SecretCache
 
 	GatewayHostStatus *k8s.GatewayHostStatus
+
+	// Rejections, if set, supplies the errors of xDS responses that
+	// Envoy rejected. GatewayHosts those errors refer to are marked invalid.
+	Rejections XDSRejections
+
+	// AccessLogRoutes, if set, is given the status of every GatewayHost
+	// after each rebuild to attribute access log entries to them.
+	AccessLogRoutes AccessLogRoutes
+
 	logrus.FieldLogger
 	*metrics.Metrics
 }
 
+// XDSRejections reports the errors of xDS responses rejected by Envoy.
+type XDSRejections interface {
+	RejectedErrors() []string
+}
+
+// AccessLogRoutes attributes access log entries to the routes of valid
+// GatewayHosts.
+type AccessLogRoutes interface {
+	SetRoutes([]dag.Status)
+}
+
 type statusable interface {
 	Statuses() map[dag.Meta]dag.Status
 }
 
+// statusMap is a statusable holding a fixed set of statuses.
+type statusMap map[dag.Meta]dag.Status
+
+func (sm statusMap) Statuses() map[dag.Meta]dag.Status { return sm }
+
 func (ch *CacheHandler) OnChange(kc *dag.KubernetesCache) {
 	timer := prometheus.NewTimer(ch.CacheHandlerOnUpdateSummary)
 	defer timer.ObserveDuration()
 	dag := dag.BuildDAG(kc)
 	//dw := debug.DotWriter{kc}
 	//dw.WriteDot(os.Stderr)
-	ch.setGatewayHostStatus(dag)
+	statuses := ch.applyRejections(dag)
+	ch.setGatewayHostStatus(statuses)
+	ch.setGatewayAPIStatus(dag)
+	// update the caches in the order Envoy needs them, clusters before
+	// the listeners and routes that refer to them.
 	ch.updateSecrets(dag)
+	ch.updateClusters(dag)
 	ch.updateListeners(dag)
 	ch.updateRoutes(dag)
-	ch.updateClusters(dag)
-	ch.updateGatewayHostMetric(dag)
+	ch.updateGatewayHostMetric(statuses)
+	ch.updateAccessLogRoutes(statuses)
 	ch.SetDAGLastRebuilt(time.Now())
 }
 
+// applyRejections marks valid GatewayHosts invalid if Envoy rejected
+// configuration generated from them.
+func (ch *CacheHandler) applyRejections(st statusable) statusable {
+	if ch.Rejections == nil {
+		return st
+	}
+	errs := ch.Rejections.RejectedErrors()
+	if len(errs) == 0 {
+		return st
+	}
+
+	statuses := make(statusMap)
+	for k, s := range st.Statuses() {
+		if s.Status == dag.StatusValid {
+			if msg, ok := rejectionFor(s, errs); ok {
+				s.Status = dag.StatusInvalid
+				s.Description = "rejected by Envoy: " + msg
+			}
+		}
+		statuses[k] = s
+	}
+	return statuses
+}
+
+// rejectionFor returns the first of errs that names the virtual host of
+// the GatewayHost of s, or a cluster of one of its services.
+func rejectionFor(s dag.Status, errs []string) (string, bool) {
+	var names []string
+	if s.Vhost != "" {
+		names = append(names, s.Vhost)
+	}
+	for _, r := range s.Object.Spec.Routes {
+		for _, svc := range r.Services {
+			// clusters are named namespace/service/port/hash
+			names = append(names, fmt.Sprintf("%s/%s/%d/", s.Object.Namespace, svc.Name, svc.Port))
+		}
+	}
+
+	for _, e := range errs {
+		for _, n := range names {
+			if containsName(e, n) {
+				return e, true
+			}
+		}
+	}
+	return "", false
+}
+
+// containsName reports whether name occurs in s, not as part of a longer
+// hostname. Names ending in a / are prefixes and may be followed by anything.
+func containsName(s, name string) bool {
+	for i := 0; ; {
+		j := strings.Index(s[i:], name)
+		if j < 0 {
+			return false
+		}
+		start, end := i+j, i+j+len(name)
+		if (start == 0 || !isHostnameByte(s[start-1])) &&
+			(strings.HasSuffix(name, "/") || end == len(s) || !isHostnameByte(s[end])) {
+			return true
+		}
+		i = start + 1
+	}
+}
+
+func isHostnameByte(b byte) bool {
+	return b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z' || b >= '0' && b <= '9' || b == '.' || b == '-'
+}
+
 func (ch *CacheHandler) setGatewayHostStatus(st statusable) {
 	for _, s := range st.Statuses() {
 		ch.Debugf("CacheHandler(): Setting GatewayHost Status [%v]\n", s)
@@ -73,6 +174,28 @@ func (ch *CacheHandler) setGatewayHostStatus(st statusable) {
 	}
 }
 
+func (ch *CacheHandler) setGatewayAPIStatus(d *dag.DAG) {
+	for _, s := range d.GatewayClassStatuses() {
+		if err := ch.GatewayHostStatus.SetGatewayClassStatus(s.Conditions, s.Object); err != nil {
+			ch.Errorf("Error Setting Status of GatewayClass: %v", err)
+		}
+	}
+	for _, s := range d.GatewayStatuses() {
+		if err := ch.GatewayHostStatus.SetGatewayStatus(s.Conditions, s.Listeners, s.Object); err != nil {
+			ch.Errorf("Error Setting Status of Gateway: %v", err)
+		}
+	}
+	for _, s := range d.HTTPRouteStatuses() {
+		if len(s.Parents) == 0 {
+			continue
+		}
+		controller := string(s.Parents[0].ControllerName)
+		if err := ch.GatewayHostStatus.SetHTTPRouteStatus(controller, s.Parents, s.Object); err != nil {
+			ch.Errorf("Error Setting Status of HTTPRoute: %v", err)
+		}
+	}
+}
+
 func (ch *CacheHandler) updateSecrets(root dag.Visitable) {
 	secrets := visitSecrets(root)
 	ch.SecretCache.Update(secrets)
@@ -93,6 +216,17 @@ func (ch *CacheHandler) updateClusters(root dag.Visitable) {
 	ch.ClusterCache.Update(clusters)
 }
 
+func (ch *CacheHandler) updateAccessLogRoutes(st statusable) {
+	if ch.AccessLogRoutes == nil {
+		return
+	}
+	var statuses []dag.Status
+	for _, s := range st.Statuses() {
+		statuses = append(statuses, s)
+	}
+	ch.AccessLogRoutes.SetRoutes(statuses)
+}
+
 func (ch *CacheHandler) updateGatewayHostMetric(st statusable) {
 	metrics := calculateGatewayHostMetric(st)
 	ch.Metrics.SetGatewayHostMetric(metrics)
@@ -584,3 +584,70 @@ func TestGatewayHostMetrics(t *testing.T) {
 		})
 	}
 }
+
+type staticRejections []string
+
+func (sr staticRejections) RejectedErrors() []string { return sr }
+
+func TestRejectionFor(t *testing.T) {
+	gh := &gatewayhostv1.GatewayHost{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "example"},
+		Spec: gatewayhostv1.GatewayHostSpec{
+			VirtualHost: &gatewayhostv1.VirtualHost{Fqdn: "example.com"},
+			Routes: []gatewayhostv1.Route{{
+				Services: []gatewayhostv1.Service{{Name: "home", Port: 80}},
+			}},
+		},
+	}
+	st := dag.Status{Object: gh, Status: dag.StatusValid, Vhost: "example.com"}
+
+	tests := map[string]struct {
+		errs []string
+		want bool
+	}{
+		"virtual host name": {
+			errs: []string{"Only unique values for domains are permitted. Duplicate entry of domain example.com in route ingress_http"},
+			want: true,
+		},
+		"longer virtual host name": {
+			errs: []string{"Duplicate entry of domain api.example.com in route ingress_http"},
+			want: false,
+		},
+		"cluster name": {
+			errs: []string{"Error adding/updating cluster(s) default/home/80/da39a3ee5e: bad cluster"},
+			want: true,
+		},
+		"cluster in other namespace": {
+			errs: []string{"Error adding/updating cluster(s) other/home/80/da39a3ee5e: bad cluster"},
+			want: false,
+		},
+		"unrelated": {
+			errs: []string{"Error adding/updating listener(s) ingress_https: bad listener"},
+			want: false,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			msg, got := rejectionFor(st, tc.errs)
+			if got != tc.want {
+				t.Fatalf("expected: %v, got: %v", tc.want, got)
+			}
+			if got && msg != tc.errs[0] {
+				t.Fatalf("expected message %q, got %q", tc.errs[0], msg)
+			}
+		})
+	}
+
+	ch := CacheHandler{Rejections: staticRejections{"Error adding/updating cluster(s) default/home/80/da39a3ee5e: bad cluster"}}
+	got := ch.applyRejections(statusMap{dag.Meta{}: st}).Statuses()[dag.Meta{}]
+	want := dag.Status{
+		Object:      gh,
+		Status:      dag.StatusInvalid,
+		Description: "rejected by Envoy: Error adding/updating cluster(s) default/home/80/da39a3ee5e: bad cluster",
+		Vhost:       "example.com",
+	}
+	if !reflect.DeepEqual(want, got) {
+		t.Fatalf("expected:\n%v\ngot:\n%v", want, got)
+	}
+}
@@ -31,6 +31,7 @@ import (
 type ClusterCache struct {
 	mu      sync.Mutex
 	values  map[string]*envoy_config_cluster_v3.Cluster
+	hashes  map[string]string
 	waiters []chan int
 	last    int
 }
@@ -56,11 +57,21 @@ func (c *ClusterCache) Register(ch chan int, last int) {
 }
 
 // Update replaces the contents of the cache with the supplied map.
+// Waiters are only notified if the content of a resource changed.
 func (c *ClusterCache) Update(v map[string]*envoy_config_cluster_v3.Cluster) {
+	hashes := make(map[string]string, len(v))
+	for name, cluster := range v {
+		hashes[name] = hash(cluster)
+	}
+
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
 	c.values = v
+	if !changed(c.hashes, hashes) {
+		return
+	}
+	c.hashes = hashes
 	c.notify()
 }
 
@@ -124,6 +135,14 @@ func visitClusters(root dag.Vertex) map[string]*envoy_config_cluster_v3.Cluster
 		clusters: make(map[string]*envoy_config_cluster_v3.Cluster),
 	}
 	cv.visit(root)
+
+	// the collector spans are sent to when the tracing GlobalConfig has
+	// an address.
+	if d, ok := root.(*dag.DAG); ok && d.TracingConfig() != "" {
+		if c := envoy.TracingCollectorCluster(d.TracingConfig()); c != nil {
+			cv.clusters[c.Name] = c
+		}
+	}
 	return cv.clusters
 }
 
@@ -141,6 +160,13 @@ func (v *clusterVisitor) visit(vertex dag.Vertex) {
 		}
 	}
 
+	// the clusters requests are mirrored to are not children of the route
+	if route, ok := vertex.(*dag.Route); ok && route.MirrorPolicy != nil {
+		for _, c := range route.MirrorPolicy.Clusters {
+			v.visit(c)
+		}
+	}
+
 	// recurse into children of v
 	vertex.Visit(v.visit)
 }
@@ -90,6 +90,47 @@ func TestClusterCacheContents(t *testing.T) {
 	}
 }
 
+func TestClusterCacheUpdate(t *testing.T) {
+	kuard := func(lbPolicy envoy_config_cluster_v3.Cluster_LbPolicy) *envoy_config_cluster_v3.Cluster {
+		return &envoy_config_cluster_v3.Cluster{
+			Name:     "default/kuard/443/da39a3ee5e",
+			LbPolicy: lbPolicy,
+		}
+	}
+	var cc ClusterCache
+	ch := make(chan int, 1)
+	notified := func() bool {
+		select {
+		case <-ch:
+			return true
+		default:
+			return false
+		}
+	}
+
+	cc.Update(clustermap(kuard(envoy_config_cluster_v3.Cluster_ROUND_ROBIN)))
+	cc.Register(ch, -1)
+	last := <-ch
+
+	// an update with identical content notifies no one.
+	cc.Register(ch, last)
+	cc.Update(clustermap(kuard(envoy_config_cluster_v3.Cluster_ROUND_ROBIN)))
+	if notified() {
+		t.Fatal("notified of identical update")
+	}
+
+	cc.Update(clustermap(kuard(envoy_config_cluster_v3.Cluster_RANDOM)))
+	if !notified() {
+		t.Fatal("not notified of changed cluster")
+	}
+
+	cc.Register(ch, last+1)
+	cc.Update(nil)
+	if !notified() {
+		t.Fatal("not notified of removed cluster")
+	}
+}
+
 func TestClusterCacheQuery(t *testing.T) {
 	tests := map[string]struct {
 		contents map[string]*envoy_config_cluster_v3.Cluster
@@ -235,6 +276,23 @@ func TestClusterVisit(t *testing.T) {
 					DnsLookupFamily: envoy_config_cluster_v3.Cluster_V4_ONLY,
 				}),
 		},
+		"tracing collector": {
+			objs: []interface{}{
+				&gatewayhostv1.GlobalConfig{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "tracing",
+						Namespace: "enroute-system",
+					},
+					Spec: gatewayhostv1.GlobalConfigSpec{
+						Type:   "globalconfig_tracing",
+						Config: `{"provider":"opentelemetry","address":"otel-collector.tracing"}`,
+					},
+				},
+			},
+			want: map[string]*envoy_config_cluster_v3.Cluster{
+				envoy.TracingCluster: envoy.TracingCollectorCluster(`{"provider":"opentelemetry","address":"otel-collector.tracing"}`),
+			},
+		},
 		"single named service": {
 			objs: []interface{}{
 				&v1.Ingress{
@@ -433,6 +491,76 @@ func TestClusterVisit(t *testing.T) {
 				},
 			),
 		},
+		"gatewayhost with mirror": {
+			objs: []interface{}{
+				&gatewayhostv1.GatewayHost{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "simple",
+						Namespace: "default",
+					},
+					Spec: gatewayhostv1.GatewayHostSpec{
+						VirtualHost: &gatewayhostv1.VirtualHost{
+							Fqdn: "www.example.com",
+						},
+						Routes: []gatewayhostv1.Route{{
+							Conditions: []gatewayhostv1.Condition{{
+								Prefix: "/",
+							}},
+							Services: []gatewayhostv1.Service{{
+								Name: "backend",
+								Port: 80,
+							}},
+							Mirror: &gatewayhostv1.MirrorPolicy{
+								Services: []gatewayhostv1.MirrorService{{
+									Name: "backend-v2",
+									Port: 80,
+								}},
+							},
+						}},
+					},
+				},
+				service("default", "backend", core_v1.ServicePort{
+					Name:       "http",
+					Protocol:   "TCP",
+					Port:       80,
+					TargetPort: intstr.FromInt(6502),
+				}),
+				service("default", "backend-v2", core_v1.ServicePort{
+					Name:       "http",
+					Protocol:   "TCP",
+					Port:       80,
+					TargetPort: intstr.FromInt(6502),
+				}),
+			},
+			want: clustermap(
+				&envoy_config_cluster_v3.Cluster{
+					Name:                 "default/backend/80/da39a3ee5e",
+					AltStatName:          "default_backend_80",
+					ClusterDiscoveryType: envoy.ClusterDiscoveryType(envoy_config_cluster_v3.Cluster_EDS),
+					EdsClusterConfig: &envoy_config_cluster_v3.Cluster_EdsClusterConfig{
+						EdsConfig:   envoy.ConfigSource("enroute"),
+						ServiceName: "default/backend/http",
+					},
+					ConnectTimeout:  protobuf.Duration(250 * time.Millisecond),
+					LbPolicy:        envoy_config_cluster_v3.Cluster_ROUND_ROBIN,
+					CommonLbConfig:  envoy.ClusterCommonLBConfig(),
+					DnsLookupFamily: envoy_config_cluster_v3.Cluster_V4_ONLY,
+				},
+				&envoy_config_cluster_v3.Cluster{
+					Name:                 "default/backend-v2/80/da39a3ee5e",
+					AltStatName:          "default_backend-v2_80",
+					ClusterDiscoveryType: envoy.ClusterDiscoveryType(envoy_config_cluster_v3.Cluster_EDS),
+					EdsClusterConfig: &envoy_config_cluster_v3.Cluster_EdsClusterConfig{
+						EdsConfig:   envoy.ConfigSource("enroute"),
+						ServiceName: "default/backend-v2/http",
+					},
+					ConnectTimeout:  protobuf.Duration(250 * time.Millisecond),
+					LbPolicy:        envoy_config_cluster_v3.Cluster_ROUND_ROBIN,
+					CommonLbConfig:  envoy.ClusterCommonLBConfig(),
+					DnsLookupFamily: envoy_config_cluster_v3.Cluster_V4_ONLY,
+				},
+			),
+		},
 		"gatewayhost with simple path healthcheck": {
 			objs: []interface{}{
 				&gatewayhostv1.GatewayHost{
@@ -7,11 +7,16 @@
 package contour
 
 import (
+	"sort"
+	"strings"
+	"sync"
+
 	"github.com/sirupsen/logrus"
 	// v1 "k8s.io/api/core/v1"
 	// metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	gatewayhostv1 "github.com/saarasio/enroute/enroute-dp/apis/enroute/v1"
-	_ "github.com/saarasio/enroute/enroute-dp/saarasconfig"
+	"github.com/saarasio/enroute/enroute-dp/ratelim"
+	cfg "github.com/saarasio/enroute/enroute-dp/saarasconfig"
 	k8scache "k8s.io/client-go/tools/cache"
 )
 
@@ -19,8 +24,17 @@ type GlobalConfigTranslator struct {
 	logrus.FieldLogger
 	clusterLoadAssignmentCache
 	Cond
+
+	// C receives the JSON array of all rate limit configs each time
+	// a globalconfig_ratelimit GlobalConfig changes. May be nil.
 	C  chan string
 	C2 chan string
+
+	mu               sync.Mutex
+	ratelimitConfigs map[string]string
+
+	// send, if set, sends the rate limit configs instead of C.
+	send func(configs string)
 }
 
 func (e *GlobalConfigTranslator) OnAdd(obj interface{}, isInInitialList bool) {
@@ -59,12 +73,19 @@ func (e *GlobalConfigTranslator) OnDelete(obj interface{}) {
 
 func (e *GlobalConfigTranslator) addGlobalConfig(pc *gatewayhostv1.GlobalConfig) {
 	switch pc.Spec.Type {
+	case cfg.PROXY_CONFIG_RATELIMIT:
+		e.setRateLimitConfig(pc)
 	default:
 	}
 }
 
 func (e *GlobalConfigTranslator) updateGlobalConfig(oldpc, newpc *gatewayhostv1.GlobalConfig) {
+	if oldpc.Spec.Type == cfg.PROXY_CONFIG_RATELIMIT && newpc.Spec.Type != cfg.PROXY_CONFIG_RATELIMIT {
+		e.removeRateLimitConfig(oldpc)
+	}
 	switch newpc.Spec.Type {
+	case cfg.PROXY_CONFIG_RATELIMIT:
+		e.setRateLimitConfig(newpc)
 	default:
 	}
 }
@@ -72,6 +93,70 @@ func (e *GlobalConfigTranslator) updateGlobalConfig(oldpc, newpc *gatewayhostv1.
 func (e *GlobalConfigTranslator) removeGlobalConfig(pc *gatewayhostv1.GlobalConfig) {
 
 	switch pc.Spec.Type {
+	case cfg.PROXY_CONFIG_RATELIMIT:
+		e.removeRateLimitConfig(pc)
 	default:
 	}
 }
+
+func globalConfigKey(pc *gatewayhostv1.GlobalConfig) string {
+	return pc.Namespace + "/" + pc.Name
+}
+
+func (e *GlobalConfigTranslator) setRateLimitConfig(pc *gatewayhostv1.GlobalConfig) {
+	if _, err := ratelim.UnmarshalRateLimitGlobalConfig(pc.Spec.Config); err != nil {
+		e.Errorf("GlobalConfig %s: invalid ratelimit config: %v", globalConfigKey(pc), err)
+		return
+	}
+
+	e.mu.Lock()
+	if e.ratelimitConfigs == nil {
+		e.ratelimitConfigs = make(map[string]string)
+	}
+	e.ratelimitConfigs[globalConfigKey(pc)] = pc.Spec.Config
+	configs := e.rateLimitConfigs()
+	e.mu.Unlock()
+
+	e.sendRateLimitConfigs(configs)
+}
+
+func (e *GlobalConfigTranslator) removeRateLimitConfig(pc *gatewayhostv1.GlobalConfig) {
+	e.mu.Lock()
+	if _, ok := e.ratelimitConfigs[globalConfigKey(pc)]; !ok {
+		e.mu.Unlock()
+		return
+	}
+	delete(e.ratelimitConfigs, globalConfigKey(pc))
+	configs := e.rateLimitConfigs()
+	e.mu.Unlock()
+
+	e.sendRateLimitConfigs(configs)
+}
+
+// rateLimitConfigs returns all rate limit configs, ordered by the name of
+// their GlobalConfig, as a JSON array. e.mu must be held.
+func (e *GlobalConfigTranslator) rateLimitConfigs() string {
+	keys := make([]string, 0, len(e.ratelimitConfigs))
+	for k := range e.ratelimitConfigs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	configs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		configs = append(configs, e.ratelimitConfigs[k])
+	}
+	return "[" + strings.Join(configs, ",") + "]"
+}
+
+// sendRateLimitConfigs sends configs on C. It must be called without e.mu
+// held, so that a slow receiver does not block the other handlers.
+func (e *GlobalConfigTranslator) sendRateLimitConfigs(configs string) {
+	if e.send != nil {
+		e.send(configs)
+		return
+	}
+	if e.C == nil {
+		return
+	}
+	e.C <- configs
+}
@@ -0,0 +1,106 @@
+//go:build !c && !e
+// +build !c,!e
+
+// SPDX-License-Identifier: Apache-2.0
+// Copyright(c) 2018-2023 Saaras Inc.
+
+package contour
+
+import (
+	"io/ioutil"
+	"testing"
+
+	gatewayhostv1 "github.com/saarasio/enroute/enroute-dp/apis/enroute/v1"
+	cfg "github.com/saarasio/enroute/enroute-dp/saarasconfig"
+	"github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestGlobalConfigTranslatorRateLimit(t *testing.T) {
+	log := logrus.New()
+	log.SetOutput(ioutil.Discard)
+
+	c := make(chan string, 10)
+	pct := &GlobalConfigTranslator{FieldLogger: log, C: c}
+
+	globalconfig := func(name, typ, config string) *gatewayhostv1.GlobalConfig {
+		return &gatewayhostv1.GlobalConfig{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+			Spec:       gatewayhostv1.GlobalConfigSpec{Name: name, Type: typ, Config: config},
+		}
+	}
+	expect := func(want string) {
+		t.Helper()
+		select {
+		case got := <-c:
+			if got != want {
+				t.Fatalf("expected: %s, got: %s", want, got)
+			}
+		default:
+			t.Fatalf("expected: %s, got nothing", want)
+		}
+	}
+	expectNothing := func() {
+		t.Helper()
+		select {
+		case got := <-c:
+			t.Fatalf("expected nothing, got: %s", got)
+		default:
+		}
+	}
+
+	rl1 := globalconfig("rl1", cfg.PROXY_CONFIG_RATELIMIT, `{"domain":"a"}`)
+	rl2 := globalconfig("rl2", cfg.PROXY_CONFIG_RATELIMIT, `{"domain":"b"}`)
+
+	pct.OnAdd(rl2, false)
+	expect(`[{"domain":"b"}]`)
+	pct.OnAdd(rl1, false)
+	expect(`[{"domain":"a"},{"domain":"b"}]`)
+
+	// invalid configs are ignored
+	pct.OnUpdate(rl1, globalconfig("rl1", cfg.PROXY_CONFIG_RATELIMIT, `{"domain":`))
+	expectNothing()
+
+	// other types are ignored
+	pct.OnAdd(globalconfig("other", "globalconfig_other", `{}`), false)
+	expectNothing()
+
+	pct.OnDelete(rl2)
+	expect(`[{"domain":"a"}]`)
+	pct.OnDelete(rl1)
+	expect(`[]`)
+}
+
+func TestGlobalConfigTranslatorSendsWithoutLock(t *testing.T) {
+	log := logrus.New()
+	log.SetOutput(ioutil.Discard)
+
+	// the fake sender signals it is sending and waits to be released
+	sending := make(chan string, 1)
+	release := make(chan struct{})
+	pct := &GlobalConfigTranslator{FieldLogger: log}
+	pct.send = func(configs string) {
+		sending <- configs
+		<-release
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		pct.OnAdd(&gatewayhostv1.GlobalConfig{
+			ObjectMeta: metav1.ObjectMeta{Name: "rl1", Namespace: "default"},
+			Spec:       gatewayhostv1.GlobalConfigSpec{Name: "rl1", Type: cfg.PROXY_CONFIG_RATELIMIT, Config: `{"domain":"a"}`},
+		}, false)
+	}()
+
+	if got := <-sending; got != `[{"domain":"a"}]` {
+		t.Fatalf("expected: %s, got: %s", `[{"domain":"a"}]`, got)
+	}
+	if !pct.mu.TryLock() {
+		t.Fatal("lock held while sending the rate limit configs")
+	}
+	pct.mu.Unlock()
+
+	close(release)
+	<-done
+}
@@ -0,0 +1,33 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright(c) 2018-2023 Saaras Inc.
+
+package contour
+
+import (
+	"github.com/golang/protobuf/proto"
+	"github.com/saarasio/enroute/enroute-dp/internal/protobuf"
+)
+
+// hash returns the hash of the content of m, or "" if m cannot be
+// marshaled.
+func hash(m proto.Message) string {
+	b, err := protobuf.Marshal(m)
+	if err != nil {
+		return ""
+	}
+	return protobuf.Hash(b)
+}
+
+// changed returns true if the resources hashed in next differ from those
+// in prev. A resource that could not be hashed is always a change.
+func changed(prev, next map[string]string) bool {
+	if len(prev) != len(next) {
+		return true
+	}
+	for name, h := range next {
+		if h == "" || prev[name] != h {
+			return true
+		}
+	}
+	return false
+}
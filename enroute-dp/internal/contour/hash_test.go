@@ -0,0 +1,55 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright(c) 2018-2023 Saaras Inc.
+
+package contour
+
+import "testing"
+
+func TestChanged(t *testing.T) {
+	tests := map[string]struct {
+		prev, next map[string]string
+		want       bool
+	}{
+		"both empty": {
+			want: false,
+		},
+		"equal": {
+			prev: map[string]string{"a": "1", "b": "2"},
+			next: map[string]string{"a": "1", "b": "2"},
+			want: false,
+		},
+		"added": {
+			prev: map[string]string{"a": "1"},
+			next: map[string]string{"a": "1", "b": "2"},
+			want: true,
+		},
+		"removed": {
+			prev: map[string]string{"a": "1", "b": "2"},
+			next: map[string]string{"a": "1"},
+			want: true,
+		},
+		"renamed": {
+			prev: map[string]string{"a": "1"},
+			next: map[string]string{"b": "1"},
+			want: true,
+		},
+		"modified": {
+			prev: map[string]string{"a": "1"},
+			next: map[string]string{"a": "2"},
+			want: true,
+		},
+		"not hashed": {
+			prev: map[string]string{"a": ""},
+			next: map[string]string{"a": ""},
+			want: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := changed(tc.prev, tc.next); got != tc.want {
+				t.Fatalf("expected %v, got %v", tc.want, got)
+			}
+		})
+	}
+}
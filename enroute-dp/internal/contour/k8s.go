@@ -29,6 +29,7 @@ import (
 	"github.com/sirupsen/logrus"
 	"k8s.io/api/networking/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	gwapi_v1beta1 "sigs.k8s.io/gateway-api/apis/v1beta1"
 )
 
 const DEFAULT_INGRESS_CLASS = "enroute"
@@ -83,6 +84,9 @@ func (reh *ResourceEventHandler) OnUpdate(oldObj, newObj interface{}) {
 	default:
 		if cmp.Equal(oldObj, newObj,
 			cmpopts.IgnoreFields(gatewayhostv1.GatewayHost{}, "Status"),
+			cmpopts.IgnoreFields(gwapi_v1beta1.GatewayClass{}, "Status"),
+			cmpopts.IgnoreFields(gwapi_v1beta1.Gateway{}, "Status"),
+			cmpopts.IgnoreFields(gwapi_v1beta1.HTTPRoute{}, "Status"),
 			cmpopts.IgnoreFields(metav1.ObjectMeta{}, "ResourceVersion")) {
 			reh.WithField("op", "update").Debugf("%T skipping update, only status has changed", newObj)
 			return
@@ -130,6 +130,7 @@ func (lvc *ListenerVisitorConfig) httpsAccessLog() string {
 type ListenerCache struct {
 	mu           sync.Mutex
 	values       map[string]*envoy_config_listener_v3.Listener
+	hashes       map[string]string
 	staticValues map[string]*envoy_config_listener_v3.Listener
 	waiters      []chan int
 	last         int
@@ -166,11 +167,21 @@ func (c *ListenerCache) Register(ch chan int, last int) {
 }
 
 // Update replaces the contents of the cache with the supplied map.
+// Waiters are only notified if the content of a resource changed.
 func (c *ListenerCache) Update(v map[string]*envoy_config_listener_v3.Listener) {
+	hashes := make(map[string]string, len(v))
+	for name, listener := range v {
+		hashes[name] = hash(listener)
+	}
+
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
 	c.values = v
+	if !changed(c.hashes, hashes) {
+		return
+	}
+	c.hashes = hashes
 	c.notify()
 }
 
@@ -275,6 +286,26 @@ func visitListeners(root dag.Vertex, lvc *ListenerVisitorConfig) map[string]*env
 			})
 	}
 
+	// access log GlobalConfigs replace the access logs of the listeners
+	// they apply to, a tracing GlobalConfig traces requests on all of them.
+	// Local reply GlobalConfigs map the local replies of the listeners they
+	// apply to, after the custom responses of their virtual hosts.
+	if d, ok := root.(*dag.DAG); ok {
+		for name, l := range lv.listeners {
+			if logs := envoy.ListenerAccessLogs(d.AccessLogConfigs(), name, lv.accessLog(name)); len(logs) > 0 {
+				envoy.SetAccessLogs(l, logs)
+			}
+			if lrc := envoy.ListenerLocalReply(d.LocalReplyConfigs(), name); lrc != nil {
+				envoy.SetLocalReply(l, lrc)
+			}
+			if tc := d.TracingConfig(); tc != "" {
+				if tracing := envoy.ListenerTracing(tc); tracing != nil {
+					envoy.SetTracing(l, tracing)
+				}
+			}
+		}
+	}
+
 	if logger.EL.ELogger != nil {
 		logger.EL.ELogger.Debugf("contour:visitListeners() -> setupHttpFilters()")
 	}
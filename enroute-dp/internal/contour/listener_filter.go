@@ -16,12 +16,21 @@ func (v *listenerVisitor) updateListener(name string, vh *dag.VirtualHost) {
 	}
 
 	// populateTestLuaFilter2(vh)
-	if vh.HttpFilters != nil {
-		if len(vh.HttpFilters) > 0 {
-			listener := v.listeners[name]
-			envoy.AddHttpFilterToListener(listener, vh, vh.Name)
-		}
+	if len(vh.HttpFilters) > 0 || hasHttpRouteFilters(vh) {
+		listener := v.listeners[name]
+		envoy.AddHttpFilterToListener(listener, vh, vh.Name)
+		envoy.AddVirtualHostAccessLogs(listener, vh, v.accessLog(name))
+		envoy.AddVirtualHostLocalReplies(listener, vh)
+		envoy.AddVirtualHostLocalRateLimitReplies(listener, vh)
+	}
+}
+
+// accessLog returns the access log of the listener named name.
+func (v *listenerVisitor) accessLog(name string) string {
+	if name == ENVOY_HTTPS_LISTENER {
+		return v.httpsAccessLog()
 	}
+	return v.httpAccessLog()
 }
 
 func (v *listenerVisitor) setupHttpFilters(vertex dag.Vertex) {
@@ -41,3 +50,20 @@ func (v *listenerVisitor) setupHttpFilters(vertex dag.Vertex) {
 		vertex.Visit(v.setupHttpFilters)
 	}
 }
+
+// hasHttpRouteFilters returns true if a route of vh has a Wasm, RBAC or
+// local rate limit filter, which is added to the listener of vh.
+func hasHttpRouteFilters(vh *dag.VirtualHost) bool {
+	found := false
+	vh.Visit(func(v dag.Vertex) {
+		r, ok := v.(*dag.Route)
+		if !ok {
+			return
+		}
+		if len(envoy.WasmRouteFilters(r)) > 0 || envoy.RbacRouteFilter(r) != nil ||
+			envoy.LocalRateLimitRouteFilter(r) != nil {
+			found = true
+		}
+	})
+	return found
+}
@@ -19,10 +19,16 @@ package contour
 import (
 	"testing"
 
+	envoy_config_accesslog_v3 "github.com/envoyproxy/go-control-plane/envoy/config/accesslog/v3"
 	envoy_config_core_v3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
 	envoy_config_listener_v3 "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
+	envoy_extensions_access_loggers_grpc_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/access_loggers/grpc/v3"
+	envoy_extensions_filters_network_http_connection_manager_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
 	envoy_extensions_transport_sockets_tls_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/transport_sockets/tls/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/wellknown"
 	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/golang/protobuf/ptypes/any"
 	"github.com/google/go-cmp/cmp"
 	"github.com/prometheus/client_golang/prometheus"
 	gatewayhostv1 "github.com/saarasio/enroute/enroute-dp/apis/enroute/v1"
@@ -176,6 +182,189 @@ func TestListenerVisit(t *testing.T) {
 				FilterChains: envoy.FilterChains(envoy.HTTPConnectionManager(ENVOY_HTTP_LISTENER, DEFAULT_HTTP_ACCESS_LOG, nil)),
 			}),
 		},
+		"tracing globalconfig": {
+			objs: []interface{}{
+				&netv1.Ingress{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "kuard",
+						Namespace: "default",
+					},
+					Spec: netv1.IngressSpec{
+						DefaultBackend: &netv1.IngressBackend{
+							Service: &netv1.IngressServiceBackend{
+								Name: "kuard",
+								Port: netv1.ServiceBackendPort{
+									Number: 8080,
+								},
+							},
+						},
+					},
+				},
+				&corev1.Service{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "kuard",
+						Namespace: "default",
+					},
+					Spec: corev1.ServiceSpec{
+						Ports: []corev1.ServicePort{{
+							Name:     "http",
+							Protocol: "TCP",
+							Port:     8080,
+						}},
+					},
+				},
+				&gatewayhostv1.GlobalConfig{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "tracing",
+						Namespace: "enroute-system",
+					},
+					Spec: gatewayhostv1.GlobalConfigSpec{
+						Type:   "globalconfig_tracing",
+						Config: `{"provider":"zipkin","address":"zipkin.tracing","random_sampling":25}`,
+					},
+				},
+			},
+			want: listenermap(&envoy_config_listener_v3.Listener{
+				Name:    ENVOY_HTTP_LISTENER,
+				Address: envoy.SocketAddress("0.0.0.0", 8080),
+				FilterChains: envoy.FilterChains(withTracing(
+					envoy.HTTPConnectionManager(ENVOY_HTTP_LISTENER, DEFAULT_HTTP_ACCESS_LOG, nil),
+					envoy.ListenerTracing(`{"provider":"zipkin","address":"zipkin.tracing","random_sampling":25}`),
+				)),
+			}),
+		},
+		"local reply globalconfig": {
+			objs: []interface{}{
+				&netv1.Ingress{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "kuard",
+						Namespace: "default",
+					},
+					Spec: netv1.IngressSpec{
+						DefaultBackend: &netv1.IngressBackend{
+							Service: &netv1.IngressServiceBackend{
+								Name: "kuard",
+								Port: netv1.ServiceBackendPort{
+									Number: 8080,
+								},
+							},
+						},
+					},
+				},
+				&corev1.Service{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "kuard",
+						Namespace: "default",
+					},
+					Spec: corev1.ServiceSpec{
+						Ports: []corev1.ServicePort{{
+							Name:     "http",
+							Protocol: "TCP",
+							Port:     8080,
+						}},
+					},
+				},
+				&gatewayhostv1.GlobalConfig{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "errors",
+						Namespace: "enroute-system",
+					},
+					Spec: gatewayhostv1.GlobalConfigSpec{
+						Type:   "globalconfig_localreply",
+						Config: `{"default_response_body":{"text_format":"<h1>%RESPONSE_CODE%</h1>","content_type":"text/html"}}`,
+					},
+				},
+			},
+			want: listenermap(&envoy_config_listener_v3.Listener{
+				Name:    ENVOY_HTTP_LISTENER,
+				Address: envoy.SocketAddress("0.0.0.0", 8080),
+				FilterChains: envoy.FilterChains(withLocalReply(
+					envoy.HTTPConnectionManager(ENVOY_HTTP_LISTENER, DEFAULT_HTTP_ACCESS_LOG, nil),
+					envoy.ListenerLocalReply([]string{`{"default_response_body":{"text_format":"<h1>%RESPONSE_CODE%</h1>","content_type":"text/html"}}`}, ENVOY_HTTP_LISTENER),
+				)),
+			}),
+		},
+		"access log globalconfig": {
+			objs: []interface{}{
+				&netv1.Ingress{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "kuard",
+						Namespace: "default",
+					},
+					Spec: netv1.IngressSpec{
+						DefaultBackend: &netv1.IngressBackend{
+							Service: &netv1.IngressServiceBackend{
+								Name: "kuard",
+								Port: netv1.ServiceBackendPort{
+									Number: 8080,
+								},
+							},
+						},
+					},
+				},
+				&corev1.Service{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "kuard",
+						Namespace: "default",
+					},
+					Spec: corev1.ServiceSpec{
+						Ports: []corev1.ServicePort{{
+							Name:     "http",
+							Protocol: "TCP",
+							Port:     8080,
+						}},
+					},
+				},
+				&gatewayhostv1.GlobalConfig{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "accesslog",
+						Namespace: "enroute-system",
+					},
+					Spec: gatewayhostv1.GlobalConfigSpec{
+						Type:   "globalconfig_accesslog",
+						Config: `{"listener":"ingress_http","sink":"grpc","filter":{"status_code":{"op":"GE","value":400}}}`,
+					},
+				},
+			},
+			want: listenermap(&envoy_config_listener_v3.Listener{
+				Name:    ENVOY_HTTP_LISTENER,
+				Address: envoy.SocketAddress("0.0.0.0", 8080),
+				FilterChains: envoy.FilterChains(withAccessLog(
+					envoy.HTTPConnectionManager(ENVOY_HTTP_LISTENER, DEFAULT_HTTP_ACCESS_LOG, nil),
+					&envoy_config_accesslog_v3.AccessLog{
+						Name: wellknown.HTTPGRPCAccessLog,
+						Filter: &envoy_config_accesslog_v3.AccessLogFilter{
+							FilterSpecifier: &envoy_config_accesslog_v3.AccessLogFilter_StatusCodeFilter{
+								StatusCodeFilter: &envoy_config_accesslog_v3.StatusCodeFilter{
+									Comparison: &envoy_config_accesslog_v3.ComparisonFilter{
+										Op: envoy_config_accesslog_v3.ComparisonFilter_GE,
+										Value: &envoy_config_core_v3.RuntimeUInt32{
+											DefaultValue: 400,
+											RuntimeKey:   "enroute.access_log.status_code",
+										},
+									},
+								},
+							},
+						},
+						ConfigType: &envoy_config_accesslog_v3.AccessLog_TypedConfig{
+							TypedConfig: toAny(&envoy_extensions_access_loggers_grpc_v3.HttpGrpcAccessLogConfig{
+								CommonConfig: &envoy_extensions_access_loggers_grpc_v3.CommonGrpcAccessLogConfig{
+									LogName: "enroute",
+									GrpcService: &envoy_config_core_v3.GrpcService{
+										TargetSpecifier: &envoy_config_core_v3.GrpcService_EnvoyGrpc_{
+											EnvoyGrpc: &envoy_config_core_v3.GrpcService_EnvoyGrpc{
+												ClusterName: "enroute",
+											},
+										},
+									},
+									TransportApiVersion: envoy_config_core_v3.ApiVersion_V3,
+								},
+							}),
+						},
+					},
+				)),
+			}),
+		},
 		"one http only gatewayhost": {
 			objs: []interface{}{
 				&gatewayhostv1.GatewayHost{
@@ -789,6 +978,52 @@ func TestListenerVisit(t *testing.T) {
 	}
 }
 
+// withAccessLog replaces the access logs of the HTTP connection manager
+// filter hcm with logs.
+func withAccessLog(hcm *envoy_config_listener_v3.Filter, logs ...*envoy_config_accesslog_v3.AccessLog) *envoy_config_listener_v3.Filter {
+	config := &envoy_extensions_filters_network_http_connection_manager_v3.HttpConnectionManager{}
+	if err := ptypes.UnmarshalAny(hcm.GetTypedConfig(), config); err != nil {
+		panic(err)
+	}
+	config.AccessLog = logs
+	hcm.ConfigType = &envoy_config_listener_v3.Filter_TypedConfig{
+		TypedConfig: toAny(config),
+	}
+	return hcm
+}
+
+func withTracing(hcm *envoy_config_listener_v3.Filter, tracing *envoy_extensions_filters_network_http_connection_manager_v3.HttpConnectionManager_Tracing) *envoy_config_listener_v3.Filter {
+	config := &envoy_extensions_filters_network_http_connection_manager_v3.HttpConnectionManager{}
+	if err := ptypes.UnmarshalAny(hcm.GetTypedConfig(), config); err != nil {
+		panic(err)
+	}
+	config.Tracing = tracing
+	hcm.ConfigType = &envoy_config_listener_v3.Filter_TypedConfig{
+		TypedConfig: toAny(config),
+	}
+	return hcm
+}
+
+func withLocalReply(hcm *envoy_config_listener_v3.Filter, lrc *envoy_extensions_filters_network_http_connection_manager_v3.LocalReplyConfig) *envoy_config_listener_v3.Filter {
+	config := &envoy_extensions_filters_network_http_connection_manager_v3.HttpConnectionManager{}
+	if err := ptypes.UnmarshalAny(hcm.GetTypedConfig(), config); err != nil {
+		panic(err)
+	}
+	config.LocalReplyConfig = lrc
+	hcm.ConfigType = &envoy_config_listener_v3.Filter_TypedConfig{
+		TypedConfig: toAny(config),
+	}
+	return hcm
+}
+
+func toAny(pb proto.Message) *any.Any {
+	a, err := ptypes.MarshalAny(pb)
+	if err != nil {
+		panic(err)
+	}
+	return a
+}
+
 func transportSocket(tlsMinProtoVersion envoy_extensions_transport_sockets_tls_v3.TlsParameters_TlsProtocol, alpnprotos ...string) *envoy_config_core_v3.TransportSocket {
 	return envoy.DownstreamTLSTransportSocket(
 		envoy.DownstreamTLSContext("default/secret/735ad571c1", tlsMinProtoVersion, alpnprotos...),
@@ -25,12 +25,14 @@ import (
 	"github.com/golang/protobuf/proto"
 	"github.com/saarasio/enroute/enroute-dp/internal/dag"
 	"github.com/saarasio/enroute/enroute-dp/internal/envoy"
+	cfg "github.com/saarasio/enroute/enroute-dp/saarasconfig"
 )
 
 // RouteCache manages the contents of the gRPC RDS cache.
 type RouteCache struct {
 	mu      sync.Mutex
 	values  map[string]*envoy_config_route_v3.RouteConfiguration
+	hashes  map[string]string
 	waiters []chan int
 	last    int
 }
@@ -56,11 +58,21 @@ func (c *RouteCache) Register(ch chan int, last int) {
 }
 
 // Update replaces the contents of the cache with the supplied map.
+// Waiters are only notified if the content of a resource changed.
 func (c *RouteCache) Update(v map[string]*envoy_config_route_v3.RouteConfiguration) {
+	hashes := make(map[string]string, len(v))
+	for name, route := range v {
+		hashes[name] = hash(route)
+	}
+
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
 	c.values = v
+	if !changed(c.hashes, hashes) {
+		return
+	}
+	c.hashes = hashes
 	c.notify()
 }
 
@@ -122,6 +134,16 @@ func (*RouteCache) TypeURL() string { return resource.RouteType }
 
 type routeVisitor struct {
 	routes map[string]*envoy_config_route_v3.RouteConfiguration
+
+	// wasmFilters holds the names of the Wasm filters attached to
+	// virtual hosts or routes, which other routes sharing their listener
+	// disable.
+	wasmFilters []string
+
+	// extAuthzFilters holds the names of the external authorization
+	// filters of virtual hosts, which the routes of other virtual hosts
+	// sharing their listener disable.
+	extAuthzFilters []string
 }
 
 func visitRoutes(root dag.Vertex) map[string]*envoy_config_route_v3.RouteConfiguration {
@@ -134,6 +156,8 @@ func visitRoutes(root dag.Vertex) map[string]*envoy_config_route_v3.RouteConfigu
 				Name: "ingress_https",
 			},
 		},
+		wasmFilters:     wasmFilters(root),
+		extAuthzFilters: extAuthzFilters(root),
 	}
 	rv.visit(root)
 	for _, v := range rv.routes {
@@ -147,7 +171,79 @@ func SetupFilters(vh *dag.VirtualHost, vhost *envoy_config_route_v3.VirtualHost,
 	envoy.SetupEnvoyFilters(vh, vhost, isVh, r)
 }
 
-func envoyRouteFromDagRoute(vh *dag.VirtualHost, vhost *envoy_config_route_v3.VirtualHost, isVh bool, r *dag.Route) {
+// wasmFilters returns the names of the Wasm filters attached to the
+// virtual hosts and routes of root.
+func wasmFilters(root dag.Vertex) []string {
+	seen := make(map[string]bool)
+	var names []string
+	add := func(name string) {
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	var visit func(dag.Vertex)
+	visit = func(v dag.Vertex) {
+		switch v := v.(type) {
+		case *dag.Route:
+			for _, rf := range envoy.WasmRouteFilters(v) {
+				add(envoy.WasmFilterName(rf.Filter_namespace, rf.Filter_name))
+			}
+			return
+		case *dag.VirtualHost:
+			addVirtualHostWasmFilters(v, add)
+		case *dag.SecureVirtualHost:
+			addVirtualHostWasmFilters(&v.VirtualHost, add)
+		}
+		v.Visit(visit)
+	}
+	visit(root)
+	sort.Strings(names)
+	return names
+}
+
+func addVirtualHostWasmFilters(vh *dag.VirtualHost, add func(string)) {
+	for _, hf := range vh.HttpFilters {
+		if hf.Filter_type == cfg.FILTER_TYPE_HTTP_WASM {
+			add(envoy.WasmFilterName(hf.Filter_namespace, hf.Filter_name))
+		}
+	}
+}
+
+// extAuthzFilters returns the names of the external authorization filters
+// of the virtual hosts of root.
+func extAuthzFilters(root dag.Vertex) []string {
+	seen := make(map[string]bool)
+	var names []string
+	var visit func(dag.Vertex)
+	visit = func(v dag.Vertex) {
+		var vh *dag.VirtualHost
+		switch v := v.(type) {
+		case *dag.VirtualHost:
+			vh = v
+		case *dag.SecureVirtualHost:
+			vh = &v.VirtualHost
+		default:
+			v.Visit(visit)
+			return
+		}
+		for _, hf := range vh.HttpFilters {
+			if hf.Filter_type != cfg.FILTER_TYPE_HTTP_EXTAUTHZ {
+				continue
+			}
+			name := envoy.ExtAuthzFilterName(hf.Filter_namespace, hf.Filter_name)
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	visit(root)
+	sort.Strings(names)
+	return names
+}
+
+func envoyRouteFromDagRoute(vh *dag.VirtualHost, vhost *envoy_config_route_v3.VirtualHost, isVh bool, r *dag.Route, wasmFilters, extAuthzFilters []string) {
 	if len(r.Clusters) < 1 {
 		// no services for this route, skip it.
 		return
@@ -168,20 +264,31 @@ func envoyRouteFromDagRoute(vh *dag.VirtualHost, vhost *envoy_config_route_v3.Vi
 	}
 
 	envoy.SetupRouteRedirects(r, rr)
+	envoy.SetupRouteJwt(vh, r, rr)
+	envoy.SetupRouteExtAuthz(vh, r, rr, extAuthzFilters)
+	envoy.SetupRouteWasm(vh, r, rr, wasmFilters)
+	envoy.SetupRouteRbac(r, rr)
+	envoy.SetupRouteTracing(r, rr)
+	envoy.SetupRouteLocalRateLimit(r, rr)
+	envoy.SetupRouteHeaders(r, rr)
 
 	vhost.Routes = append(vhost.Routes, rr)
 }
 
 func (v *routeVisitor) visit(vertex dag.Vertex) {
+	wasmFilters, extAuthzFilters := v.wasmFilters, v.extAuthzFilters
 	switch l := vertex.(type) {
 	case *dag.Listener:
 		l.Visit(func(vertex dag.Vertex) {
 			switch vh := vertex.(type) {
 			case *dag.VirtualHost:
 				vhost := envoy.VirtualHost(vh.Name)
+				envoy.SetupVirtualHostRbac(vh, vhost)
+				envoy.SetupVirtualHostLocalRateLimit(vh, vhost)
+				envoy.SetupVirtualHostHeaders(vh, vhost)
 				vh.Visit(func(v dag.Vertex) {
 					if r, ok := v.(*dag.Route); ok {
-						envoyRouteFromDagRoute(vh, vhost, true, r)
+						envoyRouteFromDagRoute(vh, vhost, true, r, wasmFilters, extAuthzFilters)
 					}
 				})
 				if len(vhost.Routes) < 1 {
@@ -191,9 +298,12 @@ func (v *routeVisitor) visit(vertex dag.Vertex) {
 				v.routes["ingress_http"].VirtualHosts = append(v.routes["ingress_http"].VirtualHosts, vhost)
 			case *dag.SecureVirtualHost:
 				vhost := envoy.VirtualHost(vh.VirtualHost.Name)
+				envoy.SetupVirtualHostRbac(&vh.VirtualHost, vhost)
+				envoy.SetupVirtualHostLocalRateLimit(&vh.VirtualHost, vhost)
+				envoy.SetupVirtualHostHeaders(&vh.VirtualHost, vhost)
 				vh.Visit(func(v dag.Vertex) {
 					if r, ok := v.(*dag.Route); ok {
-						envoyRouteFromDagRoute(&vh.VirtualHost, vhost, false, r)
+						envoyRouteFromDagRoute(&vh.VirtualHost, vhost, false, r, wasmFilters, extAuthzFilters)
 					}
 				})
 				if len(vhost.Routes) < 1 {
@@ -218,22 +328,47 @@ func (v virtualHostsByName) Len() int           { return len(v) }
 func (v virtualHostsByName) Swap(i, j int)      { v[i], v[j] = v[j], v[i] }
 func (v virtualHostsByName) Less(i, j int) bool { return v[i].Name < v[j].Name }
 
+// longestRouteFirst sorts routes in reverse: exact path matches come
+// first, then regex matches, longest first, then prefix matches, longest
+// first. Routes with the same path come with the most header and query
+// parameter matches first.
 type longestRouteFirst []*envoy_config_route_v3.Route
 
 func (l longestRouteFirst) Len() int      { return len(l) }
 func (l longestRouteFirst) Swap(i, j int) { l[i], l[j] = l[j], l[i] }
 func (l longestRouteFirst) Less(i, j int) bool {
-	a, ok := l[i].Match.PathSpecifier.(*envoy_config_route_v3.RouteMatch_Prefix)
-	if !ok {
-		// ignore non prefix matches
-		return false
+	a, b := l[i].Match, l[j].Match
+
+	ra, pa := pathMatchRank(a)
+	rb, pb := pathMatchRank(b)
+	if ra != rb {
+		return ra < rb
 	}
 
-	b, ok := l[j].Match.PathSpecifier.(*envoy_config_route_v3.RouteMatch_Prefix)
-	if !ok {
-		// ignore non prefix matches
-		return false
+	if pa != pb {
+		if ra == regexMatchRank && len(pa) != len(pb) {
+			return len(pa) < len(pb)
+		}
+		return pa < pb
 	}
 
-	return a.Prefix < b.Prefix
+	return len(a.GetHeaders())+len(a.GetQueryParameters()) < len(b.GetHeaders())+len(b.GetQueryParameters())
+}
+
+const (
+	prefixMatchRank = iota
+	regexMatchRank
+	exactMatchRank
+)
+
+// pathMatchRank returns the rank of the kind of path match of m and its path.
+func pathMatchRank(m *envoy_config_route_v3.RouteMatch) (int, string) {
+	switch p := m.GetPathSpecifier().(type) {
+	case *envoy_config_route_v3.RouteMatch_Path:
+		return exactMatchRank, p.Path
+	case *envoy_config_route_v3.RouteMatch_SafeRegex:
+		return regexMatchRank, p.SafeRegex.GetRegex()
+	default:
+		return prefixMatchRank, m.GetPrefix()
+	}
 }
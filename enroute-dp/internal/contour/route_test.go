@@ -17,6 +17,7 @@
 package contour
 
 import (
+	"sort"
 	"testing"
 	"time"
 
@@ -30,6 +31,7 @@ import (
 	"github.com/saarasio/enroute/enroute-dp/internal/envoy"
 	"github.com/saarasio/enroute/enroute-dp/internal/metrics"
 	"github.com/saarasio/enroute/enroute-dp/internal/protobuf"
+	cfg "github.com/saarasio/enroute/enroute-dp/saarasconfig"
 	corev1 "k8s.io/api/core/v1"
 	netv1 "k8s.io/api/networking/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -1811,3 +1813,74 @@ func weightedCluster(name string, weight uint32) *envoy_config_route_v3.Weighted
 		Weight: protobuf.UInt32(weight),
 	}
 }
+
+func TestWasmFilters(t *testing.T) {
+	wasm := func(namespace, name string) dag.Filter {
+		return dag.Filter{Filter_name: name, Filter_type: cfg.FILTER_TYPE_HTTP_WASM, Filter_namespace: namespace}
+	}
+	root := &dag.Listener{
+		Port: 80,
+		VirtualHosts: map[string]dag.Vertex{
+			"www.example.com": &dag.VirtualHost{
+				Name:        "www.example.com",
+				HttpFilters: []*dag.HttpFilter{{Filter: wasm("default", "vh")}},
+				Routes: map[string]*dag.Route{
+					"/": {
+						PathCondition: &dag.PrefixCondition{Prefix: "/"},
+						RouteFilters:  []*dag.RouteFilter{{Filter: wasm("default", "route")}},
+					},
+				},
+			},
+			"secure.example.com": &dag.SecureVirtualHost{
+				VirtualHost: dag.VirtualHost{
+					Name:        "secure.example.com",
+					HttpFilters: []*dag.HttpFilter{{Filter: wasm("default", "secure")}, {Filter: wasm("default", "vh")}, {Filter: wasm("other", "vh")}},
+				},
+			},
+		},
+	}
+
+	assert.Equal(t, []string{"envoy.wasm.default/route", "envoy.wasm.default/secure", "envoy.wasm.default/vh", "envoy.wasm.other/vh"}, wasmFilters(root))
+}
+
+func TestLongestRouteFirst(t *testing.T) {
+	route := func(match *envoy_config_route_v3.RouteMatch) *envoy_config_route_v3.Route {
+		return &envoy_config_route_v3.Route{Match: match}
+	}
+	withMethod := func(match *envoy_config_route_v3.RouteMatch) *envoy_config_route_v3.RouteMatch {
+		match.Headers = []*envoy_config_route_v3.HeaderMatcher{{
+			Name:                 ":method",
+			HeaderMatchSpecifier: &envoy_config_route_v3.HeaderMatcher_ExactMatch{ExactMatch: "GET"},
+		}}
+		return match
+	}
+	exact := func(path string) *envoy_config_route_v3.RouteMatch {
+		return &envoy_config_route_v3.RouteMatch{
+			PathSpecifier: &envoy_config_route_v3.RouteMatch_Path{Path: path},
+		}
+	}
+
+	got := []*envoy_config_route_v3.Route{
+		route(envoy.RouteMatch("/")),
+		route(envoy.RouteMatch("/api/v[0-9]+")),
+		route(envoy.RouteMatch("/api")),
+		route(exact("/api/healthz")),
+		route(envoy.RouteMatch("/api/v[0-9]+/users/.*")),
+		route(withMethod(envoy.RouteMatch("/api"))),
+		route(exact("/healthz")),
+		route(envoy.RouteMatch("/api/v1")),
+	}
+	sort.Stable(sort.Reverse(longestRouteFirst(got)))
+
+	want := []*envoy_config_route_v3.Route{
+		route(exact("/healthz")),
+		route(exact("/api/healthz")),
+		route(envoy.RouteMatch("/api/v[0-9]+/users/.*")),
+		route(envoy.RouteMatch("/api/v[0-9]+")),
+		route(envoy.RouteMatch("/api/v1")),
+		route(withMethod(envoy.RouteMatch("/api"))),
+		route(envoy.RouteMatch("/api")),
+		route(envoy.RouteMatch("/")),
+	}
+	assert.Equal(t, want, got)
+}
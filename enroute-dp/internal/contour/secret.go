@@ -31,6 +31,7 @@ import (
 type SecretCache struct {
 	mu      sync.Mutex
 	values  map[string]*envoy_extensions_transport_sockets_tls_v3.Secret
+	hashes  map[string]string
 	waiters []chan int
 	last    int
 }
@@ -56,11 +57,21 @@ func (c *SecretCache) Register(ch chan int, last int) {
 }
 
 // Update replaces the contents of the cache with the supplied map.
+// Waiters are only notified if the content of a resource changed.
 func (c *SecretCache) Update(v map[string]*envoy_extensions_transport_sockets_tls_v3.Secret) {
+	hashes := make(map[string]string, len(v))
+	for name, secret := range v {
+		hashes[name] = hash(secret)
+	}
+
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
 	c.values = v
+	if !changed(c.hashes, hashes) {
+		return
+	}
+	c.hashes = hashes
 	c.notify()
 }
 
@@ -60,6 +60,10 @@ func (b *builder) reset() {
 	b.httpfilters = make(map[HttpFilterMeta]*HttpFilter, len(b.httpfilters))
 
 	b.statuses = make(map[Meta]Status, len(b.statuses))
+
+	b.gatewayClassStatuses = make(map[string]*GatewayClassStatus, len(b.gatewayClassStatuses))
+	b.gatewayStatuses = make(map[Meta]*GatewayStatus, len(b.gatewayStatuses))
+	b.httpRouteStatuses = make(map[Meta]*HTTPRouteStatus, len(b.httpRouteStatuses))
 }
 
 // A builder holds the state of one invocation of Builder.Build.
@@ -78,6 +82,11 @@ type builder struct {
 
 	statuses map[Meta]Status
 	log      logrus.FieldLogger
+
+	// Gateway API status computed while building the DAG.
+	gatewayClassStatuses map[string]*GatewayClassStatus
+	gatewayStatuses      map[Meta]*GatewayStatus
+	httpRouteStatuses    map[Meta]*HTTPRouteStatus
 }
 
 func (b *builder) debugPrintServices(m Meta, port net_v1.ServiceBackendPort) {
@@ -311,6 +320,8 @@ func (b *builder) compute() *DAG {
 
 	b.computeGatewayHosts()
 
+	b.computeGateways()
+
 	return b.DAG()
 }
 
@@ -545,6 +556,14 @@ func (b *builder) computeGatewayHosts() {
 			continue
 		}
 
+		reqHP, respHP, err := virtualHostHeadersPolicies(ir)
+		if err != nil {
+			b.setStatus(Status{Object: ir, Status: StatusInvalid,
+				Description: err.Error(),
+				Vhost:       host})
+			continue
+		}
+
 		var enforceTLS, passthrough bool
 		if tls := ir.Spec.VirtualHost.TLS; tls != nil {
 			// attach secrets to TLS enabled vhosts
@@ -557,6 +576,8 @@ func (b *builder) computeGatewayHosts() {
 				svhost.MinProtoVersion = minProtoVersion(ir.Spec.VirtualHost.TLS.MinimumProtocolVersion)
 				enforceTLS = true
 				b.SetupHttpFilters(&svhost.VirtualHost, ir)
+				svhost.RequestHeadersPolicy = reqHP
+				svhost.ResponseHeadersPolicy = respHP
 			}
 			// passthrough is true if tls.secretName is not present, and
 			// tls.passthrough is set to true.
@@ -576,11 +597,27 @@ func (b *builder) computeGatewayHosts() {
 		case ir.Spec.Routes != nil:
 			vh := b.lookupVirtualHost(host)
 			b.SetupHttpFilters(vh, ir)
+			vh.RequestHeadersPolicy = reqHP
+			vh.ResponseHeadersPolicy = respHP
 			b.processRoutes(ir, nil, host, enforceTLS)
 		}
 	}
 }
 
+// virtualHostHeadersPolicies returns the request and response headers
+// policies of the virtual host of ir.
+func virtualHostHeadersPolicies(ir *gatewayhostv1.GatewayHost) (*HeadersPolicy, *HeadersPolicy, error) {
+	reqHP, err := headersPolicy(ir.Spec.VirtualHost.RequestHeadersPolicy, true)
+	if err != nil {
+		return nil, nil, fmt.Errorf("virtualhost request headers policy: %s", err)
+	}
+	respHP, err := headersPolicy(ir.Spec.VirtualHost.ResponseHeadersPolicy, false)
+	if err != nil {
+		return nil, nil, fmt.Errorf("virtualhost response headers policy: %s", err)
+	}
+	return reqHP, respHP, nil
+}
+
 func (b *builder) secureVirtualhostExists(host string) bool {
 	_, ok := b.listener(443).VirtualHosts[host]
 	return ok
@@ -647,6 +684,12 @@ func (b *builder) DAG() *DAG {
 		}
 	}
 	dag.statuses = b.statuses
+	dag.gatewayClassStatuses = b.gatewayClassStatuses
+	dag.gatewayStatuses = b.gatewayStatuses
+	dag.httpRouteStatuses = b.httpRouteStatuses
+	dag.accessLogConfigs = b.accessLogConfigs()
+	dag.tracingConfig = b.tracingConfig()
+	dag.localReplyConfigs = b.localReplyConfigs()
 
 	return &dag
 }
@@ -729,14 +772,26 @@ func (b *builder) processOneRoute(ir *gatewayhostv1.GatewayHost, route gatewayho
 		return fmt.Errorf("%s", errMesg)
 	}
 
-	// Look for duplicate exact match headers on this route
+	queryParamConditionValid, errMesg := queryParamConditionsValid(route.Conditions, "route")
+
+	if !queryParamConditionValid {
+		if ir != nil && ir.Spec.VirtualHost != nil && logger.EL.ELogger != nil {
+			logger.EL.ELogger.Debugf(
+				"dag:builder:processServiceRoute() Query Parameter Condition Invalid: Host [%s] Err [%s] Conditions [%+v]",
+				host, errMesg, route.Conditions)
+		}
+		return fmt.Errorf("%s", errMesg)
+	}
+
+	// Look for invalid or contradictory header conditions on this route
 	if !headerConditionsAreValid(route.Conditions) {
 		if ir != nil && ir.Spec.VirtualHost != nil && logger.EL.ELogger != nil {
 			logger.EL.ELogger.Debugf(
-				"dag:builder:processRoutes() cannot specify duplicate header 'exact match' conditions in the same route - GatewayHost [%s] ", ir.Spec.VirtualHost.Fqdn)
+				"dag:builder:processRoutes() cannot specify invalid or contradictory header conditions in the same route - GatewayHost [%s] ", ir.Spec.VirtualHost.Fqdn)
 		}
 		b.setStatus(Status{Object: ir, Status: StatusInvalid,
-			Description: "cannot specify duplicate header 'exact match' conditions in the same route", Vhost: host})
+			Description: "cannot specify invalid or contradictory header conditions in the same route", Vhost: host})
+		return fmt.Errorf("cannot specify invalid or contradictory header conditions in the same route")
 	}
 
 	// Route has a delegate, check doesn't have a service
@@ -746,16 +801,32 @@ func (b *builder) processOneRoute(ir *gatewayhostv1.GatewayHost, route gatewayho
 		return fmt.Errorf("cannot specify services and delegate in the same route")
 	}
 
+	reqHP, err := headersPolicy(route.RequestHeadersPolicy, true)
+	if err != nil {
+		b.setStatus(Status{Object: ir, Status: StatusInvalid,
+			Description: fmt.Sprintf("route request headers policy: %s", err), Vhost: host})
+		return fmt.Errorf("route request headers policy: %s", err)
+	}
+	respHP, err := headersPolicy(route.ResponseHeadersPolicy, false)
+	if err != nil {
+		b.setStatus(Status{Object: ir, Status: StatusInvalid,
+			Description: fmt.Sprintf("route response headers policy: %s", err), Vhost: host})
+		return fmt.Errorf("route response headers policy: %s", err)
+	}
+
 	if len(route.Services) > 0 {
 		r := &Route{
-			PathCondition:    mergePathConditions(route.Conditions),
-			HeaderConditions: MergeHeaderConditions(route.Conditions),
-			Websocket:        route.EnableWebsockets,
-			HTTPSUpgrade:     routeEnforceTLS(enforceTLS, route.PermitInsecure),
-			PrefixRewrite:    route.PrefixRewrite,
-			TimeoutPolicy:    timeoutPolicy(route.TimeoutPolicy),
-			RetryPolicy:      retryPolicy(route.RetryPolicy),
-			DisableExtAuthz:  route.DisableExtAuthz,
+			PathCondition:         MergePathConditions(route.Conditions),
+			HeaderConditions:      MergeHeaderConditions(route.Conditions),
+			QueryParamConditions:  MergeQueryParamConditions(route.Conditions),
+			Websocket:             route.EnableWebsockets,
+			HTTPSUpgrade:          routeEnforceTLS(enforceTLS, route.PermitInsecure),
+			PrefixRewrite:         route.PrefixRewrite,
+			TimeoutPolicy:         timeoutPolicy(route.TimeoutPolicy),
+			RetryPolicy:           retryPolicy(route.RetryPolicy),
+			DisableExtAuthz:       route.DisableExtAuthz,
+			RequestHeadersPolicy:  reqHP,
+			ResponseHeadersPolicy: respHP,
 		}
 
 		b.SetupRouteFilters(r, &route, ns)
@@ -858,6 +929,14 @@ func (b *builder) processOneRoute(ir *gatewayhostv1.GatewayHost, route gatewayho
 			}
 		}
 
+		mp, err := b.mirrorPolicy(r, &route, ns)
+		if err != nil {
+			b.setStatus(Status{Object: ir, Status: StatusInvalid,
+				Description: fmt.Sprintf("route mirror policy: %s", err), Vhost: host})
+			return fmt.Errorf("route mirror policy: %s", err)
+		}
+		r.MirrorPolicy = mp
+
 		b.lookupVirtualHost(host).addRoute(r)
 		b.lookupSecureVirtualHost(host).addRoute(r)
 	}
@@ -0,0 +1,746 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright(c) 2018-2023 Saaras Inc.
+
+package dag
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/saarasio/enroute/enroute-dp/internal/logger"
+	net_v1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	gwapi_v1 "sigs.k8s.io/gateway-api/apis/v1"
+	gwapi_v1beta1 "sigs.k8s.io/gateway-api/apis/v1beta1"
+)
+
+const (
+	// KindGateway, KindHTTPRoute, KindService and KindSecret are the
+	// object kinds that can participate in Gateway API references.
+	KindGateway   = "Gateway"
+	KindHTTPRoute = "HTTPRoute"
+	KindService   = "Service"
+	KindSecret    = "Secret"
+
+	// DEFAULT_GATEWAY_CONTROLLER_NAME is the GatewayClass controllerName
+	// handled by EnRoute when KubernetesCache.GatewayControllerName is not set.
+	DEFAULT_GATEWAY_CONTROLLER_NAME = "enroute.saaras.io/gateway-controller"
+)
+
+// GatewayClassStatus holds the status computed for a GatewayClass
+// handled by this controller.
+type GatewayClassStatus struct {
+	Object     *gwapi_v1beta1.GatewayClass
+	Conditions []metav1.Condition
+}
+
+// GatewayStatus holds the status computed for a Gateway whose
+// GatewayClass is handled by this controller.
+type GatewayStatus struct {
+	Object     *gwapi_v1beta1.Gateway
+	Conditions []metav1.Condition
+	Listeners  []gwapi_v1.ListenerStatus
+}
+
+// HTTPRouteStatus holds the status computed for each parentRef of
+// an HTTPRoute that refers to a Gateway handled by this controller.
+type HTTPRouteStatus struct {
+	Object  *gwapi_v1beta1.HTTPRoute
+	Parents []gwapi_v1.RouteParentStatus
+}
+
+// gatewayError is an error that carries the Gateway API condition reason
+// describing it.
+type gatewayError struct {
+	reason string
+	msg    string
+}
+
+func (e *gatewayError) Error() string { return e.msg }
+
+func gatewayErrorf(reason string, format string, args ...interface{}) *gatewayError {
+	return &gatewayError{reason: reason, msg: fmt.Sprintf(format, args...)}
+}
+
+// routeParentResult accumulates what happened to one parentRef of an
+// HTTPRoute while listeners were computed.
+type routeParentResult struct {
+	matched, allowed, hostnameMatched bool
+}
+
+// gatewayControllerName returns the configured GatewayClass controllerName
+// or DEFAULT_GATEWAY_CONTROLLER_NAME if not configured.
+func (kc *KubernetesCache) gatewayControllerName() string {
+	if kc.GatewayControllerName != "" {
+		return kc.GatewayControllerName
+	}
+	return DEFAULT_GATEWAY_CONTROLLER_NAME
+}
+
+// computeGateways translates Gateway API objects into the DAG.
+//
+// Each Gateway listener is mapped onto the insecure (80) or secure (443)
+// dag.Listener depending on its protocol, every HTTPRoute attached to the
+// listener becomes a set of dag.Routes on the virtual hosts formed by the
+// intersection of the listener and route hostnames.
+//
+// As with GatewayHosts, the port of a Gateway listener is not used as the
+// port of the Envoy listener, see the TODO on builder.listener.
+func (b *builder) computeGateways() {
+	controller := b.source.gatewayControllerName()
+
+	for _, gc := range b.source.gatewayclasses {
+		if string(gc.Spec.ControllerName) != controller {
+			continue
+		}
+		b.setGatewayClassStatus(&GatewayClassStatus{
+			Object: gc,
+			Conditions: []metav1.Condition{
+				gatewayCondition(gc.Generation, string(gwapi_v1.GatewayClassConditionStatusAccepted), true,
+					string(gwapi_v1.GatewayClassReasonAccepted), "GatewayClass is accepted by "+controller),
+			},
+		})
+	}
+
+	// results of every parentRef of every HTTPRoute, indexed by
+	// position in spec.parentRefs.
+	parents := make(map[Meta][]*routeParentResult)
+	routeRefErrors := make(map[Meta]error)
+
+	for _, gw := range b.source.gateways {
+		gc, ok := b.source.gatewayclasses[string(gw.Spec.GatewayClassName)]
+		if !ok || string(gc.Spec.ControllerName) != controller {
+			gatewayDebugf("Gateway [%s/%s] references GatewayClass [%s] not handled by [%s]",
+				gw.Namespace, gw.Name, gw.Spec.GatewayClassName, controller)
+			continue
+		}
+
+		st := &GatewayStatus{Object: gw}
+		programmed := 0
+		for i := range gw.Spec.Listeners {
+			l := &gw.Spec.Listeners[i]
+			ls := b.computeGatewayListener(gw, l, parents, routeRefErrors)
+			if conditionIsTrue(ls.Conditions, string(gwapi_v1.ListenerConditionProgrammed)) {
+				programmed++
+			}
+			st.Listeners = append(st.Listeners, ls)
+		}
+
+		st.Conditions = append(st.Conditions, gatewayCondition(gw.Generation,
+			string(gwapi_v1.GatewayConditionAccepted), true,
+			string(gwapi_v1.GatewayReasonAccepted), "Gateway is accepted"))
+		switch {
+		case programmed == len(gw.Spec.Listeners):
+			st.Conditions = append(st.Conditions, gatewayCondition(gw.Generation,
+				string(gwapi_v1.GatewayConditionProgrammed), true,
+				string(gwapi_v1.GatewayReasonProgrammed), "Gateway is programmed"))
+		default:
+			st.Conditions = append(st.Conditions, gatewayCondition(gw.Generation,
+				string(gwapi_v1.GatewayConditionProgrammed), false,
+				string(gwapi_v1.GatewayReasonInvalid),
+				fmt.Sprintf("%d of %d listeners are programmed", programmed, len(gw.Spec.Listeners))))
+		}
+		b.setGatewayStatus(st)
+	}
+
+	b.computeHTTPRouteStatuses(parents, routeRefErrors)
+}
+
+// computeGatewayListener attaches the HTTPRoutes permitted on listener l of
+// Gateway gw to the DAG and returns the status of the listener.
+func (b *builder) computeGatewayListener(gw *gwapi_v1beta1.Gateway, l *gwapi_v1beta1.Listener,
+	parents map[Meta][]*routeParentResult, routeRefErrors map[Meta]error) gwapi_v1.ListenerStatus {
+
+	ls := gwapi_v1.ListenerStatus{
+		Name: l.Name,
+		SupportedKinds: []gwapi_v1.RouteGroupKind{{
+			Group: (*gwapi_v1.Group)(strptr(gwapi_v1.GroupName)),
+			Kind:  KindHTTPRoute,
+		}},
+	}
+	invalid := func(condType string, err *gatewayError) gwapi_v1.ListenerStatus {
+		gatewayDebugf("Gateway [%s/%s] listener [%s]: %s", gw.Namespace, gw.Name, l.Name, err)
+		ls.Conditions = append(ls.Conditions,
+			gatewayCondition(gw.Generation, condType, false, err.reason, err.msg),
+			gatewayCondition(gw.Generation, string(gwapi_v1.ListenerConditionProgrammed), false,
+				string(gwapi_v1.ListenerReasonInvalid), err.msg),
+		)
+		return ls
+	}
+
+	var sec *Secret
+
+	switch l.Protocol {
+	case gwapi_v1.HTTPProtocolType:
+		// nothing to setup
+	case gwapi_v1.HTTPSProtocolType:
+		var err *gatewayError
+		if sec, err = b.lookupGatewayListenerSecret(gw, l); err != nil {
+			return invalid(string(gwapi_v1.ListenerConditionResolvedRefs), err)
+		}
+	default:
+		return invalid(string(gwapi_v1.ListenerConditionAccepted),
+			gatewayErrorf(string(gwapi_v1.ListenerReasonUnsupportedProtocol), "unsupported protocol %q", l.Protocol))
+	}
+
+	if err := listenerRouteKindsValid(l); err != nil {
+		return invalid(string(gwapi_v1.ListenerConditionResolvedRefs), err)
+	}
+
+	for m, route := range b.source.httproutes {
+		if len(parents[m]) == 0 {
+			parents[m] = make([]*routeParentResult, len(route.Spec.ParentRefs))
+			for i := range parents[m] {
+				parents[m][i] = &routeParentResult{}
+			}
+		}
+
+		refs := routeParentRefsForListener(route, gw, l)
+		if len(refs) == 0 {
+			continue
+		}
+		for _, i := range refs {
+			parents[m][i].matched = true
+		}
+
+		if !listenerAllowsRoute(gw, l, route) {
+			gatewayDebugf("HTTPRoute [%s/%s] not allowed by Gateway [%s/%s] listener [%s]",
+				route.Namespace, route.Name, gw.Namespace, gw.Name, l.Name)
+			continue
+		}
+		for _, i := range refs {
+			parents[m][i].allowed = true
+		}
+
+		hosts := gatewayHostnames(l.Hostname, route.Spec.Hostnames)
+		if len(hosts) == 0 {
+			continue
+		}
+		for _, i := range refs {
+			parents[m][i].hostnameMatched = true
+		}
+		ls.AttachedRoutes++
+
+		routes, err := b.computeHTTPRouteRules(route)
+		if err != nil {
+			routeRefErrors[m] = err
+		}
+
+		for _, host := range hosts {
+			if sec == nil {
+				vh := b.lookupVirtualHost(host)
+				for _, r := range routes {
+					vh.addRoute(r)
+				}
+				continue
+			}
+
+			// A secure virtual host must have a server name to match on.
+			if host == "*" {
+				continue
+			}
+			svh := b.lookupSecureVirtualHost(host)
+			svh.Secret = sec
+			svh.MinProtoVersion = minProtoVersion("")
+			for _, r := range routes {
+				svh.addRoute(r)
+			}
+		}
+	}
+
+	ls.Conditions = append(ls.Conditions,
+		gatewayCondition(gw.Generation, string(gwapi_v1.ListenerConditionAccepted), true,
+			string(gwapi_v1.ListenerReasonAccepted), "Listener is accepted"),
+		gatewayCondition(gw.Generation, string(gwapi_v1.ListenerConditionResolvedRefs), true,
+			string(gwapi_v1.ListenerReasonResolvedRefs), "All references are resolved"),
+		gatewayCondition(gw.Generation, string(gwapi_v1.ListenerConditionProgrammed), true,
+			string(gwapi_v1.ListenerReasonProgrammed), "Listener is programmed"),
+	)
+	return ls
+}
+
+// computeHTTPRouteStatuses records the status of every parentRef of every
+// HTTPRoute that refers to a Gateway handled by this controller.
+func (b *builder) computeHTTPRouteStatuses(parents map[Meta][]*routeParentResult, routeRefErrors map[Meta]error) {
+	controller := gwapi_v1.GatewayController(b.source.gatewayControllerName())
+
+	for m, route := range b.source.httproutes {
+		st := &HTTPRouteStatus{Object: route}
+
+		for i, ref := range route.Spec.ParentRefs {
+			if !b.isOwnGatewayRef(route, ref) {
+				// the parent belongs to some other controller.
+				continue
+			}
+
+			var res routeParentResult
+			if i < len(parents[m]) {
+				res = *parents[m][i]
+			}
+
+			var accepted metav1.Condition
+			switch {
+			case !res.matched:
+				accepted = gatewayCondition(route.Generation, string(gwapi_v1.RouteConditionAccepted), false,
+					string(gwapi_v1.RouteReasonNoMatchingParent), "No listener matches the parentRef")
+			case !res.allowed:
+				accepted = gatewayCondition(route.Generation, string(gwapi_v1.RouteConditionAccepted), false,
+					string(gwapi_v1.RouteReasonNotAllowedByListeners), "Route is not allowed by any listener")
+			case !res.hostnameMatched:
+				accepted = gatewayCondition(route.Generation, string(gwapi_v1.RouteConditionAccepted), false,
+					string(gwapi_v1.RouteReasonNoMatchingListenerHostname), "No listener hostname matches the route hostnames")
+			default:
+				accepted = gatewayCondition(route.Generation, string(gwapi_v1.RouteConditionAccepted), true,
+					string(gwapi_v1.RouteReasonAccepted), "Route is accepted")
+			}
+
+			resolved := gatewayCondition(route.Generation, string(gwapi_v1.RouteConditionResolvedRefs), true,
+				string(gwapi_v1.RouteReasonResolvedRefs), "All references are resolved")
+			if err, ok := routeRefErrors[m].(*gatewayError); ok {
+				resolved = gatewayCondition(route.Generation, string(gwapi_v1.RouteConditionResolvedRefs), false,
+					err.reason, err.msg)
+			}
+
+			st.Parents = append(st.Parents, gwapi_v1.RouteParentStatus{
+				ParentRef:      ref,
+				ControllerName: controller,
+				Conditions:     []metav1.Condition{accepted, resolved},
+			})
+		}
+
+		if len(st.Parents) > 0 {
+			b.setHTTPRouteStatus(st)
+		}
+	}
+}
+
+// isOwnGatewayRef returns true if ref refers to a Gateway whose GatewayClass
+// is handled by this controller.
+func (b *builder) isOwnGatewayRef(route *gwapi_v1beta1.HTTPRoute, ref gwapi_v1beta1.ParentReference) bool {
+	if ref.Group != nil && string(*ref.Group) != gwapi_v1.GroupName {
+		return false
+	}
+	if kindOrDefault(ref.Kind, KindGateway) != KindGateway {
+		return false
+	}
+	gw, ok := b.source.gateways[Meta{name: string(ref.Name), namespace: namespaceOrDefault(ref.Namespace, route.Namespace)}]
+	if !ok {
+		return false
+	}
+	gc, ok := b.source.gatewayclasses[string(gw.Spec.GatewayClassName)]
+	return ok && string(gc.Spec.ControllerName) == b.source.gatewayControllerName()
+}
+
+// lookupGatewayListenerSecret returns the Secret referenced by the first
+// certificateRef of a HTTPS listener.
+func (b *builder) lookupGatewayListenerSecret(gw *gwapi_v1beta1.Gateway, l *gwapi_v1beta1.Listener) (*Secret, *gatewayError) {
+	tls := l.TLS
+	if tls == nil || len(tls.CertificateRefs) == 0 {
+		return nil, gatewayErrorf(string(gwapi_v1.ListenerReasonInvalidCertificateRef),
+			"HTTPS listener must specify tls.certificateRefs")
+	}
+	if tls.Mode != nil && *tls.Mode != gwapi_v1.TLSModeTerminate {
+		return nil, gatewayErrorf(string(gwapi_v1.ListenerReasonInvalidCertificateRef),
+			"unsupported tls mode %q", *tls.Mode)
+	}
+
+	ref := tls.CertificateRefs[0]
+	if !groupIsCore(ref.Group) || kindOrDefault(ref.Kind, KindSecret) != KindSecret {
+		return nil, gatewayErrorf(string(gwapi_v1.ListenerReasonInvalidCertificateRef),
+			"certificateRef %q must refer to a core Secret", ref.Name)
+	}
+
+	m := Meta{name: string(ref.Name), namespace: namespaceOrDefault(ref.Namespace, gw.Namespace)}
+	if m.namespace != gw.Namespace &&
+		!b.referenceGrantPermitted(gwapi_v1.GroupName, KindGateway, gw.Namespace, "", KindSecret, m) {
+		return nil, gatewayErrorf(string(gwapi_v1.ListenerReasonRefNotPermitted),
+			"certificateRef %s/%s not permitted by any ReferenceGrant", m.namespace, m.name)
+	}
+
+	sec := b.lookupSecret(m, validSecret)
+	if sec == nil {
+		return nil, gatewayErrorf(string(gwapi_v1.ListenerReasonInvalidCertificateRef),
+			"certificateRef %s/%s not found or is malformed", m.namespace, m.name)
+	}
+	return sec, nil
+}
+
+// listenerRouteKindsValid ensures the allowedRoutes kinds of a listener
+// include HTTPRoute.
+func listenerRouteKindsValid(l *gwapi_v1beta1.Listener) *gatewayError {
+	if l.AllowedRoutes == nil || len(l.AllowedRoutes.Kinds) == 0 {
+		return nil
+	}
+	for _, k := range l.AllowedRoutes.Kinds {
+		if (k.Group == nil || string(*k.Group) == gwapi_v1.GroupName) && string(k.Kind) == KindHTTPRoute {
+			return nil
+		}
+	}
+	return gatewayErrorf(string(gwapi_v1.ListenerReasonInvalidRouteKinds), "listener does not allow HTTPRoute")
+}
+
+// routeParentRefsForListener returns the indexes of the HTTPRoute's
+// parentRefs that select listener l of Gateway gw.
+func routeParentRefsForListener(route *gwapi_v1beta1.HTTPRoute, gw *gwapi_v1beta1.Gateway, l *gwapi_v1beta1.Listener) []int {
+	var refs []int
+	for i, ref := range route.Spec.ParentRefs {
+		if ref.Group != nil && string(*ref.Group) != gwapi_v1.GroupName {
+			continue
+		}
+		if kindOrDefault(ref.Kind, KindGateway) != KindGateway {
+			continue
+		}
+		if string(ref.Name) != gw.Name || namespaceOrDefault(ref.Namespace, route.Namespace) != gw.Namespace {
+			continue
+		}
+		if ref.SectionName != nil && *ref.SectionName != l.Name {
+			continue
+		}
+		if ref.Port != nil && *ref.Port != l.Port {
+			continue
+		}
+		refs = append(refs, i)
+	}
+	return refs
+}
+
+// listenerAllowsRoute applies the allowedRoutes namespace policy of listener l.
+// Namespace selectors are not supported, as namespaces are not cached.
+func listenerAllowsRoute(gw *gwapi_v1beta1.Gateway, l *gwapi_v1beta1.Listener, route *gwapi_v1beta1.HTTPRoute) bool {
+	from := gwapi_v1.NamespacesFromSame
+	if l.AllowedRoutes != nil && l.AllowedRoutes.Namespaces != nil && l.AllowedRoutes.Namespaces.From != nil {
+		from = *l.AllowedRoutes.Namespaces.From
+	}
+
+	switch from {
+	case gwapi_v1.NamespacesFromAll:
+		return true
+	case gwapi_v1.NamespacesFromSame:
+		return route.Namespace == gw.Namespace
+	default:
+		return false
+	}
+}
+
+// computeHTTPRouteRules returns the dag.Routes for all rules of an HTTPRoute.
+// Rules without a single resolvable backend are skipped, the first reference
+// error encountered is returned alongside the routes that could be built.
+func (b *builder) computeHTTPRouteRules(route *gwapi_v1beta1.HTTPRoute) ([]*Route, error) {
+	var routes []*Route
+	var refErr error
+
+	for _, rule := range route.Spec.Rules {
+		clusters, err := b.httpRouteClusters(route, rule.BackendRefs)
+		if err != nil {
+			gatewayDebugf("HTTPRoute [%s/%s]: %s", route.Namespace, route.Name, err)
+			if refErr == nil {
+				refErr = err
+			}
+			continue
+		}
+
+		matches := rule.Matches
+		if len(matches) == 0 {
+			// a rule without matches matches all requests
+			matches = []gwapi_v1beta1.HTTPRouteMatch{{}}
+		}
+
+		for _, match := range matches {
+			r, err := httpRouteMatchToRoute(match)
+			if err != nil {
+				gatewayDebugf("HTTPRoute [%s/%s]: %s", route.Namespace, route.Name, err)
+				continue
+			}
+			r.Clusters = clusters
+			applyHTTPRouteFilters(r, rule.Filters)
+			routes = append(routes, r)
+		}
+	}
+
+	return routes, refErr
+}
+
+// httpRouteClusters resolves the backendRefs of an HTTPRoute rule to clusters.
+func (b *builder) httpRouteClusters(route *gwapi_v1beta1.HTTPRoute, refs []gwapi_v1beta1.HTTPBackendRef) ([]*Cluster, error) {
+	var clusters []*Cluster
+
+	for _, ref := range refs {
+		if !groupIsCore(ref.Group) || kindOrDefault(ref.Kind, KindService) != KindService {
+			return nil, gatewayErrorf(string(gwapi_v1.RouteReasonInvalidKind),
+				"backendRef %q: only core Services are supported", ref.Name)
+		}
+		if ref.Port == nil {
+			return nil, gatewayErrorf(string(gwapi_v1.RouteReasonUnsupportedValue),
+				"backendRef %q: port must be specified", ref.Name)
+		}
+
+		weight := int32(1)
+		if ref.Weight != nil {
+			weight = *ref.Weight
+		}
+		if weight == 0 {
+			// a zero weight backend receives no traffic
+			continue
+		}
+
+		m := Meta{name: string(ref.Name), namespace: namespaceOrDefault(ref.Namespace, route.Namespace)}
+		if m.namespace != route.Namespace &&
+			!b.referenceGrantPermitted(gwapi_v1.GroupName, KindHTTPRoute, route.Namespace, "", KindService, m) {
+			return nil, gatewayErrorf(string(gwapi_v1.RouteReasonRefNotPermitted),
+				"backendRef %s/%s not permitted by any ReferenceGrant", m.namespace, m.name)
+		}
+
+		s := b.lookupHTTPService(m, net_v1.ServiceBackendPort{Number: int32(*ref.Port)})
+		if s == nil {
+			return nil, gatewayErrorf(string(gwapi_v1.RouteReasonBackendNotFound),
+				"backendRef service [%s/%s:%d] is invalid or missing", m.namespace, m.name, *ref.Port)
+		}
+
+		clusters = append(clusters, &Cluster{
+			Upstream: s,
+			Weight:   uint32(weight),
+			SNI:      s.ExternalName,
+		})
+	}
+
+	if len(clusters) == 0 {
+		return nil, gatewayErrorf(string(gwapi_v1.RouteReasonBackendNotFound), "rule has no backends")
+	}
+
+	return clusters, nil
+}
+
+// httpRouteMatchToRoute converts a HTTPRouteMatch to a dag.Route without clusters.
+func httpRouteMatchToRoute(match gwapi_v1beta1.HTTPRouteMatch) (*Route, error) {
+	r := &Route{
+		PathCondition: &PrefixCondition{Prefix: "/"},
+	}
+
+	if match.Path != nil {
+		pathType := gwapi_v1.PathMatchPathPrefix
+		if match.Path.Type != nil {
+			pathType = *match.Path.Type
+		}
+		value := "/"
+		if match.Path.Value != nil {
+			value = *match.Path.Value
+		}
+
+		switch pathType {
+		case gwapi_v1.PathMatchPathPrefix:
+			r.PathCondition = &PrefixCondition{Prefix: value}
+		case gwapi_v1.PathMatchExact:
+			r.PathCondition = &RegexCondition{Regex: regexp.QuoteMeta(value)}
+		case gwapi_v1.PathMatchRegularExpression:
+			r.PathCondition = &RegexCondition{Regex: value}
+		default:
+			return nil, fmt.Errorf("unsupported path match type %q", pathType)
+		}
+	}
+
+	for _, h := range match.Headers {
+		if h.Type != nil && *h.Type != gwapi_v1.HeaderMatchExact {
+			return nil, fmt.Errorf("unsupported header match type %q", *h.Type)
+		}
+		r.HeaderConditions = append(r.HeaderConditions, HeaderCondition{
+			Name:      string(h.Name),
+			Value:     h.Value,
+			MatchType: "exact",
+		})
+	}
+
+	if match.Method != nil {
+		r.HeaderConditions = append(r.HeaderConditions, HeaderCondition{
+			Name:      ":method",
+			Value:     string(*match.Method),
+			MatchType: "exact",
+		})
+	}
+
+	for _, q := range match.QueryParams {
+		qp := QueryParamsCondition{
+			Key:       string(q.Name),
+			Value:     q.Value,
+			MatchType: "exact",
+		}
+		if q.Type != nil {
+			switch *q.Type {
+			case gwapi_v1.QueryParamMatchExact:
+			case gwapi_v1.QueryParamMatchRegularExpression:
+				qp.MatchType = "regex"
+			default:
+				return nil, fmt.Errorf("unsupported query param match type %q", *q.Type)
+			}
+		}
+		r.QueryParamConditions = append(r.QueryParamConditions, qp)
+	}
+
+	return r, nil
+}
+
+// applyHTTPRouteFilters applies the subset of HTTPRoute filters that have a
+// dag.Route equivalent.
+func applyHTTPRouteFilters(r *Route, filters []gwapi_v1beta1.HTTPRouteFilter) {
+	for _, f := range filters {
+		switch f.Type {
+		case gwapi_v1.HTTPRouteFilterURLRewrite:
+			if f.URLRewrite == nil || f.URLRewrite.Path == nil {
+				continue
+			}
+			if f.URLRewrite.Path.Type == gwapi_v1.PrefixMatchHTTPPathModifier && f.URLRewrite.Path.ReplacePrefixMatch != nil {
+				r.PrefixRewrite = *f.URLRewrite.Path.ReplacePrefixMatch
+			}
+		case gwapi_v1.HTTPRouteFilterRequestRedirect:
+			if f.RequestRedirect != nil && f.RequestRedirect.Scheme != nil && *f.RequestRedirect.Scheme == "https" {
+				r.HTTPSUpgrade = true
+			}
+		}
+	}
+}
+
+// referenceGrantPermitted returns true if a ReferenceGrant in the namespace
+// of the target permits a reference from the supplied group/kind/namespace.
+func (b *builder) referenceGrantPermitted(fromGroup, fromKind, fromNamespace, toGroup, toKind string, to Meta) bool {
+	for _, rg := range b.source.referencegrants {
+		if rg.Namespace != to.namespace {
+			continue
+		}
+
+		fromOk := false
+		for _, f := range rg.Spec.From {
+			if string(f.Group) == fromGroup && string(f.Kind) == fromKind && string(f.Namespace) == fromNamespace {
+				fromOk = true
+				break
+			}
+		}
+		if !fromOk {
+			continue
+		}
+
+		for _, t := range rg.Spec.To {
+			if string(t.Group) != toGroup || string(t.Kind) != toKind {
+				continue
+			}
+			if t.Name == nil || string(*t.Name) == to.name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// gatewayHostnames returns the virtual host names formed by the
+// intersection of a listener hostname and the hostnames of a route.
+func gatewayHostnames(listener *gwapi_v1beta1.Hostname, hostnames []gwapi_v1beta1.Hostname) []string {
+	lh := ""
+	if listener != nil {
+		lh = string(*listener)
+	}
+
+	if len(hostnames) == 0 {
+		return []string{stringOrDefault(lh, "*")}
+	}
+
+	var hosts []string
+	for _, h := range hostnames {
+		if host, ok := intersectHostname(lh, string(h)); ok {
+			hosts = append(hosts, host)
+		}
+	}
+	return hosts
+}
+
+// intersectHostname returns the most specific of two hostnames if they
+// match each other, a blank hostname matches any hostname.
+func intersectHostname(a, b string) (string, bool) {
+	switch {
+	case a == "":
+		return b, true
+	case b == "" || a == b:
+		return a, true
+	case strings.HasPrefix(a, "*.") && strings.HasSuffix(b, a[1:]):
+		return b, true
+	case strings.HasPrefix(b, "*.") && strings.HasSuffix(a, b[1:]):
+		return a, true
+	default:
+		return "", false
+	}
+}
+
+func strptr(s string) *string {
+	return &s
+}
+
+func groupIsCore(g *gwapi_v1beta1.Group) bool {
+	return g == nil || *g == "" || *g == "core"
+}
+
+func kindOrDefault(k *gwapi_v1beta1.Kind, def string) string {
+	if k == nil {
+		return def
+	}
+	return string(*k)
+}
+
+func namespaceOrDefault(ns *gwapi_v1beta1.Namespace, def string) string {
+	if ns == nil {
+		return def
+	}
+	return stringOrDefault(string(*ns), def)
+}
+
+// gatewayCondition returns a metav1.Condition for a Gateway API object.
+// LastTransitionTime is filled in when the condition is written back.
+func gatewayCondition(generation int64, condType string, status bool, reason, msg string) metav1.Condition {
+	cs := metav1.ConditionFalse
+	if status {
+		cs = metav1.ConditionTrue
+	}
+	return metav1.Condition{
+		Type:               condType,
+		Status:             cs,
+		Reason:             reason,
+		Message:            msg,
+		ObservedGeneration: generation,
+	}
+}
+
+func conditionIsTrue(conds []metav1.Condition, condType string) bool {
+	for _, c := range conds {
+		if c.Type == condType {
+			return c.Status == metav1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// setGatewayClassStatus records the status of a GatewayClass.
+func (b *builder) setGatewayClassStatus(st *GatewayClassStatus) {
+	if b.gatewayClassStatuses == nil {
+		b.gatewayClassStatuses = make(map[string]*GatewayClassStatus)
+	}
+	b.gatewayClassStatuses[st.Object.Name] = st
+}
+
+// setGatewayStatus records the status of a Gateway.
+func (b *builder) setGatewayStatus(st *GatewayStatus) {
+	if b.gatewayStatuses == nil {
+		b.gatewayStatuses = make(map[Meta]*GatewayStatus)
+	}
+	b.gatewayStatuses[Meta{name: st.Object.Name, namespace: st.Object.Namespace}] = st
+}
+
+// setHTTPRouteStatus records the status of an HTTPRoute.
+func (b *builder) setHTTPRouteStatus(st *HTTPRouteStatus) {
+	if b.httpRouteStatuses == nil {
+		b.httpRouteStatuses = make(map[Meta]*HTTPRouteStatus)
+	}
+	b.httpRouteStatuses[Meta{name: st.Object.Name, namespace: st.Object.Namespace}] = st
+}
+
+func gatewayDebugf(format string, args ...interface{}) {
+	if logger.EL.ELogger != nil {
+		logger.EL.ELogger.Debugf("dag:builder_gateway: "+format, args...)
+	}
+}
@@ -0,0 +1,498 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright(c) 2018-2023 Saaras Inc.
+
+package dag
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	gwapi_v1 "sigs.k8s.io/gateway-api/apis/v1"
+	gwapi_v1beta1 "sigs.k8s.io/gateway-api/apis/v1beta1"
+)
+
+func TestDAGGatewayAPI(t *testing.T) {
+	gc := &gwapi_v1beta1.GatewayClass{
+		ObjectMeta: metav1.ObjectMeta{Name: "enroute"},
+		Spec: gwapi_v1beta1.GatewayClassSpec{
+			ControllerName: DEFAULT_GATEWAY_CONTROLLER_NAME,
+		},
+	}
+
+	sec1 := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "secret",
+			Namespace: "infra",
+		},
+		Type: corev1.SecretTypeTLS,
+		Data: secretdata(CERTIFICATE, RSA_PRIVATE_KEY),
+	}
+
+	s1 := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "kuard",
+			Namespace: "default",
+		},
+		Spec: corev1.ServiceSpec{
+			Ports: []corev1.ServicePort{{
+				Name:     "http",
+				Protocol: "TCP",
+				Port:     8080,
+			}},
+		},
+	}
+
+	s2 := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "kuard",
+			Namespace: "other",
+		},
+		Spec: corev1.ServiceSpec{
+			Ports: []corev1.ServicePort{{
+				Name:     "http",
+				Protocol: "TCP",
+				Port:     8080,
+			}},
+		},
+	}
+
+	gwHTTP := &gwapi_v1beta1.Gateway{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "gw",
+			Namespace: "default",
+		},
+		Spec: gwapi_v1beta1.GatewaySpec{
+			GatewayClassName: "enroute",
+			Listeners: []gwapi_v1beta1.Listener{{
+				Name:     "http",
+				Port:     80,
+				Protocol: gwapi_v1.HTTPProtocolType,
+			}},
+		},
+	}
+
+	gwUnknownClass := gwHTTP.DeepCopy()
+	gwUnknownClass.Spec.GatewayClassName = "nginx"
+
+	gwHTTPS := &gwapi_v1beta1.Gateway{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "gw",
+			Namespace: "default",
+		},
+		Spec: gwapi_v1beta1.GatewaySpec{
+			GatewayClassName: "enroute",
+			Listeners: []gwapi_v1beta1.Listener{{
+				Name:     "https",
+				Port:     443,
+				Protocol: gwapi_v1.HTTPSProtocolType,
+				Hostname: hostname("kuard.example.com"),
+				TLS: &gwapi_v1beta1.GatewayTLSConfig{
+					CertificateRefs: []gwapi_v1beta1.SecretObjectReference{{
+						Name:      "secret",
+						Namespace: namespace("infra"),
+					}},
+				},
+			}},
+		},
+	}
+
+	// rgSecret permits Gateways in default to refer to Secrets in infra
+	rgSecret := &gwapi_v1beta1.ReferenceGrant{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "secrets",
+			Namespace: "infra",
+		},
+		Spec: gwapi_v1beta1.ReferenceGrantSpec{
+			From: []gwapi_v1beta1.ReferenceGrantFrom{{
+				Group:     gwapi_v1.GroupName,
+				Kind:      KindGateway,
+				Namespace: "default",
+			}},
+			To: []gwapi_v1beta1.ReferenceGrantTo{{
+				Kind: KindSecret,
+			}},
+		},
+	}
+
+	// rgService permits HTTPRoutes in default to refer to Services in other
+	rgService := &gwapi_v1beta1.ReferenceGrant{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "services",
+			Namespace: "other",
+		},
+		Spec: gwapi_v1beta1.ReferenceGrantSpec{
+			From: []gwapi_v1beta1.ReferenceGrantFrom{{
+				Group:     gwapi_v1.GroupName,
+				Kind:      KindHTTPRoute,
+				Namespace: "default",
+			}},
+			To: []gwapi_v1beta1.ReferenceGrantTo{{
+				Kind: KindService,
+			}},
+		},
+	}
+
+	hr1 := &gwapi_v1beta1.HTTPRoute{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "kuard",
+			Namespace: "default",
+		},
+		Spec: gwapi_v1beta1.HTTPRouteSpec{
+			CommonRouteSpec: gwapi_v1beta1.CommonRouteSpec{
+				ParentRefs: []gwapi_v1beta1.ParentReference{{Name: "gw"}},
+			},
+			Hostnames: []gwapi_v1beta1.Hostname{"kuard.example.com"},
+			Rules: []gwapi_v1beta1.HTTPRouteRule{{
+				Matches: []gwapi_v1beta1.HTTPRouteMatch{{
+					Path: &gwapi_v1beta1.HTTPPathMatch{
+						Value: strptr("/api"),
+					},
+				}},
+				BackendRefs: []gwapi_v1beta1.HTTPBackendRef{
+					backendref("kuard", "", 8080),
+				},
+			}},
+		},
+	}
+
+	// hr2 refers to a service in another namespace
+	hr2 := hr1.DeepCopy()
+	hr2.Spec.Rules[0].BackendRefs = []gwapi_v1beta1.HTTPBackendRef{
+		backendref("kuard", "other", 8080),
+	}
+
+	// hr3 matches on header, method and query parameters
+	hr3 := hr1.DeepCopy()
+	hr3.Spec.Rules[0].Matches = []gwapi_v1beta1.HTTPRouteMatch{{
+		Headers: []gwapi_v1beta1.HTTPHeaderMatch{{
+			Name:  "x-tenant",
+			Value: "acme",
+		}},
+		Method: methodptr("GET"),
+		QueryParams: []gwapi_v1beta1.HTTPQueryParamMatch{{
+			Name:  "version",
+			Value: "2",
+		}},
+	}}
+
+	// hr4 is attached to a listener section that does not exist
+	hr4 := hr1.DeepCopy()
+	hr4.Spec.ParentRefs[0].SectionName = sectionname("missing")
+
+	tests := map[string]struct {
+		objs []interface{}
+		want []Vertex
+	}{
+		"insert httproute attached to http listener": {
+			objs: []interface{}{gc, gwHTTP, s1, hr1},
+			want: listeners(
+				&Listener{
+					Port: 80,
+					VirtualHosts: virtualhosts(
+						virtualhost("kuard.example.com", routeCluster("/api",
+							&Cluster{Upstream: httpService(s1), Weight: 1})),
+					),
+				},
+			),
+		},
+		"gateway with unknown gatewayclass": {
+			objs: []interface{}{gc, gwUnknownClass, s1, hr1},
+			want: listeners(),
+		},
+		"httproute attached to missing section": {
+			objs: []interface{}{gc, gwHTTP, s1, hr4},
+			want: listeners(),
+		},
+		"insert httproute attached to https listener": {
+			objs: []interface{}{gc, gwHTTPS, sec1, rgSecret, s1, hr1},
+			want: listeners(
+				&Listener{
+					Port: 443,
+					VirtualHosts: virtualhosts(
+						&SecureVirtualHost{
+							VirtualHost: VirtualHost{
+								Name: "kuard.example.com",
+								Routes: routemap(routeCluster("/api",
+									&Cluster{Upstream: httpService(s1), Weight: 1})),
+							},
+							MinProtoVersion: minProtoVersion(""),
+							Secret:          secret(sec1),
+						},
+					),
+				},
+			),
+		},
+		"https listener secret without referencegrant": {
+			objs: []interface{}{gc, gwHTTPS, sec1, s1, hr1},
+			want: listeners(),
+		},
+		"cross namespace backend with referencegrant": {
+			objs: []interface{}{gc, gwHTTP, s2, rgService, hr2},
+			want: listeners(
+				&Listener{
+					Port: 80,
+					VirtualHosts: virtualhosts(
+						virtualhost("kuard.example.com", routeCluster("/api",
+							&Cluster{Upstream: httpService(s2), Weight: 1})),
+					),
+				},
+			),
+		},
+		"cross namespace backend without referencegrant": {
+			objs: []interface{}{gc, gwHTTP, s2, hr2},
+			want: listeners(),
+		},
+		"header, method and query param matches": {
+			objs: []interface{}{gc, gwHTTP, s1, hr3},
+			want: listeners(
+				&Listener{
+					Port: 80,
+					VirtualHosts: virtualhosts(
+						virtualhost("kuard.example.com", &Route{
+							PathCondition: prefix("/"),
+							HeaderConditions: []HeaderCondition{
+								{Name: "x-tenant", Value: "acme", MatchType: "exact"},
+								{Name: ":method", Value: "GET", MatchType: "exact"},
+							},
+							QueryParamConditions: []QueryParamsCondition{
+								{Key: "version", Value: "2", MatchType: "exact"},
+							},
+							Clusters: []*Cluster{{Upstream: httpService(s1), Weight: 1}},
+						}),
+					),
+				},
+			),
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			var kc KubernetesCache
+			for _, o := range tc.objs {
+				kc.Insert(o)
+			}
+			dag := BuildDAG(&kc)
+
+			got := make(map[int]*Listener)
+			dag.Visit(listenerMap(got).Visit)
+
+			want := make(map[int]*Listener)
+			for _, v := range tc.want {
+				if l, ok := v.(*Listener); ok {
+					want[l.Port] = l
+				}
+			}
+
+			opts := []cmp.Option{
+				cmp.AllowUnexported(Listener{}, VirtualHost{}),
+			}
+			if diff := cmp.Diff(want, got, opts...); diff != "" {
+				t.Fatal(diff)
+			}
+		})
+	}
+}
+
+func TestDAGGatewayAPIStatus(t *testing.T) {
+	gc := &gwapi_v1beta1.GatewayClass{
+		ObjectMeta: metav1.ObjectMeta{Name: "enroute"},
+		Spec: gwapi_v1beta1.GatewayClassSpec{
+			ControllerName: DEFAULT_GATEWAY_CONTROLLER_NAME,
+		},
+	}
+
+	s1 := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "kuard",
+			Namespace: "default",
+		},
+		Spec: corev1.ServiceSpec{
+			Ports: []corev1.ServicePort{{
+				Name:     "http",
+				Protocol: "TCP",
+				Port:     8080,
+			}},
+		},
+	}
+
+	gw := &gwapi_v1beta1.Gateway{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "gw",
+			Namespace: "default",
+		},
+		Spec: gwapi_v1beta1.GatewaySpec{
+			GatewayClassName: "enroute",
+			Listeners: []gwapi_v1beta1.Listener{{
+				Name:     "http",
+				Port:     80,
+				Protocol: gwapi_v1.HTTPProtocolType,
+				Hostname: hostname("*.example.com"),
+			}, {
+				Name:     "udp",
+				Port:     53,
+				Protocol: gwapi_v1.UDPProtocolType,
+			}},
+		},
+	}
+
+	route := func(name string, hostnames []gwapi_v1beta1.Hostname, svc string) *gwapi_v1beta1.HTTPRoute {
+		return &gwapi_v1beta1.HTTPRoute{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "default",
+			},
+			Spec: gwapi_v1beta1.HTTPRouteSpec{
+				CommonRouteSpec: gwapi_v1beta1.CommonRouteSpec{
+					ParentRefs: []gwapi_v1beta1.ParentReference{{Name: "gw", SectionName: sectionname("http")}},
+				},
+				Hostnames: hostnames,
+				Rules: []gwapi_v1beta1.HTTPRouteRule{{
+					BackendRefs: []gwapi_v1beta1.HTTPBackendRef{
+						backendref(svc, "", 8080),
+					},
+				}},
+			},
+		}
+	}
+
+	hrValid := route("valid", []gwapi_v1beta1.Hostname{"kuard.example.com"}, "kuard")
+	hrHostname := route("hostname", []gwapi_v1beta1.Hostname{"kuard.example.org"}, "kuard")
+	hrBackend := route("backend", nil, "missing")
+
+	var kc KubernetesCache
+	for _, o := range []interface{}{gc, gw, s1, hrValid, hrHostname, hrBackend} {
+		kc.Insert(o)
+	}
+	dag := BuildDAG(&kc)
+
+	if got := dag.GatewayClassStatuses()["enroute"]; got == nil || !conditionIsTrue(got.Conditions, "Accepted") {
+		t.Fatalf("expected GatewayClass to be accepted, got %+v", got)
+	}
+
+	gst := dag.GatewayStatuses()[Meta{name: "gw", namespace: "default"}]
+	if gst == nil {
+		t.Fatal("expected Gateway status")
+	}
+	if conditionIsTrue(gst.Conditions, "Programmed") {
+		t.Fatal("expected Gateway with an unsupported listener not to be programmed")
+	}
+	if diff := cmp.Diff(int32(2), gst.Listeners[0].AttachedRoutes); diff != "" {
+		t.Fatal(diff)
+	}
+	if conditionIsTrue(gst.Listeners[1].Conditions, "Accepted") {
+		t.Fatal("expected udp listener not to be accepted")
+	}
+
+	reasons := func(m Meta) map[string]string {
+		r := make(map[string]string)
+		for _, p := range dag.HTTPRouteStatuses()[m].Parents {
+			for _, c := range p.Conditions {
+				r[c.Type] = c.Reason
+			}
+		}
+		return r
+	}
+
+	tests := map[string]struct {
+		route *gwapi_v1beta1.HTTPRoute
+		want  map[string]string
+	}{
+		"valid": {
+			route: hrValid,
+			want:  map[string]string{"Accepted": "Accepted", "ResolvedRefs": "ResolvedRefs"},
+		},
+		"hostname mismatch": {
+			route: hrHostname,
+			want:  map[string]string{"Accepted": "NoMatchingListenerHostname", "ResolvedRefs": "ResolvedRefs"},
+		},
+		"missing backend": {
+			route: hrBackend,
+			want:  map[string]string{"Accepted": "Accepted", "ResolvedRefs": "BackendNotFound"},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := reasons(Meta{name: tc.route.Name, namespace: tc.route.Namespace})
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Fatal(diff)
+			}
+		})
+	}
+}
+
+func TestGatewayHostnames(t *testing.T) {
+	tests := map[string]struct {
+		listener  *gwapi_v1beta1.Hostname
+		hostnames []gwapi_v1beta1.Hostname
+		want      []string
+	}{
+		"no hostnames": {
+			want: []string{"*"},
+		},
+		"listener hostname only": {
+			listener: hostname("kuard.example.com"),
+			want:     []string{"kuard.example.com"},
+		},
+		"route hostnames only": {
+			hostnames: []gwapi_v1beta1.Hostname{"a.example.com", "b.example.com"},
+			want:      []string{"a.example.com", "b.example.com"},
+		},
+		"wildcard listener": {
+			listener:  hostname("*.example.com"),
+			hostnames: []gwapi_v1beta1.Hostname{"a.example.com", "a.example.org"},
+			want:      []string{"a.example.com"},
+		},
+		"wildcard route": {
+			listener:  hostname("a.example.com"),
+			hostnames: []gwapi_v1beta1.Hostname{"*.example.com"},
+			want:      []string{"a.example.com"},
+		},
+		"no intersection": {
+			listener:  hostname("a.example.com"),
+			hostnames: []gwapi_v1beta1.Hostname{"b.example.com"},
+			want:      nil,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := gatewayHostnames(tc.listener, tc.hostnames)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Fatal(diff)
+			}
+		})
+	}
+}
+
+func backendref(name, ns string, port int32) gwapi_v1beta1.HTTPBackendRef {
+	ref := gwapi_v1beta1.HTTPBackendRef{}
+	ref.Name = gwapi_v1beta1.ObjectName(name)
+	ref.Port = (*gwapi_v1beta1.PortNumber)(&port)
+	if ns != "" {
+		ref.Namespace = namespace(ns)
+	}
+	return ref
+}
+
+func hostname(h string) *gwapi_v1beta1.Hostname {
+	hn := gwapi_v1beta1.Hostname(h)
+	return &hn
+}
+
+func namespace(ns string) *gwapi_v1beta1.Namespace {
+	n := gwapi_v1beta1.Namespace(ns)
+	return &n
+}
+
+func sectionname(s string) *gwapi_v1beta1.SectionName {
+	sn := gwapi_v1beta1.SectionName(s)
+	return &sn
+}
+
+func methodptr(m string) *gwapi_v1beta1.HTTPMethod {
+	hm := gwapi_v1beta1.HTTPMethod(m)
+	return &hm
+}
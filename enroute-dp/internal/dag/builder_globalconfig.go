@@ -0,0 +1,56 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright(c) 2018-2023 Saaras Inc.
+
+package dag
+
+import (
+	"sort"
+
+	cfg "github.com/saarasio/enroute/enroute-dp/saarasconfig"
+)
+
+// globalConfigs returns the configs of the GlobalConfigs of type t,
+// ordered by namespace and name.
+func (b *builder) globalConfigs(t string) []string {
+	var keys []Meta
+	for m, gc := range b.source.globalconfigs {
+		if gc.Spec.Type == t {
+			keys = append(keys, m)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].namespace != keys[j].namespace {
+			return keys[i].namespace < keys[j].namespace
+		}
+		return keys[i].name < keys[j].name
+	})
+
+	var configs []string
+	for _, m := range keys {
+		configs = append(configs, b.source.globalconfigs[m].Spec.Config)
+	}
+	return configs
+}
+
+// accessLogConfigs returns the configs of the globalconfig_accesslog
+// GlobalConfigs, ordered by namespace and name.
+func (b *builder) accessLogConfigs() []string {
+	return b.globalConfigs(cfg.PROXY_CONFIG_ACCESSLOG)
+}
+
+// localReplyConfigs returns the configs of the globalconfig_localreply
+// GlobalConfigs, ordered by namespace and name.
+func (b *builder) localReplyConfigs() []string {
+	return b.globalConfigs(cfg.PROXY_CONFIG_LOCALREPLY)
+}
+
+// tracingConfig returns the config of the first globalconfig_tracing
+// GlobalConfig, ordered by namespace and name. Envoy traces to a single
+// collector, the others are ignored.
+func (b *builder) tracingConfig() string {
+	configs := b.globalConfigs(cfg.PROXY_CONFIG_TRACING)
+	if len(configs) == 0 {
+		return ""
+	}
+	return configs[0]
+}
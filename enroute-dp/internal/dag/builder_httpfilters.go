@@ -7,7 +7,9 @@ import (
 	_ "github.com/davecgh/go-spew/spew"
 	gatewayhostv1 "github.com/saarasio/enroute/enroute-dp/apis/enroute/v1"
 	"github.com/saarasio/enroute/enroute-dp/internal/logger"
+	cfg "github.com/saarasio/enroute/enroute-dp/saarasconfig"
 	"github.com/sirupsen/logrus"
+	net_v1 "k8s.io/api/networking/v1"
 )
 
 func (b *builder) lookupHTTPFilter(m HttpFilterMeta) *HttpFilter {
@@ -32,7 +34,10 @@ func (b *builder) addHttpFilter(hf_k8s *gatewayhostv1.HttpFilter, m HttpFilterMe
 			Filter_name:   hf_k8s.Spec.Name,
 			Filter_type:   hf_k8s.Spec.Type,
 			Filter_config: hf_k8s.Spec.HttpFilterConfig.Config,
+
+			Filter_namespace: hf_k8s.Namespace,
 		},
+		Cluster: b.httpFilterCluster(hf_k8s),
 	}
 
 	b.httpfilters[m] = &hf_dag
@@ -40,6 +45,67 @@ func (b *builder) addHttpFilter(hf_k8s *gatewayhostv1.HttpFilter, m HttpFilterMe
 	return &hf_dag
 }
 
+// httpFilterCluster returns the Cluster of the service the filter
+// communicates with, nil if the filter has no service or it is invalid.
+func (b *builder) httpFilterCluster(hf_k8s *gatewayhostv1.HttpFilter) *Cluster {
+	service := httpFilterService(hf_k8s)
+	if service.Name == "" {
+		if hf_k8s.Spec.Type == cfg.FILTER_TYPE_HTTP_WASM {
+			return wasmCluster(hf_k8s.Namespace, hf_k8s.Spec.Name, hf_k8s.Spec.HttpFilterConfig.Config)
+		}
+		return nil
+	}
+
+	m := Meta{name: service.Name, namespace: hf_k8s.Namespace}
+	s := b.lookupHTTPService(m, net_v1.ServiceBackendPort{Number: int32(service.Port)})
+	if s == nil {
+		if logger.EL.ELogger != nil {
+			logger.EL.ELogger.Infof("internal:dag:builder_httpfilter:httpFilterCluster() HTTPFilter [%s:%s] Service [%s:%d] is invalid or missing\n",
+				hf_k8s.Namespace, hf_k8s.Spec.Name, service.Name, service.Port)
+		}
+		return nil
+	}
+
+	protocol, err := getProtocol(service, s)
+	if err != nil {
+		if logger.EL.ELogger != nil {
+			logger.EL.ELogger.Infof("internal:dag:builder_httpfilter:httpFilterCluster() HTTPFilter [%s:%s] Service [%s:%d] %s\n",
+				hf_k8s.Namespace, hf_k8s.Spec.Name, service.Name, service.Port, err)
+		}
+		return nil
+	}
+	s.Protocol = protocol
+
+	// When talking to an ExternalName (DNS) service, explicitly set SNI to that name
+	return &Cluster{
+		Upstream:             s,
+		LoadBalancerStrategy: service.Strategy,
+		SNI:                  s.ExternalName,
+	}
+}
+
+// httpFilterService returns the service the filter communicates with. An
+// external authorization filter without a service in its spec names its
+// auth service, port and protocol (http or grpc) in its config instead.
+func httpFilterService(hf_k8s *gatewayhostv1.HttpFilter) gatewayhostv1.Service {
+	service := hf_k8s.Spec.Service
+	if service.Name != "" || hf_k8s.Spec.Type != cfg.FILTER_TYPE_HTTP_EXTAUTHZ {
+		return service
+	}
+
+	ea, err := cfg.UnmarshalExtAuthzFilterConfig(hf_k8s.Spec.HttpFilterConfig.Config)
+	if err != nil {
+		return service
+	}
+	service.Name = ea.AuthService
+	service.Port = ea.AuthServicePort
+	if ea.AuthServiceProto == "grpc" {
+		// gRPC auth services are spoken to over cleartext HTTP/2
+		service.Protocol = "h2c"
+	}
+	return service
+}
+
 func (b *builder) lookupHTTPVHFilter(m HttpFilterMeta, ir *gatewayhostv1.GatewayHost) *HttpFilter {
 	hf := b.lookupHTTPFilter(m)
 
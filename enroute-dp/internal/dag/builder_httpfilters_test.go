@@ -0,0 +1,60 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright(c) 2018-2023 Saaras Inc.
+//go:build !e && !c
+// +build !e,!c
+
+package dag
+
+import (
+	"testing"
+
+	gatewayhostv1 "github.com/saarasio/enroute/enroute-dp/apis/enroute/v1"
+	"github.com/saarasio/enroute/enroute-dp/internal/assert"
+	cfg "github.com/saarasio/enroute/enroute-dp/saarasconfig"
+)
+
+func TestHttpFilterService(t *testing.T) {
+	tests := map[string]struct {
+		spec gatewayhostv1.HttpFilterSpec
+		want gatewayhostv1.Service
+	}{
+		"service in spec": {
+			spec: gatewayhostv1.HttpFilterSpec{
+				Type:    cfg.FILTER_TYPE_HTTP_JWT,
+				Service: gatewayhostv1.Service{Name: "auth0", Port: 443, Protocol: "tls"},
+			},
+			want: gatewayhostv1.Service{Name: "auth0", Port: 443, Protocol: "tls"},
+		},
+		"extauthz http auth service": {
+			spec: gatewayhostv1.HttpFilterSpec{
+				Type: cfg.FILTER_TYPE_HTTP_EXTAUTHZ,
+				HttpFilterConfig: gatewayhostv1.GenericHttpFilterConfig{
+					Config: `{"auth_service":"ext-authz","auth_service_port":8080,"auth_service_proto":"http"}`,
+				},
+			},
+			want: gatewayhostv1.Service{Name: "ext-authz", Port: 8080},
+		},
+		"extauthz grpc auth service": {
+			spec: gatewayhostv1.HttpFilterSpec{
+				Type: cfg.FILTER_TYPE_HTTP_EXTAUTHZ,
+				HttpFilterConfig: gatewayhostv1.GenericHttpFilterConfig{
+					Config: `{"auth_service":"ext-authz","auth_service_port":9001,"auth_service_proto":"grpc"}`,
+				},
+			},
+			want: gatewayhostv1.Service{Name: "ext-authz", Port: 9001, Protocol: "h2c"},
+		},
+		"lua without service": {
+			spec: gatewayhostv1.HttpFilterSpec{
+				Type: cfg.FILTER_TYPE_HTTP_LUA,
+			},
+			want: gatewayhostv1.Service{},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := httpFilterService(&gatewayhostv1.HttpFilter{Spec: tc.spec})
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
@@ -0,0 +1,98 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright(c) 2018-2023 Saaras Inc.
+
+package dag
+
+import (
+	"fmt"
+
+	gatewayhostv1 "github.com/saarasio/enroute/enroute-dp/apis/enroute/v1"
+	"github.com/saarasio/enroute/enroute-dp/saarasconfig"
+	net_v1 "k8s.io/api/networking/v1"
+)
+
+// mirrorFilterPolicy returns the MirrorPolicy configured by the first
+// route_filter_mirror filter of rfilters, if any. Standalone mode attaches
+// mirrors to routes with this filter.
+func mirrorFilterPolicy(rfilters []*RouteFilter) (*gatewayhostv1.MirrorPolicy, error) {
+	for _, rf := range rfilters {
+		if rf.Filter.Filter_type != saarasconfig.FILTER_TYPE_RT_MIRROR {
+			continue
+		}
+
+		mc, err := saarasconfig.UnmarshalMirrorConfig(rf.Filter.Filter_config)
+		if err != nil {
+			return nil, fmt.Errorf("filter %q: invalid config", rf.Filter.Filter_name)
+		}
+
+		mp := gatewayhostv1.MirrorPolicy{
+			Percent: mc.Percent,
+		}
+		for _, s := range mc.Services {
+			mp.Services = append(mp.Services, gatewayhostv1.MirrorService{
+				Name: s.Name,
+				Port: int(s.Port),
+			})
+		}
+		return &mp, nil
+	}
+	return nil, nil
+}
+
+// mirrorPolicy builds the MirrorPolicy of the route k8s_r, looking up the
+// services requests are mirrored to in ns. The mirror section of the route
+// takes precedence over a mirror filter attached to it.
+func (b *builder) mirrorPolicy(dag_r *Route, k8s_r *gatewayhostv1.Route, ns string) (*MirrorPolicy, error) {
+	mp := k8s_r.Mirror
+	if mp == nil {
+		var err error
+		if mp, err = mirrorFilterPolicy(dag_r.RouteFilters); err != nil {
+			return nil, err
+		}
+	}
+	if mp == nil {
+		return nil, nil
+	}
+
+	if len(mp.Services) == 0 {
+		return nil, fmt.Errorf("at least one service must be specified")
+	}
+
+	policy := MirrorPolicy{
+		Percent: 100,
+	}
+	if mp.Percent != nil {
+		if *mp.Percent > 100 {
+			return nil, fmt.Errorf("percent must be in the range 0-100")
+		}
+		policy.Percent = *mp.Percent
+	}
+
+	for _, service := range mp.Services {
+		if service.Port < 1 || service.Port > 65535 {
+			return nil, fmt.Errorf("service %q: port must be in the range 1-65535", service.Name)
+		}
+
+		m := Meta{name: service.Name, namespace: ns}
+		s := b.lookupHTTPService(m, net_v1.ServiceBackendPort{Number: int32(service.Port)})
+		if s == nil {
+			return nil, fmt.Errorf("service [%s:%d] is invalid or missing", service.Name, service.Port)
+		}
+
+		_, err := getProtocol(gatewayhostv1.Service{
+			Name:     service.Name,
+			Port:     service.Port,
+			Protocol: service.Protocol,
+		}, s)
+		if err != nil {
+			return nil, fmt.Errorf("service [%s:%d]: %s", service.Name, service.Port, err)
+		}
+
+		// When talking to an ExternalName (DNS) service, explicitly set SNI to that name
+		policy.Clusters = append(policy.Clusters, &Cluster{
+			Upstream: s,
+			SNI:      s.ExternalName,
+		})
+	}
+	return &policy, nil
+}
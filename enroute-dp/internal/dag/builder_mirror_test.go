@@ -0,0 +1,87 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright(c) 2018-2023 Saaras Inc.
+
+package dag
+
+import (
+	"testing"
+
+	gatewayhostv1 "github.com/saarasio/enroute/enroute-dp/apis/enroute/v1"
+	"github.com/saarasio/enroute/enroute-dp/internal/assert"
+	"github.com/saarasio/enroute/enroute-dp/saarasconfig"
+)
+
+func TestMirrorFilterPolicy(t *testing.T) {
+	percent := uint32(5)
+
+	mirrorFilter := func(config string) *RouteFilter {
+		return &RouteFilter{
+			Filter: Filter{
+				Filter_name:   "mirror",
+				Filter_type:   saarasconfig.FILTER_TYPE_RT_MIRROR,
+				Filter_config: config,
+			},
+		}
+	}
+
+	tests := map[string]struct {
+		rfilters []*RouteFilter
+		want     *gatewayhostv1.MirrorPolicy
+		wantErr  bool
+	}{
+		"no filters": {
+			rfilters: nil,
+			want:     nil,
+		},
+		"no mirror filter": {
+			rfilters: []*RouteFilter{{
+				Filter: Filter{
+					Filter_name:   "tracing",
+					Filter_type:   saarasconfig.FILTER_TYPE_RT_TRACING,
+					Filter_config: `{"random_sampling":10}`,
+				},
+			}},
+			want: nil,
+		},
+		"mirror all requests": {
+			rfilters: []*RouteFilter{
+				mirrorFilter(`{"services":[{"name":"checkout-v2","port":8080}]}`),
+			},
+			want: &gatewayhostv1.MirrorPolicy{
+				Services: []gatewayhostv1.MirrorService{{
+					Name: "checkout-v2",
+					Port: 8080,
+				}},
+			},
+		},
+		"mirror a fraction of requests to two services": {
+			rfilters: []*RouteFilter{
+				mirrorFilter(`{"services":[{"name":"checkout-v2","port":8080},{"name":"checkout-v3","port":9090}],"percent":5}`),
+			},
+			want: &gatewayhostv1.MirrorPolicy{
+				Services: []gatewayhostv1.MirrorService{{
+					Name: "checkout-v2",
+					Port: 8080,
+				}, {
+					Name: "checkout-v3",
+					Port: 9090,
+				}},
+				Percent: &percent,
+			},
+		},
+		"invalid config": {
+			rfilters: []*RouteFilter{
+				mirrorFilter(`{"services":`),
+			},
+			wantErr: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := mirrorFilterPolicy(tc.rfilters)
+			assert.Equal(t, tc.wantErr, err != nil)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
@@ -39,8 +39,13 @@ func (b *builder) addRouteFilter(rf_k8s *gatewayhostv1.RouteFilter, m RouteFilte
 			Filter_name:   rf_k8s.Spec.Name,
 			Filter_type:   rf_k8s.Spec.Type,
 			Filter_config: rf_k8s.Spec.RouteFilterConfig.Config,
+
+			Filter_namespace: rf_k8s.Namespace,
 		},
 	}
+	if rf_k8s.Spec.Type == saarasconfig.FILTER_TYPE_HTTP_WASM {
+		rf_dag.Cluster = wasmCluster(rf_k8s.Namespace, rf_k8s.Spec.Name, rf_dag.Filter_config)
+	}
 
 	b.routefilters[m] = &rf_dag
 
@@ -1293,6 +1293,87 @@ func TestDAGInsert(t *testing.T) {
 		},
 	}
 
+	// ir15c mirrors a tenth of the requests to kuarder
+	mirrorPercent := uint32(10)
+	ir15c := &gatewayhostv1.GatewayHost{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "example-com",
+			Namespace: "default",
+		},
+		Spec: gatewayhostv1.GatewayHostSpec{
+			VirtualHost: &gatewayhostv1.VirtualHost{
+				Fqdn: "bar.com",
+			},
+			Routes: []gatewayhostv1.Route{{
+				Conditions: []gatewayhostv1.Condition{{
+					Prefix: "/",
+				}},
+				Services: []gatewayhostv1.Service{{
+					Name: "kuard",
+					Port: 8080,
+				}},
+				Mirror: &gatewayhostv1.MirrorPolicy{
+					Services: []gatewayhostv1.MirrorService{{
+						Name: "kuarder",
+						Port: 8080,
+					}},
+					Percent: &mirrorPercent,
+				},
+			}},
+		},
+	}
+
+	// ir15d requires two values of the same header
+	ir15d := &gatewayhostv1.GatewayHost{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "example-com",
+			Namespace: "default",
+		},
+		Spec: gatewayhostv1.GatewayHostSpec{
+			VirtualHost: &gatewayhostv1.VirtualHost{
+				Fqdn: "bar.com",
+			},
+			Routes: []gatewayhostv1.Route{{
+				Conditions: []gatewayhostv1.Condition{{
+					Prefix: "/",
+				}, {
+					Header: &gatewayhostv1.HeaderCondition{Name: "x-tenant", Exact: "a"},
+				}, {
+					Header: &gatewayhostv1.HeaderCondition{Name: "x-tenant", Exact: "b"},
+				}},
+				Services: []gatewayhostv1.Service{{
+					Name: "kuard",
+					Port: 8080,
+				}},
+			}},
+		},
+	}
+
+	// ir15e sets the Host header of the requests to its virtual host
+	ir15e := &gatewayhostv1.GatewayHost{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "example-com",
+			Namespace: "default",
+		},
+		Spec: gatewayhostv1.GatewayHostSpec{
+			VirtualHost: &gatewayhostv1.VirtualHost{
+				Fqdn: "bar.com",
+				RequestHeadersPolicy: &gatewayhostv1.HeadersPolicy{
+					Set: []gatewayhostv1.HeaderValue{{Name: "Host", Value: "internal"}},
+				},
+			},
+			Routes: []gatewayhostv1.Route{{
+				Conditions: []gatewayhostv1.Condition{{
+					Prefix: "/",
+				}},
+				Services: []gatewayhostv1.Service{{
+					Name: "kuard",
+					Port: 8080,
+				}},
+			}},
+		},
+	}
+
 	ir16a := &gatewayhostv1.GatewayHost{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      "example-com",
@@ -2811,6 +2892,42 @@ func TestDAGInsert(t *testing.T) {
 				},
 			),
 		},
+		"insert gatewayhost with mirror": {
+			objs: []interface{}{
+				ir15c,
+				s1,
+				s2,
+			},
+			want: listeners(
+				&Listener{
+					Port: 80,
+					VirtualHosts: virtualhosts(
+						virtualhost("bar.com", &Route{
+							PathCondition: prefix("/"),
+							Clusters:      clustermap(s1),
+							MirrorPolicy: &MirrorPolicy{
+								Clusters: clustermap(s2),
+								Percent:  10,
+							},
+						}),
+					),
+				},
+			),
+		},
+		"insert gatewayhost with contradictory header conditions": {
+			objs: []interface{}{
+				ir15d,
+				s1,
+			},
+			want: []Vertex{},
+		},
+		"insert gatewayhost with invalid virtualhost headers policy": {
+			objs: []interface{}{
+				ir15e,
+				s1,
+			},
+			want: []Vertex{},
+		},
 		"insert ingress with invalid perTryTimeout": {
 			objs: []interface{}{
 				ir15a,
@@ -4071,6 +4188,115 @@ func TestDAGGatewayHostStatus(t *testing.T) {
 		},
 	}
 
+	// ir17 mirrors requests to a missing service
+	ir17 := &gatewayhostv1.GatewayHost{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "roots",
+			Name:      "invalidmirror",
+		},
+		Spec: gatewayhostv1.GatewayHostSpec{
+			VirtualHost: &gatewayhostv1.VirtualHost{
+				Fqdn: "example.com",
+			},
+			Routes: []gatewayhostv1.Route{{
+				Conditions: []gatewayhostv1.Condition{{
+					Prefix: "/foo",
+				}},
+				Services: []gatewayhostv1.Service{{
+					Name: "home",
+					Port: 8080,
+				}},
+				Mirror: &gatewayhostv1.MirrorPolicy{
+					Services: []gatewayhostv1.MirrorService{{
+						Name: "invalid",
+						Port: 8080,
+					}},
+				},
+			}},
+		},
+	}
+
+	// ir18 mirrors more than all requests
+	percent := uint32(150)
+	ir18 := &gatewayhostv1.GatewayHost{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "roots",
+			Name:      "invalidmirror",
+		},
+		Spec: gatewayhostv1.GatewayHostSpec{
+			VirtualHost: &gatewayhostv1.VirtualHost{
+				Fqdn: "example.com",
+			},
+			Routes: []gatewayhostv1.Route{{
+				Conditions: []gatewayhostv1.Condition{{
+					Prefix: "/foo",
+				}},
+				Services: []gatewayhostv1.Service{{
+					Name: "home",
+					Port: 8080,
+				}},
+				Mirror: &gatewayhostv1.MirrorPolicy{
+					Services: []gatewayhostv1.MirrorService{{
+						Name: "home",
+						Port: 8080,
+					}},
+					Percent: &percent,
+				},
+			}},
+		},
+	}
+
+	// ir19 requires two values of the same header
+	ir19 := &gatewayhostv1.GatewayHost{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "roots",
+			Name:      "contradictoryheaders",
+		},
+		Spec: gatewayhostv1.GatewayHostSpec{
+			VirtualHost: &gatewayhostv1.VirtualHost{
+				Fqdn: "example.com",
+			},
+			Routes: []gatewayhostv1.Route{{
+				Conditions: []gatewayhostv1.Condition{{
+					Prefix: "/foo",
+				}, {
+					Header: &gatewayhostv1.HeaderCondition{Name: "x-tenant", Exact: "a"},
+				}, {
+					Header: &gatewayhostv1.HeaderCondition{Name: "x-tenant", Exact: "b"},
+				}},
+				Services: []gatewayhostv1.Service{{
+					Name: "home",
+					Port: 8080,
+				}},
+			}},
+		},
+	}
+
+	// ir20 sets the Host header of the requests to its virtual host
+	ir20 := &gatewayhostv1.GatewayHost{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "invalidheaders",
+			Namespace: "roots",
+		},
+		Spec: gatewayhostv1.GatewayHostSpec{
+			VirtualHost: &gatewayhostv1.VirtualHost{
+				Fqdn: "example.com",
+				RequestHeadersPolicy: &gatewayhostv1.HeadersPolicy{
+					Set: []gatewayhostv1.HeaderValue{{Name: "Host", Value: "internal"}},
+				},
+			},
+			Routes: []gatewayhostv1.Route{{
+				Conditions: []gatewayhostv1.Condition{{
+					Prefix: "/foo",
+				}},
+				Services: []gatewayhostv1.Service{{
+					Name: "home",
+					Port: 8080,
+				}},
+			}},
+		},
+	}
+
 	s4 := &corev1.Service{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      "home",
@@ -4204,6 +4430,22 @@ func TestDAGGatewayHostStatus(t *testing.T) {
 			objs: []interface{}{ir16},
 			want: []Status{{Object: ir16, Status: "invalid", Description: `Service [invalid:8080] is invalid or missing`, Vhost: "example.com"}},
 		},
+		"missing mirror service shows invalid status": {
+			objs: []interface{}{ir17, s4},
+			want: []Status{{Object: ir17, Status: "invalid", Description: `route mirror policy: service [invalid:8080] is invalid or missing`, Vhost: "example.com"}},
+		},
+		"invalid mirror percent": {
+			objs: []interface{}{ir18, s4},
+			want: []Status{{Object: ir18, Status: "invalid", Description: `route mirror policy: percent must be in the range 0-100`, Vhost: "example.com"}},
+		},
+		"contradictory header conditions": {
+			objs: []interface{}{ir19, s4},
+			want: []Status{{Object: ir19, Status: "invalid", Description: `cannot specify invalid or contradictory header conditions in the same route`, Vhost: "example.com"}},
+		},
+		"invalid virtualhost headers policy": {
+			objs: []interface{}{ir20, s4},
+			want: []Status{{Object: ir20, Status: "invalid", Description: `virtualhost request headers policy: header "Host" cannot be managed`, Vhost: "example.com"}},
+		},
 	}
 
 	for name, tc := range tests {
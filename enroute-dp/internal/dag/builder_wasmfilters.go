@@ -0,0 +1,64 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright(c) 2018-2023 Saaras Inc.
+
+package dag
+
+import (
+	"net/url"
+	"strconv"
+
+	"github.com/saarasio/enroute/enroute-dp/internal/logger"
+	cfg "github.com/saarasio/enroute/enroute-dp/saarasconfig"
+	v1 "k8s.io/api/core/v1"
+)
+
+// wasmCluster returns the Cluster a Wasm filter fetches its remote module
+// through, a DNS cluster to the host of the module URL. nil is returned
+// if the module is not remote or its URL is not http or https.
+func wasmCluster(namespace, name, config string) *Cluster {
+	wc, err := cfg.UnmarshalWasmConfig(config)
+	if err != nil || wc.Url == "" {
+		return nil
+	}
+
+	u, err := url.Parse(wc.Url)
+	if err != nil || u.Hostname() == "" {
+		if logger.EL.ELogger != nil {
+			logger.EL.ELogger.Infof("internal:dag:builder_wasmfilters:wasmCluster() Filter [%s:%s] invalid url [%s]\n", namespace, name, wc.Url)
+		}
+		return nil
+	}
+
+	var protocol, sni string
+	port := 80
+	switch u.Scheme {
+	case "http":
+	case "https":
+		protocol = "tls"
+		sni = u.Hostname()
+		port = 443
+	default:
+		if logger.EL.ELogger != nil {
+			logger.EL.ELogger.Infof("internal:dag:builder_wasmfilters:wasmCluster() Filter [%s:%s] unsupported url scheme [%s]\n", namespace, name, u.Scheme)
+		}
+		return nil
+	}
+	if p := u.Port(); p != "" {
+		if port, err = strconv.Atoi(p); err != nil {
+			return nil
+		}
+	}
+
+	return &Cluster{
+		Upstream: &HTTPService{
+			TCPService: TCPService{
+				Name:         u.Hostname(),
+				Namespace:    namespace,
+				ServicePort:  &v1.ServicePort{Port: int32(port)},
+				ExternalName: u.Hostname(),
+			},
+			Protocol: protocol,
+		},
+		SNI: sni,
+	}
+}
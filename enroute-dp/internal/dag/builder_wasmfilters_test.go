@@ -0,0 +1,62 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright(c) 2018-2023 Saaras Inc.
+
+package dag
+
+import (
+	"testing"
+
+	"github.com/saarasio/enroute/enroute-dp/internal/assert"
+	v1 "k8s.io/api/core/v1"
+)
+
+func TestWasmCluster(t *testing.T) {
+	tests := map[string]struct {
+		config string
+		want   *Cluster
+	}{
+		"https url": {
+			config: `{"url":"https://wasm.example.com/sanitiser.wasm","sha256":"abc123"}`,
+			want: &Cluster{
+				Upstream: &HTTPService{
+					TCPService: TCPService{
+						Name:         "wasm.example.com",
+						Namespace:    "default",
+						ServicePort:  &v1.ServicePort{Port: 443},
+						ExternalName: "wasm.example.com",
+					},
+					Protocol: "tls",
+				},
+				SNI: "wasm.example.com",
+			},
+		},
+		"http url with port": {
+			config: `{"url":"http://modules.enroute-system:8080/sanitiser.wasm"}`,
+			want: &Cluster{
+				Upstream: &HTTPService{
+					TCPService: TCPService{
+						Name:         "modules.enroute-system",
+						Namespace:    "default",
+						ServicePort:  &v1.ServicePort{Port: 8080},
+						ExternalName: "modules.enroute-system",
+					},
+				},
+			},
+		},
+		"oci url": {
+			config: `{"url":"oci://saarasio/vvx-json"}`,
+			want:   nil,
+		},
+		"local module": {
+			config: `{"filename":"/etc/envoy/sanitiser.wasm"}`,
+			want:   nil,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := wasmCluster("default", "sanitiser", tc.config)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
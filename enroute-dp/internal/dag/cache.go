@@ -27,6 +27,8 @@ import (
 
 	gatewayhostv1 "github.com/saarasio/enroute/enroute-dp/apis/enroute/v1"
 	"github.com/sirupsen/logrus"
+	gwapi_v1alpha2 "sigs.k8s.io/gateway-api/apis/v1alpha2"
+	gwapi_v1beta1 "sigs.k8s.io/gateway-api/apis/v1beta1"
 )
 
 // A KubernetesCache holds Kubernetes objects and associated configuration and produces
@@ -37,6 +39,10 @@ type KubernetesCache struct {
 	// namespace.
 	GatewayHostRootNamespaces []string
 
+	// GatewayControllerName is the GatewayClass controllerName handled
+	// by this instance. If empty, DEFAULT_GATEWAY_CONTROLLER_NAME is used.
+	GatewayControllerName string
+
 	mu sync.RWMutex
 	logrus.FieldLogger
 
@@ -49,6 +55,15 @@ type KubernetesCache struct {
 
 	routefilters map[RouteFilterMeta]*gatewayhostv1.RouteFilter
 	httpfilters  map[HttpFilterMeta]*gatewayhostv1.HttpFilter
+
+	globalconfigs map[Meta]*gatewayhostv1.GlobalConfig
+
+	// Gateway API objects
+	gatewayclasses  map[string]*gwapi_v1beta1.GatewayClass
+	gateways        map[Meta]*gwapi_v1beta1.Gateway
+	httproutes      map[Meta]*gwapi_v1beta1.HTTPRoute
+	udproutes       map[Meta]*gwapi_v1alpha2.UDPRoute
+	referencegrants map[Meta]*gwapi_v1beta1.ReferenceGrant
 }
 
 // Meta holds the name and namespace of a Kubernetes object.
@@ -121,6 +136,44 @@ func (kc *KubernetesCache) Insert(obj interface{}) {
 		}
 		kc.routefilters[m] = obj
 
+	case *gatewayhostv1.GlobalConfig:
+		m := Meta{name: obj.Name, namespace: obj.Namespace}
+		if kc.globalconfigs == nil {
+			kc.globalconfigs = make(map[Meta]*gatewayhostv1.GlobalConfig)
+		}
+		kc.globalconfigs[m] = obj
+
+	case *gwapi_v1beta1.GatewayClass:
+		// GatewayClass is cluster scoped
+		if kc.gatewayclasses == nil {
+			kc.gatewayclasses = make(map[string]*gwapi_v1beta1.GatewayClass)
+		}
+		kc.gatewayclasses[obj.Name] = obj
+	case *gwapi_v1beta1.Gateway:
+		m := Meta{name: obj.Name, namespace: obj.Namespace}
+		if kc.gateways == nil {
+			kc.gateways = make(map[Meta]*gwapi_v1beta1.Gateway)
+		}
+		kc.gateways[m] = obj
+	case *gwapi_v1beta1.HTTPRoute:
+		m := Meta{name: obj.Name, namespace: obj.Namespace}
+		if kc.httproutes == nil {
+			kc.httproutes = make(map[Meta]*gwapi_v1beta1.HTTPRoute)
+		}
+		kc.httproutes[m] = obj
+	case *gwapi_v1alpha2.UDPRoute:
+		m := Meta{name: obj.Name, namespace: obj.Namespace}
+		if kc.udproutes == nil {
+			kc.udproutes = make(map[Meta]*gwapi_v1alpha2.UDPRoute)
+		}
+		kc.udproutes[m] = obj
+	case *gwapi_v1beta1.ReferenceGrant:
+		m := Meta{name: obj.Name, namespace: obj.Namespace}
+		if kc.referencegrants == nil {
+			kc.referencegrants = make(map[Meta]*gwapi_v1beta1.ReferenceGrant)
+		}
+		kc.referencegrants[m] = obj
+
 	default:
 		// not an interesting object
 	}
@@ -167,6 +220,25 @@ func (kc *KubernetesCache) remove(obj interface{}) {
 	case *gatewayhostv1.RouteFilter:
 		m := RouteFilterMeta{filter_type: obj.Spec.Type, name: obj.Name, namespace: obj.Namespace}
 		delete(kc.routefilters, m)
+
+	case *gatewayhostv1.GlobalConfig:
+		m := Meta{name: obj.Name, namespace: obj.Namespace}
+		delete(kc.globalconfigs, m)
+
+	case *gwapi_v1beta1.GatewayClass:
+		delete(kc.gatewayclasses, obj.Name)
+	case *gwapi_v1beta1.Gateway:
+		m := Meta{name: obj.Name, namespace: obj.Namespace}
+		delete(kc.gateways, m)
+	case *gwapi_v1beta1.HTTPRoute:
+		m := Meta{name: obj.Name, namespace: obj.Namespace}
+		delete(kc.httproutes, m)
+	case *gwapi_v1alpha2.UDPRoute:
+		m := Meta{name: obj.Name, namespace: obj.Namespace}
+		delete(kc.udproutes, m)
+	case *gwapi_v1beta1.ReferenceGrant:
+		m := Meta{name: obj.Name, namespace: obj.Namespace}
+		delete(kc.referencegrants, m)
 	default:
 		// not interesting
 	}
@@ -15,27 +15,38 @@ package dag
 
 import (
 	"fmt"
+	"net/http"
 	"regexp"
 	"strings"
 
 	gatewayhostv1 "github.com/saarasio/enroute/enroute-dp/apis/enroute/v1"
 )
 
-// mergePathConditions merges the given slice of prefix Conditions into a single
-// prefix Condition.
+// MergePathConditions merges the given slice of path Conditions into a single
+// path Condition.
 // pathConditionsValid guarantees that if a prefix is present, it will start with a
-// / character, so we can simply concatenate.
-func mergePathConditions(conds []gatewayhostv1.Condition) Condition {
+// / character, so we can simply concatenate, and that an exact or regex
+// condition is the only path condition of the slice.
+func MergePathConditions(conds []gatewayhostv1.Condition) Condition {
 
 	prefix := ""
 	for _, cond := range conds {
+		switch {
+		case cond.Exact != "":
+			return &ExactCondition{
+				Path: cond.Exact,
+			}
+		case cond.Regex != "":
+			return &RegexCondition{
+				Regex: cond.Regex,
+			}
+		}
 		prefix = prefix + cond.Prefix
 	}
 
 	re := regexp.MustCompile(`//+`)
 	prefix = re.ReplaceAllString(prefix, `/`)
 
-	// TODO 6-3-2020 Support Regex Routes
 	// If this smells like regex, provide a RegexCondition instead of a PrefixCondition
 	if len(prefix) > 0 {
 		if strings.ContainsAny(prefix, "^+*[]%") {
@@ -59,10 +70,25 @@ func mergePathConditions(conds []gatewayhostv1.Condition) Condition {
 	}
 }
 
+// methods are the HTTP methods a method Condition can match.
+var methods = map[string]bool{
+	http.MethodGet:     true,
+	http.MethodHead:    true,
+	http.MethodPost:    true,
+	http.MethodPut:     true,
+	http.MethodPatch:   true,
+	http.MethodDelete:  true,
+	http.MethodConnect: true,
+	http.MethodOptions: true,
+	http.MethodTrace:   true,
+}
+
 // pathConditionsValid validates a slice of Conditions can be correctly merged.
-// It encodes the business rules about what is allowed for prefix Conditions.
+// It encodes the business rules about what is allowed for path and method Conditions.
 func pathConditionsValid(conds []gatewayhostv1.Condition, conditionsContext string) (bool, string) {
 	prefixCount := 0
+	pathCount := 0
+	methodCount := 0
 	for _, cond := range conds {
 		if cond.Prefix != "" {
 			prefixCount++
@@ -75,6 +101,35 @@ func pathConditionsValid(conds []gatewayhostv1.Condition, conditionsContext stri
 			err_message := fmt.Sprintf("%s: More than one prefix is not allowed in a condition block", conditionsContext)
 			return false, err_message
 		}
+		if cond.Exact != "" {
+			pathCount++
+			if cond.Exact[0] != '/' {
+				err_message := fmt.Sprintf("%s: Exact conditions must start with /, %s was supplied", conditionsContext, cond.Exact)
+				return false, err_message
+			}
+		}
+		if cond.Regex != "" {
+			pathCount++
+			if _, err := regexp.Compile(cond.Regex); err != nil {
+				err_message := fmt.Sprintf("%s: Regex condition %s is not a valid regular expression: %s", conditionsContext, cond.Regex, err)
+				return false, err_message
+			}
+		}
+		if pathCount > 0 && prefixCount+pathCount > 1 {
+			err_message := fmt.Sprintf("%s: An exact or regex condition cannot be combined with another path condition", conditionsContext)
+			return false, err_message
+		}
+		if cond.Method != "" {
+			methodCount++
+			if !methods[cond.Method] {
+				err_message := fmt.Sprintf("%s: Method condition %s is not a valid HTTP method", conditionsContext, cond.Method)
+				return false, err_message
+			}
+		}
+		if methodCount > 1 {
+			err_message := fmt.Sprintf("%s: More than one method is not allowed in a condition block", conditionsContext)
+			return false, err_message
+		}
 	}
 	return true, ""
 }
@@ -82,6 +137,13 @@ func pathConditionsValid(conds []gatewayhostv1.Condition, conditionsContext stri
 func MergeHeaderConditions(conds []gatewayhostv1.Condition) []HeaderCondition {
 	var hc []HeaderCondition
 	for _, cond := range conds {
+		if cond.Method != "" {
+			hc = append(hc, HeaderCondition{
+				Name:      ":method",
+				Value:     cond.Method,
+				MatchType: "exact",
+			})
+		}
 		switch {
 		case cond.Header == nil:
 			// skip it
@@ -90,53 +152,258 @@ func MergeHeaderConditions(conds []gatewayhostv1.Condition) []HeaderCondition {
 				Name:      cond.Header.Name,
 				MatchType: "present",
 			})
-		case cond.Header.Contains != "":
+		case cond.Header.NotPresent:
 			hc = append(hc, HeaderCondition{
 				Name:      cond.Header.Name,
-				Value:     cond.Header.Contains,
-				MatchType: "contains",
+				MatchType: "present",
+				Invert:    true,
+			})
+		case cond.Header.Contains != "":
+			hc = append(hc, HeaderCondition{
+				Name:       cond.Header.Name,
+				Value:      cond.Header.Contains,
+				MatchType:  "contains",
+				IgnoreCase: cond.Header.IgnoreCase,
 			})
 		case cond.Header.NotContains != "":
 			hc = append(hc, HeaderCondition{
-				Name:      cond.Header.Name,
-				Value:     cond.Header.NotContains,
-				MatchType: "contains",
-				Invert:    true,
+				Name:       cond.Header.Name,
+				Value:      cond.Header.NotContains,
+				MatchType:  "contains",
+				Invert:     true,
+				IgnoreCase: cond.Header.IgnoreCase,
 			})
 		case cond.Header.Exact != "":
 			hc = append(hc, HeaderCondition{
-				Name:      cond.Header.Name,
-				Value:     cond.Header.Exact,
-				MatchType: "exact",
+				Name:       cond.Header.Name,
+				Value:      cond.Header.Exact,
+				MatchType:  "exact",
+				IgnoreCase: cond.Header.IgnoreCase,
 			})
 		case cond.Header.NotExact != "":
 			hc = append(hc, HeaderCondition{
-				Name:      cond.Header.Name,
-				Value:     cond.Header.NotExact,
-				MatchType: "exact",
-				Invert:    true,
+				Name:       cond.Header.Name,
+				Value:      cond.Header.NotExact,
+				MatchType:  "exact",
+				Invert:     true,
+				IgnoreCase: cond.Header.IgnoreCase,
+			})
+		case cond.Header.Prefix != "":
+			hc = append(hc, HeaderCondition{
+				Name:       cond.Header.Name,
+				Value:      cond.Header.Prefix,
+				MatchType:  "prefix",
+				IgnoreCase: cond.Header.IgnoreCase,
+			})
+		case cond.Header.Suffix != "":
+			hc = append(hc, HeaderCondition{
+				Name:       cond.Header.Name,
+				Value:      cond.Header.Suffix,
+				MatchType:  "suffix",
+				IgnoreCase: cond.Header.IgnoreCase,
+			})
+		case cond.Header.Regex != "":
+			hc = append(hc, HeaderCondition{
+				Name:       cond.Header.Name,
+				Value:      cond.Header.Regex,
+				MatchType:  "regex",
+				IgnoreCase: cond.Header.IgnoreCase,
 			})
 		}
 	}
 	return hc
 }
 
+// headerConditionsAreValid returns false if the header Conditions of a
+// slice of Conditions contain an invalid regex or contradict each other,
+// so that no request can match them all.
 func headerConditionsAreValid(conditions []gatewayhostv1.Condition) bool {
-	// Look for duplicate "exact match" headers on conditions
-	// if found, set error condition on HTTPProxy
-	encountered := map[string]bool{}
-	for _, v := range conditions {
-		if v.Header == nil {
+	byName := map[string][]HeaderCondition{}
+	for _, hc := range MergeHeaderConditions(conditions) {
+		if hc.MatchType == "regex" {
+			if _, err := regexp.Compile(hc.Value); err != nil {
+				return false
+			}
+		}
+		// Header names are case insensitive
+		name := strings.ToLower(hc.Name)
+		byName[name] = append(byName[name], hc)
+	}
+
+	for _, hcs := range byName {
+		for i := range hcs {
+			for j := i + 1; j < len(hcs); j++ {
+				if headerConditionsContradict(hcs[i], hcs[j]) {
+					return false
+				}
+			}
+		}
+	}
+	return true
+}
+
+// headerConditionsContradict returns true if no value of a header can
+// match both a and b.
+func headerConditionsContradict(a, b HeaderCondition) bool {
+	aAbsent := a.MatchType == "present" && a.Invert
+	bAbsent := b.MatchType == "present" && b.Invert
+	if aAbsent || bAbsent {
+		// Envoy fails every other match of a header that is not present
+		return aAbsent != bAbsent
+	}
+
+	if b.MatchType == "exact" && !b.Invert {
+		a, b = b, a
+	}
+	if a.MatchType == "exact" && !a.Invert {
+		if b.MatchType == "exact" && !b.Invert {
+			// Look for duplicate "exact match" headers
+			return true
+		}
+		if b.Invert {
+			if a.IgnoreCase {
+				// another case of the value may not match b
+				return false
+			}
+			return headerValueMatches(b, a.Value)
+		}
+		if a.IgnoreCase {
+			b.IgnoreCase = true
+		}
+		return !headerValueMatches(b, a.Value)
+	}
+
+	// A match and its inversion
+	return a.MatchType == b.MatchType && a.Invert != b.Invert &&
+		a.IgnoreCase == b.IgnoreCase && a.Value == b.Value
+}
+
+// headerValueMatches returns true if the header value v matches hc,
+// ignoring the inversion of hc.
+func headerValueMatches(hc HeaderCondition, v string) bool {
+	value := hc.Value
+	if hc.IgnoreCase {
+		v, value = strings.ToLower(v), strings.ToLower(value)
+	}
+
+	switch hc.MatchType {
+	case "exact":
+		return v == value
+	case "contains":
+		return strings.Contains(v, value)
+	case "prefix":
+		return strings.HasPrefix(v, value)
+	case "suffix":
+		return strings.HasSuffix(v, value)
+	case "regex":
+		re, err := regexp.Compile(headerRegex(hc))
+		return err != nil || re.MatchString(v)
+	default:
+		return true
+	}
+}
+
+// headerRegex returns the regex of hc anchored to match the entire value of
+// the header, as Envoy does.
+func headerRegex(hc HeaderCondition) string {
+	regex := "^(?:" + hc.Value + ")$"
+	if hc.IgnoreCase {
+		regex = "(?i)" + regex
+	}
+	return regex
+}
+
+func MergeQueryParamConditions(conds []gatewayhostv1.Condition) []QueryParamsCondition {
+	var qc []QueryParamsCondition
+	for _, cond := range conds {
+		switch {
+		case cond.QueryParameter == nil:
+			// skip it
+		case cond.QueryParameter.Exact != "":
+			qc = append(qc, QueryParamsCondition{
+				Key:       cond.QueryParameter.Name,
+				Value:     cond.QueryParameter.Exact,
+				MatchType: "exact",
+			})
+		case cond.QueryParameter.Prefix != "":
+			qc = append(qc, QueryParamsCondition{
+				Key:       cond.QueryParameter.Name,
+				Value:     cond.QueryParameter.Prefix,
+				MatchType: "prefix",
+			})
+		case cond.QueryParameter.Regex != "":
+			qc = append(qc, QueryParamsCondition{
+				Key:       cond.QueryParameter.Name,
+				Value:     cond.QueryParameter.Regex,
+				MatchType: "regex",
+			})
+		case cond.QueryParameter.Present:
+			qc = append(qc, QueryParamsCondition{
+				Key:       cond.QueryParameter.Name,
+				MatchType: "present",
+			})
+		case cond.QueryParameter.NotPresent:
+			qc = append(qc, QueryParamsCondition{
+				Key:       cond.QueryParameter.Name,
+				MatchType: "present",
+				Invert:    true,
+			})
+		}
+	}
+	return qc
+}
+
+// queryParamConditionsValid validates the query parameter Conditions of a
+// slice of Conditions. Each must name a query parameter and provide exactly
+// one match, and a query parameter cannot be matched exactly twice nor be
+// both matched and not present.
+func queryParamConditionsValid(conds []gatewayhostv1.Condition, conditionsContext string) (bool, string) {
+	exact := map[string]bool{}
+	matched := map[string]bool{}
+	notPresent := map[string]bool{}
+	for _, cond := range conds {
+		qp := cond.QueryParameter
+		if qp == nil {
 			continue
 		}
+		if qp.Name == "" {
+			err_message := fmt.Sprintf("%s: Query parameter conditions must have a name", conditionsContext)
+			return false, err_message
+		}
+
+		matches := 0
+		for _, set := range []bool{qp.Exact != "", qp.Prefix != "", qp.Regex != "", qp.Present, qp.NotPresent} {
+			if set {
+				matches++
+			}
+		}
+		if matches != 1 {
+			err_message := fmt.Sprintf("%s: Query parameter condition %s must provide exactly one of exact, prefix, regex, present or notpresent", conditionsContext, qp.Name)
+			return false, err_message
+		}
+
 		switch {
-		case v.Header.Exact != "":
-			headerName := strings.ToLower(v.Header.Name)
-			if encountered[headerName] {
-				return false
+		case qp.Exact != "":
+			if exact[qp.Name] {
+				err_message := fmt.Sprintf("%s: More than one exact match of query parameter %s is not allowed in a condition block", conditionsContext, qp.Name)
+				return false, err_message
 			}
-			encountered[headerName] = true
+			exact[qp.Name] = true
+		case qp.Regex != "":
+			if _, err := regexp.Compile(qp.Regex); err != nil {
+				err_message := fmt.Sprintf("%s: Query parameter condition %s regex %s is not a valid regular expression: %s", conditionsContext, qp.Name, qp.Regex, err)
+				return false, err_message
+			}
+		}
+		if qp.NotPresent {
+			notPresent[qp.Name] = true
+		} else {
+			matched[qp.Name] = true
+		}
+		if notPresent[qp.Name] && matched[qp.Name] {
+			err_message := fmt.Sprintf("%s: Query parameter %s cannot be both matched and not present", conditionsContext, qp.Name)
+			return false, err_message
 		}
 	}
-	return true
+	return true, ""
 }
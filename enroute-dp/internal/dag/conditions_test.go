@@ -82,11 +82,25 @@ func TestPathCondition(t *testing.T) {
 			}},
 			want: &PrefixCondition{Prefix: "/"},
 		},
+		"exact condition": {
+			conditions: []gatewayhostv1.Condition{{
+				Exact: "/healthz",
+			}, {
+				Method: "GET",
+			}},
+			want: &ExactCondition{Path: "/healthz"},
+		},
+		"regex condition": {
+			conditions: []gatewayhostv1.Condition{{
+				Regex: "/v[0-9]+/users",
+			}},
+			want: &RegexCondition{Regex: "/v[0-9]+/users"},
+		},
 	}
 
 	for name, tc := range tests {
 		t.Run(name, func(t *testing.T) {
-			got := mergePathConditions(tc.conditions)
+			got := MergePathConditions(tc.conditions)
 			assert.Equal(t, tc.want, got)
 		})
 	}
@@ -113,6 +127,63 @@ func TestHeaderConditions(t *testing.T) {
 			}},
 			want: nil,
 		},
+		"header not present": {
+			conditions: []gatewayhostv1.Condition{{
+				Header: &gatewayhostv1.HeaderCondition{
+					Name:       "x-debug",
+					NotPresent: true,
+				},
+			}},
+			want: []HeaderCondition{{
+				Name:      "x-debug",
+				MatchType: "present",
+				Invert:    true,
+			}},
+		},
+		"header prefix suffix and regex": {
+			conditions: []gatewayhostv1.Condition{{
+				Header: &gatewayhostv1.HeaderCondition{
+					Name:       "user-agent",
+					Prefix:     "mozilla/",
+					IgnoreCase: true,
+				},
+			}, {
+				Header: &gatewayhostv1.HeaderCondition{
+					Name:   "x-tenant",
+					Suffix: ".eu",
+				},
+			}, {
+				Header: &gatewayhostv1.HeaderCondition{
+					Name:  "x-tenant-id",
+					Regex: "t-[0-9]+",
+				},
+			}},
+			want: []HeaderCondition{{
+				Name:       "user-agent",
+				Value:      "mozilla/",
+				MatchType:  "prefix",
+				IgnoreCase: true,
+			}, {
+				Name:      "x-tenant",
+				Value:     ".eu",
+				MatchType: "suffix",
+			}, {
+				Name:      "x-tenant-id",
+				Value:     "t-[0-9]+",
+				MatchType: "regex",
+			}},
+		},
+		"method": {
+			conditions: []gatewayhostv1.Condition{{
+				Prefix: "/api",
+				Method: "POST",
+			}},
+			want: []HeaderCondition{{
+				Name:      ":method",
+				Value:     "POST",
+				MatchType: "exact",
+			}},
+		},
 		"header present": {
 			conditions: []gatewayhostv1.Condition{{
 				Header: &gatewayhostv1.HeaderCondition{
@@ -304,6 +375,60 @@ func TestPrefixConditionsValid(t *testing.T) {
 			}},
 			want: false,
 		},
+		"exact condition with method": {
+			conditions: []gatewayhostv1.Condition{{
+				Exact:  "/healthz",
+				Method: "GET",
+			}},
+			want: true,
+		},
+		"invalid exact condition": {
+			conditions: []gatewayhostv1.Condition{{
+				Exact: "healthz",
+			}},
+			want: false,
+		},
+		"exact and prefix conditions": {
+			conditions: []gatewayhostv1.Condition{{
+				Prefix: "/api",
+			}, {
+				Exact: "/healthz",
+			}},
+			want: false,
+		},
+		"valid regex condition": {
+			conditions: []gatewayhostv1.Condition{{
+				Regex: "/v[0-9]+/.*",
+			}},
+			want: true,
+		},
+		"invalid regex condition": {
+			conditions: []gatewayhostv1.Condition{{
+				Regex: "/v[0-9+/.*",
+			}},
+			want: false,
+		},
+		"regex and exact conditions": {
+			conditions: []gatewayhostv1.Condition{{
+				Regex: "/v[0-9]+/.*",
+				Exact: "/v1/users",
+			}},
+			want: false,
+		},
+		"invalid method": {
+			conditions: []gatewayhostv1.Condition{{
+				Method: "get",
+			}},
+			want: false,
+		},
+		"two methods": {
+			conditions: []gatewayhostv1.Condition{{
+				Method: "GET",
+			}, {
+				Method: "POST",
+			}},
+			want: false,
+		},
 	}
 
 	for name, tc := range tests {
@@ -353,6 +478,160 @@ func TestValidateHeaderConditions(t *testing.T) {
 			},
 			want: false,
 		},
+		"invalid regex": {
+			conditions: []gatewayhostv1.Condition{{
+				Header: &gatewayhostv1.HeaderCondition{
+					Name:  "x-header",
+					Regex: "t-[0-9+",
+				},
+			}},
+			want: false,
+		},
+		"present and not present": {
+			conditions: []gatewayhostv1.Condition{{
+				Header: &gatewayhostv1.HeaderCondition{
+					Name:    "x-header",
+					Present: true,
+				},
+			}, {
+				Header: &gatewayhostv1.HeaderCondition{
+					Name:       "X-Header",
+					NotPresent: true,
+				},
+			}},
+			want: false,
+		},
+		"prefix and not present": {
+			conditions: []gatewayhostv1.Condition{{
+				Header: &gatewayhostv1.HeaderCondition{
+					Name:   "x-header",
+					Prefix: "abc",
+				},
+			}, {
+				Header: &gatewayhostv1.HeaderCondition{
+					Name:       "x-header",
+					NotPresent: true,
+				},
+			}},
+			want: false,
+		},
+		"contains and not contains": {
+			conditions: []gatewayhostv1.Condition{{
+				Header: &gatewayhostv1.HeaderCondition{
+					Name:     "x-header",
+					Contains: "abc",
+				},
+			}, {
+				Header: &gatewayhostv1.HeaderCondition{
+					Name:        "x-header",
+					NotContains: "abc",
+				},
+			}},
+			want: false,
+		},
+		"exact and not exact": {
+			conditions: []gatewayhostv1.Condition{{
+				Header: &gatewayhostv1.HeaderCondition{
+					Name:  "x-header",
+					Exact: "abc",
+				},
+			}, {
+				Header: &gatewayhostv1.HeaderCondition{
+					Name:     "x-header",
+					NotExact: "abc",
+				},
+			}},
+			want: false,
+		},
+		"exact and mismatched prefix": {
+			conditions: []gatewayhostv1.Condition{{
+				Header: &gatewayhostv1.HeaderCondition{
+					Name:  "x-header",
+					Exact: "abc",
+				},
+			}, {
+				Header: &gatewayhostv1.HeaderCondition{
+					Name:   "x-header",
+					Prefix: "xyz",
+				},
+			}},
+			want: false,
+		},
+		"exact and matching suffix and regex": {
+			conditions: []gatewayhostv1.Condition{{
+				Header: &gatewayhostv1.HeaderCondition{
+					Name:  "x-tenant",
+					Exact: "acme.eu",
+				},
+			}, {
+				Header: &gatewayhostv1.HeaderCondition{
+					Name:   "x-tenant",
+					Suffix: ".eu",
+				},
+			}, {
+				Header: &gatewayhostv1.HeaderCondition{
+					Name:  "x-tenant",
+					Regex: "[a-z]+\\.eu",
+				},
+			}},
+			want: true,
+		},
+		"exact and regex matching part of the value": {
+			conditions: []gatewayhostv1.Condition{{
+				Header: &gatewayhostv1.HeaderCondition{
+					Name:  "x-tenant",
+					Exact: "acme.eu",
+				},
+			}, {
+				Header: &gatewayhostv1.HeaderCondition{
+					Name:  "x-tenant",
+					Regex: "acme",
+				},
+			}},
+			want: false,
+		},
+		"exact ignoring case and prefix": {
+			conditions: []gatewayhostv1.Condition{{
+				Header: &gatewayhostv1.HeaderCondition{
+					Name:       "user-agent",
+					Exact:      "mozilla/5.0",
+					IgnoreCase: true,
+				},
+			}, {
+				Header: &gatewayhostv1.HeaderCondition{
+					Name:   "user-agent",
+					Prefix: "Mozilla/",
+				},
+			}},
+			want: true,
+		},
+		"prefix and not contains": {
+			conditions: []gatewayhostv1.Condition{{
+				Header: &gatewayhostv1.HeaderCondition{
+					Name:   "user-agent",
+					Prefix: "Mozilla/",
+				},
+			}, {
+				Header: &gatewayhostv1.HeaderCondition{
+					Name:        "user-agent",
+					NotContains: "bot",
+				},
+			}},
+			want: true,
+		},
+		"method and exact method header": {
+			conditions: []gatewayhostv1.Condition{
+				{
+					Method: "GET",
+				}, {
+					Header: &gatewayhostv1.HeaderCondition{
+						Name:  ":method",
+						Exact: "POST",
+					},
+				},
+			},
+			want: false,
+		},
 		"prefix only": {
 			conditions: []gatewayhostv1.Condition{
 				{
@@ -388,3 +667,182 @@ func TestValidateHeaderConditions(t *testing.T) {
 		})
 	}
 }
+
+func TestQueryParamConditions(t *testing.T) {
+	tests := map[string]struct {
+		conditions []gatewayhostv1.Condition
+		want       []QueryParamsCondition
+	}{
+		"empty condition list": {
+			conditions: nil,
+			want:       nil,
+		},
+		"prefix": {
+			conditions: []gatewayhostv1.Condition{{
+				Prefix: "/",
+			}},
+			want: nil,
+		},
+		"exact prefix and regex": {
+			conditions: []gatewayhostv1.Condition{{
+				QueryParameter: &gatewayhostv1.QueryParameterCondition{
+					Name:  "version",
+					Exact: "2",
+				},
+			}, {
+				QueryParameter: &gatewayhostv1.QueryParameterCondition{
+					Name:   "region",
+					Prefix: "us-",
+				},
+			}, {
+				QueryParameter: &gatewayhostv1.QueryParameterCondition{
+					Name:  "id",
+					Regex: "[0-9]+",
+				},
+			}},
+			want: []QueryParamsCondition{{
+				Key:       "version",
+				Value:     "2",
+				MatchType: "exact",
+			}, {
+				Key:       "region",
+				Value:     "us-",
+				MatchType: "prefix",
+			}, {
+				Key:       "id",
+				Value:     "[0-9]+",
+				MatchType: "regex",
+			}},
+		},
+		"present and not present": {
+			conditions: []gatewayhostv1.Condition{{
+				QueryParameter: &gatewayhostv1.QueryParameterCondition{
+					Name:    "beta",
+					Present: true,
+				},
+			}, {
+				QueryParameter: &gatewayhostv1.QueryParameterCondition{
+					Name:       "debug",
+					NotPresent: true,
+				},
+			}},
+			want: []QueryParamsCondition{{
+				Key:       "beta",
+				MatchType: "present",
+			}, {
+				Key:       "debug",
+				MatchType: "present",
+				Invert:    true,
+			}},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := MergeQueryParamConditions(tc.conditions)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestValidateQueryParamConditions(t *testing.T) {
+	tests := map[string]struct {
+		conditions []gatewayhostv1.Condition
+		want       bool
+	}{
+		"empty condition list": {
+			conditions: nil,
+			want:       true,
+		},
+		"valid conditions": {
+			conditions: []gatewayhostv1.Condition{{
+				Prefix: "/api",
+				QueryParameter: &gatewayhostv1.QueryParameterCondition{
+					Name:  "version",
+					Exact: "2",
+				},
+			}, {
+				QueryParameter: &gatewayhostv1.QueryParameterCondition{
+					Name:  "id",
+					Regex: "[0-9]+",
+				},
+			}, {
+				QueryParameter: &gatewayhostv1.QueryParameterCondition{
+					Name:       "debug",
+					NotPresent: true,
+				},
+			}},
+			want: true,
+		},
+		"no name": {
+			conditions: []gatewayhostv1.Condition{{
+				QueryParameter: &gatewayhostv1.QueryParameterCondition{
+					Exact: "2",
+				},
+			}},
+			want: false,
+		},
+		"no match": {
+			conditions: []gatewayhostv1.Condition{{
+				QueryParameter: &gatewayhostv1.QueryParameterCondition{
+					Name: "version",
+				},
+			}},
+			want: false,
+		},
+		"two matches": {
+			conditions: []gatewayhostv1.Condition{{
+				QueryParameter: &gatewayhostv1.QueryParameterCondition{
+					Name:   "version",
+					Exact:  "2",
+					Prefix: "2",
+				},
+			}},
+			want: false,
+		},
+		"invalid regex": {
+			conditions: []gatewayhostv1.Condition{{
+				QueryParameter: &gatewayhostv1.QueryParameterCondition{
+					Name:  "id",
+					Regex: "[0-9+",
+				},
+			}},
+			want: false,
+		},
+		"duplicate exact": {
+			conditions: []gatewayhostv1.Condition{{
+				QueryParameter: &gatewayhostv1.QueryParameterCondition{
+					Name:  "version",
+					Exact: "2",
+				},
+			}, {
+				QueryParameter: &gatewayhostv1.QueryParameterCondition{
+					Name:  "version",
+					Exact: "3",
+				},
+			}},
+			want: false,
+		},
+		"matched and not present": {
+			conditions: []gatewayhostv1.Condition{{
+				QueryParameter: &gatewayhostv1.QueryParameterCondition{
+					Name:       "version",
+					NotPresent: true,
+				},
+			}, {
+				QueryParameter: &gatewayhostv1.QueryParameterCondition{
+					Name:   "version",
+					Prefix: "2",
+				},
+			}},
+			want: false,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, _ := queryParamConditionsValid(tc.conditions, "test")
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
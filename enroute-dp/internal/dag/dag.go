@@ -38,6 +38,23 @@ type DAG struct {
 
 	// status computed while building this dag.
 	statuses map[Meta]Status
+
+	// Gateway API status computed while building this dag.
+	gatewayClassStatuses map[string]*GatewayClassStatus
+	gatewayStatuses      map[Meta]*GatewayStatus
+	httpRouteStatuses    map[Meta]*HTTPRouteStatus
+
+	// configs of the globalconfig_accesslog GlobalConfigs, ordered by
+	// namespace and name.
+	accessLogConfigs []string
+
+	// config of the first globalconfig_tracing GlobalConfig, ordered by
+	// namespace and name.
+	tracingConfig string
+
+	// configs of the globalconfig_localreply GlobalConfigs, ordered by
+	// namespace and name.
+	localReplyConfigs []string
 }
 
 // Visit calls fn on each root of this DAG.
@@ -47,24 +64,81 @@ func (d *DAG) Visit(fn func(Vertex)) {
 	}
 }
 
+// AccessLogConfigs returns the configs of the access log GlobalConfigs.
+func (d *DAG) AccessLogConfigs() []string {
+	return d.accessLogConfigs
+}
+
+// TracingConfig returns the config of the tracing GlobalConfig, empty if
+// there is none.
+func (d *DAG) TracingConfig() string {
+	return d.tracingConfig
+}
+
+// LocalReplyConfigs returns the configs of the local reply GlobalConfigs.
+func (d *DAG) LocalReplyConfigs() []string {
+	return d.localReplyConfigs
+}
+
 // Statuses returns a slice of Status objects associated with
 // the computation of this DAG.
 func (d *DAG) Statuses() map[Meta]Status {
 	return d.statuses
 }
 
+// GatewayClassStatuses returns the status of the GatewayClasses
+// handled by this controller.
+func (d *DAG) GatewayClassStatuses() map[string]*GatewayClassStatus {
+	return d.gatewayClassStatuses
+}
+
+// GatewayStatuses returns the status of the Gateways
+// handled by this controller.
+func (d *DAG) GatewayStatuses() map[Meta]*GatewayStatus {
+	return d.gatewayStatuses
+}
+
+// HTTPRouteStatuses returns the status of the HTTPRoutes
+// attached to Gateways handled by this controller.
+func (d *DAG) HTTPRouteStatuses() map[Meta]*HTTPRouteStatus {
+	return d.httpRouteStatuses
+}
+
 type Filter struct {
 	Filter_name   string
 	Filter_type   string
 	Filter_config string
+
+	// Filter_namespace is the namespace of the filter resource.
+	Filter_namespace string
 }
 
 type RouteFilter struct {
 	Filter
+
+	// Cluster, if set, is the upstream service the filter communicates
+	// with, eg: the server that hosts the module of a Wasm filter.
+	Cluster *Cluster
+}
+
+func (f *RouteFilter) Visit(fn func(Vertex)) {
+	if f.Cluster != nil {
+		fn(f.Cluster)
+	}
 }
 
 type HttpFilter struct {
 	Filter
+
+	// Cluster, if set, is the upstream service the filter communicates
+	// with, eg: the server that hosts the JWKS of a JWT filter.
+	Cluster *Cluster
+}
+
+func (f *HttpFilter) Visit(fn func(Vertex)) {
+	if f.Cluster != nil {
+		fn(f.Cluster)
+	}
 }
 
 type Condition interface {
@@ -80,6 +154,15 @@ func (pc *PrefixCondition) String() string {
 	return "prefix: " + pc.Prefix
 }
 
+// ExactCondition matches the entire path of a URL.
+type ExactCondition struct {
+	Path string
+}
+
+func (ec *ExactCondition) String() string {
+	return "exact: " + ec.Path
+}
+
 // RegexCondition matches the URL by regular expression.
 type RegexCondition struct {
 	Regex string
@@ -89,21 +172,44 @@ func (rc *RegexCondition) String() string {
 	return "regex: " + rc.Regex
 }
 
+// HeaderCondition matches a header of the request. MatchType is one of
+// exact, contains, prefix, suffix, regex or present. IgnoreCase compares
+// the value of the header without regard to case.
 type HeaderCondition struct {
-	Name      string
+	Name       string
+	Value      string
+	MatchType  string
+	Invert     bool
+	IgnoreCase bool
+}
+
+// QueryParamsCondition matches a query parameter of the URL. MatchType is
+// one of exact, prefix, regex or present. An inverted present condition
+// matches URLs without the query parameter.
+type QueryParamsCondition struct {
+	Key       string
 	Value     string
 	MatchType string
 	Invert    bool
 }
 
-type QueryParamsCondition struct {
-	Key          string
-	Value        string
-	IsValueRegex bool
+func (hc *HeaderCondition) String() string {
+	s := "header: " + hc.Name + " " + hc.MatchType + ": " + hc.Value
+	if hc.IgnoreCase {
+		s += " ignorecase"
+	}
+	if hc.Invert {
+		s = "not " + s
+	}
+	return s
 }
 
-func (hc *HeaderCondition) String() string {
-	return "header: " + hc.Name + " value: " + hc.Value
+func (qc *QueryParamsCondition) String() string {
+	s := "queryparam: " + qc.Key + " " + qc.MatchType + ": " + qc.Value
+	if qc.Invert {
+		s = "not " + s
+	}
+	return s
 }
 
 type Route struct {
@@ -139,6 +245,42 @@ type Route struct {
 	RouteFilters []*RouteFilter
 
 	DisableExtAuthz bool
+
+	// RequestHeadersPolicy defines how headers are managed during forwarding
+	RequestHeadersPolicy *HeadersPolicy
+
+	// ResponseHeadersPolicy defines how headers are managed during forwarding
+	ResponseHeadersPolicy *HeadersPolicy
+
+	// MirrorPolicy defines how requests are mirrored to other clusters
+	MirrorPolicy *MirrorPolicy
+}
+
+// MirrorPolicy defines how the requests of a route are mirrored
+type MirrorPolicy struct {
+	// Clusters requests are mirrored to
+	Clusters []*Cluster
+
+	// Percent of requests mirrored to each cluster
+	Percent uint32
+}
+
+// HeadersPolicy defines how headers are managed during forwarding
+type HeadersPolicy struct {
+	// Set replaces the value of a header, adding the header if absent
+	Set []HeaderValue
+
+	// Add appends a value to a header, adding the header if absent
+	Add []HeaderValue
+
+	// Remove removes headers
+	Remove []string
+}
+
+// HeaderValue represents a header name/value pair
+type HeaderValue struct {
+	Name  string
+	Value string
 }
 
 // TimeoutPolicy defines the timeout request/idle
@@ -179,6 +321,9 @@ func (r *Route) Visit(f func(Vertex)) {
 	for _, c := range r.Clusters {
 		f(c)
 	}
+	for _, rf := range r.RouteFilters {
+		f(rf)
+	}
 }
 
 // A VirtualHost represents a named L4/L7 service.
@@ -191,6 +336,12 @@ type VirtualHost struct {
 
 	Routes map[string]*Route
 
+	// RequestHeadersPolicy defines how headers are managed during forwarding
+	RequestHeadersPolicy *HeadersPolicy
+
+	// ResponseHeadersPolicy defines how headers are managed during forwarding
+	ResponseHeadersPolicy *HeadersPolicy
+
 	// Service to TCP proxy all incoming connections.
 	*TCPProxy
 }
@@ -231,6 +382,9 @@ func conditionsToString(r *Route) string {
 	for _, cond := range r.HeaderConditions {
 		s = append(s, cond.String())
 	}
+	for _, cond := range r.QueryParamConditions {
+		s = append(s, cond.String())
+	}
 	return strings.Join(s, ",")
 }
 
@@ -239,6 +393,10 @@ func (v *VirtualHost) Visit(f func(Vertex)) {
 		f(r)
 	}
 
+	for _, hf := range v.HttpFilters {
+		f(hf)
+	}
+
 	if v.TCPProxy != nil {
 		f(v.TCPProxy)
 	}
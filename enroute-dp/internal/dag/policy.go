@@ -17,6 +17,9 @@
 package dag
 
 import (
+	"fmt"
+	"regexp"
+	"strings"
 	"time"
 
 	enrouteapi "github.com/saarasio/enroute/enroute-dp/apis/enroute/v1"
@@ -44,6 +47,52 @@ func timeoutPolicy(tp *enrouteapi.TimeoutPolicy) *TimeoutPolicy {
 	}
 }
 
+// headerNameRegex matches the names of headers, as defined by RFC 7230.
+var headerNameRegex = regexp.MustCompile("^[!#$%&'*+.^_`|~0-9A-Za-z-]+$")
+
+// headersPolicy builds a HeadersPolicy from hp. Pseudo headers and, if
+// request is true, the Host header cannot be managed.
+func headersPolicy(hp *enrouteapi.HeadersPolicy, request bool) (*HeadersPolicy, error) {
+	if hp == nil {
+		return nil, nil
+	}
+
+	validName := func(name string) error {
+		switch {
+		case name == "":
+			return fmt.Errorf("header name must be specified")
+		case strings.HasPrefix(name, ":"):
+			return fmt.Errorf("pseudo header %q cannot be managed", name)
+		case request && strings.EqualFold(name, "host"):
+			return fmt.Errorf("header %q cannot be managed", name)
+		case !headerNameRegex.MatchString(name):
+			return fmt.Errorf("header name %q is invalid", name)
+		}
+		return nil
+	}
+
+	var p HeadersPolicy
+	for _, h := range hp.Set {
+		if err := validName(h.Name); err != nil {
+			return nil, err
+		}
+		p.Set = append(p.Set, HeaderValue{Name: h.Name, Value: h.Value})
+	}
+	for _, h := range hp.Add {
+		if err := validName(h.Name); err != nil {
+			return nil, err
+		}
+		p.Add = append(p.Add, HeaderValue{Name: h.Name, Value: h.Value})
+	}
+	for _, name := range hp.Remove {
+		if err := validName(name); err != nil {
+			return nil, err
+		}
+		p.Remove = append(p.Remove, name)
+	}
+	return &p, nil
+}
+
 // ingressRetryPolicy builds a RetryPolicy from ingress annotations.
 func ingressRetryPolicy(ingress *k8sapi.Ingress) *RetryPolicy {
 	retryOn := compatAnnotation(ingress, "retry-on")
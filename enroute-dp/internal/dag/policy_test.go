@@ -168,3 +168,65 @@ func TestParseTimeout(t *testing.T) {
 		})
 	}
 }
+
+func TestHeadersPolicy(t *testing.T) {
+	tests := map[string]struct {
+		hp      *v1.HeadersPolicy
+		request bool
+		want    *HeadersPolicy
+		wantErr bool
+	}{
+		"nil headers policy": {
+			hp:   nil,
+			want: nil,
+		},
+		"set add and remove": {
+			hp: &v1.HeadersPolicy{
+				Set:    []v1.HeaderValue{{Name: "x-client-ip", Value: "%DOWNSTREAM_REMOTE_ADDRESS_WITHOUT_PORT%"}},
+				Add:    []v1.HeaderValue{{Name: "x-sni", Value: "%REQUESTED_SERVER_NAME%"}},
+				Remove: []string{"x-internal"},
+			},
+			request: true,
+			want: &HeadersPolicy{
+				Set:    []HeaderValue{{Name: "x-client-ip", Value: "%DOWNSTREAM_REMOTE_ADDRESS_WITHOUT_PORT%"}},
+				Add:    []HeaderValue{{Name: "x-sni", Value: "%REQUESTED_SERVER_NAME%"}},
+				Remove: []string{"x-internal"},
+			},
+		},
+		"no header name": {
+			hp:      &v1.HeadersPolicy{Set: []v1.HeaderValue{{Value: "1"}}},
+			wantErr: true,
+		},
+		"invalid header name": {
+			hp:      &v1.HeadersPolicy{Add: []v1.HeaderValue{{Name: "x header", Value: "1"}}},
+			wantErr: true,
+		},
+		"pseudo header": {
+			hp:      &v1.HeadersPolicy{Remove: []string{":path"}},
+			wantErr: true,
+		},
+		"request host header": {
+			hp:      &v1.HeadersPolicy{Set: []v1.HeaderValue{{Name: "Host", Value: "example.com"}}},
+			request: true,
+			wantErr: true,
+		},
+		"response host header": {
+			hp: &v1.HeadersPolicy{Set: []v1.HeaderValue{{Name: "Host", Value: "example.com"}}},
+			want: &HeadersPolicy{
+				Set: []HeaderValue{{Name: "Host", Value: "example.com"}},
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := headersPolicy(tc.hp, tc.request)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("expected error %v, got %v", tc.wantErr, err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Fatal(diff)
+			}
+		})
+	}
+}
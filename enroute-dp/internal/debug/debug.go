@@ -32,6 +32,12 @@ type Service struct {
 	httpsvc.Service
 
 	KubernetesCache *dag.KubernetesCache
+
+	// XDSStatus, if set, serves the ACK/NACK state of xDS streams.
+	XDSStatus http.Handler
+
+	// LoadStats, if set, serves the load reported by Envoy.
+	LoadStats http.Handler
 }
 
 // Start fulfills the g.Start contract.
@@ -40,6 +46,8 @@ func (svc *Service) Start(stop <-chan struct{}) error {
 	registerProfile(&svc.ServeMux)
 	registerDotWriter(&svc.ServeMux, svc.KubernetesCache)
 	registerEnrouteLogger(&svc.ServeMux)
+	registerXDSStatus(&svc.ServeMux, svc.XDSStatus)
+	registerLoadStats(&svc.ServeMux, svc.LoadStats)
 	return svc.Service.Start(stop)
 }
 
@@ -68,3 +76,15 @@ func registerEnrouteLogger(mux *http.ServeMux) {
 	enrouteLogger := logger.EnrouteLogger{}
 	mux.Handle("/logging", &enrouteLogger)
 }
+
+func registerXDSStatus(mux *http.ServeMux, h http.Handler) {
+	if h != nil {
+		mux.Handle("/debug/xds", h)
+	}
+}
+
+func registerLoadStats(mux *http.ServeMux, h http.Handler) {
+	if h != nil {
+		mux.Handle("/debug/lrs", h)
+	}
+}
@@ -73,12 +73,11 @@ func TestClusterLongServiceName(t *testing.T) {
 
 	// check that it's been translated correctly.
 	assertEqual(t, &envoy_service_discovery_v3.DiscoveryResponse{
-		VersionInfo: "2",
 		Resources: resources(t,
 			cluster("default/kbujbkuh-c83ceb/8080/da39a3ee5e", "default/kbujbkuhdod66gjdmwmijz8xzgsx1nkfbrloezdjiulquzk4x3p0nnvpzi8r", "default_kbujbkuhdod66gjdmwmijz8xzgsx1nkfbrloezdjiulquzk4x3p0nnvpzi8r_8080"),
 		),
 		TypeUrl: clusterType,
-		Nonce:   "2",
+		Nonce:   "1",
 	}, streamCDS(t, cc))
 }
 
@@ -143,12 +142,11 @@ func TestClusterAddUpdateDelete(t *testing.T) {
 	rh.OnAdd(s1, false)
 
 	assertEqual(t, &envoy_service_discovery_v3.DiscoveryResponse{
-		VersionInfo: "3",
 		Resources: resources(t,
 			cluster("default/kuard/80/da39a3ee5e", "default/kuard", "default_kuard_80"),
 		),
 		TypeUrl: clusterType,
-		Nonce:   "3",
+		Nonce:   "1",
 	}, streamCDS(t, cc))
 
 	// s2 is the same as s2, but the service port has a name
@@ -164,12 +162,11 @@ func TestClusterAddUpdateDelete(t *testing.T) {
 
 	// check that we get two CDS records because the port is now named.
 	assertEqual(t, &envoy_service_discovery_v3.DiscoveryResponse{
-		VersionInfo: "4",
 		Resources: resources(t,
 			cluster("default/kuard/80/da39a3ee5e", "default/kuard/http", "default_kuard_80"),
 		),
 		TypeUrl: clusterType,
-		Nonce:   "4",
+		Nonce:   "2",
 	}, streamCDS(t, cc))
 
 	// s3 is like s2, but has a second named port. The k8s spec
@@ -195,13 +192,12 @@ func TestClusterAddUpdateDelete(t *testing.T) {
 	// check that we get four CDS records. Order is important
 	// because the CDS cache is sorted.
 	assertEqual(t, &envoy_service_discovery_v3.DiscoveryResponse{
-		VersionInfo: "5",
 		Resources: resources(t,
 			cluster("default/kuard/443/da39a3ee5e", "default/kuard/https", "default_kuard_443"),
 			cluster("default/kuard/80/da39a3ee5e", "default/kuard/http", "default_kuard_80"),
 		),
 		TypeUrl: clusterType,
-		Nonce:   "5",
+		Nonce:   "3",
 	}, streamCDS(t, cc))
 
 	// s4 is s3 with the http port removed.
@@ -220,12 +216,11 @@ func TestClusterAddUpdateDelete(t *testing.T) {
 	// check that we get two CDS records only, and that the 80 and http
 	// records have been removed even though the service object remains.
 	assertEqual(t, &envoy_service_discovery_v3.DiscoveryResponse{
-		VersionInfo: "6",
 		Resources: resources(t,
 			cluster("default/kuard/443/da39a3ee5e", "default/kuard/https", "default_kuard_443"),
 		),
 		TypeUrl: clusterType,
-		Nonce:   "6",
+		Nonce:   "4",
 	}, streamCDS(t, cc))
 }
 
@@ -288,13 +283,12 @@ func TestClusterRenameUpdateDelete(t *testing.T) {
 
 	rh.OnAdd(s1, false)
 	assertEqual(t, &envoy_service_discovery_v3.DiscoveryResponse{
-		VersionInfo: "2",
 		Resources: resources(t,
 			cluster("default/kuard/443/da39a3ee5e", "default/kuard/https", "default_kuard_443"),
 			cluster("default/kuard/80/da39a3ee5e", "default/kuard/http", "default_kuard_80"),
 		),
 		TypeUrl: clusterType,
-		Nonce:   "2",
+		Nonce:   "1",
 	}, streamCDS(t, cc))
 
 	// s2 removes the name on port 80, moves it to port 443 and deletes the https port
@@ -308,33 +302,30 @@ func TestClusterRenameUpdateDelete(t *testing.T) {
 
 	rh.OnUpdate(s1, s2)
 	assertEqual(t, &envoy_service_discovery_v3.DiscoveryResponse{
-		VersionInfo: "3",
 		Resources: resources(t,
 			cluster("default/kuard/443/da39a3ee5e", "default/kuard", "default_kuard_443"),
 		),
 		TypeUrl: clusterType,
-		Nonce:   "3",
+		Nonce:   "2",
 	}, streamCDS(t, cc))
 
 	// now replace s2 with s1 to check it works in the other direction.
 	rh.OnUpdate(s2, s1)
 	assertEqual(t, &envoy_service_discovery_v3.DiscoveryResponse{
-		VersionInfo: "4",
 		Resources: resources(t,
 			cluster("default/kuard/443/da39a3ee5e", "default/kuard/https", "default_kuard_443"),
 			cluster("default/kuard/80/da39a3ee5e", "default/kuard/http", "default_kuard_80"),
 		),
 		TypeUrl: clusterType,
-		Nonce:   "4",
+		Nonce:   "3",
 	}, streamCDS(t, cc))
 
 	// cleanup and check
 	rh.OnDelete(s1)
 	assertEqual(t, &envoy_service_discovery_v3.DiscoveryResponse{
-		VersionInfo: "5",
-		Resources:   resources(t),
-		TypeUrl:     clusterType,
-		Nonce:       "5",
+		Resources: resources(t),
+		TypeUrl:   clusterType,
+		Nonce:     "4",
 	}, streamCDS(t, cc))
 }
 
@@ -371,12 +362,11 @@ func TestIssue243(t *testing.T) {
 		)
 		rh.OnAdd(s1, false)
 		assertEqual(t, &envoy_service_discovery_v3.DiscoveryResponse{
-			VersionInfo: "2",
 			Resources: resources(t,
 				cluster("default/kuard/80/da39a3ee5e", "default/kuard", "default_kuard_80"),
 			),
 			TypeUrl: clusterType,
-			Nonce:   "2",
+			Nonce:   "1",
 		}, streamCDS(t, cc))
 	})
 }
@@ -418,12 +408,11 @@ func TestIssue247(t *testing.T) {
 	)
 	rh.OnAdd(s1, false)
 	assertEqual(t, &envoy_service_discovery_v3.DiscoveryResponse{
-		VersionInfo: "2",
 		Resources: resources(t,
 			cluster("default/kuard/80/da39a3ee5e", "default/kuard", "default_kuard_80"),
 		),
 		TypeUrl: clusterType,
-		Nonce:   "2",
+		Nonce:   "1",
 	}, streamCDS(t, cc))
 }
 func TestCDSResourceFiltering(t *testing.T) {
@@ -485,31 +474,28 @@ func TestCDSResourceFiltering(t *testing.T) {
 	)
 	rh.OnAdd(s2, false)
 	assertEqual(t, &envoy_service_discovery_v3.DiscoveryResponse{
-		VersionInfo: "3",
 		Resources: resources(t,
 			// note, resources are sorted by Cluster.Name
 			cluster("default/httpbin/8080/da39a3ee5e", "default/httpbin", "default_httpbin_8080"),
 			cluster("default/kuard/80/da39a3ee5e", "default/kuard", "default_kuard_80"),
 		),
 		TypeUrl: clusterType,
-		Nonce:   "3",
+		Nonce:   "2",
 	}, streamCDS(t, cc))
 
 	// assert we can filter on one resource
 	assertEqual(t, &envoy_service_discovery_v3.DiscoveryResponse{
-		VersionInfo: "3",
 		Resources: resources(t,
 			cluster("default/kuard/80/da39a3ee5e", "default/kuard", "default_kuard_80"),
 		),
 		TypeUrl: clusterType,
-		Nonce:   "3",
+		Nonce:   "2",
 	}, streamCDS(t, cc, "default/kuard/80/da39a3ee5e"))
 
 	// assert a non matching filter returns a response with no entries.
 	assertEqual(t, &envoy_service_discovery_v3.DiscoveryResponse{
-		VersionInfo: "3",
-		TypeUrl:     clusterType,
-		Nonce:       "3",
+		TypeUrl: clusterType,
+		Nonce:   "2",
 	}, streamCDS(t, cc, "default/httpbin/9000"))
 }
 
@@ -554,7 +540,6 @@ func TestClusterCircuitbreakerAnnotations(t *testing.T) {
 
 	// check that it's been translated correctly.
 	assertEqual(t, &envoy_service_discovery_v3.DiscoveryResponse{
-		VersionInfo: "2",
 		Resources: resources(t,
 			&envoy_config_cluster_v3.Cluster{
 				Name:                 "default/kuard/8080/da39a3ee5e",
@@ -579,7 +564,7 @@ func TestClusterCircuitbreakerAnnotations(t *testing.T) {
 			},
 		),
 		TypeUrl: clusterType,
-		Nonce:   "2",
+		Nonce:   "1",
 	}, streamCDS(t, cc))
 
 	// update s1 with slightly weird values
@@ -601,7 +586,6 @@ func TestClusterCircuitbreakerAnnotations(t *testing.T) {
 
 	// check that it's been translated correctly.
 	assertEqual(t, &envoy_service_discovery_v3.DiscoveryResponse{
-		VersionInfo: "3",
 		Resources: resources(t,
 			&envoy_config_cluster_v3.Cluster{
 				Name:                 "default/kuard/8080/da39a3ee5e",
@@ -623,7 +607,7 @@ func TestClusterCircuitbreakerAnnotations(t *testing.T) {
 			},
 		),
 		TypeUrl: clusterType,
-		Nonce:   "3",
+		Nonce:   "2",
 	}, streamCDS(t, cc))
 }
 
@@ -677,12 +661,11 @@ func TestClusterPerServiceParameters(t *testing.T) {
 	}, false)
 
 	assertEqual(t, &envoy_service_discovery_v3.DiscoveryResponse{
-		VersionInfo: "2",
 		Resources: resources(t,
 			cluster("default/kuard/80/da39a3ee5e", "default/kuard", "default_kuard_80"),
 		),
 		TypeUrl: clusterType,
-		Nonce:   "2",
+		Nonce:   "1",
 	}, streamCDS(t, cc))
 }
 
@@ -736,7 +719,6 @@ func TestClusterLoadBalancerStrategyPerRoute(t *testing.T) {
 	}, false)
 
 	assertEqual(t, &envoy_service_discovery_v3.DiscoveryResponse{
-		VersionInfo: "2",
 		Resources: resources(t,
 			&envoy_config_cluster_v3.Cluster{
 				Name:                 "default/kuard/80/58d888c08a",
@@ -766,7 +748,7 @@ func TestClusterLoadBalancerStrategyPerRoute(t *testing.T) {
 			},
 		),
 		TypeUrl: clusterType,
-		Nonce:   "2",
+		Nonce:   "1",
 	}, streamCDS(t, cc))
 
 }
@@ -813,12 +795,11 @@ func TestClusterWithHealthChecks(t *testing.T) {
 	}, false)
 
 	assertEqual(t, &envoy_service_discovery_v3.DiscoveryResponse{
-		VersionInfo: "2",
 		Resources: resources(t,
 			clusterWithHealthCheck("default/kuard/80/bc862a33ca", "default/kuard", "default_kuard_80", "/healthz", true),
 		),
 		TypeUrl: clusterType,
-		Nonce:   "2",
+		Nonce:   "1",
 	}, streamCDS(t, cc))
 }
 
@@ -866,12 +847,11 @@ func TestClusterServiceTLSBackend(t *testing.T) {
 	rh.OnAdd(s1, false)
 
 	assertEqual(t, &envoy_service_discovery_v3.DiscoveryResponse{
-		VersionInfo: "2",
 		Resources: resources(t,
 			tlscluster("default/kuard/443/da39a3ee5e", "default/kuard/securebackend", "default_kuard_443", nil, ""),
 		),
 		TypeUrl: clusterType,
-		Nonce:   "2",
+		Nonce:   "1",
 	}, streamCDS(t, cc))
 }
 
@@ -931,7 +911,6 @@ func TestClusterServiceTLSBackendCAValidation(t *testing.T) {
 	rh.OnAdd(ir1, false)
 
 	assertEqual(t, &envoy_service_discovery_v3.DiscoveryResponse{
-		VersionInfo: "3",
 		Resources: resources(t,
 			tlscluster(
 				"default/kuard/443/da39a3ee5e",
@@ -941,7 +920,7 @@ func TestClusterServiceTLSBackendCAValidation(t *testing.T) {
 				""),
 		),
 		TypeUrl: clusterType,
-		Nonce:   "3",
+		Nonce:   "1",
 	}, streamCDS(t, cc))
 
 	ir2 := &gatewayhostv1.GatewayHost{
@@ -970,7 +949,6 @@ func TestClusterServiceTLSBackendCAValidation(t *testing.T) {
 	rh.OnUpdate(ir1, ir2)
 
 	assertEqual(t, &envoy_service_discovery_v3.DiscoveryResponse{
-		VersionInfo: "4",
 		Resources: resources(t,
 			tlscluster(
 				"default/kuard/443/98c0f31c72",
@@ -980,7 +958,7 @@ func TestClusterServiceTLSBackendCAValidation(t *testing.T) {
 				"subjname"),
 		),
 		TypeUrl: clusterType,
-		Nonce:   "4",
+		Nonce:   "2",
 	}, streamCDS(t, cc))
 }
 
@@ -1016,12 +994,11 @@ func TestExternalNameService(t *testing.T) {
 	rh.OnAdd(s1, false)
 
 	assertEqual(t, &envoy_service_discovery_v3.DiscoveryResponse{
-		VersionInfo: "2",
 		Resources: resources(t,
 			externalnamecluster("default/kuard/80/da39a3ee5e", "default/kuard/", "default_kuard_80", "foo.io", 80),
 		),
 		TypeUrl: clusterType,
-		Nonce:   "2",
+		Nonce:   "1",
 	}, streamCDS(t, cc))
 }
 
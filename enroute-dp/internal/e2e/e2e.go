@@ -27,7 +27,6 @@ import (
 
 	resource "github.com/envoyproxy/go-control-plane/pkg/resource/v3"
 	"github.com/golang/protobuf/proto"
-	"github.com/golang/protobuf/ptypes"
 	"github.com/golang/protobuf/ptypes/any"
 
 	"github.com/saarasio/enroute/enroute-dp/internal/contour"
@@ -37,6 +36,7 @@ import (
 	cgrpc "github.com/saarasio/enroute/enroute-dp/internal/grpc"
 	"github.com/saarasio/enroute/enroute-dp/internal/k8s"
 	"github.com/saarasio/enroute/enroute-dp/internal/metrics"
+	"github.com/saarasio/enroute/enroute-dp/internal/protobuf"
 	"github.com/sirupsen/logrus"
 	"google.golang.org/grpc"
 	v1 "k8s.io/api/core/v1"
@@ -108,7 +108,7 @@ func setup(t *testing.T, opts ...func(*contour.ResourceEventHandler)) (cache.Res
 		ch.ListenerCache.TypeURL(): &ch.ListenerCache,
 		ch.SecretCache.TypeURL():   &ch.SecretCache,
 		et.TypeURL():               et,
-	})
+	}, nil, nil, nil)
 
 	done := make(chan error, 1)
 	go func() {
@@ -185,9 +185,9 @@ func resources(t *testing.T, protos ...proto.Message) []*any.Any {
 
 func toAny(t *testing.T, pb proto.Message) *any.Any {
 	t.Helper()
-	a, err := ptypes.MarshalAny(pb)
+	b, err := protobuf.Marshal(pb)
 	check(t, err)
-	return a
+	return &any.Any{TypeUrl: "type.googleapis.com/" + proto.MessageName(pb), Value: b}
 }
 
 type grpcStream interface {
@@ -256,8 +256,16 @@ func (r *Response) Equals(want *envoy_service_discovery_v3.DiscoveryResponse) {
 	assertEqual(r.T, want, r.DiscoveryResponse)
 }
 
+// assertEqual asserts that got equals want. The version of want is derived
+// from the content of its resources.
 func assertEqual(t *testing.T, want, got *envoy_service_discovery_v3.DiscoveryResponse) {
 	t.Helper()
+	want = proto.Clone(want).(*envoy_service_discovery_v3.DiscoveryResponse)
+	var values [][]byte
+	for _, r := range want.Resources {
+		values = append(values, r.Value)
+	}
+	want.VersionInfo = protobuf.Hash(values...)
 	m := proto.TextMarshaler{Compact: true, ExpandAny: true}
 	a := m.Text(want)
 	b := m.Text(got)
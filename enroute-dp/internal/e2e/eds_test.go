@@ -60,7 +60,6 @@ func TestAddRemoveEndpoints(t *testing.T) {
 
 	// check that it's been translated correctly.
 	assertEqual(t, &envoy_service_discovery_v3.DiscoveryResponse{
-		VersionInfo: "1",
 		Resources: resources(t,
 			envoy.ClusterLoadAssignment(
 				"super-long-namespace-name-oh-boy/what-a-descriptive-service-name-you-must-be-so-proud/http",
@@ -81,7 +80,6 @@ func TestAddRemoveEndpoints(t *testing.T) {
 	rh.OnDelete(e1)
 
 	assertEqual(t, &envoy_service_discovery_v3.DiscoveryResponse{
-		VersionInfo: "2",
 		Resources:   resources(t),
 		TypeUrl:     endpointType,
 		Nonce:       "2",
@@ -142,7 +140,6 @@ func TestAddEndpointComplicated(t *testing.T) {
 	rh.OnAdd(e1, false)
 
 	assertEqual(t, &envoy_service_discovery_v3.DiscoveryResponse{
-		VersionInfo: "1",
 		Resources: resources(t,
 			envoy.ClusterLoadAssignment(
 				"default/kuard/admin",
@@ -202,7 +199,6 @@ func TestEndpointFilter(t *testing.T) {
 	rh.OnAdd(e1, false)
 
 	assertEqual(t, &envoy_service_discovery_v3.DiscoveryResponse{
-		VersionInfo: "1",
 		Resources: resources(t,
 			envoy.ClusterLoadAssignment(
 				"default/kuard/foo",
@@ -215,7 +211,6 @@ func TestEndpointFilter(t *testing.T) {
 	}, streamEDS(t, cc, "default/kuard/foo"))
 
 	assertEqual(t, &envoy_service_discovery_v3.DiscoveryResponse{
-		VersionInfo: "1",
 		TypeUrl:     endpointType,
 		Resources: resources(t,
 			envoy.ClusterLoadAssignment(
@@ -244,7 +239,6 @@ func TestIssue602(t *testing.T) {
 
 	// Assert endpoint was added
 	assertEqual(t, &envoy_service_discovery_v3.DiscoveryResponse{
-		VersionInfo: "1",
 		Resources: resources(t,
 			envoy.ClusterLoadAssignment("default/simple", envoy.SocketAddress("192.168.183.24", 8080)),
 		),
@@ -257,7 +251,6 @@ func TestIssue602(t *testing.T) {
 	rh.OnUpdate(e1, e2)
 
 	assertEqual(t, &envoy_service_discovery_v3.DiscoveryResponse{
-		VersionInfo: "2",
 		Resources:   resources(t),
 		TypeUrl:     endpointType,
 		Nonce:       "2",
@@ -31,7 +31,6 @@ import (
 
 	"github.com/envoyproxy/go-control-plane/pkg/wellknown"
 	"github.com/saarasio/enroute/enroute-dp/apis/generated/clientset/versioned/fake"
-	"github.com/saarasio/enroute/enroute-dp/internal/assert"
 	"github.com/saarasio/enroute/enroute-dp/internal/contour"
 	"github.com/saarasio/enroute/enroute-dp/internal/dag"
 	"github.com/saarasio/enroute/enroute-dp/internal/envoy"
@@ -50,8 +49,7 @@ func TestNonTLSListener(t *testing.T) {
 
 	// assert that without any ingress objects registered
 	// there are no active listeners
-	assert.Equal(t, &envoy_service_discovery_v3.DiscoveryResponse{
-		VersionInfo: "0",
+	assertEqual(t, &envoy_service_discovery_v3.DiscoveryResponse{
 		Resources: resources(t,
 			staticListener(),
 		),
@@ -93,8 +91,7 @@ func TestNonTLSListener(t *testing.T) {
 
 	// add it and assert that we now have a ingress_http listener
 	rh.OnAdd(i1, false)
-	assert.Equal(t, &envoy_service_discovery_v3.DiscoveryResponse{
-		VersionInfo: "2",
+	assertEqual(t, &envoy_service_discovery_v3.DiscoveryResponse{
 		Resources: resources(t,
 			&envoy_config_listener_v3.Listener{
 				Name:         "ingress_http",
@@ -104,7 +101,7 @@ func TestNonTLSListener(t *testing.T) {
 			staticListener(),
 		),
 		TypeUrl: listenerType,
-		Nonce:   "2",
+		Nonce:   "1",
 	}, streamLDS(t, cc))
 
 	// i2 is the same as i1 but has the kubernetes.io/ingress.allow-http: "false" annotation
@@ -130,13 +127,12 @@ func TestNonTLSListener(t *testing.T) {
 
 	// update i1 to i2 and verify that ingress_http has gone.
 	rh.OnUpdate(i1, i2)
-	assert.Equal(t, &envoy_service_discovery_v3.DiscoveryResponse{
-		VersionInfo: "3",
+	assertEqual(t, &envoy_service_discovery_v3.DiscoveryResponse{
 		Resources: resources(t,
 			staticListener(),
 		),
 		TypeUrl: listenerType,
-		Nonce:   "3",
+		Nonce:   "2",
 	}, streamLDS(t, cc))
 
 	// i3 is similar to i2, but uses the ingress.kubernetes.io/force-ssl-redirect: "true" annotation
@@ -163,8 +159,7 @@ func TestNonTLSListener(t *testing.T) {
 
 	// update i2 to i3 and check that ingress_http has returned
 	rh.OnUpdate(i2, i3)
-	assert.Equal(t, &envoy_service_discovery_v3.DiscoveryResponse{
-		VersionInfo: "4",
+	assertEqual(t, &envoy_service_discovery_v3.DiscoveryResponse{
 		Resources: resources(t,
 			&envoy_config_listener_v3.Listener{
 				Name:         "ingress_http",
@@ -174,7 +169,7 @@ func TestNonTLSListener(t *testing.T) {
 			staticListener(),
 		),
 		TypeUrl: listenerType,
-		Nonce:   "4",
+		Nonce:   "3",
 	}, streamLDS(t, cc))
 }
 
@@ -235,19 +230,17 @@ func TestTLSListener(t *testing.T) {
 	rh.OnAdd(s1, false)
 
 	// assert that there is only a static listener
-	assert.Equal(t, &envoy_service_discovery_v3.DiscoveryResponse{
-		VersionInfo: "2",
+	assertEqual(t, &envoy_service_discovery_v3.DiscoveryResponse{
 		Resources: resources(t,
 			staticListener(),
 		),
 		TypeUrl: listenerType,
-		Nonce:   "2",
+		Nonce:   "0",
 	}, streamLDS(t, cc))
 
 	// add ingress and assert the existence of ingress_http and ingres_https
 	rh.OnAdd(i1, false)
-	assert.Equal(t, &envoy_service_discovery_v3.DiscoveryResponse{
-		VersionInfo: "3",
+	assertEqual(t, &envoy_service_discovery_v3.DiscoveryResponse{
 		Resources: resources(t,
 			&envoy_config_listener_v3.Listener{
 				Name:         "ingress_http",
@@ -265,7 +258,7 @@ func TestTLSListener(t *testing.T) {
 			staticListener(),
 		),
 		TypeUrl: listenerType,
-		Nonce:   "3",
+		Nonce:   "1",
 	}, streamLDS(t, cc))
 
 	// i2 is the same as i1 but has the kubernetes.io/ingress.allow-http: "false" annotation
@@ -295,8 +288,7 @@ func TestTLSListener(t *testing.T) {
 
 	// update i1 to i2 and verify that ingress_http has gone.
 	rh.OnUpdate(i1, i2)
-	assert.Equal(t, &envoy_service_discovery_v3.DiscoveryResponse{
-		VersionInfo: "4",
+	assertEqual(t, &envoy_service_discovery_v3.DiscoveryResponse{
 		Resources: resources(t,
 			&envoy_config_listener_v3.Listener{
 				Name:    "ingress_https",
@@ -309,18 +301,17 @@ func TestTLSListener(t *testing.T) {
 			staticListener(),
 		),
 		TypeUrl: listenerType,
-		Nonce:   "4",
+		Nonce:   "2",
 	}, streamLDS(t, cc))
 
 	// delete secret and assert that ingress_https is removed
 	rh.OnDelete(s1)
-	assert.Equal(t, &envoy_service_discovery_v3.DiscoveryResponse{
-		VersionInfo: "5",
+	assertEqual(t, &envoy_service_discovery_v3.DiscoveryResponse{
 		Resources: resources(t,
 			staticListener(),
 		),
 		TypeUrl: listenerType,
-		Nonce:   "5",
+		Nonce:   "3",
 	}, streamLDS(t, cc))
 }
 
@@ -411,13 +402,12 @@ func TestGatewayHostTLSListener(t *testing.T) {
 	rh.OnAdd(secret1, false)
 
 	// assert that there is only a static listener
-	assert.Equal(t, &envoy_service_discovery_v3.DiscoveryResponse{
-		VersionInfo: "1",
+	assertEqual(t, &envoy_service_discovery_v3.DiscoveryResponse{
 		Resources: resources(t,
 			staticListener(),
 		),
 		TypeUrl: listenerType,
-		Nonce:   "1",
+		Nonce:   "0",
 	}, streamLDS(t, cc))
 
 	l1 := &envoy_config_listener_v3.Listener{
@@ -435,8 +425,7 @@ func TestGatewayHostTLSListener(t *testing.T) {
 	// add ingress and assert the existence of ingress_http and ingres_https
 	rh.OnAdd(i1, false)
 
-	assert.Equal(t, &envoy_service_discovery_v3.DiscoveryResponse{
-		VersionInfo: "3",
+	assertEqual(t, &envoy_service_discovery_v3.DiscoveryResponse{
 		Resources: resources(t,
 			&envoy_config_listener_v3.Listener{
 				Name:         "ingress_http",
@@ -447,13 +436,12 @@ func TestGatewayHostTLSListener(t *testing.T) {
 			staticListener(),
 		),
 		TypeUrl: listenerType,
-		Nonce:   "3",
+		Nonce:   "1",
 	}, streamLDS(t, cc))
 
 	// delete secret and assert that ingress_https is removed
 	rh.OnDelete(secret1)
-	assert.Equal(t, &envoy_service_discovery_v3.DiscoveryResponse{
-		VersionInfo: "4",
+	assertEqual(t, &envoy_service_discovery_v3.DiscoveryResponse{
 		Resources: resources(t,
 			&envoy_config_listener_v3.Listener{
 				Name:         "ingress_http",
@@ -463,7 +451,7 @@ func TestGatewayHostTLSListener(t *testing.T) {
 			staticListener(),
 		),
 		TypeUrl: listenerType,
-		Nonce:   "4",
+		Nonce:   "2",
 	}, streamLDS(t, cc))
 
 	rh.OnDelete(i1)
@@ -490,8 +478,7 @@ func TestGatewayHostTLSListener(t *testing.T) {
 
 	// add ingress and assert the existence of ingress_http and ingres_https
 	rh.OnAdd(i2, false)
-	assert.Equal(t, &envoy_service_discovery_v3.DiscoveryResponse{
-		VersionInfo: "7",
+	assertEqual(t, &envoy_service_discovery_v3.DiscoveryResponse{
 		Resources: resources(t,
 			&envoy_config_listener_v3.Listener{
 				Name:         "ingress_http",
@@ -502,7 +489,7 @@ func TestGatewayHostTLSListener(t *testing.T) {
 			staticListener(),
 		),
 		TypeUrl: listenerType,
-		Nonce:   "7",
+		Nonce:   "4",
 	}, streamLDS(t, cc))
 }
 
@@ -564,8 +551,7 @@ func TestLDSFilter(t *testing.T) {
 
 	// add ingress and fetch ingress_https
 	rh.OnAdd(i1, false)
-	assert.Equal(t, &envoy_service_discovery_v3.DiscoveryResponse{
-		VersionInfo: "3",
+	assertEqual(t, &envoy_service_discovery_v3.DiscoveryResponse{
 		Resources: resources(t,
 			&envoy_config_listener_v3.Listener{
 				Name:    "ingress_https",
@@ -577,12 +563,11 @@ func TestLDSFilter(t *testing.T) {
 			},
 		),
 		TypeUrl: listenerType,
-		Nonce:   "3",
+		Nonce:   "1",
 	}, streamLDS(t, cc, "ingress_https"))
 
 	// fetch ingress_http
-	assert.Equal(t, &envoy_service_discovery_v3.DiscoveryResponse{
-		VersionInfo: "3",
+	assertEqual(t, &envoy_service_discovery_v3.DiscoveryResponse{
 		Resources: resources(t,
 			&envoy_config_listener_v3.Listener{
 				Name:         "ingress_http",
@@ -591,14 +576,13 @@ func TestLDSFilter(t *testing.T) {
 			},
 		),
 		TypeUrl: listenerType,
-		Nonce:   "3",
+		Nonce:   "1",
 	}, streamLDS(t, cc, "ingress_http"))
 
 	// fetch something non existent.
-	assert.Equal(t, &envoy_service_discovery_v3.DiscoveryResponse{
-		VersionInfo: "3",
-		TypeUrl:     listenerType,
-		Nonce:       "3",
+	assertEqual(t, &envoy_service_discovery_v3.DiscoveryResponse{
+		TypeUrl: listenerType,
+		Nonce:   "1",
 	}, streamLDS(t, cc, "HTTP"))
 }
 
@@ -607,10 +591,9 @@ func TestLDSStreamEmpty(t *testing.T) {
 	defer done()
 
 	// assert that streaming LDS with no ingresses does not stall.
-	assert.Equal(t, &envoy_service_discovery_v3.DiscoveryResponse{
-		VersionInfo: "0",
-		TypeUrl:     listenerType,
-		Nonce:       "0",
+	assertEqual(t, &envoy_service_discovery_v3.DiscoveryResponse{
+		TypeUrl: listenerType,
+		Nonce:   "0",
 	}, streamLDS(t, cc, "HTTP"))
 }
 
@@ -658,8 +641,7 @@ func TestLDSTLSMinimumProtocolVersion(t *testing.T) {
 
 	// add ingress and fetch ingress_https
 	rh.OnAdd(i1, false)
-	assert.Equal(t, &envoy_service_discovery_v3.DiscoveryResponse{
-		VersionInfo: "3",
+	assertEqual(t, &envoy_service_discovery_v3.DiscoveryResponse{
 		Resources: resources(t,
 			&envoy_config_listener_v3.Listener{
 				Name:    "ingress_https",
@@ -671,7 +653,7 @@ func TestLDSTLSMinimumProtocolVersion(t *testing.T) {
 			},
 		),
 		TypeUrl: listenerType,
-		Nonce:   "3",
+		Nonce:   "1",
 	}, streamLDS(t, cc, "ingress_https"))
 
 	i2 := &netv1.Ingress{
@@ -720,13 +702,12 @@ func TestLDSTLSMinimumProtocolVersion(t *testing.T) {
 		},
 	}
 
-	assert.Equal(t, &envoy_service_discovery_v3.DiscoveryResponse{
-		VersionInfo: "4",
+	assertEqual(t, &envoy_service_discovery_v3.DiscoveryResponse{
 		Resources: resources(t,
 			l1,
 		),
 		TypeUrl: listenerType,
-		Nonce:   "4",
+		Nonce:   "2",
 	}, streamLDS(t, cc, "ingress_https"))
 }
 
@@ -738,8 +719,7 @@ func TestLDSIngressHTTPUseProxyProtocol(t *testing.T) {
 
 	// assert that without any ingress objects registered
 	// there is only a static listener
-	assert.Equal(t, &envoy_service_discovery_v3.DiscoveryResponse{
-		VersionInfo: "0",
+	assertEqual(t, &envoy_service_discovery_v3.DiscoveryResponse{
 		Resources: resources(t,
 			staticListener(),
 		),
@@ -781,8 +761,7 @@ func TestLDSIngressHTTPUseProxyProtocol(t *testing.T) {
 	// add it and assert that we now have a ingress_http listener using
 	// the proxy protocol (the true param to filterchain)
 	rh.OnAdd(i1, false)
-	assert.Equal(t, &envoy_service_discovery_v3.DiscoveryResponse{
-		VersionInfo: "2",
+	assertEqual(t, &envoy_service_discovery_v3.DiscoveryResponse{
 		Resources: resources(t,
 			&envoy_config_listener_v3.Listener{
 				Name:    "ingress_http",
@@ -795,7 +774,7 @@ func TestLDSIngressHTTPUseProxyProtocol(t *testing.T) {
 			staticListener(),
 		),
 		TypeUrl: listenerType,
-		Nonce:   "2",
+		Nonce:   "1",
 	}, streamLDS(t, cc))
 }
 
@@ -844,13 +823,12 @@ func TestLDSIngressHTTPSUseProxyProtocol(t *testing.T) {
 	rh.OnAdd(s1, false)
 
 	// assert that there is only a static listener
-	assert.Equal(t, &envoy_service_discovery_v3.DiscoveryResponse{
-		VersionInfo: "1",
+	assertEqual(t, &envoy_service_discovery_v3.DiscoveryResponse{
 		Resources: resources(t,
 			staticListener(),
 		),
 		TypeUrl: listenerType,
-		Nonce:   "1",
+		Nonce:   "0",
 	}, streamLDS(t, cc))
 
 	rh.OnAdd(&corev1.Service{
@@ -880,8 +858,7 @@ func TestLDSIngressHTTPSUseProxyProtocol(t *testing.T) {
 		),
 		FilterChains: filterchaintls("kuard.example.com", s1, envoy.HTTPConnectionManager("ingress_https", "/dev/stdout", nil), "h2", "http/1.1"),
 	}
-	assert.Equal(t, &envoy_service_discovery_v3.DiscoveryResponse{
-		VersionInfo: "3",
+	assertEqual(t, &envoy_service_discovery_v3.DiscoveryResponse{
 		Resources: resources(t,
 			&envoy_config_listener_v3.Listener{
 				Name:    "ingress_http",
@@ -895,7 +872,7 @@ func TestLDSIngressHTTPSUseProxyProtocol(t *testing.T) {
 			staticListener(),
 		),
 		TypeUrl: listenerType,
-		Nonce:   "3",
+		Nonce:   "1",
 	}, streamLDS(t, cc))
 }
 
@@ -947,13 +924,12 @@ func TestLDSCustomAddressAndPort(t *testing.T) {
 	rh.OnAdd(s1, false)
 
 	// assert that there is only a static listener
-	assert.Equal(t, &envoy_service_discovery_v3.DiscoveryResponse{
-		VersionInfo: "1",
+	assertEqual(t, &envoy_service_discovery_v3.DiscoveryResponse{
 		Resources: resources(t,
 			staticListener(),
 		),
 		TypeUrl: listenerType,
-		Nonce:   "1",
+		Nonce:   "0",
 	}, streamLDS(t, cc))
 
 	rh.OnAdd(&corev1.Service{
@@ -987,15 +963,14 @@ func TestLDSCustomAddressAndPort(t *testing.T) {
 		),
 		FilterChains: filterchaintls("kuard.example.com", s1, envoy.HTTPConnectionManager("ingress_https", "/dev/stdout", nil), "h2", "http/1.1"),
 	}
-	assert.Equal(t, &envoy_service_discovery_v3.DiscoveryResponse{
-		VersionInfo: "3",
+	assertEqual(t, &envoy_service_discovery_v3.DiscoveryResponse{
 		Resources: resources(t,
 			ingress_http,
 			ingress_https,
 			staticListener(),
 		),
 		TypeUrl: listenerType,
-		Nonce:   "3",
+		Nonce:   "1",
 	}, streamLDS(t, cc))
 }
 
@@ -1059,13 +1034,12 @@ func TestLDSCustomAccessLogPaths(t *testing.T) {
 	rh.OnAdd(s1, false)
 
 	// assert that there is only a static listener
-	assert.Equal(t, &envoy_service_discovery_v3.DiscoveryResponse{
-		VersionInfo: "2",
+	assertEqual(t, &envoy_service_discovery_v3.DiscoveryResponse{
 		Resources: resources(t,
 			staticListener(),
 		),
 		TypeUrl: listenerType,
-		Nonce:   "2",
+		Nonce:   "0",
 	}, streamLDS(t, cc))
 
 	rh.OnAdd(i1, false)
@@ -1083,15 +1057,14 @@ func TestLDSCustomAccessLogPaths(t *testing.T) {
 		),
 		FilterChains: filterchaintls("kuard.example.com", s1, envoy.HTTPConnectionManager("ingress_https", "/tmp/https_access.log", nil), "h2", "http/1.1"),
 	}
-	assert.Equal(t, &envoy_service_discovery_v3.DiscoveryResponse{
-		VersionInfo: "3",
+	assertEqual(t, &envoy_service_discovery_v3.DiscoveryResponse{
 		Resources: resources(t,
 			ingress_http,
 			ingress_https,
 			staticListener(),
 		),
 		TypeUrl: listenerType,
-		Nonce:   "3",
+		Nonce:   "1",
 	}, streamLDS(t, cc))
 }
 
@@ -1105,8 +1078,7 @@ func TestLDSGatewayHostInsideRootNamespaces(t *testing.T) {
 	defer done()
 
 	// assert that there is only a static listener
-	assert.Equal(t, &envoy_service_discovery_v3.DiscoveryResponse{
-		VersionInfo: "0",
+	assertEqual(t, &envoy_service_discovery_v3.DiscoveryResponse{
 		Resources: resources(t,
 			staticListener(),
 		),
@@ -1153,8 +1125,7 @@ func TestLDSGatewayHostInsideRootNamespaces(t *testing.T) {
 	rh.OnAdd(ir1, false)
 
 	// assert there is an active listener
-	assert.Equal(t, &envoy_service_discovery_v3.DiscoveryResponse{
-		VersionInfo: "2",
+	assertEqual(t, &envoy_service_discovery_v3.DiscoveryResponse{
 		Resources: resources(t,
 			&envoy_config_listener_v3.Listener{
 				Name:         "ingress_http",
@@ -1164,7 +1135,7 @@ func TestLDSGatewayHostInsideRootNamespaces(t *testing.T) {
 			staticListener(),
 		),
 		TypeUrl: listenerType,
-		Nonce:   "2",
+		Nonce:   "1",
 	}, streamLDS(t, cc))
 }
 
@@ -1178,8 +1149,7 @@ func TestLDSGatewayHostOutsideRootNamespaces(t *testing.T) {
 	defer done()
 
 	// assert that there is only a static listener
-	assert.Equal(t, &envoy_service_discovery_v3.DiscoveryResponse{
-		VersionInfo: "0",
+	assertEqual(t, &envoy_service_discovery_v3.DiscoveryResponse{
 		Resources: resources(t,
 			staticListener(),
 		),
@@ -1211,13 +1181,12 @@ func TestLDSGatewayHostOutsideRootNamespaces(t *testing.T) {
 	rh.OnAdd(ir1, false)
 
 	// assert that there is only a static listener
-	assert.Equal(t, &envoy_service_discovery_v3.DiscoveryResponse{
-		VersionInfo: "1",
+	assertEqual(t, &envoy_service_discovery_v3.DiscoveryResponse{
 		Resources: resources(t,
 			staticListener(),
 		),
 		TypeUrl: listenerType,
-		Nonce:   "1",
+		Nonce:   "0",
 	}, streamLDS(t, cc))
 }
 
@@ -1231,8 +1200,7 @@ func TestGatewayHostHTTPS(t *testing.T) {
 	defer done()
 
 	// assert that there is only a static listener
-	assert.Equal(t, &envoy_service_discovery_v3.DiscoveryResponse{
-		VersionInfo: "0",
+	assertEqual(t, &envoy_service_discovery_v3.DiscoveryResponse{
 		Resources: resources(t,
 			staticListener(),
 		),
@@ -1315,15 +1283,14 @@ func TestGatewayHostHTTPS(t *testing.T) {
 		),
 		FilterChains: filterchaintls("example.com", s1, envoy.HTTPConnectionManager("ingress_https", "/dev/stdout", nil), "h2", "http/1.1"),
 	}
-	assert.Equal(t, &envoy_service_discovery_v3.DiscoveryResponse{
-		VersionInfo: "3",
+	assertEqual(t, &envoy_service_discovery_v3.DiscoveryResponse{
 		Resources: resources(t,
 			ingressHTTP,
 			ingressHTTPS,
 			staticListener(),
 		),
 		TypeUrl: listenerType,
-		Nonce:   "3",
+		Nonce:   "1",
 	}, streamLDS(t, cc))
 }
 
@@ -1387,14 +1354,13 @@ func TestLDSGatewayHostTCPProxyTLSPassthrough(t *testing.T) {
 		),
 	}
 
-	assert.Equal(t, &envoy_service_discovery_v3.DiscoveryResponse{
-		VersionInfo: "2",
+	assertEqual(t, &envoy_service_discovery_v3.DiscoveryResponse{
 		Resources: resources(t,
 			ingressHTTPS,
 			staticListener(),
 		),
 		TypeUrl: listenerType,
-		Nonce:   "2",
+		Nonce:   "1",
 	}, streamLDS(t, cc))
 }
 
@@ -1462,14 +1428,13 @@ func TestLDSGatewayHostTCPForward(t *testing.T) {
 		),
 	}
 
-	assert.Equal(t, &envoy_service_discovery_v3.DiscoveryResponse{
-		VersionInfo: "3",
+	assertEqual(t, &envoy_service_discovery_v3.DiscoveryResponse{
 		Resources: resources(t,
 			ingressHTTPS,
 			staticListener(),
 		),
 		TypeUrl: listenerType,
-		Nonce:   "3",
+		Nonce:   "1",
 	}, streamLDS(t, cc))
 }
 
@@ -1479,8 +1444,7 @@ func TestGatewayHostTLSCertificateDelegation(t *testing.T) {
 	defer done()
 
 	// assert that there is only a static listener
-	assert.Equal(t, &envoy_service_discovery_v3.DiscoveryResponse{
-		VersionInfo: "0",
+	assertEqual(t, &envoy_service_discovery_v3.DiscoveryResponse{
 		Resources: resources(t,
 			staticListener(),
 		),
@@ -1549,14 +1513,13 @@ func TestGatewayHostTLSCertificateDelegation(t *testing.T) {
 	}
 
 	// assert there is no ingress_https because there is no matching secret.
-	assert.Equal(t, &envoy_service_discovery_v3.DiscoveryResponse{
-		VersionInfo: "3",
+	assertEqual(t, &envoy_service_discovery_v3.DiscoveryResponse{
 		Resources: resources(t,
 			ingress_http,
 			staticListener(),
 		),
 		TypeUrl: listenerType,
-		Nonce:   "3",
+		Nonce:   "1",
 	}, streamLDS(t, cc))
 
 	// t1 is a TLSCertificateDelegation that permits default to access secret/wildcard
@@ -1585,15 +1548,14 @@ func TestGatewayHostTLSCertificateDelegation(t *testing.T) {
 		FilterChains: filterchaintls("example.com", s1, envoy.HTTPConnectionManager("ingress_https", "/dev/stdout", nil), "h2", "http/1.1"),
 	}
 
-	assert.Equal(t, &envoy_service_discovery_v3.DiscoveryResponse{
-		VersionInfo: "4",
+	assertEqual(t, &envoy_service_discovery_v3.DiscoveryResponse{
 		Resources: resources(t,
 			ingress_http,
 			ingress_https,
 			staticListener(),
 		),
 		TypeUrl: listenerType,
-		Nonce:   "4",
+		Nonce:   "2",
 	}, streamLDS(t, cc))
 
 	// t2 is a TLSCertificateDelegation that permits access to secret/wildcard from all namespaces.
@@ -1613,15 +1575,14 @@ func TestGatewayHostTLSCertificateDelegation(t *testing.T) {
 	}
 	rh.OnUpdate(t1, t2)
 
-	assert.Equal(t, &envoy_service_discovery_v3.DiscoveryResponse{
-		VersionInfo: "5",
+	assertEqual(t, &envoy_service_discovery_v3.DiscoveryResponse{
 		Resources: resources(t,
 			ingress_http,
 			ingress_https,
 			staticListener(),
 		),
 		TypeUrl: listenerType,
-		Nonce:   "5",
+		Nonce:   "2",
 	}, streamLDS(t, cc))
 
 	// t3 is a TLSCertificateDelegation that permits access to secret/different all namespaces.
@@ -1641,14 +1602,13 @@ func TestGatewayHostTLSCertificateDelegation(t *testing.T) {
 	}
 	rh.OnUpdate(t2, t3)
 
-	assert.Equal(t, &envoy_service_discovery_v3.DiscoveryResponse{
-		VersionInfo: "6",
+	assertEqual(t, &envoy_service_discovery_v3.DiscoveryResponse{
 		Resources: resources(t,
 			ingress_http,
 			staticListener(),
 		),
 		TypeUrl: listenerType,
-		Nonce:   "6",
+		Nonce:   "3",
 	}, streamLDS(t, cc))
 
 	// t4 is a TLSCertificateDelegation that permits access to secret/wildcard from the kube-secret namespace.
@@ -1668,14 +1628,13 @@ func TestGatewayHostTLSCertificateDelegation(t *testing.T) {
 	}
 	rh.OnUpdate(t3, t4)
 
-	assert.Equal(t, &envoy_service_discovery_v3.DiscoveryResponse{
-		VersionInfo: "7",
+	assertEqual(t, &envoy_service_discovery_v3.DiscoveryResponse{
 		Resources: resources(t,
 			ingress_http,
 			staticListener(),
 		),
 		TypeUrl: listenerType,
-		Nonce:   "7",
+		Nonce:   "3",
 	}, streamLDS(t, cc))
 
 }
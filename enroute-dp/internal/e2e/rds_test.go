@@ -109,7 +109,6 @@ func TestEditIngress(t *testing.T) {
 
 	// check that it's been translated correctly.
 	assertEqual(t, &envoy_service_discovery_v3.DiscoveryResponse{
-		VersionInfo: "2",
 		Resources: resources(t,
 			&envoy_config_route_v3.RouteConfiguration{
 				Name: "ingress_http",
@@ -157,7 +156,6 @@ func TestEditIngress(t *testing.T) {
 
 	// check that ingress_http has been updated.
 	assertEqual(t, &envoy_service_discovery_v3.DiscoveryResponse{
-		VersionInfo: "3",
 		Resources: resources(t,
 			&envoy_config_route_v3.RouteConfiguration{
 				Name: "ingress_http",
@@ -241,7 +239,6 @@ func TestIngressPathRouteWithoutHost(t *testing.T) {
 
 	// check that it's been translated correctly.
 	assertEqual(t, &envoy_service_discovery_v3.DiscoveryResponse{
-		VersionInfo: "2",
 		Resources: resources(t,
 			&envoy_config_route_v3.RouteConfiguration{
 				Name: "ingress_http",
@@ -326,7 +323,6 @@ func TestEditIngressInPlace(t *testing.T) {
 	rh.OnAdd(s2, false)
 
 	assertEqual(t, &envoy_service_discovery_v3.DiscoveryResponse{
-		VersionInfo: "3",
 		Resources: resources(t,
 			&envoy_config_route_v3.RouteConfiguration{
 				Name: "ingress_http",
@@ -345,7 +341,7 @@ func TestEditIngressInPlace(t *testing.T) {
 			},
 		),
 		TypeUrl: routeType,
-		Nonce:   "3",
+		Nonce:   "2",
 	}, streamRDS(t, cc))
 
 	// i2 is like i1 but adds a second route
@@ -384,7 +380,6 @@ func TestEditIngressInPlace(t *testing.T) {
 	}
 	rh.OnUpdate(i1, i2)
 	assertEqual(t, &envoy_service_discovery_v3.DiscoveryResponse{
-		VersionInfo: "4",
 		Resources: resources(t,
 			&envoy_config_route_v3.RouteConfiguration{
 				Name: "ingress_http",
@@ -407,7 +402,7 @@ func TestEditIngressInPlace(t *testing.T) {
 			},
 		),
 		TypeUrl: routeType,
-		Nonce:   "4",
+		Nonce:   "3",
 	}, streamRDS(t, cc))
 
 	// i3 is like i2, but adds the ingress.kubernetes.io/force-ssl-redirect: "true" annotation
@@ -451,7 +446,6 @@ func TestEditIngressInPlace(t *testing.T) {
 	}
 	rh.OnUpdate(i2, i3)
 	assertEqual(t, &envoy_service_discovery_v3.DiscoveryResponse{
-		VersionInfo: "5",
 		Resources: resources(t,
 			&envoy_config_route_v3.RouteConfiguration{
 				Name: "ingress_http",
@@ -470,7 +464,7 @@ func TestEditIngressInPlace(t *testing.T) {
 			&envoy_config_route_v3.RouteConfiguration{Name: "ingress_https"},
 		),
 		TypeUrl: routeType,
-		Nonce:   "5",
+		Nonce:   "4",
 	}, streamRDS(t, cc))
 
 	rh.OnAdd(&corev1.Secret{
@@ -531,7 +525,6 @@ func TestEditIngressInPlace(t *testing.T) {
 	}
 	rh.OnUpdate(i3, i4)
 	assertEqual(t, &envoy_service_discovery_v3.DiscoveryResponse{
-		VersionInfo: "7",
 		Resources: resources(t,
 			&envoy_config_route_v3.RouteConfiguration{
 				Name: "ingress_http",
@@ -565,7 +558,7 @@ func TestEditIngressInPlace(t *testing.T) {
 			},
 		),
 		TypeUrl: routeType,
-		Nonce:   "7",
+		Nonce:   "5",
 	}, streamRDS(t, cc))
 }
 
@@ -789,7 +782,7 @@ func TestSSLRedirectOverlay(t *testing.T) {
 		},
 	}, false)
 
-	assertRDS(t, cc, "5", []*envoy_config_route_v3.VirtualHost{{ // ingress_http
+	assertRDS(t, cc, "3", []*envoy_config_route_v3.VirtualHost{{ // ingress_http
 		Name:    "example.com",
 		Domains: domains("example.com"),
 		Routes: []*envoy_config_route_v3.Route{{
@@ -876,7 +869,7 @@ func TestInvalidCertInIngress(t *testing.T) {
 		},
 	}, false)
 
-	assertRDS(t, cc, "3", []*envoy_config_route_v3.VirtualHost{{ // ingress_http
+	assertRDS(t, cc, "2", []*envoy_config_route_v3.VirtualHost{{ // ingress_http
 		Name:    "kuard.io",
 		Domains: domains("kuard.io"),
 		Routes: []*envoy_config_route_v3.Route{{
@@ -899,7 +892,7 @@ func TestInvalidCertInIngress(t *testing.T) {
 		},
 	})
 
-	assertRDS(t, cc, "4", []*envoy_config_route_v3.VirtualHost{{ // ingress_http
+	assertRDS(t, cc, "3", []*envoy_config_route_v3.VirtualHost{{ // ingress_http
 		Name:    "kuard.io",
 		Domains: domains("kuard.io"),
 		Routes: []*envoy_config_route_v3.Route{{
@@ -1152,7 +1145,6 @@ func TestRDSFilter(t *testing.T) {
 	rh.OnAdd(s2, false)
 
 	assertEqual(t, &envoy_service_discovery_v3.DiscoveryResponse{
-		VersionInfo: "5",
 		Resources: resources(t,
 			&envoy_config_route_v3.RouteConfiguration{
 				Name: "ingress_http",
@@ -1171,11 +1163,10 @@ func TestRDSFilter(t *testing.T) {
 			},
 		),
 		TypeUrl: routeType,
-		Nonce:   "5",
+		Nonce:   "3",
 	}, streamRDS(t, cc, "ingress_http"))
 
 	assertEqual(t, &envoy_service_discovery_v3.DiscoveryResponse{
-		VersionInfo: "5",
 		Resources: resources(t,
 			&envoy_config_route_v3.RouteConfiguration{
 				Name: "ingress_https",
@@ -1195,7 +1186,7 @@ func TestRDSFilter(t *testing.T) {
 			},
 		),
 		TypeUrl: routeType,
-		Nonce:   "5",
+		Nonce:   "3",
 	}, streamRDS(t, cc, "ingress_https"))
 }
 
@@ -1497,7 +1488,6 @@ func TestDefaultBackendDoesNotOverwriteNamedHost(t *testing.T) {
 	}, false)
 
 	assertEqual(t, &envoy_service_discovery_v3.DiscoveryResponse{
-		VersionInfo: "3",
 		Resources: resources(t,
 			&envoy_config_route_v3.RouteConfiguration{
 				Name: "ingress_http",
@@ -1525,7 +1515,7 @@ func TestDefaultBackendDoesNotOverwriteNamedHost(t *testing.T) {
 			},
 		),
 		TypeUrl: routeType,
-		Nonce:   "3",
+		Nonce:   "2",
 	}, streamRDS(t, cc, "ingress_http"))
 }
 
@@ -1576,7 +1566,6 @@ func TestRDSGatewayHostInsideRootNamespaces(t *testing.T) {
 	rh.OnAdd(ir1, false)
 
 	assertEqual(t, &envoy_service_discovery_v3.DiscoveryResponse{
-		VersionInfo: "2",
 		Resources: resources(t,
 			&envoy_config_route_v3.RouteConfiguration{
 				Name: "ingress_http",
@@ -1643,13 +1632,12 @@ func TestRDSGatewayHostOutsideRootNamespaces(t *testing.T) {
 	rh.OnAdd(ir1, false)
 
 	assertEqual(t, &envoy_service_discovery_v3.DiscoveryResponse{
-		VersionInfo: "2",
 		Resources: resources(t,
 			&envoy_config_route_v3.RouteConfiguration{
 				Name: "ingress_http",
 			}),
 		TypeUrl: routeType,
-		Nonce:   "2",
+		Nonce:   "1",
 	}, streamRDS(t, cc, "ingress_http"))
 }
 
@@ -1829,7 +1817,7 @@ func TestRDSGatewayHostClassAnnotation(t *testing.T) {
 	}
 	rh.OnUpdate(ir4, ir5)
 
-	assertRDS(t, cc, "5", []*envoy_config_route_v3.VirtualHost{{
+	assertRDS(t, cc, "4", []*envoy_config_route_v3.VirtualHost{{
 		Name:    "www.example.com",
 		Domains: domains("www.example.com"),
 		Routes: []*envoy_config_route_v3.Route{{
@@ -1840,7 +1828,7 @@ func TestRDSGatewayHostClassAnnotation(t *testing.T) {
 	}}, nil)
 
 	rh.OnUpdate(ir5, ir3)
-	assertRDS(t, cc, "6", nil, nil)
+	assertRDS(t, cc, "5", nil, nil)
 }
 
 // Test DAGAdapter.IngressClass setting works, this could be done
@@ -1987,7 +1975,7 @@ func TestRDSIngressClassAnnotation(t *testing.T) {
 		},
 	}
 	rh.OnUpdate(i4, i5)
-	assertRDS(t, cc, "5", []*envoy_config_route_v3.VirtualHost{{
+	assertRDS(t, cc, "4", []*envoy_config_route_v3.VirtualHost{{
 		Name:    "*",
 		Domains: []string{"*"},
 		Routes: []*envoy_config_route_v3.Route{{
@@ -1998,7 +1986,7 @@ func TestRDSIngressClassAnnotation(t *testing.T) {
 	}}, nil)
 
 	rh.OnUpdate(i5, i3)
-	assertRDS(t, cc, "6", nil, nil)
+	assertRDS(t, cc, "5", nil, nil)
 }
 
 // issue 523, check for data races caused by accidentally
@@ -2284,7 +2272,6 @@ func TestRouteWithTLS(t *testing.T) {
 
 	// check that ingress_http has been updated.
 	assertEqual(t, &envoy_service_discovery_v3.DiscoveryResponse{
-		VersionInfo: "3",
 		Resources: resources(t,
 			&envoy_config_route_v3.RouteConfiguration{
 				Name: "ingress_http",
@@ -2309,7 +2296,7 @@ func TestRouteWithTLS(t *testing.T) {
 				}}},
 		),
 		TypeUrl: routeType,
-		Nonce:   "3",
+		Nonce:   "2",
 	}, streamRDS(t, cc))
 }
 func TestRouteWithTLS_InsecurePaths(t *testing.T) {
@@ -2392,7 +2379,6 @@ func TestRouteWithTLS_InsecurePaths(t *testing.T) {
 
 	// check that ingress_http has been updated.
 	assertEqual(t, &envoy_service_discovery_v3.DiscoveryResponse{
-		VersionInfo: "4",
 		Resources: resources(t,
 			&envoy_config_route_v3.RouteConfiguration{
 				Name: "ingress_http",
@@ -2429,7 +2415,7 @@ func TestRouteWithTLS_InsecurePaths(t *testing.T) {
 				}}},
 		),
 		TypeUrl: routeType,
-		Nonce:   "4",
+		Nonce:   "2",
 	}, streamRDS(t, cc))
 }
 
@@ -2912,7 +2898,7 @@ func TestLoadBalancingStrategies(t *testing.T) {
 			},
 		},
 	}}
-	assertRDS(t, cc, "5", want, nil)
+	assertRDS(t, cc, "2", want, nil)
 }
 
 func TestCorsFilter(t *testing.T) {
@@ -3018,7 +3004,7 @@ func TestCorsFilter(t *testing.T) {
 			},
 		},
 	}}
-	assertRDS(t, cc, "6", want, nil)
+	assertRDS(t, cc, "2", want, nil)
 }
 
 func CorsConfig(allowOrigin, allowMethods, allowHeaders, exposeHeaders, maxAge string) *envoy_config_route_v3.CorsPolicy {
@@ -3042,10 +3028,9 @@ func CorsConfig(allowOrigin, allowMethods, allowHeaders, exposeHeaders, maxAge s
 	}
 }
 
-func assertRDS(t *testing.T, cc *grpc.ClientConn, versioninfo string, ingress_http, ingress_https []*envoy_config_route_v3.VirtualHost) {
+func assertRDS(t *testing.T, cc *grpc.ClientConn, nonce string, ingress_http, ingress_https []*envoy_config_route_v3.VirtualHost) {
 	t.Helper()
 	assertEqual(t, &envoy_service_discovery_v3.DiscoveryResponse{
-		VersionInfo: versioninfo,
 		Resources: resources(t,
 			&envoy_config_route_v3.RouteConfiguration{
 				Name:         "ingress_http",
@@ -3057,7 +3042,7 @@ func assertRDS(t *testing.T, cc *grpc.ClientConn, versioninfo string, ingress_ht
 			},
 		),
 		TypeUrl: routeType,
-		Nonce:   versioninfo,
+		Nonce:   nonce,
 	}, streamRDS(t, cc))
 }
 
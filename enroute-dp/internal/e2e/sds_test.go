@@ -57,10 +57,9 @@ func TestSDSVisibility(t *testing.T) {
 	// assert that the secret is _not_ visible as it is
 	// not referenced by any ingress/gatewayhost
 	c.Request(secretType).Equals(&envoy_service_discovery_v3.DiscoveryResponse{
-		VersionInfo: "1",
-		Resources:   resources(t),
-		TypeUrl:     secretType,
-		Nonce:       "1",
+		Resources: resources(t),
+		TypeUrl:   secretType,
+		Nonce:     "0",
 	})
 
 	// i1 is a tls ingress
@@ -83,12 +82,11 @@ func TestSDSVisibility(t *testing.T) {
 	// have any valid routes.
 	// i1 has a default route to backend:80, but there is no matching service.
 	c.Request(secretType).Equals(&envoy_service_discovery_v3.DiscoveryResponse{
-		VersionInfo: "2",
 		Resources: resources(t,
 			secret(s1),
 		),
 		TypeUrl: secretType,
-		Nonce:   "2",
+		Nonce:   "1",
 	})
 }
 
@@ -133,24 +131,22 @@ func TestSDSShouldNotIncrementVersionNumberForUnrelatedSecret(t *testing.T) {
 	rh.OnAdd(i1, false)
 
 	c.Request(secretType).Equals(&envoy_service_discovery_v3.DiscoveryResponse{
-		VersionInfo: "2",
 		Resources: resources(t,
 			secret(s1),
 		),
 		TypeUrl: secretType,
-		Nonce:   "2",
+		Nonce:   "1",
 	})
 
 	// verify that requesting the same resource without change
 	// does not bump the current version_info.
 
 	c.Request(secretType).Equals(&envoy_service_discovery_v3.DiscoveryResponse{
-		VersionInfo: "2",
 		Resources: resources(t,
 			secret(s1),
 		),
 		TypeUrl: secretType,
-		Nonce:   "2",
+		Nonce:   "1",
 	})
 
 	// s2 is not referenced by any active ingress object.
@@ -172,7 +168,6 @@ func TestSDSShouldNotIncrementVersionNumberForUnrelatedSecret(t *testing.T) {
 	// TODO(dfc) 1166: currently Contour will rebuild all the xDS tables
 	// when an unrelated secret changes.
 	c.Request(secretType).Equals(&envoy_service_discovery_v3.DiscoveryResponse{
-		VersionInfo: "2",
 		Resources: resources(t,
 			secret(s1),
 		),
@@ -224,10 +219,9 @@ func TestSDSshouldNotPublishInvalidSecret(t *testing.T) {
 
 	// SDS should be empty
 	c.Request(secretType).Equals(&envoy_service_discovery_v3.DiscoveryResponse{
-		VersionInfo: "2",
-		Resources:   resources(t),
-		TypeUrl:     secretType,
-		Nonce:       "2",
+		Resources: resources(t),
+		TypeUrl:   secretType,
+		Nonce:     "0",
 	})
 }
 
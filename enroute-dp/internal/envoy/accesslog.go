@@ -17,9 +17,22 @@
 package envoy
 
 import (
+	"fmt"
+	"regexp"
+	"strings"
+
 	envoy_config_accesslog_v3 "github.com/envoyproxy/go-control-plane/envoy/config/accesslog/v3"
+	envoy_config_core_v3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	envoy_config_listener_v3 "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
+	envoy_config_route_v3 "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
 	envoy_extensions_access_loggers_file_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/access_loggers/file/v3"
+	envoy_extensions_access_loggers_grpc_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/access_loggers/grpc/v3"
+	envoy_extensions_filters_network_http_connection_manager_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
 	"github.com/envoyproxy/go-control-plane/pkg/wellknown"
+	"github.com/saarasio/enroute/enroute-dp/internal/dag"
+	"github.com/saarasio/enroute/enroute-dp/internal/logger"
+	cfg "github.com/saarasio/enroute/enroute-dp/saarasconfig"
+	structpb "google.golang.org/protobuf/types/known/structpb"
 )
 
 // FileAccessLog returns a new file based access log filter.
@@ -34,3 +47,328 @@ func FileAccessLog(path string) []*envoy_config_accesslog_v3.AccessLog {
 		},
 	}}
 }
+
+// defaultAccessLogCluster is the cluster of enroute in the bootstrap, the
+// gRPC access log service defaults to it.
+const defaultAccessLogCluster = "enroute"
+
+// defaultJsonAccessLogFormat is the format of a JSON access log that does
+// not define its fields.
+var defaultJsonAccessLogFormat = map[string]interface{}{
+	"start_time":            "%START_TIME%",
+	"method":                "%REQ(:METHOD)%",
+	"path":                  "%REQ(X-ENVOY-ORIGINAL-PATH?:PATH)%",
+	"protocol":              "%PROTOCOL%",
+	"response_code":         "%RESPONSE_CODE%",
+	"response_flags":        "%RESPONSE_FLAGS%",
+	"bytes_received":        "%BYTES_RECEIVED%",
+	"bytes_sent":            "%BYTES_SENT%",
+	"duration":              "%DURATION%",
+	"upstream_service_time": "%RESP(X-ENVOY-UPSTREAM-SERVICE-TIME)%",
+	"x_forwarded_for":       "%REQ(X-FORWARDED-FOR)%",
+	"user_agent":            "%REQ(USER-AGENT)%",
+	"request_id":            "%REQ(X-REQUEST-ID)%",
+	"authority":             "%REQ(:AUTHORITY)%",
+	"upstream_host":         "%UPSTREAM_HOST%",
+	"upstream_cluster":      "%UPSTREAM_CLUSTER%",
+	"route_name":            "%ROUTE_NAME%",
+}
+
+// AccessLog returns the access log configured by alc. Unless alc names
+// them, a file access log is written to path and a gRPC access log is sent
+// to cluster, or to enroute if cluster is nil.
+func AccessLog(alc cfg.AccessLogConfig, path string, cluster *dag.Cluster) (*envoy_config_accesslog_v3.AccessLog, error) {
+	filter, err := accessLogFilter(alc.Filter)
+	if err != nil {
+		return nil, err
+	}
+
+	switch alc.Sink {
+	case "", "file":
+		fal := &envoy_extensions_access_loggers_file_v3.FileAccessLog{
+			Path: path,
+		}
+		if alc.Path != "" {
+			fal.Path = alc.Path
+		}
+		format, err := accessLogFormat(alc)
+		if err != nil {
+			return nil, err
+		}
+		if format != nil {
+			fal.AccessLogFormat = &envoy_extensions_access_loggers_file_v3.FileAccessLog_LogFormat{
+				LogFormat: format,
+			}
+		}
+		return &envoy_config_accesslog_v3.AccessLog{
+			Name:   wellknown.FileAccessLog,
+			Filter: filter,
+			ConfigType: &envoy_config_accesslog_v3.AccessLog_TypedConfig{
+				TypedConfig: toAny(fal),
+			},
+		}, nil
+	case "grpc":
+		clustername := defaultAccessLogCluster
+		switch {
+		case alc.Cluster != "":
+			clustername = alc.Cluster
+		case cluster != nil:
+			clustername = Clustername(cluster)
+		}
+		logname := alc.LogName
+		if logname == "" {
+			logname = "enroute"
+		}
+		return &envoy_config_accesslog_v3.AccessLog{
+			Name:   wellknown.HTTPGRPCAccessLog,
+			Filter: filter,
+			ConfigType: &envoy_config_accesslog_v3.AccessLog_TypedConfig{
+				TypedConfig: toAny(&envoy_extensions_access_loggers_grpc_v3.HttpGrpcAccessLogConfig{
+					CommonConfig: &envoy_extensions_access_loggers_grpc_v3.CommonGrpcAccessLogConfig{
+						LogName: logname,
+						GrpcService: &envoy_config_core_v3.GrpcService{
+							TargetSpecifier: &envoy_config_core_v3.GrpcService_EnvoyGrpc_{
+								EnvoyGrpc: &envoy_config_core_v3.GrpcService_EnvoyGrpc{
+									ClusterName: clustername,
+								},
+							},
+						},
+						TransportApiVersion: envoy_config_core_v3.ApiVersion_V3,
+					},
+				}),
+			},
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported sink %q", alc.Sink)
+	}
+}
+
+// accessLogFormat returns the format of a file access log, nil for Envoy's
+// default format.
+func accessLogFormat(alc cfg.AccessLogConfig) (*envoy_config_core_v3.SubstitutionFormatString, error) {
+	switch alc.Format {
+	case "", "text":
+		if alc.TextFormat == "" {
+			return nil, nil
+		}
+		format := alc.TextFormat
+		if !strings.HasSuffix(format, "\n") {
+			format += "\n"
+		}
+		return &envoy_config_core_v3.SubstitutionFormatString{
+			Format: &envoy_config_core_v3.SubstitutionFormatString_TextFormatSource{
+				TextFormatSource: &envoy_config_core_v3.DataSource{
+					Specifier: &envoy_config_core_v3.DataSource_InlineString{InlineString: format},
+				},
+			},
+		}, nil
+	case "json":
+		fields := alc.JsonFormat
+		if len(fields) == 0 {
+			fields = defaultJsonAccessLogFormat
+		}
+		jsonformat, err := structpb.NewStruct(fields)
+		if err != nil {
+			return nil, err
+		}
+		return &envoy_config_core_v3.SubstitutionFormatString{
+			Format: &envoy_config_core_v3.SubstitutionFormatString_JsonFormat{
+				JsonFormat: jsonformat,
+			},
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported format %q", alc.Format)
+	}
+}
+
+// accessLogFilter returns a filter matching requests that match every
+// matcher of f, nil if f has none.
+func accessLogFilter(f cfg.AccessLogFilterConfig) (*envoy_config_accesslog_v3.AccessLogFilter, error) {
+	var filters []*envoy_config_accesslog_v3.AccessLogFilter
+
+	if f.StatusCode != nil {
+		c, err := comparisonFilter(f.StatusCode, "enroute.access_log.status_code")
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, &envoy_config_accesslog_v3.AccessLogFilter{
+			FilterSpecifier: &envoy_config_accesslog_v3.AccessLogFilter_StatusCodeFilter{
+				StatusCodeFilter: &envoy_config_accesslog_v3.StatusCodeFilter{Comparison: c},
+			},
+		})
+	}
+
+	if f.Duration != nil {
+		c, err := comparisonFilter(f.Duration, "enroute.access_log.duration")
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, &envoy_config_accesslog_v3.AccessLogFilter{
+			FilterSpecifier: &envoy_config_accesslog_v3.AccessLogFilter_DurationFilter{
+				DurationFilter: &envoy_config_accesslog_v3.DurationFilter{Comparison: c},
+			},
+		})
+	}
+
+	if f.NotHealthCheck {
+		filters = append(filters, &envoy_config_accesslog_v3.AccessLogFilter{
+			FilterSpecifier: &envoy_config_accesslog_v3.AccessLogFilter_NotHealthCheckFilter{
+				NotHealthCheckFilter: &envoy_config_accesslog_v3.NotHealthCheckFilter{},
+			},
+		})
+	}
+
+	for _, h := range f.Headers {
+		header, err := configHeaderMatcher(h)
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, headerAccessLogFilter(header))
+	}
+
+	return andAccessLogFilter(filters), nil
+}
+
+func comparisonFilter(c *cfg.AccessLogComparison, runtimeKey string) (*envoy_config_accesslog_v3.ComparisonFilter, error) {
+	var op envoy_config_accesslog_v3.ComparisonFilter_Op
+	switch strings.ToUpper(c.Op) {
+	case "", "EQ":
+		op = envoy_config_accesslog_v3.ComparisonFilter_EQ
+	case "GE":
+		op = envoy_config_accesslog_v3.ComparisonFilter_GE
+	case "LE":
+		op = envoy_config_accesslog_v3.ComparisonFilter_LE
+	default:
+		return nil, fmt.Errorf("unsupported op %q", c.Op)
+	}
+	return &envoy_config_accesslog_v3.ComparisonFilter{
+		Op: op,
+		Value: &envoy_config_core_v3.RuntimeUInt32{
+			DefaultValue: c.Value,
+			RuntimeKey:   runtimeKey,
+		},
+	}, nil
+}
+
+func headerAccessLogFilter(header *envoy_config_route_v3.HeaderMatcher) *envoy_config_accesslog_v3.AccessLogFilter {
+	return &envoy_config_accesslog_v3.AccessLogFilter{
+		FilterSpecifier: &envoy_config_accesslog_v3.AccessLogFilter_HeaderFilter{
+			HeaderFilter: &envoy_config_accesslog_v3.HeaderFilter{Header: header},
+		},
+	}
+}
+
+// andAccessLogFilter returns a filter matching requests that match every
+// one of filters.
+func andAccessLogFilter(filters []*envoy_config_accesslog_v3.AccessLogFilter) *envoy_config_accesslog_v3.AccessLogFilter {
+	switch len(filters) {
+	case 0:
+		return nil
+	case 1:
+		return filters[0]
+	default:
+		return &envoy_config_accesslog_v3.AccessLogFilter{
+			FilterSpecifier: &envoy_config_accesslog_v3.AccessLogFilter_AndFilter{
+				AndFilter: &envoy_config_accesslog_v3.AndFilter{Filters: filters},
+			},
+		}
+	}
+}
+
+// ListenerAccessLogs returns the access logs of the listener named
+// listener configured by configs, the configs of the access log
+// GlobalConfigs. Files are written to path unless a config names one.
+func ListenerAccessLogs(configs []string, listener, path string) []*envoy_config_accesslog_v3.AccessLog {
+	var logs []*envoy_config_accesslog_v3.AccessLog
+	for _, config := range configs {
+		alc, err := cfg.UnmarshalAccessLogConfig(config)
+		if err == nil && alc.Listener != "" && alc.Listener != listener {
+			continue
+		}
+		var al *envoy_config_accesslog_v3.AccessLog
+		if err == nil {
+			al, err = AccessLog(alc, path, nil)
+		}
+		if err != nil {
+			if logger.EL.ELogger != nil {
+				logger.EL.ELogger.Errorf("internal:envoy:accesslog:ListenerAccessLogs() Listener [%s] invalid access log config [%s]\n",
+					listener, err)
+			}
+			continue
+		}
+		logs = append(logs, al)
+	}
+	return logs
+}
+
+// SetAccessLogs replaces the access logs of each HTTP connection manager
+// of l with logs.
+func SetAccessLogs(l *envoy_config_listener_v3.Listener, logs []*envoy_config_accesslog_v3.AccessLog) {
+	updateHttpConnectionManagers(l, "", func(hcm *envoy_extensions_filters_network_http_connection_manager_v3.HttpConnectionManager, _ bool) {
+		hcm.AccessLog = logs
+	})
+}
+
+// AddVirtualHostAccessLogs adds the access logs of the access log filters of
+// vh to the HTTP connection manager of l serving vh. When vh shares it with
+// other virtual hosts, they only log the requests for vh. Files are written
+// to path unless a filter names one.
+func AddVirtualHostAccessLogs(l *envoy_config_listener_v3.Listener, vh *dag.VirtualHost, path string) {
+	var filters []*dag.HttpFilter
+	for _, hf := range vh.HttpFilters {
+		if hf.Filter_type == cfg.FILTER_TYPE_HTTP_ACCESSLOG {
+			filters = append(filters, hf)
+		}
+	}
+	if len(filters) == 0 {
+		return
+	}
+
+	updateHttpConnectionManagers(l, vh.Name, func(hcm *envoy_extensions_filters_network_http_connection_manager_v3.HttpConnectionManager, sni bool) {
+		for _, hf := range filters {
+			alc, err := cfg.UnmarshalAccessLogConfig(hf.Filter_config)
+			var al *envoy_config_accesslog_v3.AccessLog
+			if err == nil {
+				al, err = AccessLog(alc, path, hf.Cluster)
+			}
+			if err != nil {
+				if logger.EL.ELogger != nil {
+					logger.EL.ELogger.Errorf("internal:envoy:accesslog:AddVirtualHostAccessLogs() Filter [%s] invalid access log config [%s]\n",
+						hf.Filter_name, err)
+				}
+				continue
+			}
+			if !sni && vh.Name != "*" {
+				al.Filter = andAccessLogFilter(append(
+					[]*envoy_config_accesslog_v3.AccessLogFilter{authorityAccessLogFilter(vh.Name)},
+					accessLogFilters(al.Filter)...))
+			}
+			hcm.AccessLog = append(hcm.AccessLog, al)
+		}
+	})
+}
+
+func accessLogFilters(f *envoy_config_accesslog_v3.AccessLogFilter) []*envoy_config_accesslog_v3.AccessLogFilter {
+	if f == nil {
+		return nil
+	}
+	if and := f.GetAndFilter(); and != nil {
+		return and.Filters
+	}
+	return []*envoy_config_accesslog_v3.AccessLogFilter{f}
+}
+
+// authorityAccessLogFilter matches requests for the virtual host named
+// name, with or without a port.
+func authorityAccessLogFilter(name string) *envoy_config_accesslog_v3.AccessLogFilter {
+	host := regexp.QuoteMeta(name)
+	if strings.HasPrefix(name, "*.") {
+		host = ".+" + regexp.QuoteMeta(name[1:])
+	}
+	return headerAccessLogFilter(&envoy_config_route_v3.HeaderMatcher{
+		Name: ":authority",
+		HeaderMatchSpecifier: &envoy_config_route_v3.HeaderMatcher_SafeRegexMatch{
+			SafeRegexMatch: SafeRegexMatch("^" + host + "(:[0-9]+)?$"),
+		},
+	})
+}
@@ -20,10 +20,20 @@ import (
 	"testing"
 
 	envoy_config_accesslog_v3 "github.com/envoyproxy/go-control-plane/envoy/config/accesslog/v3"
+	envoy_config_core_v3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	envoy_config_listener_v3 "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
+	envoy_config_route_v3 "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
 	envoy_extensions_access_loggers_file_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/access_loggers/file/v3"
+	envoy_extensions_access_loggers_grpc_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/access_loggers/grpc/v3"
+	envoy_extensions_filters_network_http_connection_manager_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
 	"github.com/envoyproxy/go-control-plane/pkg/wellknown"
+	types "github.com/golang/protobuf/ptypes"
 	"github.com/google/go-cmp/cmp"
+	"github.com/saarasio/enroute/enroute-dp/internal/dag"
+	cfg "github.com/saarasio/enroute/enroute-dp/saarasconfig"
 	"google.golang.org/protobuf/testing/protocmp"
+	structpb "google.golang.org/protobuf/types/known/structpb"
+	v1 "k8s.io/api/core/v1"
 )
 
 func TestFileAccessLog(t *testing.T) {
@@ -52,3 +62,204 @@ func TestFileAccessLog(t *testing.T) {
 		})
 	}
 }
+
+func fileAccessLog(path string, format *envoy_config_core_v3.SubstitutionFormatString, filter *envoy_config_accesslog_v3.AccessLogFilter) *envoy_config_accesslog_v3.AccessLog {
+	fal := &envoy_extensions_access_loggers_file_v3.FileAccessLog{
+		Path: path,
+	}
+	if format != nil {
+		fal.AccessLogFormat = &envoy_extensions_access_loggers_file_v3.FileAccessLog_LogFormat{
+			LogFormat: format,
+		}
+	}
+	return &envoy_config_accesslog_v3.AccessLog{
+		Name:   wellknown.FileAccessLog,
+		Filter: filter,
+		ConfigType: &envoy_config_accesslog_v3.AccessLog_TypedConfig{
+			TypedConfig: toAny(fal),
+		},
+	}
+}
+
+func TestAccessLog(t *testing.T) {
+	collector := &dag.Cluster{
+		Upstream: &dag.HTTPService{
+			TCPService: dag.TCPService{
+				Name:        "collector",
+				Namespace:   "default",
+				ServicePort: &v1.ServicePort{Port: 9001},
+			},
+		},
+	}
+	jsonFormat, _ := structpb.NewStruct(map[string]interface{}{
+		"code": "%RESPONSE_CODE%",
+		"path": "%REQ(:PATH)%",
+	})
+	grpcAccessLog := func(logname, cluster string) *envoy_config_accesslog_v3.AccessLog {
+		return &envoy_config_accesslog_v3.AccessLog{
+			Name: wellknown.HTTPGRPCAccessLog,
+			ConfigType: &envoy_config_accesslog_v3.AccessLog_TypedConfig{
+				TypedConfig: toAny(&envoy_extensions_access_loggers_grpc_v3.HttpGrpcAccessLogConfig{
+					CommonConfig: &envoy_extensions_access_loggers_grpc_v3.CommonGrpcAccessLogConfig{
+						LogName: logname,
+						GrpcService: &envoy_config_core_v3.GrpcService{
+							TargetSpecifier: &envoy_config_core_v3.GrpcService_EnvoyGrpc_{
+								EnvoyGrpc: &envoy_config_core_v3.GrpcService_EnvoyGrpc{
+									ClusterName: cluster,
+								},
+							},
+						},
+						TransportApiVersion: envoy_config_core_v3.ApiVersion_V3,
+					},
+				}),
+			},
+		}
+	}
+
+	tests := map[string]struct {
+		config  string
+		cluster *dag.Cluster
+		want    *envoy_config_accesslog_v3.AccessLog
+		wantErr bool
+	}{
+		"default": {
+			config: `{}`,
+			want:   fileAccessLog("/dev/stdout", nil, nil),
+		},
+		"text format": {
+			config: `{"path":"/var/log/envoy.log","text_format":"%RESPONSE_CODE% %REQ(:PATH)%"}`,
+			want: fileAccessLog("/var/log/envoy.log", &envoy_config_core_v3.SubstitutionFormatString{
+				Format: &envoy_config_core_v3.SubstitutionFormatString_TextFormatSource{
+					TextFormatSource: &envoy_config_core_v3.DataSource{
+						Specifier: &envoy_config_core_v3.DataSource_InlineString{InlineString: "%RESPONSE_CODE% %REQ(:PATH)%\n"},
+					},
+				},
+			}, nil),
+		},
+		"json format with filters": {
+			config: `{"format":"json","json_format":{"code":"%RESPONSE_CODE%","path":"%REQ(:PATH)%"},
+				"filter":{"status_code":{"op":"ge","value":400},"not_health_check":true}}`,
+			want: fileAccessLog("/dev/stdout", &envoy_config_core_v3.SubstitutionFormatString{
+				Format: &envoy_config_core_v3.SubstitutionFormatString_JsonFormat{
+					JsonFormat: jsonFormat,
+				},
+			}, &envoy_config_accesslog_v3.AccessLogFilter{
+				FilterSpecifier: &envoy_config_accesslog_v3.AccessLogFilter_AndFilter{
+					AndFilter: &envoy_config_accesslog_v3.AndFilter{
+						Filters: []*envoy_config_accesslog_v3.AccessLogFilter{{
+							FilterSpecifier: &envoy_config_accesslog_v3.AccessLogFilter_StatusCodeFilter{
+								StatusCodeFilter: &envoy_config_accesslog_v3.StatusCodeFilter{
+									Comparison: &envoy_config_accesslog_v3.ComparisonFilter{
+										Op: envoy_config_accesslog_v3.ComparisonFilter_GE,
+										Value: &envoy_config_core_v3.RuntimeUInt32{
+											DefaultValue: 400,
+											RuntimeKey:   "enroute.access_log.status_code",
+										},
+									},
+								},
+							},
+						}, {
+							FilterSpecifier: &envoy_config_accesslog_v3.AccessLogFilter_NotHealthCheckFilter{
+								NotHealthCheckFilter: &envoy_config_accesslog_v3.NotHealthCheckFilter{},
+							},
+						}},
+					},
+				},
+			}),
+		},
+		"grpc to enroute": {
+			config: `{"sink":"grpc"}`,
+			want:   grpcAccessLog("enroute", "enroute"),
+		},
+		"grpc to the service of the filter": {
+			config:  `{"sink":"grpc","log_name":"www"}`,
+			cluster: collector,
+			want:    grpcAccessLog("www", "default/collector/9001/da39a3ee5e"),
+		},
+		"unsupported sink": {
+			config:  `{"sink":"syslog"}`,
+			wantErr: true,
+		},
+		"unsupported op": {
+			config:  `{"filter":{"duration":{"op":"gt","value":100}}}`,
+			wantErr: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			alc, err := cfg.UnmarshalAccessLogConfig(tc.config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := AccessLog(alc, "/dev/stdout", tc.cluster)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("expected error: %v, got: %v", tc.wantErr, err)
+			}
+			if diff := cmp.Diff(tc.want, got, protocmp.Transform()); diff != "" {
+				t.Fatal(diff)
+			}
+		})
+	}
+}
+
+func TestAddVirtualHostAccessLogs(t *testing.T) {
+	accessLogs := func(l *envoy_config_listener_v3.Listener) []*envoy_config_accesslog_v3.AccessLog {
+		hcm := &envoy_extensions_filters_network_http_connection_manager_v3.HttpConnectionManager{}
+		if err := types.UnmarshalAny(l.FilterChains[0].Filters[0].GetTypedConfig(), hcm); err != nil {
+			t.Fatal(err)
+		}
+		return hcm.AccessLog
+	}
+	vh := &dag.VirtualHost{
+		Name: "www.example.com",
+		HttpFilters: []*dag.HttpFilter{{
+			Filter: dag.Filter{
+				Filter_name:   "accesslog",
+				Filter_type:   cfg.FILTER_TYPE_HTTP_ACCESSLOG,
+				Filter_config: `{"path":"/var/log/www.log"}`,
+			},
+		}},
+	}
+
+	tests := map[string]struct {
+		listener *envoy_config_listener_v3.Listener
+		want     []*envoy_config_accesslog_v3.AccessLog
+	}{
+		"shared listener": {
+			listener: Listener("ingress_http", "0.0.0.0", 8080, nil, HTTPConnectionManager("ingress_http", "/dev/stdout", nil)),
+			want: []*envoy_config_accesslog_v3.AccessLog{
+				fileAccessLog("/dev/stdout", nil, nil),
+				fileAccessLog("/var/log/www.log", nil, headerAccessLogFilter(&envoy_config_route_v3.HeaderMatcher{
+					Name: ":authority",
+					HeaderMatchSpecifier: &envoy_config_route_v3.HeaderMatcher_SafeRegexMatch{
+						SafeRegexMatch: SafeRegexMatch(`^www\.example\.com(:[0-9]+)?$`),
+					},
+				})),
+			},
+		},
+		"filter chain of the virtual host": {
+			listener: &envoy_config_listener_v3.Listener{
+				FilterChains: []*envoy_config_listener_v3.FilterChain{{
+					FilterChainMatch: &envoy_config_listener_v3.FilterChainMatch{
+						ServerNames: []string{"www.example.com"},
+					},
+					Filters: Filters(HTTPConnectionManager("ingress_https", "/dev/stdout", nil)),
+				}},
+			},
+			want: []*envoy_config_accesslog_v3.AccessLog{
+				fileAccessLog("/dev/stdout", nil, nil),
+				fileAccessLog("/var/log/www.log", nil, nil),
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			AddVirtualHostAccessLogs(tc.listener, vh, "/dev/stdout")
+			if diff := cmp.Diff(tc.want, accessLogs(tc.listener), protocmp.Transform()); diff != "" {
+				t.Fatal(diff)
+			}
+		})
+	}
+}
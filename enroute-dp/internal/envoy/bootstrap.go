@@ -32,6 +32,15 @@ import (
 
 // Bootstrap creates a new v2 Bootstrap configuration.
 func Bootstrap(c *BootstrapConfig) *bootstrap.Bootstrap {
+	xdsType := envoy_config_cluster_v3.Cluster_STRICT_DNS
+	xdsAddress := SocketAddress(stringOrDefault(c.XDSAddress, "127.0.0.1"), intOrDefault(c.XDSGRPCPort, 8001))
+	if c.XDSUnixSocket != "" {
+		// pipe addresses are not resolved, only static clusters
+		// may refer to them.
+		xdsType = envoy_config_cluster_v3.Cluster_STATIC
+		xdsAddress = PipeAddress(c.XDSUnixSocket)
+	}
+
 	b := &bootstrap.Bootstrap{
 		DynamicResources: &bootstrap.Bootstrap_DynamicResources{
 			LdsConfig: ConfigSource("enroute"),
@@ -42,13 +51,11 @@ func Bootstrap(c *BootstrapConfig) *bootstrap.Bootstrap {
 				Name:                 "enroute",
 				AltStatName:          strings.Join([]string{c.Namespace, "enroute", strconv.Itoa(intOrDefault(c.XDSGRPCPort, 8001))}, "_"),
 				ConnectTimeout:       protobuf.Duration(5 * time.Second),
-				ClusterDiscoveryType: ClusterDiscoveryType(envoy_config_cluster_v3.Cluster_STRICT_DNS),
+				ClusterDiscoveryType: ClusterDiscoveryType(xdsType),
 				LbPolicy:             envoy_config_cluster_v3.Cluster_ROUND_ROBIN,
 				LoadAssignment: &envoy_config_endpoint_v3.ClusterLoadAssignment{
 					ClusterName: "enroute",
-					Endpoints: Endpoints(
-						SocketAddress(stringOrDefault(c.XDSAddress, "127.0.0.1"), intOrDefault(c.XDSGRPCPort, 8001)),
-					),
+					Endpoints:   Endpoints(xdsAddress),
 				},
 				Http2ProtocolOptions: new(envoy_config_core_v3.Http2ProtocolOptions), // enables http2
 				CircuitBreakers: &envoy_config_cluster_v3.CircuitBreakers{
@@ -116,6 +123,26 @@ func Bootstrap(c *BootstrapConfig) *bootstrap.Bootstrap {
 		},
 	}
 
+	if c.ADS {
+		b.DynamicResources = &bootstrap.Bootstrap_DynamicResources{
+			AdsConfig: enrouteAPIConfigSource(),
+			LdsConfig: ADSConfigSource(),
+			CdsConfig: ADSConfigSource(),
+		}
+	}
+
+	if c.LoadReporting {
+		b.ClusterManager = &bootstrap.ClusterManager{
+			LoadStatsConfig: enrouteAPIConfigSource(),
+		}
+	}
+
+	if c.TraceCollectorAddress != "" {
+		b.StaticResources.Clusters = append(b.StaticResources.Clusters, tracingCollectorCluster(
+			BootstrapTracingCluster, c.TraceCollectorAddress, intOrDefault(c.TraceCollectorPort, 9411), c.TraceCollectorGRPC,
+		))
+	}
+
 	if c.GrpcClientCert != "" || c.GrpcClientKey != "" || c.GrpcCABundle != "" {
 		// If one of the two TLS options is not empty, they all must be not empty
 		if !(c.GrpcClientCert != "" && c.GrpcClientKey != "" && c.GrpcCABundle != "") {
@@ -190,6 +217,22 @@ func intOrDefault(i, def int) int {
 	return i
 }
 
+// enrouteAPIConfigSource returns an ApiConfigSource for the gRPC services
+// of the enroute cluster.
+func enrouteAPIConfigSource() *envoy_config_core_v3.ApiConfigSource {
+	return &envoy_config_core_v3.ApiConfigSource{
+		ApiType: envoy_config_core_v3.ApiConfigSource_GRPC,
+		GrpcServices: []*envoy_config_core_v3.GrpcService{{
+			TargetSpecifier: &envoy_config_core_v3.GrpcService_EnvoyGrpc_{
+				EnvoyGrpc: &envoy_config_core_v3.GrpcService_EnvoyGrpc{
+					ClusterName: "enroute",
+				},
+			},
+		}},
+		TransportApiVersion: envoy_config_core_v3.ApiVersion_V3,
+	}
+}
+
 // BootstrapConfig holds configuration values for a v2.Bootstrap.
 type BootstrapConfig struct {
 	// AdminAccessLogPath is the path to write the access log for the administration server.
@@ -212,10 +255,36 @@ type BootstrapConfig struct {
 	// Defaults to 8001.
 	XDSGRPCPort int
 
+	// XDSUnixSocket is the path of the Unix domain socket of the gRPC XDS
+	// management server. If set, XDSAddress and XDSGRPCPort are ignored.
+	XDSUnixSocket string
+
 	RLAddress string
 
 	RLPort int
 
+	// ADS configures Envoy to fetch all resources over a single
+	// Aggregated Discovery Service stream rather than one stream per
+	// resource type.
+	ADS bool
+
+	// LoadReporting configures Envoy to report the load of its upstream
+	// clusters to enroute over the Load Reporting Service.
+	LoadReporting bool
+
+	// TraceCollectorAddress, if set, adds a cluster for the trace collector
+	// at this address that tracing GlobalConfigs without an address send
+	// spans to.
+	TraceCollectorAddress string
+
+	// TraceCollectorPort is the port of the trace collector.
+	// Defaults to 9411.
+	TraceCollectorPort int
+
+	// TraceCollectorGRPC reaches the trace collector over gRPC, as
+	// OpenTelemetry collectors are.
+	TraceCollectorGRPC bool
+
 	// Namespace is the namespace where Contour is running
 	Namespace string
 
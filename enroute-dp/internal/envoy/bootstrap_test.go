@@ -187,6 +187,156 @@ func TestBootstrap(t *testing.T) {
       }
     }
   }
+}`,
+		},
+		"--ads": {
+			config: BootstrapConfig{Namespace: "testing-ns", ADS: true},
+			want: `{
+  "static_resources": {
+    "clusters": [
+      {
+        "name": "enroute",
+        "alt_stat_name": "testing-ns_enroute_8001",
+        "type": "STRICT_DNS",
+        "connect_timeout": "5s",
+        "load_assignment": {
+          "cluster_name": "enroute",
+          "endpoints": [
+            {
+              "lb_endpoints": [
+                {
+                  "endpoint": {
+                    "address": {
+                      "socket_address": {
+                        "address": "127.0.0.1",
+                        "port_value": 8001
+                      }
+                    }
+                  }
+                }
+              ]
+            }
+          ]
+        },
+        "circuit_breakers": {
+          "thresholds": [
+            {
+              "priority": "HIGH",
+              "max_connections": 100000,
+              "max_pending_requests": 100000,
+              "max_requests": 60000000,
+              "max_retries": 50
+            },
+            {
+              "max_connections": 100000,
+              "max_pending_requests": 100000,
+              "max_requests": 60000000,
+              "max_retries": 50
+            }
+          ]
+        },
+        "http2_protocol_options": {}
+      },
+      {
+        "name": "enroute_ratelimit",
+        "alt_stat_name": "testing-ns_enroute_8003",
+        "type": "STRICT_DNS",
+        "connect_timeout": "5s",
+        "load_assignment": {
+          "cluster_name": "enroute_ratelimit",
+          "endpoints": [
+            {
+              "lb_endpoints": [
+                {
+                  "endpoint": {
+                    "address": {
+                      "socket_address": {
+                        "address": "127.0.0.1",
+                        "port_value": 8003
+                      }
+                    }
+                  }
+                }
+              ]
+            }
+          ]
+        },
+        "circuit_breakers": {
+          "thresholds": [
+            {
+              "priority": "HIGH",
+              "max_connections": 100000,
+              "max_pending_requests": 100000,
+              "max_requests": 60000000,
+              "max_retries": 50
+            },
+            {
+              "max_connections": 100000,
+              "max_pending_requests": 100000,
+              "max_requests": 60000000,
+              "max_retries": 50
+            }
+          ]
+        },
+        "http2_protocol_options": {}
+      },
+      {
+        "name": "service-stats",
+        "alt_stat_name": "testing-ns_service-stats_9001",
+        "type": "LOGICAL_DNS",
+        "connect_timeout": "0.250s",
+        "load_assignment": {
+          "cluster_name": "service-stats",
+          "endpoints": [   
+            {                          
+              "lb_endpoints": [
+                {
+                  "endpoint": {
+                    "address": {
+                      "socket_address": {
+                        "address": "127.0.0.1",
+                        "port_value": 9001
+                      }    
+                    }     
+                  }
+                }          
+              ]                        
+            }
+          ]
+        }
+      }
+    ]
+  },
+  "dynamic_resources": {
+    "lds_config": {
+      "ads": {},
+      "resource_api_version": "V3"
+    },
+    "cds_config": {
+      "ads": {},
+      "resource_api_version": "V3"
+    },
+    "ads_config": {
+      "api_type": "GRPC",
+      "grpc_services": [
+        {
+          "envoy_grpc": {
+            "cluster_name": "enroute"
+          }
+        }
+      ],
+      "transport_api_version": "V3"
+    }
+  },
+  "admin": {
+    "access_log_path": "/dev/null",
+    "address": {
+      "socket_address": {
+        "address": "127.0.0.1",
+        "port_value": 9001
+      }
+    }
+  }
 }`,
 		},
 		"--admin-address=8.8.8.8 --admin-port=9200": {
@@ -682,9 +832,10 @@ func TestBootstrap(t *testing.T) {
   }
 }`,
 		},
-		"--stats-address=8.8.8.8 --stats-port=9200": {
+		"--xds-unix-socket=/var/run/enroute/xds.sock": {
 			config: BootstrapConfig{
-				Namespace: "testing-ns",
+				XDSUnixSocket: "/var/run/enroute/xds.sock",
+				Namespace:     "testing-ns",
 			},
 			want: `{
   "static_resources": {
@@ -692,7 +843,7 @@ func TestBootstrap(t *testing.T) {
       {
         "name": "enroute",
         "alt_stat_name": "testing-ns_enroute_8001",
-        "type": "STRICT_DNS",
+        "type": "STATIC",
         "connect_timeout": "5s",
         "load_assignment": {
           "cluster_name": "enroute",
@@ -702,9 +853,8 @@ func TestBootstrap(t *testing.T) {
                 {
                   "endpoint": {
                     "address": {
-                      "socket_address": {
-                        "address": "127.0.0.1",
-                        "port_value": 8001
+                      "pipe": {
+                        "path": "/var/run/enroute/xds.sock"
                       }
                     }
                   }
@@ -846,12 +996,9 @@ func TestBootstrap(t *testing.T) {
   }
 }`,
 		},
-		"--envoy-cafile=CA.cert --envoy-client-cert=client.cert --envoy-client-key=client.key": {
+		"--stats-address=8.8.8.8 --stats-port=9200": {
 			config: BootstrapConfig{
-				Namespace:      "testing-ns",
-				GrpcCABundle:   "CA.cert",
-				GrpcClientCert: "client.cert",
-				GrpcClientKey:  "client.key",
+				Namespace: "testing-ns",
 			},
 			want: `{
   "static_resources": {
@@ -897,34 +1044,9 @@ func TestBootstrap(t *testing.T) {
             }
           ]
         },
-        "http2_protocol_options": {},
-        "transport_socket": {
-          "name":"tls",
-          "typed_config": {
-            "@type":"type.googleapis.com/envoy.extensions.transport_sockets.tls.v3.UpstreamTlsContext",
-            "common_tls_context": {
-              "tls_certificates": [
-                {
-                  "certificate_chain": {
-                    "filename": "client.cert"
-                  },
-                  "private_key": {
-                    "filename": "client.key"
-                  }
-                }
-              ],
-              "validation_context": {
-                "trusted_ca": {
-                  "filename": "CA.cert"
-                },
-                "match_subject_alt_names": [
-                   { "exact" : "enroute" }
-                ]
-              }
-            }
-          }
-        }
+        "http2_protocol_options": {}
       },
+
       {
         "name": "enroute_ratelimit",
         "alt_stat_name": "testing-ns_enroute_8003",
@@ -969,6 +1091,7 @@ func TestBootstrap(t *testing.T) {
         "http2_protocol_options": {}
       },
 
+
       {
         "name": "service-stats",
         "alt_stat_name": "testing-ns_service-stats_9001",
@@ -1035,6 +1158,553 @@ func TestBootstrap(t *testing.T) {
       }
     }
   }
+}`,
+		},
+		"--envoy-cafile=CA.cert --envoy-client-cert=client.cert --envoy-client-key=client.key": {
+			config: BootstrapConfig{
+				Namespace:      "testing-ns",
+				GrpcCABundle:   "CA.cert",
+				GrpcClientCert: "client.cert",
+				GrpcClientKey:  "client.key",
+			},
+			want: `{
+  "static_resources": {
+    "clusters": [
+      {
+        "name": "enroute",
+        "alt_stat_name": "testing-ns_enroute_8001",
+        "type": "STRICT_DNS",
+        "connect_timeout": "5s",
+        "load_assignment": {
+          "cluster_name": "enroute",
+          "endpoints": [
+            {
+              "lb_endpoints": [
+                {
+                  "endpoint": {
+                    "address": {
+                      "socket_address": {
+                        "address": "127.0.0.1",
+                        "port_value": 8001
+                      }
+                    }
+                  }
+                }
+              ]
+            }
+          ]
+        },
+        "circuit_breakers": {
+          "thresholds": [
+            {
+              "priority": "HIGH",
+              "max_connections": 100000,
+              "max_pending_requests": 100000,
+              "max_requests": 60000000,
+              "max_retries": 50
+            },
+            {
+              "max_connections": 100000,
+              "max_pending_requests": 100000,
+              "max_requests": 60000000,
+              "max_retries": 50
+            }
+          ]
+        },
+        "http2_protocol_options": {},
+        "transport_socket": {
+          "name":"tls",
+          "typed_config": {
+            "@type":"type.googleapis.com/envoy.extensions.transport_sockets.tls.v3.UpstreamTlsContext",
+            "common_tls_context": {
+              "tls_certificates": [
+                {
+                  "certificate_chain": {
+                    "filename": "client.cert"
+                  },
+                  "private_key": {
+                    "filename": "client.key"
+                  }
+                }
+              ],
+              "validation_context": {
+                "trusted_ca": {
+                  "filename": "CA.cert"
+                },
+                "match_subject_alt_names": [
+                   { "exact" : "enroute" }
+                ]
+              }
+            }
+          }
+        }
+      },
+      {
+        "name": "enroute_ratelimit",
+        "alt_stat_name": "testing-ns_enroute_8003",
+        "type": "STRICT_DNS",
+        "connect_timeout": "5s",
+        "load_assignment": {
+          "cluster_name": "enroute_ratelimit",
+          "endpoints": [
+            {
+              "lb_endpoints": [
+                {
+                  "endpoint": {
+                    "address": {
+                      "socket_address": {
+                        "address": "127.0.0.1",
+                        "port_value": 8003
+                      }
+                    }
+                  }
+                }
+              ]
+            }
+          ]
+        },
+        "circuit_breakers": {
+          "thresholds": [
+            {
+              "priority": "HIGH",
+              "max_connections": 100000,
+              "max_pending_requests": 100000,
+              "max_requests": 60000000,
+              "max_retries": 50
+            },
+            {
+              "max_connections": 100000,
+              "max_pending_requests": 100000,
+              "max_requests": 60000000,
+              "max_retries": 50
+            }
+          ]
+        },
+        "http2_protocol_options": {}
+      },
+
+      {
+        "name": "service-stats",
+        "alt_stat_name": "testing-ns_service-stats_9001",
+        "type": "LOGICAL_DNS",
+        "connect_timeout": "0.250s",
+        "load_assignment": {
+          "cluster_name": "service-stats",
+          "endpoints": [   
+            {                          
+              "lb_endpoints": [
+                {
+                  "endpoint": {
+                    "address": {
+                      "socket_address": {
+                        "address": "127.0.0.1",
+                        "port_value": 9001
+                      }    
+                    }     
+                  }
+                }          
+              ]                        
+            }
+          ]
+        }
+      }
+    ]
+  },
+  "dynamic_resources": {
+    "lds_config": {
+      "api_config_source": {
+        "api_type": "GRPC",
+        "grpc_services": [
+          {
+            "envoy_grpc": {
+              "cluster_name": "enroute"
+            }
+          }
+        ],
+        "transport_api_version": "V3"
+      },
+      "resource_api_version": "V3"
+    },
+    "cds_config": {
+      "api_config_source": {
+        "api_type": "GRPC",
+        "grpc_services": [
+          {
+            "envoy_grpc": {
+              "cluster_name": "enroute"
+            }
+          }
+        ],
+        "transport_api_version": "V3"
+      },
+      "resource_api_version": "V3"
+    }
+  },
+  "admin": {
+    "access_log_path": "/dev/null",
+    "address": {
+      "socket_address": {
+        "address": "127.0.0.1",
+        "port_value": 9001
+      }
+    }
+  }
+}`,
+		},
+		"--load-reporting": {
+			config: BootstrapConfig{Namespace: "testing-ns", LoadReporting: true},
+			want: `{
+  "static_resources": {
+    "clusters": [
+      {
+        "name": "enroute",
+        "alt_stat_name": "testing-ns_enroute_8001",
+        "type": "STRICT_DNS",
+        "connect_timeout": "5s",
+        "load_assignment": {
+          "cluster_name": "enroute",
+          "endpoints": [
+            {
+              "lb_endpoints": [
+                {
+                  "endpoint": {
+                    "address": {
+                      "socket_address": {
+                        "address": "127.0.0.1",
+                        "port_value": 8001
+                      }
+                    }
+                  }
+                }
+              ]
+            }
+          ]
+        },
+        "circuit_breakers": {
+          "thresholds": [
+            {
+              "priority": "HIGH",
+              "max_connections": 100000,
+              "max_pending_requests": 100000,
+              "max_requests": 60000000,
+              "max_retries": 50
+            },
+            {
+              "max_connections": 100000,
+              "max_pending_requests": 100000,
+              "max_requests": 60000000,
+              "max_retries": 50
+            }
+          ]
+        },
+        "http2_protocol_options": {}
+      },
+      {
+        "name": "enroute_ratelimit",
+        "alt_stat_name": "testing-ns_enroute_8003",
+        "type": "STRICT_DNS",
+        "connect_timeout": "5s",
+        "load_assignment": {
+          "cluster_name": "enroute_ratelimit",
+          "endpoints": [
+            {
+              "lb_endpoints": [
+                {
+                  "endpoint": {
+                    "address": {
+                      "socket_address": {
+                        "address": "127.0.0.1",
+                        "port_value": 8003
+                      }
+                    }
+                  }
+                }
+              ]
+            }
+          ]
+        },
+        "circuit_breakers": {
+          "thresholds": [
+            {
+              "priority": "HIGH",
+              "max_connections": 100000,
+              "max_pending_requests": 100000,
+              "max_requests": 60000000,
+              "max_retries": 50
+            },
+            {
+              "max_connections": 100000,
+              "max_pending_requests": 100000,
+              "max_requests": 60000000,
+              "max_retries": 50
+            }
+          ]
+        },
+        "http2_protocol_options": {}
+      },
+      {
+        "name": "service-stats",
+        "alt_stat_name": "testing-ns_service-stats_9001",
+        "type": "LOGICAL_DNS",
+        "connect_timeout": "0.250s",
+        "load_assignment": {
+          "cluster_name": "service-stats",
+          "endpoints": [   
+            {                          
+              "lb_endpoints": [
+                {
+                  "endpoint": {
+                    "address": {
+                      "socket_address": {
+                        "address": "127.0.0.1",
+                        "port_value": 9001
+                      }    
+                    }     
+                  }
+                }          
+              ]                        
+            }
+          ]
+        }
+      }
+    ]
+  },
+  "cluster_manager": {
+    "load_stats_config": {
+      "api_type": "GRPC",
+      "grpc_services": [
+        {
+          "envoy_grpc": {
+            "cluster_name": "enroute"
+          }
+        }
+      ],
+      "transport_api_version": "V3"
+    }
+  },
+  "dynamic_resources": {
+    "lds_config": {
+      "api_config_source": {
+        "api_type": "GRPC",
+        "grpc_services": [
+          {
+            "envoy_grpc": {
+              "cluster_name": "enroute"
+            }
+          }
+        ],
+        "transport_api_version": "V3"
+      },
+      "resource_api_version": "V3"
+    },
+    "cds_config": {
+      "api_config_source": {
+        "api_type": "GRPC",
+        "grpc_services": [
+          {
+            "envoy_grpc": {
+              "cluster_name": "enroute"
+            }
+          }
+        ],
+        "transport_api_version": "V3"
+      },
+      "resource_api_version": "V3"
+    }
+  },
+  "admin": {
+    "access_log_path": "/dev/null",
+    "address": {
+      "socket_address": {
+        "address": "127.0.0.1",
+        "port_value": 9001
+      }
+    }
+  }
+}`,
+		},
+		"--trace-collector-address": {
+			config: BootstrapConfig{Namespace: "testing-ns", TraceCollectorAddress: "otel-collector.tracing", TraceCollectorPort: 4317, TraceCollectorGRPC: true},
+			want: `{
+  "static_resources": {
+    "clusters": [
+      {
+        "name": "enroute",
+        "alt_stat_name": "testing-ns_enroute_8001",
+        "type": "STRICT_DNS",
+        "connect_timeout": "5s",
+        "load_assignment": {
+          "cluster_name": "enroute",
+          "endpoints": [
+            {
+              "lb_endpoints": [
+                {
+                  "endpoint": {
+                    "address": {
+                      "socket_address": {
+                        "address": "127.0.0.1",
+                        "port_value": 8001
+                      }
+                    }
+                  }
+                }
+              ]
+            }
+          ]
+        },
+        "circuit_breakers": {
+          "thresholds": [
+            {
+              "priority": "HIGH",
+              "max_connections": 100000,
+              "max_pending_requests": 100000,
+              "max_requests": 60000000,
+              "max_retries": 50
+            },
+            {
+              "max_connections": 100000,
+              "max_pending_requests": 100000,
+              "max_requests": 60000000,
+              "max_retries": 50
+            }
+          ]
+        },
+        "http2_protocol_options": {}
+      },
+      {
+        "name": "enroute_ratelimit",
+        "alt_stat_name": "testing-ns_enroute_8003",
+        "type": "STRICT_DNS",
+        "connect_timeout": "5s",
+        "load_assignment": {
+          "cluster_name": "enroute_ratelimit",
+          "endpoints": [
+            {
+              "lb_endpoints": [
+                {
+                  "endpoint": {
+                    "address": {
+                      "socket_address": {
+                        "address": "127.0.0.1",
+                        "port_value": 8003
+                      }
+                    }
+                  }
+                }
+              ]
+            }
+          ]
+        },
+        "circuit_breakers": {
+          "thresholds": [
+            {
+              "priority": "HIGH",
+              "max_connections": 100000,
+              "max_pending_requests": 100000,
+              "max_requests": 60000000,
+              "max_retries": 50
+            },
+            {
+              "max_connections": 100000,
+              "max_pending_requests": 100000,
+              "max_requests": 60000000,
+              "max_retries": 50
+            }
+          ]
+        },
+        "http2_protocol_options": {}
+      },
+      {
+        "name": "service-stats",
+        "alt_stat_name": "testing-ns_service-stats_9001",
+        "type": "LOGICAL_DNS",
+        "connect_timeout": "0.250s",
+        "load_assignment": {
+          "cluster_name": "service-stats",
+          "endpoints": [   
+            {                          
+              "lb_endpoints": [
+                {
+                  "endpoint": {
+                    "address": {
+                      "socket_address": {
+                        "address": "127.0.0.1",
+                        "port_value": 9001
+                      }    
+                    }     
+                  }
+                }          
+              ]                        
+            }
+          ]
+        }
+      },
+      {
+        "name": "jaeger-trace",
+        "type": "STRICT_DNS",
+        "connect_timeout": "5s",
+        "load_assignment": {
+          "cluster_name": "jaeger-trace",
+          "endpoints": [
+            {
+              "lb_endpoints": [
+                {
+                  "endpoint": {
+                    "address": {
+                      "socket_address": {
+                        "address": "otel-collector.tracing",
+                        "port_value": 4317
+                      }
+                    }
+                  }
+                }
+              ]
+            }
+          ]
+        },
+        "http2_protocol_options": {}
+      }
+    ]
+  },
+  "dynamic_resources": {
+    "lds_config": {
+      "api_config_source": {
+        "api_type": "GRPC",
+        "grpc_services": [
+          {
+            "envoy_grpc": {
+              "cluster_name": "enroute"
+            }
+          }
+        ],
+        "transport_api_version": "V3"
+      },
+      "resource_api_version": "V3"
+    },
+    "cds_config": {
+      "api_config_source": {
+        "api_type": "GRPC",
+        "grpc_services": [
+          {
+            "envoy_grpc": {
+              "cluster_name": "enroute"
+            }
+          }
+        ],
+        "transport_api_version": "V3"
+      },
+      "resource_api_version": "V3"
+    }
+  },
+  "admin": {
+    "access_log_path": "/dev/null",
+    "address": {
+      "socket_address": {
+        "address": "127.0.0.1",
+        "port_value": 9001
+      }
+    }
+  }
 }`,
 		},
 	}
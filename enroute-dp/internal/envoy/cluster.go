@@ -438,6 +438,17 @@ func ConfigSource(cluster string) *envoy_config_core_v3.ConfigSource {
 	}
 }
 
+// ADSConfigSource returns a *envoy_config_core_v3.ConfigSource for resources
+// fetched over the Aggregated Discovery Service stream.
+func ADSConfigSource() *envoy_config_core_v3.ConfigSource {
+	return &envoy_config_core_v3.ConfigSource{
+		ConfigSourceSpecifier: &envoy_config_core_v3.ConfigSource_Ads{
+			Ads: &envoy_config_core_v3.AggregatedConfigSource{},
+		},
+		ResourceApiVersion: envoy_config_core_v3.ApiVersion_V3,
+	}
+}
+
 // ClusterDiscoveryType returns the type of a ClusterDiscovery as a Cluster_type.
 func ClusterDiscoveryType(t envoy_config_cluster_v3.Cluster_DiscoveryType) *envoy_config_cluster_v3.Cluster_Type {
 	return &envoy_config_cluster_v3.Cluster_Type{Type: t}
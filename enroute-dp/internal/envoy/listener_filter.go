@@ -43,11 +43,25 @@ func buildHttpFilterMap(listener_filters *[]*envoy_extensions_filters_network_ht
 	if dag_http_filters != nil {
 		for _, df := range dag_http_filters {
 			hf := DagFilterToHttpFilter(df, vh)
-			if hf != nil {
-				(*m)[hf.Name] = hf
+			if hf == nil {
+				continue
 			}
+			// The virtual hosts sharing a listener share its jwt_authn filter
+			if prev, ok := (*m)[hf.Name]; ok && hf.Name == HTTPFilterJwt {
+				hf = mergeJwtFilters(prev, hf)
+			}
+			// and its health check filter, which checks the clusters
+			// of all of them
+			if prev, ok := (*m)[hf.Name]; ok && hf.Name == HTTPFilterHealthCheck {
+				hf = mergeHealthCheckFilters(prev, hf)
+			}
+			(*m)[hf.Name] = hf
 		}
 	}
+
+	addWasmRouteFilters(vh, m)
+	addRbacRouteFilter(vh, m)
+	addLocalRateLimitRouteFilter(vh, m)
 }
 
 func Find(slice []string, val string) (int, bool) {
@@ -128,3 +142,52 @@ func AddHttpFilterToListener(l *envoy_config_listener_v3.Listener, vh *dag.Virtu
 		}
 	}
 }
+
+// updateHttpConnectionManagers calls fn on the HTTP connection manager of
+// the filter chain of l serving the virtual host named name, or on those of
+// every filter chain if none serves name alone. sni is true in the former
+// case.
+func updateHttpConnectionManagers(l *envoy_config_listener_v3.Listener, name string,
+	fn func(hcm *envoy_extensions_filters_network_http_connection_manager_v3.HttpConnectionManager, sni bool)) {
+	if l == nil {
+		return
+	}
+
+	serves := func(fc *envoy_config_listener_v3.FilterChain) bool {
+		if fc.FilterChainMatch == nil {
+			return false
+		}
+		_, found := Find(fc.FilterChainMatch.ServerNames, name)
+		return found
+	}
+
+	sni := false
+	for _, fc := range l.FilterChains {
+		if serves(fc) {
+			sni = true
+		}
+	}
+
+	for _, fc := range l.FilterChains {
+		if sni && !serves(fc) {
+			continue
+		}
+		for _, f := range fc.Filters {
+			if f.Name != wellknown.HTTPConnectionManager {
+				continue
+			}
+			config := f.GetTypedConfig()
+			if config == nil {
+				continue
+			}
+			hcm := &envoy_extensions_filters_network_http_connection_manager_v3.HttpConnectionManager{}
+			if err := types.UnmarshalAny(config, hcm); err != nil {
+				continue
+			}
+			fn(hcm, sni)
+			f.ConfigType = &envoy_config_listener_v3.Filter_TypedConfig{
+				TypedConfig: toAny(hcm),
+			}
+		}
+	}
+}
@@ -0,0 +1,179 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright(c) 2018-2023 Saaras Inc.
+
+package envoy
+
+import (
+	"time"
+
+	envoy_config_core_v3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	envoy_config_route_v3 "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	envoy_extensions_filters_http_ext_authz_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/ext_authz/v3"
+	envoy_extensions_filters_network_http_connection_manager_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+	envoy_type_matcher_v3 "github.com/envoyproxy/go-control-plane/envoy/type/matcher/v3"
+	envoy_type_v3 "github.com/envoyproxy/go-control-plane/envoy/type/v3"
+	"github.com/golang/protobuf/ptypes/any"
+	"github.com/saarasio/enroute/enroute-dp/internal/dag"
+	"github.com/saarasio/enroute/enroute-dp/internal/logger"
+	"github.com/saarasio/enroute/enroute-dp/internal/protobuf"
+	cfg "github.com/saarasio/enroute/enroute-dp/saarasconfig"
+)
+
+// HTTPFilterExtAuthz prefixes the name of each external authorization
+// filter. The filters of all the virtual hosts sharing a listener are set
+// on it, routes refer to them to disable external authorization.
+const HTTPFilterExtAuthz = "envoy.ext_authz"
+
+// ExtAuthzFilterName returns the name of the external authorization filter
+// named name in namespace.
+func ExtAuthzFilterName(namespace, name string) string {
+	return HTTPFilterExtAuthz + "." + namespace + "/" + name
+}
+
+// defaultExtAuthzTimeout bounds the requests to the auth service unless the
+// filter config sets a timeout.
+const defaultExtAuthzTimeout = 10 * time.Second
+
+func httpExtAuthzTypedConfig(f *dag.HttpFilter) *envoy_extensions_filters_network_http_connection_manager_v3.HttpFilter_TypedConfig {
+	ea := httpExtAuthzConfig(f)
+	if ea == nil {
+		return nil
+	}
+	return &envoy_extensions_filters_network_http_connection_manager_v3.HttpFilter_TypedConfig{
+		TypedConfig: toAny(ea),
+	}
+}
+
+// httpExtAuthzConfig returns the ext_authz config of an external
+// authorization filter, nil if its config is invalid or its auth service
+// is missing.
+func httpExtAuthzConfig(f *dag.HttpFilter) *envoy_extensions_filters_http_ext_authz_v3.ExtAuthz {
+	if f == nil {
+		return nil
+	}
+
+	ec, err := cfg.UnmarshalExtAuthzFilterConfig(f.Filter_config)
+	if err != nil {
+		if logger.EL.ELogger != nil {
+			logger.EL.ELogger.Errorf("internal:envoy:listener_filter_extauthz:httpExtAuthzConfig() Filter [%s] failed to decode config [%s]\n",
+				f.Filter_name, err)
+		}
+		return nil
+	}
+
+	if f.Cluster == nil {
+		if logger.EL.ELogger != nil {
+			logger.EL.ELogger.Infof("internal:envoy:listener_filter_extauthz:httpExtAuthzConfig() Filter [%s] has no auth service\n",
+				f.Filter_name)
+		}
+		return nil
+	}
+
+	ea := &envoy_extensions_filters_http_ext_authz_v3.ExtAuthz{
+		TransportApiVersion: envoy_config_core_v3.ApiVersion_V3,
+		FailureModeAllow:    ec.Failure_mode_allow,
+		AllowedHeaders:      exactStringMatchers(ec.AllowedRequestHeaders),
+	}
+
+	timeout := protobuf.Duration(defaultExtAuthzTimeout)
+	if ec.Timeout > 0 {
+		timeout = protobuf.Duration(time.Duration(ec.Timeout) * time.Second)
+	}
+
+	cluster := Clustername(f.Cluster)
+	if ec.AuthServiceProto == "grpc" {
+		ea.Services = &envoy_extensions_filters_http_ext_authz_v3.ExtAuthz_GrpcService{
+			GrpcService: &envoy_config_core_v3.GrpcService{
+				TargetSpecifier: &envoy_config_core_v3.GrpcService_EnvoyGrpc_{
+					EnvoyGrpc: &envoy_config_core_v3.GrpcService_EnvoyGrpc{
+						ClusterName: cluster,
+					},
+				},
+				Timeout: timeout,
+			},
+		}
+	} else {
+		uri := ec.Url
+		if uri == "" {
+			uri = "http://" + ec.AuthService
+		}
+		hs := &envoy_extensions_filters_http_ext_authz_v3.HttpService{
+			ServerUri: &envoy_config_core_v3.HttpUri{
+				Uri: uri,
+				HttpUpstreamType: &envoy_config_core_v3.HttpUri_Cluster{
+					Cluster: cluster,
+				},
+				Timeout: timeout,
+			},
+			PathPrefix: ec.Path_prefix,
+		}
+		if len(ec.AllowedAuthorizationHeaders) > 0 {
+			hs.AuthorizationResponse = &envoy_extensions_filters_http_ext_authz_v3.AuthorizationResponse{
+				AllowedUpstreamHeaders: exactStringMatchers(ec.AllowedAuthorizationHeaders),
+			}
+		}
+		ea.Services = &envoy_extensions_filters_http_ext_authz_v3.ExtAuthz_HttpService{
+			HttpService: hs,
+		}
+	}
+
+	if ec.Body_max_bytes > 0 {
+		ea.WithRequestBody = &envoy_extensions_filters_http_ext_authz_v3.BufferSettings{
+			MaxRequestBytes:     ec.Body_max_bytes,
+			AllowPartialMessage: ec.Body_allow_partial,
+			PackAsBytes:         ec.PackRawBytes,
+		}
+	}
+
+	if ec.Status_on_error > 0 {
+		ea.StatusOnError = &envoy_type_v3.HttpStatus{
+			Code: envoy_type_v3.StatusCode(ec.Status_on_error),
+		}
+	}
+
+	return ea
+}
+
+// exactStringMatchers returns a matcher for any of values, nil if values
+// is empty.
+func exactStringMatchers(values []string) *envoy_type_matcher_v3.ListStringMatcher {
+	if len(values) == 0 {
+		return nil
+	}
+	var lsm envoy_type_matcher_v3.ListStringMatcher
+	for _, v := range values {
+		lsm.Patterns = append(lsm.Patterns, &envoy_type_matcher_v3.StringMatcher{
+			MatchPattern: &envoy_type_matcher_v3.StringMatcher_Exact{Exact: v},
+		})
+	}
+	return &lsm
+}
+
+// SetupRouteExtAuthz disables on rr each of the external authorization
+// filters named extAuthzFilters, the filters of all the virtual hosts,
+// that vh does not attach. The filters of vh are disabled too if r
+// disables external authorization.
+func SetupRouteExtAuthz(vh *dag.VirtualHost, r *dag.Route, rr *envoy_config_route_v3.Route, extAuthzFilters []string) {
+	attached := make(map[string]bool)
+	if !r.DisableExtAuthz {
+		for _, hf := range vh.HttpFilters {
+			if hf.Filter_type == cfg.FILTER_TYPE_HTTP_EXTAUTHZ {
+				attached[ExtAuthzFilterName(hf.Filter_namespace, hf.Filter_name)] = true
+			}
+		}
+	}
+
+	for _, name := range extAuthzFilters {
+		if attached[name] {
+			continue
+		}
+		if rr.TypedPerFilterConfig == nil {
+			rr.TypedPerFilterConfig = make(map[string]*any.Any)
+		}
+		rr.TypedPerFilterConfig[name] = toAny(&envoy_extensions_filters_http_ext_authz_v3.ExtAuthzPerRoute{
+			Override: &envoy_extensions_filters_http_ext_authz_v3.ExtAuthzPerRoute_Disabled{
+				Disabled: true,
+			},
+		})
+	}
+}
@@ -0,0 +1,202 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright(c) 2018-2023 Saaras Inc.
+
+package envoy
+
+import (
+	"testing"
+	"time"
+
+	envoy_config_core_v3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	envoy_config_route_v3 "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	envoy_extensions_filters_http_ext_authz_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/ext_authz/v3"
+	envoy_type_matcher_v3 "github.com/envoyproxy/go-control-plane/envoy/type/matcher/v3"
+	envoy_type_v3 "github.com/envoyproxy/go-control-plane/envoy/type/v3"
+	"github.com/golang/protobuf/ptypes/any"
+	"github.com/saarasio/enroute/enroute-dp/internal/assert"
+	"github.com/saarasio/enroute/enroute-dp/internal/dag"
+	"github.com/saarasio/enroute/enroute-dp/internal/protobuf"
+	cfg "github.com/saarasio/enroute/enroute-dp/saarasconfig"
+	v1 "k8s.io/api/core/v1"
+)
+
+func extAuthzFilter(config string, cluster *dag.Cluster) *dag.HttpFilter {
+	return &dag.HttpFilter{
+		Filter: dag.Filter{
+			Filter_name:   "extauthz",
+			Filter_type:   cfg.FILTER_TYPE_HTTP_EXTAUTHZ,
+			Filter_config: config,
+
+			Filter_namespace: "default",
+		},
+		Cluster: cluster,
+	}
+}
+
+func TestHttpExtAuthzConfig(t *testing.T) {
+	authz := &dag.Cluster{
+		Upstream: &dag.HTTPService{
+			TCPService: dag.TCPService{
+				Name:      "ext-authz",
+				Namespace: "default",
+				ServicePort: &v1.ServicePort{
+					Protocol: "TCP",
+					Port:     8080,
+				},
+			},
+		},
+	}
+
+	tests := map[string]struct {
+		filter *dag.HttpFilter
+		want   *envoy_extensions_filters_http_ext_authz_v3.ExtAuthz
+	}{
+		"no auth service": {
+			filter: extAuthzFilter(`{"auth_service":"ext-authz","auth_service_port":8080}`, nil),
+			want:   nil,
+		},
+		"http auth service": {
+			filter: extAuthzFilter(`{"auth_service":"ext-authz","auth_service_port":8080,"auth_service_proto":"http",
+				"failure_mode_allow":true,"timeout":10,"path_prefix":"/check","status_on_error":403,
+				"body_max_bytes":409,"body_allow_partial":true,
+				"allowed_request_headers":["x-stamp"],"allowed_authorization_headers":["x-auth-userId"]}`, authz),
+			want: &envoy_extensions_filters_http_ext_authz_v3.ExtAuthz{
+				TransportApiVersion: envoy_config_core_v3.ApiVersion_V3,
+				FailureModeAllow:    true,
+				AllowedHeaders:      exactStringMatchers([]string{"x-stamp"}),
+				Services: &envoy_extensions_filters_http_ext_authz_v3.ExtAuthz_HttpService{
+					HttpService: &envoy_extensions_filters_http_ext_authz_v3.HttpService{
+						ServerUri: &envoy_config_core_v3.HttpUri{
+							Uri: "http://ext-authz",
+							HttpUpstreamType: &envoy_config_core_v3.HttpUri_Cluster{
+								Cluster: "default/ext-authz/8080/da39a3ee5e",
+							},
+							Timeout: protobuf.Duration(10 * time.Second),
+						},
+						PathPrefix: "/check",
+						AuthorizationResponse: &envoy_extensions_filters_http_ext_authz_v3.AuthorizationResponse{
+							AllowedUpstreamHeaders: &envoy_type_matcher_v3.ListStringMatcher{
+								Patterns: []*envoy_type_matcher_v3.StringMatcher{{
+									MatchPattern: &envoy_type_matcher_v3.StringMatcher_Exact{Exact: "x-auth-userId"},
+								}},
+							},
+						},
+					},
+				},
+				WithRequestBody: &envoy_extensions_filters_http_ext_authz_v3.BufferSettings{
+					MaxRequestBytes:     409,
+					AllowPartialMessage: true,
+				},
+				StatusOnError: &envoy_type_v3.HttpStatus{
+					Code: envoy_type_v3.StatusCode_Forbidden,
+				},
+			},
+		},
+		"grpc auth service": {
+			filter: extAuthzFilter(`{"auth_service":"ext-authz","auth_service_port":8080,"auth_service_proto":"grpc",
+				"body_max_bytes":1024,"pack_raw_bytes":true}`, authz),
+			want: &envoy_extensions_filters_http_ext_authz_v3.ExtAuthz{
+				TransportApiVersion: envoy_config_core_v3.ApiVersion_V3,
+				Services: &envoy_extensions_filters_http_ext_authz_v3.ExtAuthz_GrpcService{
+					GrpcService: &envoy_config_core_v3.GrpcService{
+						TargetSpecifier: &envoy_config_core_v3.GrpcService_EnvoyGrpc_{
+							EnvoyGrpc: &envoy_config_core_v3.GrpcService_EnvoyGrpc{
+								ClusterName: "default/ext-authz/8080/da39a3ee5e",
+							},
+						},
+						Timeout: protobuf.Duration(10 * time.Second),
+					},
+				},
+				WithRequestBody: &envoy_extensions_filters_http_ext_authz_v3.BufferSettings{
+					MaxRequestBytes: 1024,
+					PackAsBytes:     true,
+				},
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := httpExtAuthzConfig(tc.filter)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestHttpExtAuthzConfigDefaultTimeout(t *testing.T) {
+	authz := &dag.Cluster{
+		Upstream: &dag.HTTPService{
+			TCPService: dag.TCPService{
+				Name:      "ext-authz",
+				Namespace: "default",
+				ServicePort: &v1.ServicePort{
+					Protocol: "TCP",
+					Port:     8080,
+				},
+			},
+		},
+	}
+
+	ea := httpExtAuthzConfig(extAuthzFilter(`{"auth_service":"ext-authz","auth_service_port":8080,"auth_service_proto":"http"}`, authz))
+	if err := ea.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, protobuf.Duration(defaultExtAuthzTimeout), ea.GetHttpService().GetServerUri().GetTimeout())
+}
+
+func TestSetupRouteExtAuthz(t *testing.T) {
+	disabled := func(names ...string) map[string]*any.Any {
+		m := make(map[string]*any.Any)
+		for _, name := range names {
+			m[name] = toAny(&envoy_extensions_filters_http_ext_authz_v3.ExtAuthzPerRoute{
+				Override: &envoy_extensions_filters_http_ext_authz_v3.ExtAuthzPerRoute_Disabled{
+					Disabled: true,
+				},
+			})
+		}
+		return m
+	}
+	other := &dag.HttpFilter{
+		Filter: dag.Filter{
+			Filter_name: "extauthz",
+			Filter_type: cfg.FILTER_TYPE_HTTP_EXTAUTHZ,
+
+			Filter_namespace: "other",
+		},
+	}
+	extAuthzFilters := []string{"envoy.ext_authz.default/extauthz", "envoy.ext_authz.other/extauthz"}
+
+	tests := map[string]struct {
+		filters []*dag.HttpFilter
+		route   *dag.Route
+		want    map[string]*any.Any
+	}{
+		"route disables ext_authz": {
+			filters: []*dag.HttpFilter{extAuthzFilter("{}", nil)},
+			route:   &dag.Route{DisableExtAuthz: true},
+			want:    disabled("envoy.ext_authz.default/extauthz", "envoy.ext_authz.other/extauthz"),
+		},
+		"route does not disable ext_authz": {
+			filters: []*dag.HttpFilter{extAuthzFilter("{}", nil)},
+			route:   &dag.Route{},
+			want:    disabled("envoy.ext_authz.other/extauthz"),
+		},
+		"virtual host with both filters": {
+			filters: []*dag.HttpFilter{extAuthzFilter("{}", nil), other},
+			route:   &dag.Route{},
+			want:    nil,
+		},
+		"virtual host without ext_authz": {
+			route: &dag.Route{},
+			want:  disabled("envoy.ext_authz.default/extauthz", "envoy.ext_authz.other/extauthz"),
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			rr := &envoy_config_route_v3.Route{}
+			SetupRouteExtAuthz(&dag.VirtualHost{HttpFilters: tc.filters}, tc.route, rr, extAuthzFilters)
+			assert.Equal(t, &envoy_config_route_v3.Route{TypedPerFilterConfig: tc.want}, rr)
+		})
+	}
+}
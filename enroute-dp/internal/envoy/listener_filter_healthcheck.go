@@ -0,0 +1,153 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright(c) 2018-2023 Saaras Inc.
+
+package envoy
+
+import (
+	"time"
+
+	envoy_config_route_v3 "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	envoy_extensions_filters_http_health_check_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/health_check/v3"
+	envoy_extensions_filters_network_http_connection_manager_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+	envoy_type_v3 "github.com/envoyproxy/go-control-plane/envoy/type/v3"
+	types "github.com/golang/protobuf/ptypes"
+	"github.com/golang/protobuf/ptypes/wrappers"
+	"github.com/saarasio/enroute/enroute-dp/internal/dag"
+	"github.com/saarasio/enroute/enroute-dp/internal/logger"
+	"github.com/saarasio/enroute/enroute-dp/internal/protobuf"
+	cfg "github.com/saarasio/enroute/enroute-dp/saarasconfig"
+)
+
+// HTTPFilterHealthCheck is the name of the health check filter.
+const HTTPFilterHealthCheck = "envoy.health_check"
+
+const defaultHealthCheckPath = "/healthz"
+
+func httpHealthCheckTypedConfig(f *dag.HttpFilter, vh *dag.VirtualHost) *envoy_extensions_filters_network_http_connection_manager_v3.HttpFilter_TypedConfig {
+	hc := httpHealthCheckConfig(f, vh)
+	if hc == nil {
+		return nil
+	}
+	return &envoy_extensions_filters_network_http_connection_manager_v3.HttpFilter_TypedConfig{
+		TypedConfig: toAny(hc),
+	}
+}
+
+// httpHealthCheckConfig returns the config of a health check filter, nil if
+// its config is invalid. The clusters named by the config are looked up
+// among the clusters of vh.
+func httpHealthCheckConfig(f *dag.HttpFilter, vh *dag.VirtualHost) *envoy_extensions_filters_http_health_check_v3.HealthCheck {
+	hcc, err := cfg.UnmarshalHealthCheckConfig(f.Filter_config)
+	if err != nil {
+		if logger.EL.ELogger != nil {
+			logger.EL.ELogger.Errorf("internal:envoy:listener_filter_healthcheck:httpHealthCheckConfig() Filter [%s] failed to decode config [%s]\n",
+				f.Filter_name, err)
+		}
+		return nil
+	}
+
+	path := hcc.Path
+	if path == "" {
+		path = defaultHealthCheckPath
+	}
+
+	hc := &envoy_extensions_filters_http_health_check_v3.HealthCheck{
+		PassThroughMode: &wrappers.BoolValue{Value: hcc.PassThrough},
+		Headers: []*envoy_config_route_v3.HeaderMatcher{{
+			Name:                 ":path",
+			HeaderMatchSpecifier: &envoy_config_route_v3.HeaderMatcher_ExactMatch{ExactMatch: path},
+		}},
+	}
+
+	// Envoy only caches the response of the upstream in pass through mode
+	if hcc.PassThrough && hcc.CacheTime > 0 {
+		hc.CacheTime = protobuf.Duration(time.Duration(hcc.CacheTime) * time.Second)
+	}
+
+	for _, mh := range hcc.ClusterMinHealthy {
+		clusters := healthCheckClusters(vh, mh)
+		if len(clusters) == 0 {
+			if logger.EL.ELogger != nil {
+				logger.EL.ELogger.Infof("internal:envoy:listener_filter_healthcheck:httpHealthCheckConfig() Filter [%s] no cluster for service [%s]\n",
+					f.Filter_name, mh.Service)
+			}
+			continue
+		}
+		if hc.ClusterMinHealthyPercentages == nil {
+			hc.ClusterMinHealthyPercentages = make(map[string]*envoy_type_v3.Percent)
+		}
+		for _, c := range clusters {
+			hc.ClusterMinHealthyPercentages[Clustername(c)] = &envoy_type_v3.Percent{Value: mh.MinHealthyPercent}
+		}
+	}
+
+	return hc
+}
+
+// healthCheckClusters returns the clusters of the routes of vh whose
+// service matches mh.
+func healthCheckClusters(vh *dag.VirtualHost, mh cfg.HealthCheckClusterMinHealthy) []*dag.Cluster {
+	var clusters []*dag.Cluster
+	if vh == nil {
+		return clusters
+	}
+
+	vh.Visit(func(v dag.Vertex) {
+		r, ok := v.(*dag.Route)
+		if !ok {
+			return
+		}
+		r.Visit(func(v dag.Vertex) {
+			c, ok := v.(*dag.Cluster)
+			if !ok {
+				return
+			}
+			s, ok := c.Upstream.(*dag.HTTPService)
+			if !ok {
+				return
+			}
+			if s.Name != mh.Service {
+				return
+			}
+			if mh.Namespace != "" && s.Namespace != mh.Namespace {
+				return
+			}
+			if mh.Port != 0 && (s.ServicePort == nil || s.ServicePort.Port != mh.Port) {
+				return
+			}
+			clusters = append(clusters, c)
+		})
+	})
+	return clusters
+}
+
+// mergeHealthCheckFilters returns the health check filter b with the
+// cluster min healthy percentages of a and b, those of b taking
+// precedence. The virtual hosts of a listener share its health check
+// filter, so it checks the clusters of each virtual host attaching it.
+func mergeHealthCheckFilters(a, b *envoy_extensions_filters_network_http_connection_manager_v3.HttpFilter) *envoy_extensions_filters_network_http_connection_manager_v3.HttpFilter {
+	var ha, hb envoy_extensions_filters_http_health_check_v3.HealthCheck
+	if err := types.UnmarshalAny(a.GetTypedConfig(), &ha); err != nil {
+		return b
+	}
+	if err := types.UnmarshalAny(b.GetTypedConfig(), &hb); err != nil {
+		return a
+	}
+
+	for name, p := range ha.ClusterMinHealthyPercentages {
+		if _, ok := hb.ClusterMinHealthyPercentages[name]; ok {
+			continue
+		}
+		if hb.ClusterMinHealthyPercentages == nil {
+			hb.ClusterMinHealthyPercentages = make(map[string]*envoy_type_v3.Percent)
+		}
+		hb.ClusterMinHealthyPercentages[name] = p
+	}
+
+	return &envoy_extensions_filters_network_http_connection_manager_v3.HttpFilter{
+		Name: HTTPFilterHealthCheck,
+		ConfigType: &envoy_extensions_filters_network_http_connection_manager_v3.HttpFilter_TypedConfig{
+			TypedConfig: toAny(&hb),
+		},
+	}
+}
@@ -0,0 +1,180 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright(c) 2018-2023 Saaras Inc.
+
+package envoy
+
+import (
+	"testing"
+	"time"
+
+	envoy_config_route_v3 "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	envoy_extensions_filters_http_health_check_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/health_check/v3"
+	envoy_extensions_filters_network_http_connection_manager_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+	envoy_type_v3 "github.com/envoyproxy/go-control-plane/envoy/type/v3"
+	types "github.com/golang/protobuf/ptypes"
+	"github.com/golang/protobuf/ptypes/wrappers"
+	"github.com/saarasio/enroute/enroute-dp/internal/assert"
+	"github.com/saarasio/enroute/enroute-dp/internal/dag"
+	"github.com/saarasio/enroute/enroute-dp/internal/protobuf"
+	cfg "github.com/saarasio/enroute/enroute-dp/saarasconfig"
+	v1 "k8s.io/api/core/v1"
+)
+
+func TestHttpHealthCheckConfig(t *testing.T) {
+	cluster := func(name string, port int32) *dag.Cluster {
+		return &dag.Cluster{
+			Upstream: &dag.HTTPService{
+				TCPService: dag.TCPService{
+					Name:        name,
+					Namespace:   "default",
+					ServicePort: &v1.ServicePort{Port: port},
+				},
+			},
+		}
+	}
+	vh := &dag.VirtualHost{
+		Name: "www.example.com",
+		Routes: map[string]*dag.Route{
+			"/": {
+				PathCondition: &dag.PrefixCondition{Prefix: "/"},
+				Clusters:      []*dag.Cluster{cluster("httpbin", 80), cluster("httpbin", 8080)},
+			},
+			"/echo": {
+				PathCondition: &dag.PrefixCondition{Prefix: "/echo"},
+				Clusters:      []*dag.Cluster{cluster("echo", 80)},
+			},
+		},
+	}
+	path := func(p string) []*envoy_config_route_v3.HeaderMatcher {
+		return []*envoy_config_route_v3.HeaderMatcher{{
+			Name:                 ":path",
+			HeaderMatchSpecifier: &envoy_config_route_v3.HeaderMatcher_ExactMatch{ExactMatch: p},
+		}}
+	}
+
+	tests := map[string]struct {
+		config string
+		want   *envoy_extensions_filters_http_health_check_v3.HealthCheck
+	}{
+		"default path": {
+			config: `{}`,
+			want: &envoy_extensions_filters_http_health_check_v3.HealthCheck{
+				PassThroughMode: &wrappers.BoolValue{Value: false},
+				Headers:         path("/healthz"),
+			},
+		},
+		"pass through with cache": {
+			config: `{"path":"/ready","pass_through":true,"cache_time":5}`,
+			want: &envoy_extensions_filters_http_health_check_v3.HealthCheck{
+				PassThroughMode: &wrappers.BoolValue{Value: true},
+				CacheTime:       protobuf.Duration(5 * time.Second),
+				Headers:         path("/ready"),
+			},
+		},
+		"cache without pass through": {
+			config: `{"cache_time":5}`,
+			want: &envoy_extensions_filters_http_health_check_v3.HealthCheck{
+				PassThroughMode: &wrappers.BoolValue{Value: false},
+				Headers:         path("/healthz"),
+			},
+		},
+		"cluster min healthy": {
+			config: `{"cluster_min_healthy":[{"service":"httpbin","min_healthy_percent":50},
+				{"service":"echo","port":80,"min_healthy_percent":25},{"service":"missing","min_healthy_percent":10}]}`,
+			want: &envoy_extensions_filters_http_health_check_v3.HealthCheck{
+				PassThroughMode: &wrappers.BoolValue{Value: false},
+				Headers:         path("/healthz"),
+				ClusterMinHealthyPercentages: map[string]*envoy_type_v3.Percent{
+					"default/httpbin/80/da39a3ee5e":   {Value: 50},
+					"default/httpbin/8080/da39a3ee5e": {Value: 50},
+					"default/echo/80/da39a3ee5e":      {Value: 25},
+				},
+			},
+		},
+		"invalid config": {
+			config: `{"path":`,
+			want:   nil,
+		},
+		"min healthy percent above 100": {
+			config: `{"cluster_min_healthy":[{"service":"httpbin","min_healthy_percent":150}]}`,
+			want:   nil,
+		},
+		"negative min healthy percent": {
+			config: `{"cluster_min_healthy":[{"service":"httpbin","min_healthy_percent":-1}]}`,
+			want:   nil,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			f := &dag.HttpFilter{
+				Filter: dag.Filter{
+					Filter_name:   "healthcheck",
+					Filter_type:   cfg.FILTER_TYPE_HTTP_HEALTHCHECK,
+					Filter_config: tc.config,
+				},
+			}
+			got := httpHealthCheckConfig(f, vh)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestMergeHealthCheckFilters(t *testing.T) {
+	filter := func(config string) *dag.HttpFilter {
+		return &dag.HttpFilter{
+			Filter: dag.Filter{
+				Filter_name:   "healthcheck",
+				Filter_type:   cfg.FILTER_TYPE_HTTP_HEALTHCHECK,
+				Filter_config: config,
+			},
+		}
+	}
+	vhost := func(name, service string, f *dag.HttpFilter) *dag.VirtualHost {
+		return &dag.VirtualHost{
+			Name:        name,
+			HttpFilters: []*dag.HttpFilter{f},
+			Routes: map[string]*dag.Route{
+				"/": {
+					PathCondition: &dag.PrefixCondition{Prefix: "/"},
+					Clusters: []*dag.Cluster{{
+						Upstream: &dag.HTTPService{
+							TCPService: dag.TCPService{
+								Name:        service,
+								Namespace:   "default",
+								ServicePort: &v1.ServicePort{Port: 80},
+							},
+						},
+					}},
+				},
+			},
+		}
+	}
+	f := filter(`{"cluster_min_healthy":[{"service":"httpbin","min_healthy_percent":50},{"service":"echo","min_healthy_percent":25}]}`)
+	a := vhost("a.example.com", "httpbin", f)
+	b := vhost("b.example.com", "echo", f)
+
+	m := make(map[string]*envoy_extensions_filters_network_http_connection_manager_v3.HttpFilter)
+	buildHttpFilterMap(&[]*envoy_extensions_filters_network_http_connection_manager_v3.HttpFilter{}, a.HttpFilters, a, &m)
+	listenerFilters := []*envoy_extensions_filters_network_http_connection_manager_v3.HttpFilter{m[HTTPFilterHealthCheck]}
+	m = make(map[string]*envoy_extensions_filters_network_http_connection_manager_v3.HttpFilter)
+	buildHttpFilterMap(&listenerFilters, b.HttpFilters, b, &m)
+
+	var got envoy_extensions_filters_http_health_check_v3.HealthCheck
+	if err := types.UnmarshalAny(m[HTTPFilterHealthCheck].GetTypedConfig(), &got); err != nil {
+		t.Fatal(err)
+	}
+
+	want := &envoy_extensions_filters_http_health_check_v3.HealthCheck{
+		PassThroughMode: &wrappers.BoolValue{Value: false},
+		Headers: []*envoy_config_route_v3.HeaderMatcher{{
+			Name:                 ":path",
+			HeaderMatchSpecifier: &envoy_config_route_v3.HeaderMatcher_ExactMatch{ExactMatch: "/healthz"},
+		}},
+		ClusterMinHealthyPercentages: map[string]*envoy_type_v3.Percent{
+			"default/httpbin/80/da39a3ee5e": {Value: 50},
+			"default/echo/80/da39a3ee5e":    {Value: 25},
+		},
+	}
+	assert.Equal(t, want, &got)
+}
@@ -33,6 +33,50 @@ func DagFilterToHttpFilter(df *dag.HttpFilter, vh *dag.VirtualHost) *envoy_exten
 				ConfigType: httpCorsTypedConfig(df, vh),
 			}
 			return cors_http_filter
+		case cfg.FILTER_TYPE_HTTP_JWT:
+			config := httpJwtTypedConfig(vh)
+			if config == nil {
+				return nil
+			}
+			jwt_http_filter := &envoy_extensions_filters_network_http_connection_manager_v3.HttpFilter{
+				Name:       HTTPFilterJwt,
+				ConfigType: config,
+			}
+			return jwt_http_filter
+		case cfg.FILTER_TYPE_HTTP_EXTAUTHZ:
+			config := httpExtAuthzTypedConfig(df)
+			if config == nil {
+				return nil
+			}
+			extauthz_http_filter := &envoy_extensions_filters_network_http_connection_manager_v3.HttpFilter{
+				Name:       ExtAuthzFilterName(df.Filter_namespace, df.Filter_name),
+				ConfigType: config,
+			}
+			return extauthz_http_filter
+		case cfg.FILTER_TYPE_HTTP_WASM:
+			config := httpWasmTypedConfig(df.Filter, df.Cluster)
+			if config == nil {
+				return nil
+			}
+			wasm_http_filter := &envoy_extensions_filters_network_http_connection_manager_v3.HttpFilter{
+				Name:       WasmFilterName(df.Filter_namespace, df.Filter_name),
+				ConfigType: config,
+			}
+			return wasm_http_filter
+		case cfg.FILTER_TYPE_VH_RBAC:
+			return httpRbacFilter()
+		case cfg.FILTER_TYPE_VH_LOCAL_RATELIMIT:
+			return httpLocalRateLimitFilter()
+		case cfg.FILTER_TYPE_HTTP_HEALTHCHECK:
+			config := httpHealthCheckTypedConfig(df, vh)
+			if config == nil {
+				return nil
+			}
+			healthcheck_http_filter := &envoy_extensions_filters_network_http_connection_manager_v3.HttpFilter{
+				Name:       HTTPFilterHealthCheck,
+				ConfigType: config,
+			}
+			return healthcheck_http_filter
 
 		default:
 		}
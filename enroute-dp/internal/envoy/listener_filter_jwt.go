@@ -0,0 +1,400 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright(c) 2018-2023 Saaras Inc.
+
+package envoy
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	envoy_config_core_v3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	envoy_config_route_v3 "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	envoy_extensions_filters_http_jwt_authn_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/jwt_authn/v3"
+	envoy_extensions_filters_network_http_connection_manager_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+	types "github.com/golang/protobuf/ptypes"
+	"github.com/golang/protobuf/ptypes/any"
+	"github.com/saarasio/enroute/enroute-dp/internal/dag"
+	"github.com/saarasio/enroute/enroute-dp/internal/logger"
+	"github.com/saarasio/enroute/enroute-dp/internal/protobuf"
+	cfg "github.com/saarasio/enroute/enroute-dp/saarasconfig"
+)
+
+// HTTPFilterJwt is the name of the jwt_authn filter. The filter of the
+// listener holds the providers and requirements of all the virtual hosts
+// sharing it but has no rules, each route selects its requirement in its
+// per filter config.
+const HTTPFilterJwt = "envoy.jwt_authn"
+
+// defaultJwksTimeout is how long to wait for a remote JWKS unless the
+// filter config sets a timeout.
+const defaultJwksTimeout = 5 * time.Second
+
+// jwtRuleMatch is the path a JWT rule matches.
+type jwtRuleMatch struct {
+	prefix, path string
+}
+
+// jwtVirtualHost holds the providers of the JWT filters of a virtual host
+// and the paths each of them requires or bypasses verification for.
+type jwtVirtualHost struct {
+	providers map[string]*envoy_extensions_filters_http_jwt_authn_v3.JwtProvider
+	requires  map[jwtRuleMatch][]string
+	bypass    map[jwtRuleMatch]bool
+}
+
+func httpJwtTypedConfig(vh *dag.VirtualHost) *envoy_extensions_filters_network_http_connection_manager_v3.HttpFilter_TypedConfig {
+	jwt := httpJwtConfig(vh)
+	if jwt == nil {
+		return nil
+	}
+	return &envoy_extensions_filters_network_http_connection_manager_v3.HttpFilter_TypedConfig{
+		TypedConfig: toAny(jwt),
+	}
+}
+
+// httpJwtConfig returns the jwt_authn config of vh, the providers of its
+// JWT filters and the requirements its routes select, see SetupRouteJwt.
+// nil is returned if no filter has a valid provider.
+func httpJwtConfig(vh *dag.VirtualHost) *envoy_extensions_filters_http_jwt_authn_v3.JwtAuthentication {
+	jv := virtualHostJwt(vh)
+	if jv == nil {
+		return nil
+	}
+
+	requirements := make(map[string]*envoy_extensions_filters_http_jwt_authn_v3.JwtRequirement)
+	vh.Visit(func(v dag.Vertex) {
+		r, ok := v.(*dag.Route)
+		if !ok {
+			return
+		}
+		if providers := jv.routeProviders(vh, r); len(providers) > 0 {
+			requirements[jwtRequirementName(providers)] = jwtRequirement(providers)
+		}
+	})
+
+	return &envoy_extensions_filters_http_jwt_authn_v3.JwtAuthentication{
+		Providers:           jv.providers,
+		RequirementMap:      requirements,
+		BypassCorsPreflight: true,
+	}
+}
+
+// virtualHostJwt aggregates the JWT filters of vh, one provider per filter.
+// Providers are named after vh as the providers of all the virtual hosts
+// sharing a listener are set on its filter. nil is returned if no filter
+// has a valid provider.
+func virtualHostJwt(vh *dag.VirtualHost) *jwtVirtualHost {
+	if vh == nil {
+		return nil
+	}
+
+	jv := &jwtVirtualHost{
+		providers: make(map[string]*envoy_extensions_filters_http_jwt_authn_v3.JwtProvider),
+		requires:  make(map[jwtRuleMatch][]string),
+		bypass:    make(map[jwtRuleMatch]bool),
+	}
+
+	for _, f := range vh.HttpFilters {
+		if f.Filter_type != cfg.FILTER_TYPE_HTTP_JWT {
+			continue
+		}
+
+		jc, err := cfg.UnmarshalJwtConfig(f.Filter_config)
+		if err != nil {
+			if logger.EL.ELogger != nil {
+				logger.EL.ELogger.Errorf("internal:envoy:listener_filter_jwt:virtualHostJwt() Filter [%s] failed to decode config [%s]\n",
+					f.Filter_name, err)
+			}
+			continue
+		}
+
+		name := jc.Name
+		if name == "" {
+			name = f.Filter_name
+		}
+
+		provider := jwtProvider(f, jc)
+		if provider == nil {
+			if logger.EL.ELogger != nil {
+				logger.EL.ELogger.Infof("internal:envoy:listener_filter_jwt:virtualHostJwt() Filter [%s] has neither a JWKS service nor a local JWKS\n",
+					f.Filter_name)
+			}
+			continue
+		}
+		// RBAC policies match claims of the payload under name
+		provider.PayloadInMetadata = name
+		pname := vh.Name + "/" + name
+		jv.providers[pname] = provider
+
+		rules := jc.Route
+		if len(rules) == 0 {
+			rules = []cfg.JwtRule{{Prefix: "/"}}
+		}
+		for _, r := range rules {
+			m := jwtRuleMatch{prefix: r.Prefix, path: r.Path}
+			if m.prefix == "" && m.path == "" {
+				m.prefix = "/"
+			}
+			if r.Bypass {
+				jv.bypass[m] = true
+				continue
+			}
+			jv.requires[m] = append(jv.requires[m], pname)
+		}
+	}
+
+	if len(jv.providers) == 0 {
+		return nil
+	}
+	return jv
+}
+
+// SetupRouteJwt selects on rr the requirement of the route r of vh, or
+// disables the jwt_authn filter if the rules of vh require no JWT for r.
+func SetupRouteJwt(vh *dag.VirtualHost, r *dag.Route, rr *envoy_config_route_v3.Route) {
+	jv := virtualHostJwt(vh)
+	if jv == nil {
+		return
+	}
+
+	prc := &envoy_extensions_filters_http_jwt_authn_v3.PerRouteConfig{
+		RequirementSpecifier: &envoy_extensions_filters_http_jwt_authn_v3.PerRouteConfig_Disabled{
+			Disabled: true,
+		},
+	}
+	if providers := jv.routeProviders(vh, r); len(providers) > 0 {
+		prc.RequirementSpecifier = &envoy_extensions_filters_http_jwt_authn_v3.PerRouteConfig_RequirementName{
+			RequirementName: jwtRequirementName(providers),
+		}
+	}
+
+	if rr.TypedPerFilterConfig == nil {
+		rr.TypedPerFilterConfig = make(map[string]*any.Any)
+	}
+	rr.TypedPerFilterConfig[HTTPFilterJwt] = toAny(prc)
+}
+
+// routeProviders returns the providers any of which the route r of vh
+// requires a JWT of, none if it requires no JWT. The most specific rule
+// matching all the requests of r applies. A rule matching only some of
+// them applies too if it requires a JWT, unless a more specific route of
+// vh takes all of its requests, as r selects a single requirement and
+// none of the requests of r may go unverified.
+func (jv *jwtVirtualHost) routeProviders(vh *dag.VirtualHost, r *dag.Route) []string {
+	var cover *jwtRuleMatch
+	required := make(map[string]bool)
+
+	matches := make(map[jwtRuleMatch]bool)
+	for m := range jv.requires {
+		matches[m] = true
+	}
+	for m := range jv.bypass {
+		matches[m] = true
+	}
+
+	for m := range matches {
+		switch {
+		case jwtRuleCovers(m, r.PathCondition):
+			if cover == nil || m.path != "" || (cover.path == "" && len(m.prefix) > len(cover.prefix)) {
+				m := m
+				cover = &m
+			}
+		case jv.bypass[m]:
+			continue
+		case jwtRuleOverlaps(m, r.PathCondition) && !jwtRuleTakenFrom(m, vh, r):
+			for _, p := range jv.requires[m] {
+				required[p] = true
+			}
+		}
+	}
+	if cover != nil && !jv.bypass[*cover] {
+		for _, p := range jv.requires[*cover] {
+			required[p] = true
+		}
+	}
+
+	var providers []string
+	for p := range required {
+		providers = append(providers, p)
+	}
+	sort.Strings(providers)
+	return providers
+}
+
+// jwtRuleCovers returns true if m matches all the requests of a route
+// matching cond.
+func jwtRuleCovers(m jwtRuleMatch, cond dag.Condition) bool {
+	switch c := cond.(type) {
+	case *dag.PrefixCondition:
+		return m.path == "" && strings.HasPrefix(c.Prefix, m.prefix)
+	case *dag.ExactCondition:
+		if m.path != "" {
+			return m.path == c.Path
+		}
+		return strings.HasPrefix(c.Path, m.prefix)
+	default:
+		return m.path == "" && m.prefix == "/"
+	}
+}
+
+// jwtRuleOverlaps returns true if m may match some of the requests of a
+// route matching cond.
+func jwtRuleOverlaps(m jwtRuleMatch, cond dag.Condition) bool {
+	switch c := cond.(type) {
+	case *dag.PrefixCondition:
+		return strings.HasPrefix(m.path+m.prefix, c.Prefix)
+	case *dag.ExactCondition:
+		return false
+	default:
+		return true
+	}
+}
+
+// jwtRuleTakenFrom returns true if a route of vh matched before the prefix
+// route r matches all the requests m matches.
+func jwtRuleTakenFrom(m jwtRuleMatch, vh *dag.VirtualHost, r *dag.Route) bool {
+	pc, ok := r.PathCondition.(*dag.PrefixCondition)
+	if !ok {
+		return false
+	}
+	for _, other := range vh.Routes {
+		if other == r || len(other.Clusters) == 0 ||
+			len(other.HeaderConditions) > 0 || len(other.QueryParamConditions) > 0 {
+			continue
+		}
+		switch c := other.PathCondition.(type) {
+		case *dag.PrefixCondition:
+			if len(c.Prefix) > len(pc.Prefix) && strings.HasPrefix(m.path+m.prefix, c.Prefix) {
+				return true
+			}
+		case *dag.ExactCondition:
+			if m.path != "" && m.path == c.Path {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// jwtRequirementName returns the name of the requirement of providers.
+func jwtRequirementName(providers []string) string {
+	return strings.Join(providers, ",")
+}
+
+// mergeJwtFilters returns a jwt_authn filter with the providers and
+// requirements of both a and b, b taking precedence.
+func mergeJwtFilters(a, b *envoy_extensions_filters_network_http_connection_manager_v3.HttpFilter) *envoy_extensions_filters_network_http_connection_manager_v3.HttpFilter {
+	var ja, jb envoy_extensions_filters_http_jwt_authn_v3.JwtAuthentication
+	if err := types.UnmarshalAny(a.GetTypedConfig(), &ja); err != nil {
+		return b
+	}
+	if err := types.UnmarshalAny(b.GetTypedConfig(), &jb); err != nil {
+		return a
+	}
+
+	jwt := &envoy_extensions_filters_http_jwt_authn_v3.JwtAuthentication{
+		Providers:           make(map[string]*envoy_extensions_filters_http_jwt_authn_v3.JwtProvider),
+		RequirementMap:      make(map[string]*envoy_extensions_filters_http_jwt_authn_v3.JwtRequirement),
+		BypassCorsPreflight: true,
+	}
+	for _, j := range []*envoy_extensions_filters_http_jwt_authn_v3.JwtAuthentication{&ja, &jb} {
+		for name, p := range j.Providers {
+			jwt.Providers[name] = p
+		}
+		for name, r := range j.RequirementMap {
+			jwt.RequirementMap[name] = r
+		}
+	}
+
+	return &envoy_extensions_filters_network_http_connection_manager_v3.HttpFilter{
+		Name: HTTPFilterJwt,
+		ConfigType: &envoy_extensions_filters_network_http_connection_manager_v3.HttpFilter_TypedConfig{
+			TypedConfig: toAny(jwt),
+		},
+	}
+}
+
+// jwtProvider returns the provider of a JWT filter, nil if the filter has
+// no JWKS to verify tokens with.
+func jwtProvider(f *dag.HttpFilter, jc cfg.JwtConfig) *envoy_extensions_filters_http_jwt_authn_v3.JwtProvider {
+	provider := &envoy_extensions_filters_http_jwt_authn_v3.JwtProvider{
+		Issuer:               jc.Issuer,
+		Forward:              jc.Forward,
+		ForwardPayloadHeader: jc.Jwt_forward_header_name,
+	}
+
+	if jc.Audience != "" {
+		provider.Audiences = append(provider.Audiences, jc.Audience)
+	}
+	provider.Audiences = append(provider.Audiences, jc.Audiences...)
+
+	for _, c := range jc.ClaimToHeaders {
+		provider.ClaimToHeaders = append(provider.ClaimToHeaders, &envoy_extensions_filters_http_jwt_authn_v3.JwtClaimToHeader{
+			HeaderName: c.HeaderName,
+			ClaimName:  c.ClaimName,
+		})
+	}
+
+	switch {
+	case jc.LocalJwks != "":
+		provider.JwksSourceSpecifier = &envoy_extensions_filters_http_jwt_authn_v3.JwtProvider_LocalJwks{
+			LocalJwks: &envoy_config_core_v3.DataSource{
+				Specifier: &envoy_config_core_v3.DataSource_InlineString{
+					InlineString: jc.LocalJwks,
+				},
+			},
+		}
+	case jc.JwksUri != "" && f.Cluster != nil:
+		timeout := defaultJwksTimeout
+		if jc.Timeout > 0 {
+			timeout = time.Duration(jc.Timeout) * time.Second
+		}
+		remote := &envoy_extensions_filters_http_jwt_authn_v3.RemoteJwks{
+			HttpUri: &envoy_config_core_v3.HttpUri{
+				Uri: jc.JwksUri,
+				HttpUpstreamType: &envoy_config_core_v3.HttpUri_Cluster{
+					Cluster: Clustername(f.Cluster),
+				},
+				Timeout: protobuf.Duration(timeout),
+			},
+		}
+		if jc.CacheDuration > 0 {
+			remote.CacheDuration = protobuf.Duration(time.Duration(jc.CacheDuration) * time.Second)
+		}
+		provider.JwksSourceSpecifier = &envoy_extensions_filters_http_jwt_authn_v3.JwtProvider_RemoteJwks{
+			RemoteJwks: remote,
+		}
+	default:
+		return nil
+	}
+
+	return provider
+}
+
+// jwtRequirement returns a requirement satisfied by a JWT verified by any
+// of providers.
+func jwtRequirement(providers []string) *envoy_extensions_filters_http_jwt_authn_v3.JwtRequirement {
+	if len(providers) == 1 {
+		return &envoy_extensions_filters_http_jwt_authn_v3.JwtRequirement{
+			RequiresType: &envoy_extensions_filters_http_jwt_authn_v3.JwtRequirement_ProviderName{
+				ProviderName: providers[0],
+			},
+		}
+	}
+
+	sort.Strings(providers)
+	var any envoy_extensions_filters_http_jwt_authn_v3.JwtRequirementOrList
+	for _, p := range providers {
+		any.Requirements = append(any.Requirements, &envoy_extensions_filters_http_jwt_authn_v3.JwtRequirement{
+			RequiresType: &envoy_extensions_filters_http_jwt_authn_v3.JwtRequirement_ProviderName{
+				ProviderName: p,
+			},
+		})
+	}
+	return &envoy_extensions_filters_http_jwt_authn_v3.JwtRequirement{
+		RequiresType: &envoy_extensions_filters_http_jwt_authn_v3.JwtRequirement_RequiresAny{
+			RequiresAny: &any,
+		},
+	}
+}
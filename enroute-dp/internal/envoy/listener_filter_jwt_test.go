@@ -0,0 +1,304 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright(c) 2018-2023 Saaras Inc.
+
+package envoy
+
+import (
+	"testing"
+	"time"
+
+	envoy_config_core_v3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	envoy_config_route_v3 "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	envoy_extensions_filters_http_jwt_authn_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/jwt_authn/v3"
+	envoy_extensions_filters_network_http_connection_manager_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+	types "github.com/golang/protobuf/ptypes"
+	"github.com/golang/protobuf/ptypes/any"
+	"github.com/saarasio/enroute/enroute-dp/internal/assert"
+	"github.com/saarasio/enroute/enroute-dp/internal/dag"
+	"github.com/saarasio/enroute/enroute-dp/internal/protobuf"
+	cfg "github.com/saarasio/enroute/enroute-dp/saarasconfig"
+	v1 "k8s.io/api/core/v1"
+)
+
+func jwtFilter(name, config string, cluster *dag.Cluster) *dag.HttpFilter {
+	return &dag.HttpFilter{
+		Filter: dag.Filter{
+			Filter_name:   name,
+			Filter_type:   cfg.FILTER_TYPE_HTTP_JWT,
+			Filter_config: config,
+		},
+		Cluster: cluster,
+	}
+}
+
+func prefixRoute(prefix string) *dag.Route {
+	return &dag.Route{
+		PathCondition: &dag.PrefixCondition{Prefix: prefix},
+		Clusters:      []*dag.Cluster{{}},
+	}
+}
+
+func exactRoute(path string) *dag.Route {
+	return &dag.Route{
+		PathCondition: &dag.ExactCondition{Path: path},
+		Clusters:      []*dag.Cluster{{}},
+	}
+}
+
+func jwtVirtualHostRoutes(filters []*dag.HttpFilter, routes ...*dag.Route) *dag.VirtualHost {
+	vh := &dag.VirtualHost{Name: "www.example.com", HttpFilters: filters, Routes: make(map[string]*dag.Route)}
+	for _, r := range routes {
+		vh.Routes[r.PathCondition.String()] = r
+	}
+	return vh
+}
+
+func TestHttpJwtConfig(t *testing.T) {
+	jwks := &dag.Cluster{
+		Upstream: &dag.HTTPService{
+			TCPService: dag.TCPService{
+				Name:      "auth0",
+				Namespace: "default",
+				ServicePort: &v1.ServicePort{
+					Protocol: "TCP",
+					Port:     443,
+				},
+			},
+		},
+	}
+	remote := `{"name":"auth0","issuer":"https://saaras.auth0.com/","audience":"api.saaras.io",
+		"jwks_uri":"https://saaras.auth0.com/.well-known/jwks.json","jwt_forward_header_name":"x-jwt-payload"}`
+
+	tests := map[string]struct {
+		filters []*dag.HttpFilter
+		routes  []*dag.Route
+		want    *envoy_extensions_filters_http_jwt_authn_v3.JwtAuthentication
+	}{
+		"no jwt filter": {
+			filters: []*dag.HttpFilter{{
+				Filter: dag.Filter{Filter_name: "lua", Filter_type: cfg.FILTER_TYPE_HTTP_LUA},
+			}},
+			want: nil,
+		},
+		"remote jwks without a cluster": {
+			filters: []*dag.HttpFilter{jwtFilter("auth0", remote, nil)},
+			want:    nil,
+		},
+		"remote jwks": {
+			filters: []*dag.HttpFilter{jwtFilter("auth0", remote, jwks)},
+			routes:  []*dag.Route{prefixRoute("/")},
+			want: &envoy_extensions_filters_http_jwt_authn_v3.JwtAuthentication{
+				Providers: map[string]*envoy_extensions_filters_http_jwt_authn_v3.JwtProvider{
+					"www.example.com/auth0": {
+						Issuer:               "https://saaras.auth0.com/",
+						Audiences:            []string{"api.saaras.io"},
+						ForwardPayloadHeader: "x-jwt-payload",
+						PayloadInMetadata:    "auth0",
+						JwksSourceSpecifier: &envoy_extensions_filters_http_jwt_authn_v3.JwtProvider_RemoteJwks{
+							RemoteJwks: &envoy_extensions_filters_http_jwt_authn_v3.RemoteJwks{
+								HttpUri: &envoy_config_core_v3.HttpUri{
+									Uri: "https://saaras.auth0.com/.well-known/jwks.json",
+									HttpUpstreamType: &envoy_config_core_v3.HttpUri_Cluster{
+										Cluster: "default/auth0/443/da39a3ee5e",
+									},
+									Timeout: protobuf.Duration(5 * time.Second),
+								},
+							},
+						},
+					},
+				},
+				RequirementMap: map[string]*envoy_extensions_filters_http_jwt_authn_v3.JwtRequirement{
+					"www.example.com/auth0": jwtRequirement([]string{"www.example.com/auth0"}),
+				},
+				BypassCorsPreflight: true,
+			},
+		},
+		"local jwks, claims and bypassed path": {
+			filters: []*dag.HttpFilter{jwtFilter("local", `{"name":"local","local_jwks":"{}","forward":true,
+				"claim_to_headers":[{"header_name":"x-sub","claim_name":"sub"}],
+				"route":[{"prefix":"/api"},{"path":"/api/healthz","bypass":true}]}`, nil)},
+			routes: []*dag.Route{prefixRoute("/"), prefixRoute("/api"), exactRoute("/api/healthz")},
+			want: &envoy_extensions_filters_http_jwt_authn_v3.JwtAuthentication{
+				Providers: map[string]*envoy_extensions_filters_http_jwt_authn_v3.JwtProvider{
+					"www.example.com/local": {
+						Forward:           true,
+						PayloadInMetadata: "local",
+						ClaimToHeaders: []*envoy_extensions_filters_http_jwt_authn_v3.JwtClaimToHeader{{
+							HeaderName: "x-sub",
+							ClaimName:  "sub",
+						}},
+						JwksSourceSpecifier: &envoy_extensions_filters_http_jwt_authn_v3.JwtProvider_LocalJwks{
+							LocalJwks: &envoy_config_core_v3.DataSource{
+								Specifier: &envoy_config_core_v3.DataSource_InlineString{InlineString: "{}"},
+							},
+						},
+					},
+				},
+				RequirementMap: map[string]*envoy_extensions_filters_http_jwt_authn_v3.JwtRequirement{
+					"www.example.com/local": jwtRequirement([]string{"www.example.com/local"}),
+				},
+				BypassCorsPreflight: true,
+			},
+		},
+		"issuers sharing a prefix": {
+			filters: []*dag.HttpFilter{
+				jwtFilter("b", `{"name":"b","local_jwks":"{}","route":[{"prefix":"/"},{"prefix":"/admin"}]}`, nil),
+				jwtFilter("a", `{"name":"a","local_jwks":"{}"}`, nil),
+			},
+			routes: []*dag.Route{prefixRoute("/"), prefixRoute("/admin/users")},
+			want: &envoy_extensions_filters_http_jwt_authn_v3.JwtAuthentication{
+				Providers: map[string]*envoy_extensions_filters_http_jwt_authn_v3.JwtProvider{
+					"www.example.com/a": {
+						PayloadInMetadata: "a",
+						JwksSourceSpecifier: &envoy_extensions_filters_http_jwt_authn_v3.JwtProvider_LocalJwks{
+							LocalJwks: &envoy_config_core_v3.DataSource{
+								Specifier: &envoy_config_core_v3.DataSource_InlineString{InlineString: "{}"},
+							},
+						},
+					},
+					"www.example.com/b": {
+						PayloadInMetadata: "b",
+						JwksSourceSpecifier: &envoy_extensions_filters_http_jwt_authn_v3.JwtProvider_LocalJwks{
+							LocalJwks: &envoy_config_core_v3.DataSource{
+								Specifier: &envoy_config_core_v3.DataSource_InlineString{InlineString: "{}"},
+							},
+						},
+					},
+				},
+				RequirementMap: map[string]*envoy_extensions_filters_http_jwt_authn_v3.JwtRequirement{
+					"www.example.com/b":                   jwtRequirement([]string{"www.example.com/b"}),
+					"www.example.com/a,www.example.com/b": jwtRequirement([]string{"www.example.com/a", "www.example.com/b"}),
+				},
+				BypassCorsPreflight: true,
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := httpJwtConfig(jwtVirtualHostRoutes(tc.filters, tc.routes...))
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestSetupRouteJwt(t *testing.T) {
+	api := jwtFilter("api", `{"name":"api","local_jwks":"{}",
+		"route":[{"prefix":"/api"},{"path":"/api/healthz","bypass":true}]}`, nil)
+	all := jwtFilter("all", `{"name":"all","local_jwks":"{}","route":[{"prefix":"/"},{"prefix":"/public","bypass":true}]}`, nil)
+
+	disabled := &envoy_extensions_filters_http_jwt_authn_v3.PerRouteConfig{
+		RequirementSpecifier: &envoy_extensions_filters_http_jwt_authn_v3.PerRouteConfig_Disabled{Disabled: true},
+	}
+	requires := func(name string) *envoy_extensions_filters_http_jwt_authn_v3.PerRouteConfig {
+		return &envoy_extensions_filters_http_jwt_authn_v3.PerRouteConfig{
+			RequirementSpecifier: &envoy_extensions_filters_http_jwt_authn_v3.PerRouteConfig_RequirementName{RequirementName: name},
+		}
+	}
+
+	tests := map[string]struct {
+		filters []*dag.HttpFilter
+		routes  []*dag.Route
+		route   *dag.Route
+		want    *envoy_extensions_filters_http_jwt_authn_v3.PerRouteConfig
+	}{
+		"no jwt filter": {
+			route: prefixRoute("/api"),
+			want:  nil,
+		},
+		"route under a required prefix": {
+			filters: []*dag.HttpFilter{api},
+			route:   prefixRoute("/api/v1"),
+			want:    requires("www.example.com/api"),
+		},
+		"route outside the required prefix": {
+			filters: []*dag.HttpFilter{api},
+			route:   prefixRoute("/static"),
+			want:    disabled,
+		},
+		"bypassed path": {
+			filters: []*dag.HttpFilter{api},
+			route:   exactRoute("/api/healthz"),
+			want:    disabled,
+		},
+		"route overlapping a required prefix": {
+			filters: []*dag.HttpFilter{api},
+			route:   prefixRoute("/"),
+			want:    requires("www.example.com/api"),
+		},
+		"required prefix taken by a more specific route": {
+			filters: []*dag.HttpFilter{api},
+			routes:  []*dag.Route{prefixRoute("/api")},
+			route:   prefixRoute("/"),
+			want:    disabled,
+		},
+		"regex route": {
+			filters: []*dag.HttpFilter{api},
+			route: &dag.Route{
+				PathCondition: &dag.RegexCondition{Regex: "/.*"},
+				Clusters:      []*dag.Cluster{{}},
+			},
+			want: requires("www.example.com/api"),
+		},
+		"bypassed prefix": {
+			filters: []*dag.HttpFilter{api, all},
+			route:   prefixRoute("/public/css"),
+			want:    disabled,
+		},
+		"providers of several filters": {
+			filters: []*dag.HttpFilter{api, all},
+			route:   prefixRoute("/"),
+			want:    requires("www.example.com/all,www.example.com/api"),
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			vh := jwtVirtualHostRoutes(tc.filters, append(tc.routes, tc.route)...)
+			rr := &envoy_config_route_v3.Route{}
+			SetupRouteJwt(vh, tc.route, rr)
+			var want map[string]*any.Any
+			if tc.want != nil {
+				want = map[string]*any.Any{HTTPFilterJwt: toAny(tc.want)}
+			}
+			assert.Equal(t, &envoy_config_route_v3.Route{TypedPerFilterConfig: want}, rr)
+		})
+	}
+}
+
+func TestMergeJwtFilters(t *testing.T) {
+	a := &dag.VirtualHost{
+		Name:        "a.example.com",
+		HttpFilters: []*dag.HttpFilter{jwtFilter("jwt", `{"name":"jwt","local_jwks":"{}"}`, nil)},
+		Routes:      map[string]*dag.Route{"/": prefixRoute("/")},
+	}
+	b := &dag.VirtualHost{
+		Name:        "b.example.com",
+		HttpFilters: []*dag.HttpFilter{jwtFilter("jwt", `{"name":"jwt","local_jwks":"{}"}`, nil)},
+		Routes:      map[string]*dag.Route{"/": prefixRoute("/")},
+	}
+
+	m := make(map[string]*envoy_extensions_filters_network_http_connection_manager_v3.HttpFilter)
+	buildHttpFilterMap(&[]*envoy_extensions_filters_network_http_connection_manager_v3.HttpFilter{}, a.HttpFilters, a, &m)
+	listenerFilters := []*envoy_extensions_filters_network_http_connection_manager_v3.HttpFilter{m[HTTPFilterJwt]}
+	m = make(map[string]*envoy_extensions_filters_network_http_connection_manager_v3.HttpFilter)
+	buildHttpFilterMap(&listenerFilters, b.HttpFilters, b, &m)
+
+	var got envoy_extensions_filters_http_jwt_authn_v3.JwtAuthentication
+	if err := types.UnmarshalAny(m[HTTPFilterJwt].GetTypedConfig(), &got); err != nil {
+		t.Fatal(err)
+	}
+
+	want := &envoy_extensions_filters_http_jwt_authn_v3.JwtAuthentication{
+		Providers: map[string]*envoy_extensions_filters_http_jwt_authn_v3.JwtProvider{
+			"a.example.com/jwt": httpJwtConfig(a).Providers["a.example.com/jwt"],
+			"b.example.com/jwt": httpJwtConfig(b).Providers["b.example.com/jwt"],
+		},
+		RequirementMap: map[string]*envoy_extensions_filters_http_jwt_authn_v3.JwtRequirement{
+			"a.example.com/jwt": jwtRequirement([]string{"a.example.com/jwt"}),
+			"b.example.com/jwt": jwtRequirement([]string{"b.example.com/jwt"}),
+		},
+		BypassCorsPreflight: true,
+	}
+	assert.Equal(t, want, &got)
+}
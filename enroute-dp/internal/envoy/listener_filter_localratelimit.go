@@ -0,0 +1,340 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright(c) 2018-2023 Saaras Inc.
+
+package envoy
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"time"
+
+	envoy_config_accesslog_v3 "github.com/envoyproxy/go-control-plane/envoy/config/accesslog/v3"
+	envoy_config_core_v3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	envoy_config_listener_v3 "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
+	envoy_config_route_v3 "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	envoy_extensions_common_ratelimit_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/common/ratelimit/v3"
+	envoy_extensions_filters_http_local_ratelimit_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/local_ratelimit/v3"
+	envoy_extensions_filters_network_http_connection_manager_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+	envoy_type_v3 "github.com/envoyproxy/go-control-plane/envoy/type/v3"
+	"github.com/golang/protobuf/ptypes/any"
+	"github.com/saarasio/enroute/enroute-dp/internal/dag"
+	"github.com/saarasio/enroute/enroute-dp/internal/logger"
+	"github.com/saarasio/enroute/enroute-dp/internal/protobuf"
+	cfg "github.com/saarasio/enroute/enroute-dp/saarasconfig"
+)
+
+// HTTPFilterLocalRateLimit is the name of the local rate limit filter. The
+// filter of the listener limits nothing, the token buckets of each virtual
+// host and route are set in their per filter config.
+const HTTPFilterLocalRateLimit = "envoy.filters.http.local_ratelimit"
+
+const localRateLimitStatPrefix = "http_local_rate_limiter"
+
+// localRateLimitStage is the stage of the rate limit actions of local rate
+// limit filters, actions of route_filter_ratelimit are at stage 0 and only
+// sent to the ratelimit service.
+const localRateLimitStage = 1
+
+func httpLocalRateLimitFilter() *envoy_extensions_filters_network_http_connection_manager_v3.HttpFilter {
+	return &envoy_extensions_filters_network_http_connection_manager_v3.HttpFilter{
+		Name: HTTPFilterLocalRateLimit,
+		ConfigType: &envoy_extensions_filters_network_http_connection_manager_v3.HttpFilter_TypedConfig{
+			TypedConfig: toAny(&envoy_extensions_filters_http_local_ratelimit_v3.LocalRateLimit{
+				StatPrefix: localRateLimitStatPrefix,
+			}),
+		},
+	}
+}
+
+// LocalRateLimitRouteFilter returns the local rate limit filter attached to
+// r, nil if none is.
+func LocalRateLimitRouteFilter(r *dag.Route) *dag.RouteFilter {
+	for _, rf := range r.RouteFilters {
+		if rf.Filter_type == cfg.FILTER_TYPE_VH_LOCAL_RATELIMIT {
+			return rf
+		}
+	}
+	return nil
+}
+
+// addLocalRateLimitRouteFilter adds the local rate limit filter to m if a
+// route of vh has one.
+func addLocalRateLimitRouteFilter(vh *dag.VirtualHost, m *map[string]*envoy_extensions_filters_network_http_connection_manager_v3.HttpFilter) {
+	if vh == nil {
+		return
+	}
+	if _, ok := (*m)[HTTPFilterLocalRateLimit]; ok {
+		return
+	}
+
+	vh.Visit(func(v dag.Vertex) {
+		if r, ok := v.(*dag.Route); ok && LocalRateLimitRouteFilter(r) != nil {
+			(*m)[HTTPFilterLocalRateLimit] = httpLocalRateLimitFilter()
+		}
+	})
+}
+
+// unmarshalLocalRateLimitConfig returns the config of f, false if it is
+// invalid.
+func unmarshalLocalRateLimitConfig(f dag.Filter) (cfg.LocalRateLimitConfig, bool) {
+	lrl, err := cfg.UnmarshalLocalRateLimitConfig(f.Filter_config)
+	if err != nil {
+		if logger.EL.ELogger != nil {
+			logger.EL.ELogger.Errorf("internal:envoy:listener_filter_localratelimit:unmarshalLocalRateLimitConfig() Filter [%s] failed to decode config [%s]\n",
+				f.Filter_name, err)
+		}
+		return lrl, false
+	}
+	return lrl, true
+}
+
+// localRateLimitTokenBucket returns the token bucket of tb.
+func localRateLimitTokenBucket(tb cfg.LocalRateLimitTokenBucket) (*envoy_type_v3.TokenBucket, error) {
+	if tb.MaxTokens == 0 {
+		return nil, fmt.Errorf("token bucket requires max_tokens")
+	}
+	tokensPerFill := tb.TokensPerFill
+	if tokensPerFill == 0 {
+		tokensPerFill = tb.MaxTokens
+	}
+	fillInterval := time.Second
+	if tb.FillInterval != "" {
+		d, err := time.ParseDuration(tb.FillInterval)
+		if err != nil {
+			return nil, err
+		}
+		if d < 50*time.Millisecond {
+			return nil, fmt.Errorf("fill_interval %s is less than 50ms", tb.FillInterval)
+		}
+		fillInterval = d
+	}
+	return &envoy_type_v3.TokenBucket{
+		MaxTokens:     tb.MaxTokens,
+		TokensPerFill: protobuf.UInt32(tokensPerFill),
+		FillInterval:  protobuf.Duration(fillInterval),
+	}, nil
+}
+
+func localRateLimitEnabled(runtimeKey string) *envoy_config_core_v3.RuntimeFractionalPercent {
+	return &envoy_config_core_v3.RuntimeFractionalPercent{
+		DefaultValue: &envoy_type_v3.FractionalPercent{
+			Numerator:   100,
+			Denominator: envoy_type_v3.FractionalPercent_HUNDRED,
+		},
+		RuntimeKey: runtimeKey,
+	}
+}
+
+// LocalRateLimit returns the per filter config of a local rate limit filter.
+func LocalRateLimit(lrl cfg.LocalRateLimitConfig) (*envoy_extensions_filters_http_local_ratelimit_v3.LocalRateLimit, error) {
+	if lrl.Disabled {
+		// the filter is not enabled for any request
+		return &envoy_extensions_filters_http_local_ratelimit_v3.LocalRateLimit{
+			StatPrefix: localRateLimitStatPrefix,
+		}, nil
+	}
+
+	tb, err := localRateLimitTokenBucket(lrl.LocalRateLimitTokenBucket)
+	if err != nil {
+		return nil, err
+	}
+	config := &envoy_extensions_filters_http_local_ratelimit_v3.LocalRateLimit{
+		StatPrefix:     localRateLimitStatPrefix,
+		TokenBucket:    tb,
+		FilterEnabled:  localRateLimitEnabled("enroute.local_rate_limit.enabled"),
+		FilterEnforced: localRateLimitEnabled("enroute.local_rate_limit.enforced"),
+		Stage:          localRateLimitStage,
+		VhRateLimits:   envoy_extensions_common_ratelimit_v3.VhRateLimitsOptions_INCLUDE,
+	}
+	if lrl.StatusCode != 0 {
+		config.Status = &envoy_type_v3.HttpStatus{Code: envoy_type_v3.StatusCode(lrl.StatusCode)}
+	}
+	if lrl.ResponseHeaders {
+		config.EnableXRatelimitHeaders = envoy_extensions_common_ratelimit_v3.XRateLimitHeadersRFCVersion_DRAFT_VERSION_03
+	}
+	for _, d := range lrl.Descriptors {
+		if len(d.Entries) == 0 {
+			return nil, fmt.Errorf("descriptor requires entries")
+		}
+		tb, err := localRateLimitTokenBucket(d.LocalRateLimitTokenBucket)
+		if err != nil {
+			return nil, err
+		}
+		descriptor := &envoy_extensions_common_ratelimit_v3.LocalRateLimitDescriptor{
+			TokenBucket: tb,
+		}
+		for _, e := range d.Entries {
+			descriptor.Entries = append(descriptor.Entries, &envoy_extensions_common_ratelimit_v3.RateLimitDescriptor_Entry{
+				Key:   e.Key,
+				Value: e.Value,
+			})
+		}
+		config.Descriptors = append(config.Descriptors, descriptor)
+	}
+	return config, nil
+}
+
+// localRateLimits returns the rate limit actions producing the descriptor
+// entries of lrl, nil if it has none.
+func localRateLimits(lrl cfg.LocalRateLimitConfig) []*envoy_config_route_v3.RateLimit {
+	actions := rateLimitActions(lrl.Actions)
+	if lrl.Disabled || len(actions) == 0 {
+		return nil
+	}
+	return []*envoy_config_route_v3.RateLimit{{
+		Stage:   protobuf.UInt32(localRateLimitStage),
+		Actions: actions,
+	}}
+}
+
+// SetupVirtualHostLocalRateLimit sets the token buckets of the local rate
+// limit filter of vh on vhost.
+func SetupVirtualHostLocalRateLimit(vh *dag.VirtualHost, vhost *envoy_config_route_v3.VirtualHost) {
+	hf := dag.GetVHHttpFilterConfigIfPresent(cfg.FILTER_TYPE_VH_LOCAL_RATELIMIT, vh)
+	if hf == nil {
+		return
+	}
+	lrl, ok := unmarshalLocalRateLimitConfig(hf.Filter)
+	if !ok {
+		return
+	}
+	config, err := LocalRateLimit(lrl)
+	if err != nil {
+		if logger.EL.ELogger != nil {
+			logger.EL.ELogger.Errorf("internal:envoy:listener_filter_localratelimit:SetupVirtualHostLocalRateLimit() Filter [%s] invalid config [%s]\n",
+				hf.Filter_name, err)
+		}
+		return
+	}
+
+	if vhost.TypedPerFilterConfig == nil {
+		vhost.TypedPerFilterConfig = make(map[string]*any.Any)
+	}
+	vhost.TypedPerFilterConfig[HTTPFilterLocalRateLimit] = toAny(config)
+	vhost.RateLimits = append(vhost.RateLimits, localRateLimits(lrl)...)
+}
+
+// SetupRouteLocalRateLimit sets the token buckets of the local rate limit
+// filter of r on rr, they override those of its virtual host.
+func SetupRouteLocalRateLimit(r *dag.Route, rr *envoy_config_route_v3.Route) {
+	rf := LocalRateLimitRouteFilter(r)
+	if rf == nil {
+		return
+	}
+	lrl, ok := unmarshalLocalRateLimitConfig(rf.Filter)
+	if !ok {
+		return
+	}
+	config, err := LocalRateLimit(lrl)
+	if err != nil {
+		if logger.EL.ELogger != nil {
+			logger.EL.ELogger.Errorf("internal:envoy:listener_filter_localratelimit:SetupRouteLocalRateLimit() Filter [%s] invalid config [%s]\n",
+				rf.Filter_name, err)
+		}
+		return
+	}
+
+	if rr.TypedPerFilterConfig == nil {
+		rr.TypedPerFilterConfig = make(map[string]*any.Any)
+	}
+	rr.TypedPerFilterConfig[HTTPFilterLocalRateLimit] = toAny(config)
+	if ra := rr.GetRoute(); ra != nil {
+		ra.RateLimits = append(ra.RateLimits, localRateLimits(lrl)...)
+	}
+}
+
+// rateLimitedAccessLogFilter matches requests that were rate limited.
+func rateLimitedAccessLogFilter() *envoy_config_accesslog_v3.AccessLogFilter {
+	return &envoy_config_accesslog_v3.AccessLogFilter{
+		FilterSpecifier: &envoy_config_accesslog_v3.AccessLogFilter_ResponseFlagFilter{
+			ResponseFlagFilter: &envoy_config_accesslog_v3.ResponseFlagFilter{
+				Flags: []string{"RL"},
+			},
+		},
+	}
+}
+
+// routePathAccessLogFilter matches requests whose path matches the path
+// condition of r, nil if r has none.
+func routePathAccessLogFilter(r *dag.Route) *envoy_config_accesslog_v3.AccessLogFilter {
+	header := &envoy_config_route_v3.HeaderMatcher{Name: ":path"}
+	switch c := r.PathCondition.(type) {
+	case *dag.PrefixCondition:
+		header.HeaderMatchSpecifier = &envoy_config_route_v3.HeaderMatcher_PrefixMatch{PrefixMatch: c.Prefix}
+	case *dag.ExactCondition:
+		// the route matches the path without its query string
+		header.HeaderMatchSpecifier = &envoy_config_route_v3.HeaderMatcher_SafeRegexMatch{
+			SafeRegexMatch: SafeRegexMatch(regexp.QuoteMeta(c.Path) + `(\?.*)?`),
+		}
+	case *dag.RegexCondition:
+		// the route matches the path without its query string
+		header.HeaderMatchSpecifier = &envoy_config_route_v3.HeaderMatcher_SafeRegexMatch{
+			SafeRegexMatch: SafeRegexMatch(c.Regex + `(\?.*)?`),
+		}
+	default:
+		return nil
+	}
+	return headerAccessLogFilter(header)
+}
+
+// localRateLimitMapper returns the mapper of the body of requests rate
+// limited by lrl, nil if it has none.
+func localRateLimitMapper(lrl cfg.LocalRateLimitConfig, filters ...*envoy_config_accesslog_v3.AccessLogFilter) (*envoy_extensions_filters_network_http_connection_manager_v3.ResponseMapper, error) {
+	if lrl.Disabled || lrl.ResponseBody == nil {
+		return nil, nil
+	}
+	format, err := localReplyBodyFormat(*lrl.ResponseBody)
+	if err != nil || format == nil {
+		return nil, err
+	}
+	return &envoy_extensions_filters_network_http_connection_manager_v3.ResponseMapper{
+		Filter:             andAccessLogFilter(append([]*envoy_config_accesslog_v3.AccessLogFilter{rateLimitedAccessLogFilter()}, filters...)),
+		BodyFormatOverride: format,
+	}, nil
+}
+
+// AddVirtualHostLocalRateLimitReplies adds the response bodies of the local
+// rate limit filters of vh and of its routes to the HTTP connection manager
+// of l serving vh. The bodies of routes are matched before that of vh.
+func AddVirtualHostLocalRateLimitReplies(l *envoy_config_listener_v3.Listener, vh *dag.VirtualHost) {
+	var mappers []*envoy_extensions_filters_network_http_connection_manager_v3.ResponseMapper
+	add := func(f dag.Filter, filters ...*envoy_config_accesslog_v3.AccessLogFilter) {
+		lrl, ok := unmarshalLocalRateLimitConfig(f)
+		if !ok {
+			return
+		}
+		rm, err := localRateLimitMapper(lrl, filters...)
+		if err != nil {
+			if logger.EL.ELogger != nil {
+				logger.EL.ELogger.Errorf("internal:envoy:listener_filter_localratelimit:AddVirtualHostLocalRateLimitReplies() Filter [%s] invalid response body [%s]\n",
+					f.Filter_name, err)
+			}
+			return
+		}
+		if rm != nil {
+			mappers = append(mappers, rm)
+		}
+	}
+
+	var routes []*dag.Route
+	vh.Visit(func(v dag.Vertex) {
+		if r, ok := v.(*dag.Route); ok && LocalRateLimitRouteFilter(r) != nil {
+			routes = append(routes, r)
+		}
+	})
+	// longest path first, as routes are matched
+	sort.SliceStable(routes, func(i, j int) bool {
+		return len(routes[i].PathCondition.String()) > len(routes[j].PathCondition.String())
+	})
+	for _, r := range routes {
+		if path := routePathAccessLogFilter(r); path != nil {
+			add(LocalRateLimitRouteFilter(r).Filter, path)
+		}
+	}
+	if hf := dag.GetVHHttpFilterConfigIfPresent(cfg.FILTER_TYPE_VH_LOCAL_RATELIMIT, vh); hf != nil {
+		add(hf.Filter)
+	}
+
+	addLocalReplyMappers(l, vh, mappers)
+}
@@ -0,0 +1,285 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright(c) 2018-2023 Saaras Inc.
+
+package envoy
+
+import (
+	"testing"
+	"time"
+
+	envoy_config_accesslog_v3 "github.com/envoyproxy/go-control-plane/envoy/config/accesslog/v3"
+	envoy_config_route_v3 "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	envoy_extensions_common_ratelimit_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/common/ratelimit/v3"
+	envoy_extensions_filters_http_local_ratelimit_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/local_ratelimit/v3"
+	envoy_extensions_filters_network_http_connection_manager_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+	envoy_type_v3 "github.com/envoyproxy/go-control-plane/envoy/type/v3"
+	types "github.com/golang/protobuf/ptypes"
+	"github.com/golang/protobuf/ptypes/any"
+	"github.com/google/go-cmp/cmp"
+	"github.com/saarasio/enroute/enroute-dp/internal/assert"
+	"github.com/saarasio/enroute/enroute-dp/internal/dag"
+	"github.com/saarasio/enroute/enroute-dp/internal/protobuf"
+	cfg "github.com/saarasio/enroute/enroute-dp/saarasconfig"
+	"google.golang.org/protobuf/testing/protocmp"
+)
+
+func localRateLimitFilter(config string) dag.Filter {
+	return dag.Filter{
+		Filter_name:   "localratelimit",
+		Filter_type:   cfg.FILTER_TYPE_VH_LOCAL_RATELIMIT,
+		Filter_config: config,
+	}
+}
+
+func TestLocalRateLimit(t *testing.T) {
+	tokenBucket := func(max, perFill uint32, interval time.Duration) *envoy_type_v3.TokenBucket {
+		return &envoy_type_v3.TokenBucket{
+			MaxTokens:     max,
+			TokensPerFill: protobuf.UInt32(perFill),
+			FillInterval:  protobuf.Duration(interval),
+		}
+	}
+	enabled := func(tb *envoy_type_v3.TokenBucket) *envoy_extensions_filters_http_local_ratelimit_v3.LocalRateLimit {
+		return &envoy_extensions_filters_http_local_ratelimit_v3.LocalRateLimit{
+			StatPrefix:     "http_local_rate_limiter",
+			TokenBucket:    tb,
+			FilterEnabled:  localRateLimitEnabled("enroute.local_rate_limit.enabled"),
+			FilterEnforced: localRateLimitEnabled("enroute.local_rate_limit.enforced"),
+			Stage:          1,
+			VhRateLimits:   envoy_extensions_common_ratelimit_v3.VhRateLimitsOptions_INCLUDE,
+		}
+	}
+
+	tests := map[string]struct {
+		config  string
+		want    *envoy_extensions_filters_http_local_ratelimit_v3.LocalRateLimit
+		wantErr bool
+	}{
+		"token bucket defaults": {
+			config: `{"max_tokens":100}`,
+			want:   enabled(tokenBucket(100, 100, time.Second)),
+		},
+		"descriptors status and headers": {
+			config: `{"max_tokens":100,"tokens_per_fill":10,"fill_interval":"1m","status_code":503,"response_headers":true,
+				"descriptors":[{"entries":[{"key":"generic_key","value":"free"}],"max_tokens":5}]}`,
+			want: func() *envoy_extensions_filters_http_local_ratelimit_v3.LocalRateLimit {
+				lrl := enabled(tokenBucket(100, 10, time.Minute))
+				lrl.Status = &envoy_type_v3.HttpStatus{Code: envoy_type_v3.StatusCode_ServiceUnavailable}
+				lrl.EnableXRatelimitHeaders = envoy_extensions_common_ratelimit_v3.XRateLimitHeadersRFCVersion_DRAFT_VERSION_03
+				lrl.Descriptors = []*envoy_extensions_common_ratelimit_v3.LocalRateLimitDescriptor{{
+					Entries: []*envoy_extensions_common_ratelimit_v3.RateLimitDescriptor_Entry{{
+						Key:   "generic_key",
+						Value: "free",
+					}},
+					TokenBucket: tokenBucket(5, 5, time.Second),
+				}}
+				return lrl
+			}(),
+		},
+		"disabled": {
+			config: `{"disabled":true}`,
+			want: &envoy_extensions_filters_http_local_ratelimit_v3.LocalRateLimit{
+				StatPrefix: "http_local_rate_limiter",
+			},
+		},
+		"no max tokens": {
+			config:  `{"fill_interval":"1s"}`,
+			wantErr: true,
+		},
+		"fill interval too short": {
+			config:  `{"max_tokens":10,"fill_interval":"10ms"}`,
+			wantErr: true,
+		},
+		"descriptor without entries": {
+			config:  `{"max_tokens":10,"descriptors":[{"max_tokens":5}]}`,
+			wantErr: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			lrl, err := cfg.UnmarshalLocalRateLimitConfig(tc.config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := LocalRateLimit(lrl)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("expected error %v, got %v", tc.wantErr, err)
+			}
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestSetupRouteLocalRateLimit(t *testing.T) {
+	tests := map[string]struct {
+		route *dag.Route
+		want  *envoy_config_route_v3.Route
+	}{
+		"route with actions": {
+			route: &dag.Route{RouteFilters: []*dag.RouteFilter{{Filter: localRateLimitFilter(
+				`{"max_tokens":10,"actions":[{"remote_address":"remote_address"}]}`)}}},
+			want: &envoy_config_route_v3.Route{
+				Action: &envoy_config_route_v3.Route_Route{
+					Route: &envoy_config_route_v3.RouteAction{
+						RateLimits: []*envoy_config_route_v3.RateLimit{{
+							Stage:   protobuf.UInt32(1),
+							Actions: []*envoy_config_route_v3.RateLimit_Action{rateLimitActionRemoteAddress()},
+						}},
+					},
+				},
+				TypedPerFilterConfig: map[string]*any.Any{
+					HTTPFilterLocalRateLimit: toAny(&envoy_extensions_filters_http_local_ratelimit_v3.LocalRateLimit{
+						StatPrefix: "http_local_rate_limiter",
+						TokenBucket: &envoy_type_v3.TokenBucket{
+							MaxTokens:     10,
+							TokensPerFill: protobuf.UInt32(10),
+							FillInterval:  protobuf.Duration(time.Second),
+						},
+						FilterEnabled:  localRateLimitEnabled("enroute.local_rate_limit.enabled"),
+						FilterEnforced: localRateLimitEnabled("enroute.local_rate_limit.enforced"),
+						Stage:          1,
+						VhRateLimits:   envoy_extensions_common_ratelimit_v3.VhRateLimitsOptions_INCLUDE,
+					}),
+				},
+			},
+		},
+		"route disables local rate limit": {
+			route: &dag.Route{RouteFilters: []*dag.RouteFilter{{Filter: localRateLimitFilter(`{"disabled":true}`)}}},
+			want: &envoy_config_route_v3.Route{
+				Action: &envoy_config_route_v3.Route_Route{
+					Route: &envoy_config_route_v3.RouteAction{},
+				},
+				TypedPerFilterConfig: map[string]*any.Any{
+					HTTPFilterLocalRateLimit: toAny(&envoy_extensions_filters_http_local_ratelimit_v3.LocalRateLimit{
+						StatPrefix: "http_local_rate_limiter",
+					}),
+				},
+			},
+		},
+		"invalid config": {
+			route: &dag.Route{RouteFilters: []*dag.RouteFilter{{Filter: localRateLimitFilter(`{"max_tokens":"ten"}`)}}},
+			want: &envoy_config_route_v3.Route{
+				Action: &envoy_config_route_v3.Route_Route{
+					Route: &envoy_config_route_v3.RouteAction{},
+				},
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			rr := &envoy_config_route_v3.Route{
+				Action: &envoy_config_route_v3.Route_Route{
+					Route: &envoy_config_route_v3.RouteAction{},
+				},
+			}
+			SetupRouteLocalRateLimit(tc.route, rr)
+			assert.Equal(t, tc.want, rr)
+		})
+	}
+}
+
+func TestAddVirtualHostLocalRateLimitReplies(t *testing.T) {
+	vh := &dag.VirtualHost{
+		Name: "www.example.com",
+		HttpFilters: []*dag.HttpFilter{{
+			Filter: localRateLimitFilter(`{"max_tokens":100,"response_body":{"json_format":{"error":"slow down"}}}`),
+		}},
+	}
+	vh.Routes = map[string]*dag.Route{
+		"prefix: /": {
+			PathCondition: &dag.PrefixCondition{Prefix: "/"},
+		},
+		"prefix: /api": {
+			PathCondition: &dag.PrefixCondition{Prefix: "/api"},
+			RouteFilters: []*dag.RouteFilter{{
+				Filter: localRateLimitFilter(`{"max_tokens":10,"response_body":{"text_format":"api limit reached"}}`),
+			}},
+		},
+	}
+
+	l := Listener("ingress_http", "0.0.0.0", 8080, nil, HTTPConnectionManager("ingress_http", "/dev/stdout", nil))
+	AddVirtualHostLocalRateLimitReplies(l, vh)
+
+	hcm := &envoy_extensions_filters_network_http_connection_manager_v3.HttpConnectionManager{}
+	if err := types.UnmarshalAny(l.FilterChains[0].Filters[0].GetTypedConfig(), hcm); err != nil {
+		t.Fatal(err)
+	}
+
+	apiBody, err := localReplyBodyFormat(cfg.UpdateResponseBody{TextFormat: "api limit reached"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	vhBody, err := localReplyBodyFormat(cfg.UpdateResponseBody{JsonFormat: map[string]interface{}{"error": "slow down"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &envoy_extensions_filters_network_http_connection_manager_v3.LocalReplyConfig{
+		Mappers: []*envoy_extensions_filters_network_http_connection_manager_v3.ResponseMapper{{
+			Filter: andAccessLogFilter([]*envoy_config_accesslog_v3.AccessLogFilter{
+				authorityAccessLogFilter("www.example.com"),
+				rateLimitedAccessLogFilter(),
+				headerAccessLogFilter(&envoy_config_route_v3.HeaderMatcher{
+					Name:                 ":path",
+					HeaderMatchSpecifier: &envoy_config_route_v3.HeaderMatcher_PrefixMatch{PrefixMatch: "/api"},
+				}),
+			}),
+			BodyFormatOverride: apiBody,
+		}, {
+			Filter: andAccessLogFilter([]*envoy_config_accesslog_v3.AccessLogFilter{
+				authorityAccessLogFilter("www.example.com"),
+				rateLimitedAccessLogFilter(),
+			}),
+			BodyFormatOverride: vhBody,
+		}},
+	}
+	if diff := cmp.Diff(want, hcm.LocalReplyConfig, protocmp.Transform()); diff != "" {
+		t.Fatal(diff)
+	}
+
+	// a listener without bodies is left as is
+	l = Listener("ingress_http", "0.0.0.0", 8080, nil, HTTPConnectionManager("ingress_http", "/dev/stdout", nil))
+	AddVirtualHostLocalRateLimitReplies(l, &dag.VirtualHost{Name: "www.example.com"})
+	assert.Equal(t, Listener("ingress_http", "0.0.0.0", 8080, nil, HTTPConnectionManager("ingress_http", "/dev/stdout", nil)), l)
+}
+
+func TestRoutePathAccessLogFilter(t *testing.T) {
+	tests := map[string]struct {
+		cond dag.Condition
+		want *envoy_config_route_v3.HeaderMatcher
+	}{
+		"prefix": {
+			cond: &dag.PrefixCondition{Prefix: "/api"},
+			want: &envoy_config_route_v3.HeaderMatcher{
+				Name:                 ":path",
+				HeaderMatchSpecifier: &envoy_config_route_v3.HeaderMatcher_PrefixMatch{PrefixMatch: "/api"},
+			},
+		},
+		"exact": {
+			cond: &dag.ExactCondition{Path: "/v1.0/status"},
+			want: &envoy_config_route_v3.HeaderMatcher{
+				Name: ":path",
+				HeaderMatchSpecifier: &envoy_config_route_v3.HeaderMatcher_SafeRegexMatch{
+					SafeRegexMatch: SafeRegexMatch(`/v1\.0/status(\?.*)?`),
+				},
+			},
+		},
+		"regex": {
+			cond: &dag.RegexCondition{Regex: "/items/[a-z]+"},
+			want: &envoy_config_route_v3.HeaderMatcher{
+				Name: ":path",
+				HeaderMatchSpecifier: &envoy_config_route_v3.HeaderMatcher_SafeRegexMatch{
+					SafeRegexMatch: SafeRegexMatch(`/items/[a-z]+(\?.*)?`),
+				},
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := routePathAccessLogFilter(&dag.Route{PathCondition: tc.cond})
+			assert.Equal(t, headerAccessLogFilter(tc.want), got)
+		})
+	}
+}
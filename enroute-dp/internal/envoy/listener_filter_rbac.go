@@ -0,0 +1,379 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright(c) 2018-2023 Saaras Inc.
+
+package envoy
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	envoy_config_core_v3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	envoy_config_rbac_v3 "github.com/envoyproxy/go-control-plane/envoy/config/rbac/v3"
+	envoy_config_route_v3 "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	envoy_extensions_filters_http_rbac_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/rbac/v3"
+	envoy_extensions_filters_network_http_connection_manager_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+	envoy_type_matcher_v3 "github.com/envoyproxy/go-control-plane/envoy/type/matcher/v3"
+	"github.com/golang/protobuf/ptypes/any"
+	"github.com/golang/protobuf/ptypes/wrappers"
+	"github.com/saarasio/enroute/enroute-dp/internal/dag"
+	"github.com/saarasio/enroute/enroute-dp/internal/logger"
+	cfg "github.com/saarasio/enroute/enroute-dp/saarasconfig"
+)
+
+// HTTPFilterRbac is the name of the RBAC filter. The filter of the listener
+// enforces nothing, the policies of each virtual host and route are set in
+// their per filter config.
+const HTTPFilterRbac = "envoy.rbac"
+
+// jwtAuthnMetadata is the dynamic metadata namespace the jwt_authn filter
+// stores the payload of verified JWTs in.
+const jwtAuthnMetadata = "envoy.filters.http.jwt_authn"
+
+func httpRbacFilter() *envoy_extensions_filters_network_http_connection_manager_v3.HttpFilter {
+	return &envoy_extensions_filters_network_http_connection_manager_v3.HttpFilter{
+		Name: HTTPFilterRbac,
+		ConfigType: &envoy_extensions_filters_network_http_connection_manager_v3.HttpFilter_TypedConfig{
+			TypedConfig: toAny(&envoy_extensions_filters_http_rbac_v3.RBAC{}),
+		},
+	}
+}
+
+// RbacRouteFilter returns the RBAC filter attached to r, nil if none is.
+func RbacRouteFilter(r *dag.Route) *dag.RouteFilter {
+	for _, rf := range r.RouteFilters {
+		if rf.Filter_type == cfg.FILTER_TYPE_VH_RBAC {
+			return rf
+		}
+	}
+	return nil
+}
+
+// addRbacRouteFilter adds the RBAC filter to m if a route of vh has one.
+func addRbacRouteFilter(vh *dag.VirtualHost, m *map[string]*envoy_extensions_filters_network_http_connection_manager_v3.HttpFilter) {
+	if vh == nil {
+		return
+	}
+	if _, ok := (*m)[HTTPFilterRbac]; ok {
+		return
+	}
+
+	vh.Visit(func(v dag.Vertex) {
+		if r, ok := v.(*dag.Route); ok && RbacRouteFilter(r) != nil {
+			(*m)[HTTPFilterRbac] = httpRbacFilter()
+		}
+	})
+}
+
+// SetupVirtualHostRbac sets the policies of the RBAC filter of vh on vhost.
+func SetupVirtualHostRbac(vh *dag.VirtualHost, vhost *envoy_config_route_v3.VirtualHost) {
+	hf := dag.GetVHHttpFilterConfigIfPresent(cfg.FILTER_TYPE_VH_RBAC, vh)
+	if hf == nil {
+		return
+	}
+
+	if vhost.TypedPerFilterConfig == nil {
+		vhost.TypedPerFilterConfig = make(map[string]*any.Any)
+	}
+	vhost.TypedPerFilterConfig[HTTPFilterRbac] = toAny(rbacPerRoute(hf.Filter))
+}
+
+// SetupRouteRbac sets the policies of the RBAC filter of r on rr, they
+// override those of its virtual host.
+func SetupRouteRbac(r *dag.Route, rr *envoy_config_route_v3.Route) {
+	rf := RbacRouteFilter(r)
+	if rf == nil {
+		return
+	}
+
+	if rr.TypedPerFilterConfig == nil {
+		rr.TypedPerFilterConfig = make(map[string]*any.Any)
+	}
+	rr.TypedPerFilterConfig[HTTPFilterRbac] = toAny(rbacPerRoute(rf.Filter))
+}
+
+// rbacPerRoute returns the per filter config of an RBAC filter. A filter
+// whose config is invalid denies every request.
+func rbacPerRoute(f dag.Filter) *envoy_extensions_filters_http_rbac_v3.RBACPerRoute {
+	rc, err := cfg.UnmarshalRbacConfig(f.Filter_config)
+	if err == nil && rc.Disabled {
+		return &envoy_extensions_filters_http_rbac_v3.RBACPerRoute{}
+	}
+
+	var rules *envoy_config_rbac_v3.RBAC
+	if err == nil {
+		rules, err = rbacRules(rc)
+	}
+	if err != nil {
+		if logger.EL.ELogger != nil {
+			logger.EL.ELogger.Errorf("internal:envoy:listener_filter_rbac:rbacPerRoute() Filter [%s] invalid config, denying all requests [%s]\n",
+				f.Filter_name, err)
+		}
+		return &envoy_extensions_filters_http_rbac_v3.RBACPerRoute{
+			Rbac: &envoy_extensions_filters_http_rbac_v3.RBAC{
+				Rules: &envoy_config_rbac_v3.RBAC{Action: envoy_config_rbac_v3.RBAC_ALLOW},
+			},
+		}
+	}
+
+	if rc.Shadow {
+		return &envoy_extensions_filters_http_rbac_v3.RBACPerRoute{
+			Rbac: &envoy_extensions_filters_http_rbac_v3.RBAC{
+				ShadowRules:           rules,
+				ShadowRulesStatPrefix: f.Filter_name + "_",
+			},
+		}
+	}
+	return &envoy_extensions_filters_http_rbac_v3.RBACPerRoute{
+		Rbac: &envoy_extensions_filters_http_rbac_v3.RBAC{
+			Rules: rules,
+		},
+	}
+}
+
+func rbacRules(rc cfg.RbacConfig) (*envoy_config_rbac_v3.RBAC, error) {
+	rules := &envoy_config_rbac_v3.RBAC{
+		Policies: make(map[string]*envoy_config_rbac_v3.Policy),
+	}
+
+	switch strings.ToUpper(rc.Action) {
+	case "", "ALLOW":
+		rules.Action = envoy_config_rbac_v3.RBAC_ALLOW
+	case "DENY":
+		rules.Action = envoy_config_rbac_v3.RBAC_DENY
+	default:
+		return nil, fmt.Errorf("unsupported action %q", rc.Action)
+	}
+
+	for i, p := range rc.Policies {
+		name := p.Name
+		if name == "" {
+			name = fmt.Sprintf("policy-%d", i)
+		}
+		policy, err := rbacPolicy(p)
+		if err != nil {
+			return nil, fmt.Errorf("policy %q: %v", name, err)
+		}
+		rules.Policies[name] = policy
+	}
+	return rules, nil
+}
+
+// rbacPolicy returns a policy whose single permission matches the request
+// and whose single principal matches the client.
+func rbacPolicy(p cfg.RbacPolicy) (*envoy_config_rbac_v3.Policy, error) {
+	var permissions []*envoy_config_rbac_v3.Permission
+
+	var paths []*envoy_config_rbac_v3.Permission
+	for _, path := range p.Paths {
+		m := &envoy_type_matcher_v3.StringMatcher{}
+		switch {
+		case path.Path != "":
+			m.MatchPattern = &envoy_type_matcher_v3.StringMatcher_Exact{Exact: path.Path}
+		case path.Prefix != "":
+			m.MatchPattern = &envoy_type_matcher_v3.StringMatcher_Prefix{Prefix: path.Prefix}
+		default:
+			return nil, fmt.Errorf("path requires prefix or path")
+		}
+		paths = append(paths, &envoy_config_rbac_v3.Permission{
+			Rule: &envoy_config_rbac_v3.Permission_UrlPath{
+				UrlPath: &envoy_type_matcher_v3.PathMatcher{
+					Rule: &envoy_type_matcher_v3.PathMatcher_Path{Path: m},
+				},
+			},
+		})
+	}
+	permissions = appendPermission(permissions, paths)
+
+	var methods []*envoy_config_rbac_v3.Permission
+	for _, method := range p.Methods {
+		methods = append(methods, &envoy_config_rbac_v3.Permission{
+			Rule: &envoy_config_rbac_v3.Permission_Header{
+				Header: &envoy_config_route_v3.HeaderMatcher{
+					Name:                 ":method",
+					HeaderMatchSpecifier: &envoy_config_route_v3.HeaderMatcher_ExactMatch{ExactMatch: strings.ToUpper(method)},
+				},
+			},
+		})
+	}
+	permissions = appendPermission(permissions, methods)
+
+	for _, h := range p.Headers {
+		header, err := configHeaderMatcher(h)
+		if err != nil {
+			return nil, err
+		}
+		permissions = append(permissions, &envoy_config_rbac_v3.Permission{
+			Rule: &envoy_config_rbac_v3.Permission_Header{Header: header},
+		})
+	}
+
+	var principals []*envoy_config_rbac_v3.Principal
+
+	var cidrs []*envoy_config_rbac_v3.Principal
+	for _, c := range p.SourceCidrs {
+		cidr, err := cidrRange(c)
+		if err != nil {
+			return nil, err
+		}
+		// the remote ip honours x-forwarded-for, as trusted by the
+		// connection manager.
+		cidrs = append(cidrs, &envoy_config_rbac_v3.Principal{
+			Identifier: &envoy_config_rbac_v3.Principal_RemoteIp{RemoteIp: cidr},
+		})
+	}
+	principals = appendPrincipal(principals, cidrs)
+
+	var authenticated []*envoy_config_rbac_v3.Principal
+	for _, name := range p.Principals {
+		authenticated = append(authenticated, &envoy_config_rbac_v3.Principal{
+			Identifier: &envoy_config_rbac_v3.Principal_Authenticated_{
+				Authenticated: &envoy_config_rbac_v3.Principal_Authenticated{
+					PrincipalName: &envoy_type_matcher_v3.StringMatcher{
+						MatchPattern: &envoy_type_matcher_v3.StringMatcher_Exact{Exact: name},
+					},
+				},
+			},
+		})
+	}
+	principals = appendPrincipal(principals, authenticated)
+
+	for _, c := range p.JwtClaims {
+		if c.Provider == "" || c.Claim == "" {
+			return nil, fmt.Errorf("jwt claim requires provider and claim")
+		}
+		principals = append(principals, &envoy_config_rbac_v3.Principal{
+			Identifier: &envoy_config_rbac_v3.Principal_Metadata{
+				Metadata: &envoy_type_matcher_v3.MetadataMatcher{
+					Filter: jwtAuthnMetadata,
+					Path: []*envoy_type_matcher_v3.MetadataMatcher_PathSegment{{
+						Segment: &envoy_type_matcher_v3.MetadataMatcher_PathSegment_Key{Key: c.Provider},
+					}, {
+						Segment: &envoy_type_matcher_v3.MetadataMatcher_PathSegment_Key{Key: c.Claim},
+					}},
+					Value: &envoy_type_matcher_v3.ValueMatcher{
+						MatchPattern: &envoy_type_matcher_v3.ValueMatcher_StringMatch{
+							StringMatch: &envoy_type_matcher_v3.StringMatcher{
+								MatchPattern: &envoy_type_matcher_v3.StringMatcher_Exact{Exact: c.Value},
+							},
+						},
+					},
+				},
+			},
+		})
+	}
+
+	policy := &envoy_config_rbac_v3.Policy{}
+	switch len(permissions) {
+	case 0:
+		policy.Permissions = []*envoy_config_rbac_v3.Permission{{
+			Rule: &envoy_config_rbac_v3.Permission_Any{Any: true},
+		}}
+	case 1:
+		policy.Permissions = permissions
+	default:
+		policy.Permissions = []*envoy_config_rbac_v3.Permission{{
+			Rule: &envoy_config_rbac_v3.Permission_AndRules{
+				AndRules: &envoy_config_rbac_v3.Permission_Set{Rules: permissions},
+			},
+		}}
+	}
+	switch len(principals) {
+	case 0:
+		policy.Principals = []*envoy_config_rbac_v3.Principal{{
+			Identifier: &envoy_config_rbac_v3.Principal_Any{Any: true},
+		}}
+	case 1:
+		policy.Principals = principals
+	default:
+		policy.Principals = []*envoy_config_rbac_v3.Principal{{
+			Identifier: &envoy_config_rbac_v3.Principal_AndIds{
+				AndIds: &envoy_config_rbac_v3.Principal_Set{Ids: principals},
+			},
+		}}
+	}
+	return policy, nil
+}
+
+// appendPermission appends a permission matching any of any to permissions.
+func appendPermission(permissions, any []*envoy_config_rbac_v3.Permission) []*envoy_config_rbac_v3.Permission {
+	switch len(any) {
+	case 0:
+		return permissions
+	case 1:
+		return append(permissions, any[0])
+	default:
+		return append(permissions, &envoy_config_rbac_v3.Permission{
+			Rule: &envoy_config_rbac_v3.Permission_OrRules{
+				OrRules: &envoy_config_rbac_v3.Permission_Set{Rules: any},
+			},
+		})
+	}
+}
+
+// appendPrincipal appends a principal matching any of any to principals.
+func appendPrincipal(principals, any []*envoy_config_rbac_v3.Principal) []*envoy_config_rbac_v3.Principal {
+	switch len(any) {
+	case 0:
+		return principals
+	case 1:
+		return append(principals, any[0])
+	default:
+		return append(principals, &envoy_config_rbac_v3.Principal{
+			Identifier: &envoy_config_rbac_v3.Principal_OrIds{
+				OrIds: &envoy_config_rbac_v3.Principal_Set{Ids: any},
+			},
+		})
+	}
+}
+
+func configHeaderMatcher(h cfg.HeaderMatch) (*envoy_config_route_v3.HeaderMatcher, error) {
+	if h.Name == "" {
+		return nil, fmt.Errorf("header requires name")
+	}
+	header := &envoy_config_route_v3.HeaderMatcher{
+		Name:        h.Name,
+		InvertMatch: h.Invert,
+	}
+	switch {
+	case h.Exact != "":
+		header.HeaderMatchSpecifier = &envoy_config_route_v3.HeaderMatcher_ExactMatch{ExactMatch: h.Exact}
+	case h.Prefix != "":
+		header.HeaderMatchSpecifier = &envoy_config_route_v3.HeaderMatcher_PrefixMatch{PrefixMatch: h.Prefix}
+	case h.Regex != "":
+		header.HeaderMatchSpecifier = &envoy_config_route_v3.HeaderMatcher_SafeRegexMatch{SafeRegexMatch: SafeRegexMatch(h.Regex)}
+	case h.Present:
+		header.HeaderMatchSpecifier = &envoy_config_route_v3.HeaderMatcher_PresentMatch{PresentMatch: true}
+	default:
+		return nil, fmt.Errorf("header %q requires exact, prefix, regex or present", h.Name)
+	}
+	return header, nil
+}
+
+// cidrRange parses an address or a CIDR.
+func cidrRange(s string) (*envoy_config_core_v3.CidrRange, error) {
+	if !strings.Contains(s, "/") {
+		ip := net.ParseIP(s)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid address %q", s)
+		}
+		bits := 128
+		if ip.To4() != nil {
+			bits = 32
+		}
+		return &envoy_config_core_v3.CidrRange{
+			AddressPrefix: ip.String(),
+			PrefixLen:     &wrappers.UInt32Value{Value: uint32(bits)},
+		}, nil
+	}
+
+	ip, ipnet, err := net.ParseCIDR(s)
+	if err != nil {
+		return nil, err
+	}
+	ones, _ := ipnet.Mask.Size()
+	return &envoy_config_core_v3.CidrRange{
+		AddressPrefix: ip.String(),
+		PrefixLen:     &wrappers.UInt32Value{Value: uint32(ones)},
+	}, nil
+}
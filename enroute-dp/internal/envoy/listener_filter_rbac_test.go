@@ -0,0 +1,219 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright(c) 2018-2023 Saaras Inc.
+
+package envoy
+
+import (
+	"testing"
+
+	envoy_config_core_v3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	envoy_config_rbac_v3 "github.com/envoyproxy/go-control-plane/envoy/config/rbac/v3"
+	envoy_config_route_v3 "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	envoy_extensions_filters_http_rbac_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/rbac/v3"
+	envoy_type_matcher_v3 "github.com/envoyproxy/go-control-plane/envoy/type/matcher/v3"
+	"github.com/golang/protobuf/ptypes/any"
+	"github.com/golang/protobuf/ptypes/wrappers"
+	"github.com/saarasio/enroute/enroute-dp/internal/assert"
+	"github.com/saarasio/enroute/enroute-dp/internal/dag"
+	cfg "github.com/saarasio/enroute/enroute-dp/saarasconfig"
+)
+
+func rbacFilter(config string) dag.Filter {
+	return dag.Filter{
+		Filter_name:   "rbac",
+		Filter_type:   cfg.FILTER_TYPE_VH_RBAC,
+		Filter_config: config,
+	}
+}
+
+func TestRbacPerRoute(t *testing.T) {
+	anyPermission := []*envoy_config_rbac_v3.Permission{{
+		Rule: &envoy_config_rbac_v3.Permission_Any{Any: true},
+	}}
+	anyPrincipal := []*envoy_config_rbac_v3.Principal{{
+		Identifier: &envoy_config_rbac_v3.Principal_Any{Any: true},
+	}}
+	method := func(m string) *envoy_config_rbac_v3.Permission {
+		return &envoy_config_rbac_v3.Permission{
+			Rule: &envoy_config_rbac_v3.Permission_Header{
+				Header: &envoy_config_route_v3.HeaderMatcher{
+					Name:                 ":method",
+					HeaderMatchSpecifier: &envoy_config_route_v3.HeaderMatcher_ExactMatch{ExactMatch: m},
+				},
+			},
+		}
+	}
+	remoteIp := func(prefix string, len uint32) *envoy_config_rbac_v3.Principal {
+		return &envoy_config_rbac_v3.Principal{
+			Identifier: &envoy_config_rbac_v3.Principal_RemoteIp{
+				RemoteIp: &envoy_config_core_v3.CidrRange{
+					AddressPrefix: prefix,
+					PrefixLen:     &wrappers.UInt32Value{Value: len},
+				},
+			},
+		}
+	}
+	denyAll := &envoy_extensions_filters_http_rbac_v3.RBACPerRoute{
+		Rbac: &envoy_extensions_filters_http_rbac_v3.RBAC{
+			Rules: &envoy_config_rbac_v3.RBAC{Action: envoy_config_rbac_v3.RBAC_ALLOW},
+		},
+	}
+
+	tests := map[string]struct {
+		config string
+		want   *envoy_extensions_filters_http_rbac_v3.RBACPerRoute
+	}{
+		"allow from cidrs": {
+			config: `{"policies":[{"name":"internal","source_cidrs":["10.0.0.0/8","192.168.1.10"]}]}`,
+			want: &envoy_extensions_filters_http_rbac_v3.RBACPerRoute{
+				Rbac: &envoy_extensions_filters_http_rbac_v3.RBAC{
+					Rules: &envoy_config_rbac_v3.RBAC{
+						Action: envoy_config_rbac_v3.RBAC_ALLOW,
+						Policies: map[string]*envoy_config_rbac_v3.Policy{
+							"internal": {
+								Permissions: anyPermission,
+								Principals: []*envoy_config_rbac_v3.Principal{{
+									Identifier: &envoy_config_rbac_v3.Principal_OrIds{
+										OrIds: &envoy_config_rbac_v3.Principal_Set{
+											Ids: []*envoy_config_rbac_v3.Principal{
+												remoteIp("10.0.0.0", 8),
+												remoteIp("192.168.1.10", 32),
+											},
+										},
+									},
+								}},
+							},
+						},
+					},
+				},
+			},
+		},
+		"deny writes to admin": {
+			config: `{"action":"deny","policies":[{"name":"admin-writes","paths":[{"prefix":"/admin"}],"methods":["post","PUT"],
+				"headers":[{"name":"x-internal","present":true,"invert":true}]}]}`,
+			want: &envoy_extensions_filters_http_rbac_v3.RBACPerRoute{
+				Rbac: &envoy_extensions_filters_http_rbac_v3.RBAC{
+					Rules: &envoy_config_rbac_v3.RBAC{
+						Action: envoy_config_rbac_v3.RBAC_DENY,
+						Policies: map[string]*envoy_config_rbac_v3.Policy{
+							"admin-writes": {
+								Permissions: []*envoy_config_rbac_v3.Permission{{
+									Rule: &envoy_config_rbac_v3.Permission_AndRules{
+										AndRules: &envoy_config_rbac_v3.Permission_Set{
+											Rules: []*envoy_config_rbac_v3.Permission{{
+												Rule: &envoy_config_rbac_v3.Permission_UrlPath{
+													UrlPath: &envoy_type_matcher_v3.PathMatcher{
+														Rule: &envoy_type_matcher_v3.PathMatcher_Path{
+															Path: &envoy_type_matcher_v3.StringMatcher{
+																MatchPattern: &envoy_type_matcher_v3.StringMatcher_Prefix{Prefix: "/admin"},
+															},
+														},
+													},
+												},
+											}, {
+												Rule: &envoy_config_rbac_v3.Permission_OrRules{
+													OrRules: &envoy_config_rbac_v3.Permission_Set{
+														Rules: []*envoy_config_rbac_v3.Permission{method("POST"), method("PUT")},
+													},
+												},
+											}, {
+												Rule: &envoy_config_rbac_v3.Permission_Header{
+													Header: &envoy_config_route_v3.HeaderMatcher{
+														Name:                 "x-internal",
+														InvertMatch:          true,
+														HeaderMatchSpecifier: &envoy_config_route_v3.HeaderMatcher_PresentMatch{PresentMatch: true},
+													},
+												},
+											}},
+										},
+									},
+								}},
+								Principals: anyPrincipal,
+							},
+						},
+					},
+				},
+			},
+		},
+		"shadow jwt claim": {
+			config: `{"shadow":true,"policies":[{"name":"admins","jwt_claims":[{"provider":"auth0","claim":"role","value":"admin"}]}]}`,
+			want: &envoy_extensions_filters_http_rbac_v3.RBACPerRoute{
+				Rbac: &envoy_extensions_filters_http_rbac_v3.RBAC{
+					ShadowRules: &envoy_config_rbac_v3.RBAC{
+						Action: envoy_config_rbac_v3.RBAC_ALLOW,
+						Policies: map[string]*envoy_config_rbac_v3.Policy{
+							"admins": {
+								Permissions: anyPermission,
+								Principals: []*envoy_config_rbac_v3.Principal{{
+									Identifier: &envoy_config_rbac_v3.Principal_Metadata{
+										Metadata: &envoy_type_matcher_v3.MetadataMatcher{
+											Filter: "envoy.filters.http.jwt_authn",
+											Path: []*envoy_type_matcher_v3.MetadataMatcher_PathSegment{{
+												Segment: &envoy_type_matcher_v3.MetadataMatcher_PathSegment_Key{Key: "auth0"},
+											}, {
+												Segment: &envoy_type_matcher_v3.MetadataMatcher_PathSegment_Key{Key: "role"},
+											}},
+											Value: &envoy_type_matcher_v3.ValueMatcher{
+												MatchPattern: &envoy_type_matcher_v3.ValueMatcher_StringMatch{
+													StringMatch: &envoy_type_matcher_v3.StringMatcher{
+														MatchPattern: &envoy_type_matcher_v3.StringMatcher_Exact{Exact: "admin"},
+													},
+												},
+											},
+										},
+									},
+								}},
+							},
+						},
+					},
+					ShadowRulesStatPrefix: "rbac_",
+				},
+			},
+		},
+		"disabled": {
+			config: `{"disabled":true}`,
+			want:   &envoy_extensions_filters_http_rbac_v3.RBACPerRoute{},
+		},
+		"invalid cidr denies all": {
+			config: `{"policies":[{"source_cidrs":["10.0.0.0/33"]}]}`,
+			want:   denyAll,
+		},
+		"invalid action denies all": {
+			config: `{"action":"log"}`,
+			want:   denyAll,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := rbacPerRoute(rbacFilter(tc.config))
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestSetupRouteRbac(t *testing.T) {
+	tests := map[string]struct {
+		route *dag.Route
+		want  map[string]*any.Any
+	}{
+		"route disables rbac": {
+			route: &dag.Route{RouteFilters: []*dag.RouteFilter{{Filter: rbacFilter(`{"disabled":true}`)}}},
+			want: map[string]*any.Any{
+				HTTPFilterRbac: toAny(&envoy_extensions_filters_http_rbac_v3.RBACPerRoute{}),
+			},
+		},
+		"route without rbac": {
+			route: &dag.Route{},
+			want:  nil,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			rr := &envoy_config_route_v3.Route{}
+			SetupRouteRbac(tc.route, rr)
+			assert.Equal(t, &envoy_config_route_v3.Route{TypedPerFilterConfig: tc.want}, rr)
+		})
+	}
+}
@@ -0,0 +1,228 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright(c) 2018-2023 Saaras Inc.
+
+package envoy
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	envoy_config_core_v3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	envoy_config_route_v3 "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	envoy_extensions_filters_http_wasm_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/wasm/v3"
+	envoy_extensions_filters_network_http_connection_manager_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+	envoy_extensions_wasm_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/wasm/v3"
+	"github.com/golang/protobuf/ptypes/any"
+	"github.com/golang/protobuf/ptypes/wrappers"
+	"github.com/saarasio/enroute/enroute-dp/internal/dag"
+	"github.com/saarasio/enroute/enroute-dp/internal/logger"
+	"github.com/saarasio/enroute/enroute-dp/internal/protobuf"
+	cfg "github.com/saarasio/enroute/enroute-dp/saarasconfig"
+)
+
+// HTTPFilterWasmPrefix prefixes the name of each Wasm filter.
+const HTTPFilterWasmPrefix = "envoy.wasm."
+
+const (
+	defaultWasmRuntime      = "envoy.wasm.runtime.v8"
+	defaultWasmFetchTimeout = 10 * time.Second
+)
+
+// WasmFilterName returns the name of the Wasm filter named name in
+// namespace. Namespaces cannot contain a slash, so filters of the same name
+// in different namespaces get different names.
+func WasmFilterName(namespace, name string) string {
+	return HTTPFilterWasmPrefix + namespace + "/" + name
+}
+
+func httpWasmTypedConfig(f dag.Filter, cluster *dag.Cluster) *envoy_extensions_filters_network_http_connection_manager_v3.HttpFilter_TypedConfig {
+	w := httpWasmConfig(f, cluster)
+	if w == nil {
+		return nil
+	}
+	return &envoy_extensions_filters_network_http_connection_manager_v3.HttpFilter_TypedConfig{
+		TypedConfig: toAny(w),
+	}
+}
+
+// httpWasmConfig returns the config of a Wasm filter, nil if its config is
+// invalid. cluster is the cluster a remote module is fetched through.
+func httpWasmConfig(f dag.Filter, cluster *dag.Cluster) *envoy_extensions_filters_http_wasm_v3.Wasm {
+	wc, err := cfg.UnmarshalWasmConfig(f.Filter_config)
+	if err != nil {
+		if logger.EL.ELogger != nil {
+			logger.EL.ELogger.Errorf("internal:envoy:listener_filter_wasmfilter:httpWasmConfig() Filter [%s] failed to decode config [%s]\n",
+				f.Filter_name, err)
+		}
+		return nil
+	}
+
+	code, err := wasmCode(wc, cluster)
+	if err != nil {
+		if logger.EL.ELogger != nil {
+			logger.EL.ELogger.Errorf("internal:envoy:listener_filter_wasmfilter:httpWasmConfig() Filter [%s] %s\n",
+				f.Filter_name, err)
+		}
+		return nil
+	}
+
+	runtime := wc.Runtime
+	if runtime == "" {
+		runtime = defaultWasmRuntime
+	}
+
+	plugin := &envoy_extensions_wasm_v3.PluginConfig{
+		Name:   f.Filter_name,
+		RootId: wc.RootId,
+		Vm: &envoy_extensions_wasm_v3.PluginConfig_VmConfig{
+			VmConfig: &envoy_extensions_wasm_v3.VmConfig{
+				VmId:    wc.VmId,
+				Runtime: runtime,
+				Code:    code,
+			},
+		},
+		FailOpen: wc.FailOpen,
+	}
+	if len(wc.Config) > 0 {
+		plugin.Configuration = toAny(&wrappers.StringValue{Value: wasmPluginConfig(wc.Config)})
+	}
+
+	return &envoy_extensions_filters_http_wasm_v3.Wasm{
+		Config: plugin,
+	}
+}
+
+// wasmCode returns the source of the module of a Wasm filter.
+func wasmCode(wc cfg.WasmConfig, cluster *dag.Cluster) (*envoy_config_core_v3.AsyncDataSource, error) {
+	switch {
+	case wc.Filename != "":
+		return &envoy_config_core_v3.AsyncDataSource{
+			Specifier: &envoy_config_core_v3.AsyncDataSource_Local{
+				Local: &envoy_config_core_v3.DataSource{
+					Specifier: &envoy_config_core_v3.DataSource_Filename{Filename: wc.Filename},
+				},
+			},
+		}, nil
+	case wc.InlineCode != "":
+		code, err := base64.StdEncoding.DecodeString(wc.InlineCode)
+		if err != nil {
+			return nil, fmt.Errorf("inline_code is not base64 encoded: %v", err)
+		}
+		return &envoy_config_core_v3.AsyncDataSource{
+			Specifier: &envoy_config_core_v3.AsyncDataSource_Local{
+				Local: &envoy_config_core_v3.DataSource{
+					Specifier: &envoy_config_core_v3.DataSource_InlineBytes{InlineBytes: code},
+				},
+			},
+		}, nil
+	case wc.Url != "":
+		if wc.Sha256 == "" {
+			return nil, errors.New("sha256 is required with url")
+		}
+		if cluster == nil {
+			return nil, errors.New("url is not an http or https URL")
+		}
+		timeout := defaultWasmFetchTimeout
+		if wc.Timeout > 0 {
+			timeout = time.Duration(wc.Timeout) * time.Second
+		}
+		return &envoy_config_core_v3.AsyncDataSource{
+			Specifier: &envoy_config_core_v3.AsyncDataSource_Remote{
+				Remote: &envoy_config_core_v3.RemoteDataSource{
+					HttpUri: &envoy_config_core_v3.HttpUri{
+						Uri: wc.Url,
+						HttpUpstreamType: &envoy_config_core_v3.HttpUri_Cluster{
+							Cluster: Clustername(cluster),
+						},
+						Timeout: protobuf.Duration(timeout),
+					},
+					Sha256: wc.Sha256,
+				},
+			},
+		}, nil
+	default:
+		return nil, errors.New("one of filename, inline_code or url is required")
+	}
+}
+
+// wasmPluginConfig returns the plugin configuration passed to a module, a
+// JSON string is passed as is, any other value as JSON.
+func wasmPluginConfig(config json.RawMessage) string {
+	var s string
+	if err := json.Unmarshal(config, &s); err == nil {
+		return s
+	}
+	return string(config)
+}
+
+// WasmRouteFilters returns the Wasm filters attached to r.
+func WasmRouteFilters(r *dag.Route) []*dag.RouteFilter {
+	var filters []*dag.RouteFilter
+	for _, rf := range r.RouteFilters {
+		if rf.Filter_type == cfg.FILTER_TYPE_HTTP_WASM {
+			filters = append(filters, rf)
+		}
+	}
+	return filters
+}
+
+// addWasmRouteFilters adds the Wasm filters attached to the routes of vh to
+// m, unless vh has a filter of the same name. Routes that do not attach
+// them disable them, see SetupRouteWasm.
+func addWasmRouteFilters(vh *dag.VirtualHost, m *map[string]*envoy_extensions_filters_network_http_connection_manager_v3.HttpFilter) {
+	if vh == nil {
+		return
+	}
+
+	vh.Visit(func(v dag.Vertex) {
+		r, ok := v.(*dag.Route)
+		if !ok {
+			return
+		}
+		for _, rf := range WasmRouteFilters(r) {
+			name := WasmFilterName(rf.Filter_namespace, rf.Filter_name)
+			if _, ok := (*m)[name]; ok {
+				continue
+			}
+			config := httpWasmTypedConfig(rf.Filter, rf.Cluster)
+			if config == nil {
+				continue
+			}
+			(*m)[name] = &envoy_extensions_filters_network_http_connection_manager_v3.HttpFilter{
+				Name:       name,
+				ConfigType: config,
+			}
+		}
+	})
+}
+
+// SetupRouteWasm disables on rr each of the Wasm filters named wasmFilters,
+// the filters attached to all the virtual hosts and routes, that neither r
+// nor vh attach.
+func SetupRouteWasm(vh *dag.VirtualHost, r *dag.Route, rr *envoy_config_route_v3.Route, wasmFilters []string) {
+	attached := make(map[string]bool)
+	for _, rf := range WasmRouteFilters(r) {
+		attached[WasmFilterName(rf.Filter_namespace, rf.Filter_name)] = true
+	}
+	for _, hf := range vh.HttpFilters {
+		if hf.Filter_type == cfg.FILTER_TYPE_HTTP_WASM {
+			attached[WasmFilterName(hf.Filter_namespace, hf.Filter_name)] = true
+		}
+	}
+
+	for _, name := range wasmFilters {
+		if attached[name] {
+			continue
+		}
+		if rr.TypedPerFilterConfig == nil {
+			rr.TypedPerFilterConfig = make(map[string]*any.Any)
+		}
+		rr.TypedPerFilterConfig[name] = toAny(&envoy_config_route_v3.FilterConfig{
+			IsOptional: true,
+			Disabled:   true,
+		})
+	}
+}
@@ -0,0 +1,183 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright(c) 2018-2023 Saaras Inc.
+
+package envoy
+
+import (
+	"testing"
+	"time"
+
+	envoy_config_core_v3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	envoy_config_route_v3 "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	envoy_extensions_filters_http_wasm_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/wasm/v3"
+	envoy_extensions_wasm_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/wasm/v3"
+	"github.com/golang/protobuf/ptypes/any"
+	"github.com/golang/protobuf/ptypes/wrappers"
+	"github.com/saarasio/enroute/enroute-dp/internal/assert"
+	"github.com/saarasio/enroute/enroute-dp/internal/dag"
+	"github.com/saarasio/enroute/enroute-dp/internal/protobuf"
+	cfg "github.com/saarasio/enroute/enroute-dp/saarasconfig"
+	v1 "k8s.io/api/core/v1"
+)
+
+func wasmFilter(code *envoy_config_core_v3.AsyncDataSource, configuration string) *envoy_extensions_filters_http_wasm_v3.Wasm {
+	w := &envoy_extensions_filters_http_wasm_v3.Wasm{
+		Config: &envoy_extensions_wasm_v3.PluginConfig{
+			Name: "sanitiser",
+			Vm: &envoy_extensions_wasm_v3.PluginConfig_VmConfig{
+				VmConfig: &envoy_extensions_wasm_v3.VmConfig{
+					Runtime: "envoy.wasm.runtime.v8",
+					Code:    code,
+				},
+			},
+		},
+	}
+	if configuration != "" {
+		w.Config.Configuration = toAny(&wrappers.StringValue{Value: configuration})
+	}
+	return w
+}
+
+func TestHttpWasmConfig(t *testing.T) {
+	modules := &dag.Cluster{
+		Upstream: &dag.HTTPService{
+			TCPService: dag.TCPService{
+				Name:         "wasm.example.com",
+				Namespace:    "default",
+				ServicePort:  &v1.ServicePort{Port: 443},
+				ExternalName: "wasm.example.com",
+			},
+			Protocol: "tls",
+		},
+		SNI: "wasm.example.com",
+	}
+
+	tests := map[string]struct {
+		config  string
+		cluster *dag.Cluster
+		want    *envoy_extensions_filters_http_wasm_v3.Wasm
+	}{
+		"local file": {
+			config: `{"filename":"/etc/envoy/sanitiser.wasm","config":{"strip":["x-debug"]}}`,
+			want: wasmFilter(&envoy_config_core_v3.AsyncDataSource{
+				Specifier: &envoy_config_core_v3.AsyncDataSource_Local{
+					Local: &envoy_config_core_v3.DataSource{
+						Specifier: &envoy_config_core_v3.DataSource_Filename{Filename: "/etc/envoy/sanitiser.wasm"},
+					},
+				},
+			}, `{"strip":["x-debug"]}`),
+		},
+		"inline module": {
+			config: `{"inline_code":"AGFzbQEAAAA=","config":"plain text"}`,
+			want: wasmFilter(&envoy_config_core_v3.AsyncDataSource{
+				Specifier: &envoy_config_core_v3.AsyncDataSource_Local{
+					Local: &envoy_config_core_v3.DataSource{
+						Specifier: &envoy_config_core_v3.DataSource_InlineBytes{InlineBytes: []byte("\x00asm\x01\x00\x00\x00")},
+					},
+				},
+			}, "plain text"),
+		},
+		"inline module not base64": {
+			config: `{"inline_code":"not base64!"}`,
+			want:   nil,
+		},
+		"remote module": {
+			config:  `{"url":"https://wasm.example.com/sanitiser.wasm","sha256":"abc123"}`,
+			cluster: modules,
+			want: wasmFilter(&envoy_config_core_v3.AsyncDataSource{
+				Specifier: &envoy_config_core_v3.AsyncDataSource_Remote{
+					Remote: &envoy_config_core_v3.RemoteDataSource{
+						HttpUri: &envoy_config_core_v3.HttpUri{
+							Uri: "https://wasm.example.com/sanitiser.wasm",
+							HttpUpstreamType: &envoy_config_core_v3.HttpUri_Cluster{
+								Cluster: "default/wasm.example.com/443/da39a3ee5e",
+							},
+							Timeout: protobuf.Duration(10 * time.Second),
+						},
+						Sha256: "abc123",
+					},
+				},
+			}, ""),
+		},
+		"remote module without sha256": {
+			config:  `{"url":"https://wasm.example.com/sanitiser.wasm"}`,
+			cluster: modules,
+			want:    nil,
+		},
+		"remote module without cluster": {
+			config: `{"url":"oci://saarasio/vvx-json","sha256":"abc123"}`,
+			want:   nil,
+		},
+		"no module": {
+			config: `{}`,
+			want:   nil,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			f := dag.Filter{
+				Filter_name:   "sanitiser",
+				Filter_type:   cfg.FILTER_TYPE_HTTP_WASM,
+				Filter_config: tc.config,
+			}
+			got := httpWasmConfig(f, tc.cluster)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestSetupRouteWasm(t *testing.T) {
+	wasm := func(namespace, name string) dag.Filter {
+		return dag.Filter{Filter_name: name, Filter_type: cfg.FILTER_TYPE_HTTP_WASM, Filter_namespace: namespace}
+	}
+	disabled := toAny(&envoy_config_route_v3.FilterConfig{
+		IsOptional: true,
+		Disabled:   true,
+	})
+
+	tests := map[string]struct {
+		vh    *dag.VirtualHost
+		route *dag.Route
+		want  map[string]*any.Any
+	}{
+		"route attaches the filter": {
+			vh:    &dag.VirtualHost{},
+			route: &dag.Route{RouteFilters: []*dag.RouteFilter{{Filter: wasm("default", "a")}}},
+			want: map[string]*any.Any{
+				"envoy.wasm.default/b": disabled,
+			},
+		},
+		"route attaches no filter": {
+			vh:    &dag.VirtualHost{},
+			route: &dag.Route{},
+			want: map[string]*any.Any{
+				"envoy.wasm.default/a": disabled,
+				"envoy.wasm.default/b": disabled,
+			},
+		},
+		"virtual host attaches the filter": {
+			vh:    &dag.VirtualHost{HttpFilters: []*dag.HttpFilter{{Filter: wasm("default", "b")}}},
+			route: &dag.Route{},
+			want: map[string]*any.Any{
+				"envoy.wasm.default/a": disabled,
+			},
+		},
+		"filter of the same name in another namespace": {
+			vh:    &dag.VirtualHost{HttpFilters: []*dag.HttpFilter{{Filter: wasm("other", "b")}}},
+			route: &dag.Route{},
+			want: map[string]*any.Any{
+				"envoy.wasm.default/a": disabled,
+				"envoy.wasm.default/b": disabled,
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			rr := &envoy_config_route_v3.Route{}
+			SetupRouteWasm(tc.vh, tc.route, rr, []string{"envoy.wasm.default/a", "envoy.wasm.default/b"})
+			assert.Equal(t, &envoy_config_route_v3.Route{TypedPerFilterConfig: tc.want}, rr)
+		})
+	}
+}
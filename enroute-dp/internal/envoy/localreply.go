@@ -0,0 +1,240 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright(c) 2018-2023 Saaras Inc.
+
+package envoy
+
+import (
+	"fmt"
+
+	envoy_config_accesslog_v3 "github.com/envoyproxy/go-control-plane/envoy/config/accesslog/v3"
+	envoy_config_core_v3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	envoy_config_listener_v3 "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
+	envoy_config_route_v3 "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	envoy_extensions_filters_network_http_connection_manager_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+	"github.com/saarasio/enroute/enroute-dp/internal/dag"
+	"github.com/saarasio/enroute/enroute-dp/internal/logger"
+	"github.com/saarasio/enroute/enroute-dp/internal/protobuf"
+	cfg "github.com/saarasio/enroute/enroute-dp/saarasconfig"
+	structpb "google.golang.org/protobuf/types/known/structpb"
+)
+
+// localReplyBodyFormat returns the format of the body of a local reply,
+// nil if b has no format and the body is left as is.
+func localReplyBodyFormat(b cfg.UpdateResponseBody) (*envoy_config_core_v3.SubstitutionFormatString, error) {
+	contentType := b.ContentType
+	if contentType == "" && len(b.JsonFormat) > 0 {
+		contentType = "application/json"
+	}
+
+	if contentType == "application/json" {
+		if len(b.JsonFormat) == 0 {
+			return nil, fmt.Errorf("content type %q requires json_format", contentType)
+		}
+		jsonformat, err := structpb.NewStruct(b.JsonFormat)
+		if err != nil {
+			return nil, err
+		}
+		return &envoy_config_core_v3.SubstitutionFormatString{
+			Format: &envoy_config_core_v3.SubstitutionFormatString_JsonFormat{
+				JsonFormat: jsonformat,
+			},
+			ContentType: contentType,
+		}, nil
+	}
+
+	if b.TextFormat == "" {
+		return nil, nil
+	}
+	if contentType == "" {
+		contentType = "text/plain"
+	}
+	return &envoy_config_core_v3.SubstitutionFormatString{
+		Format: &envoy_config_core_v3.SubstitutionFormatString_TextFormatSource{
+			TextFormatSource: &envoy_config_core_v3.DataSource{
+				Specifier: &envoy_config_core_v3.DataSource_InlineString{InlineString: b.TextFormat},
+			},
+		},
+		ContentType: contentType,
+	}, nil
+}
+
+// statusCodeFilter matches local replies with status code, any local reply
+// if code is 0.
+func statusCodeFilter(code uint32) *envoy_config_accesslog_v3.AccessLogFilter {
+	op := envoy_config_accesslog_v3.ComparisonFilter_EQ
+	if code == 0 {
+		op = envoy_config_accesslog_v3.ComparisonFilter_GE
+	}
+	return &envoy_config_accesslog_v3.AccessLogFilter{
+		FilterSpecifier: &envoy_config_accesslog_v3.AccessLogFilter_StatusCodeFilter{
+			StatusCodeFilter: &envoy_config_accesslog_v3.StatusCodeFilter{
+				Comparison: &envoy_config_accesslog_v3.ComparisonFilter{
+					Op: op,
+					Value: &envoy_config_core_v3.RuntimeUInt32{
+						DefaultValue: code,
+						RuntimeKey:   "enroute.local_reply.status_code",
+					},
+				},
+			},
+		},
+	}
+}
+
+// responseMapper returns the mapper of the local replies matched by cr.
+func responseMapper(cr cfg.CustomResponse) (*envoy_extensions_filters_network_http_connection_manager_v3.ResponseMapper, error) {
+	filters := []*envoy_config_accesslog_v3.AccessLogFilter{statusCodeFilter(cr.MatchStatusCode)}
+	if cr.MatchPathPrefix != "" {
+		filters = append(filters, headerAccessLogFilter(&envoy_config_route_v3.HeaderMatcher{
+			Name:                 ":path",
+			HeaderMatchSpecifier: &envoy_config_route_v3.HeaderMatcher_PrefixMatch{PrefixMatch: cr.MatchPathPrefix},
+		}))
+	}
+	for _, h := range cr.MatchHeaders {
+		header, err := configHeaderMatcher(h)
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, headerAccessLogFilter(header))
+	}
+
+	format, err := localReplyBodyFormat(cr.UpdateResponseBody)
+	if err != nil {
+		return nil, err
+	}
+	rm := &envoy_extensions_filters_network_http_connection_manager_v3.ResponseMapper{
+		Filter:             andAccessLogFilter(filters),
+		BodyFormatOverride: format,
+	}
+	if cr.UpdateStatusCode != 0 {
+		rm.StatusCode = protobuf.UInt32(cr.UpdateStatusCode)
+	}
+	return rm, nil
+}
+
+// responseMappers returns the mappers of the overrides of crc. The default
+// body of crc, if set, is mapped last for any local reply.
+func responseMappers(crc cfg.CustomResponseConfig) ([]*envoy_extensions_filters_network_http_connection_manager_v3.ResponseMapper, error) {
+	overrides := crc.CustomResponseOverride
+	if crc.DefaultResponseBody != nil {
+		overrides = append(overrides[:len(overrides):len(overrides)], cfg.CustomResponse{
+			UpdateResponseBody: *crc.DefaultResponseBody,
+		})
+	}
+
+	var mappers []*envoy_extensions_filters_network_http_connection_manager_v3.ResponseMapper
+	for _, cr := range overrides {
+		rm, err := responseMapper(cr)
+		if err != nil {
+			return nil, err
+		}
+		mappers = append(mappers, rm)
+	}
+	return mappers, nil
+}
+
+// ListenerLocalReply returns the local reply config of the listener named
+// listener configured by configs, the configs of the local reply
+// GlobalConfigs, nil if none applies. The default body of the first
+// config that has one formats the local replies no mapper matches.
+func ListenerLocalReply(configs []string, listener string) *envoy_extensions_filters_network_http_connection_manager_v3.LocalReplyConfig {
+	var lrc envoy_extensions_filters_network_http_connection_manager_v3.LocalReplyConfig
+	for _, config := range configs {
+		crc, err := cfg.UnmarshalCustomResponse(config)
+		if err == nil && crc.Listener != "" && crc.Listener != listener {
+			continue
+		}
+
+		var mappers []*envoy_extensions_filters_network_http_connection_manager_v3.ResponseMapper
+		var format *envoy_config_core_v3.SubstitutionFormatString
+		if err == nil {
+			defaultBody := crc.DefaultResponseBody
+			crc.DefaultResponseBody = nil
+			mappers, err = responseMappers(crc)
+			if err == nil && defaultBody != nil {
+				format, err = localReplyBodyFormat(*defaultBody)
+			}
+		}
+		if err != nil {
+			if logger.EL.ELogger != nil {
+				logger.EL.ELogger.Errorf("internal:envoy:localreply:ListenerLocalReply() Listener [%s] invalid local reply config [%s]\n",
+					listener, err)
+			}
+			continue
+		}
+
+		lrc.Mappers = append(lrc.Mappers, mappers...)
+		if lrc.BodyFormat == nil {
+			lrc.BodyFormat = format
+		}
+	}
+
+	if len(lrc.Mappers) == 0 && lrc.BodyFormat == nil {
+		return nil
+	}
+	return &lrc
+}
+
+// SetLocalReply sets the local reply config of each HTTP connection
+// manager of l.
+func SetLocalReply(l *envoy_config_listener_v3.Listener, lrc *envoy_extensions_filters_network_http_connection_manager_v3.LocalReplyConfig) {
+	updateHttpConnectionManagers(l, "", func(hcm *envoy_extensions_filters_network_http_connection_manager_v3.HttpConnectionManager, _ bool) {
+		hcm.LocalReplyConfig = lrc
+	})
+}
+
+// AddVirtualHostLocalReplies adds the mappers of the custom response filter
+// of vh to the HTTP connection manager of l serving vh, ahead of those of
+// the listener.
+func AddVirtualHostLocalReplies(l *envoy_config_listener_v3.Listener, vh *dag.VirtualHost) {
+	hf := dag.GetVHHttpFilterConfigIfPresent(cfg.FILTER_TYPE_CUSTOM_RESPONSE, vh)
+	if hf == nil {
+		return
+	}
+
+	crc, err := cfg.UnmarshalCustomResponse(hf.Filter_config)
+	var mappers []*envoy_extensions_filters_network_http_connection_manager_v3.ResponseMapper
+	if err == nil {
+		mappers, err = responseMappers(crc)
+	}
+	if err != nil {
+		if logger.EL.ELogger != nil {
+			logger.EL.ELogger.Errorf("internal:envoy:localreply:AddVirtualHostLocalReplies() Filter [%s] invalid custom response config [%s]\n",
+				hf.Filter_name, err)
+		}
+		return
+	}
+	addLocalReplyMappers(l, vh, mappers)
+}
+
+// addLocalReplyMappers adds mappers to the HTTP connection manager of l
+// serving vh, ahead of its other mappers. When vh shares it with other
+// virtual hosts, mappers only map the local replies to requests for vh.
+func addLocalReplyMappers(l *envoy_config_listener_v3.Listener, vh *dag.VirtualHost, mappers []*envoy_extensions_filters_network_http_connection_manager_v3.ResponseMapper) {
+	if len(mappers) == 0 {
+		return
+	}
+
+	updateHttpConnectionManagers(l, vh.Name, func(hcm *envoy_extensions_filters_network_http_connection_manager_v3.HttpConnectionManager, sni bool) {
+		lrc := &envoy_extensions_filters_network_http_connection_manager_v3.LocalReplyConfig{}
+		if hcm.LocalReplyConfig != nil {
+			lrc.BodyFormat = hcm.LocalReplyConfig.BodyFormat
+			lrc.Mappers = hcm.LocalReplyConfig.Mappers
+		}
+
+		var vhMappers []*envoy_extensions_filters_network_http_connection_manager_v3.ResponseMapper
+		for _, rm := range mappers {
+			if !sni && vh.Name != "*" {
+				rm = &envoy_extensions_filters_network_http_connection_manager_v3.ResponseMapper{
+					Filter: andAccessLogFilter(append(
+						[]*envoy_config_accesslog_v3.AccessLogFilter{authorityAccessLogFilter(vh.Name)},
+						accessLogFilters(rm.Filter)...)),
+					StatusCode:         rm.StatusCode,
+					BodyFormatOverride: rm.BodyFormatOverride,
+				}
+			}
+			vhMappers = append(vhMappers, rm)
+		}
+		lrc.Mappers = append(vhMappers, lrc.Mappers...)
+		hcm.LocalReplyConfig = lrc
+	})
+}
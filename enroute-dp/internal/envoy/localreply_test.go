@@ -0,0 +1,190 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright(c) 2018-2023 Saaras Inc.
+
+package envoy
+
+import (
+	"testing"
+
+	envoy_config_accesslog_v3 "github.com/envoyproxy/go-control-plane/envoy/config/accesslog/v3"
+	envoy_config_core_v3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	envoy_config_listener_v3 "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
+	envoy_config_route_v3 "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	envoy_extensions_filters_network_http_connection_manager_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+	types "github.com/golang/protobuf/ptypes"
+	"github.com/google/go-cmp/cmp"
+	"github.com/saarasio/enroute/enroute-dp/internal/dag"
+	"github.com/saarasio/enroute/enroute-dp/internal/protobuf"
+	cfg "github.com/saarasio/enroute/enroute-dp/saarasconfig"
+	"google.golang.org/protobuf/testing/protocmp"
+	structpb "google.golang.org/protobuf/types/known/structpb"
+)
+
+func textBodyFormat(format, contentType string) *envoy_config_core_v3.SubstitutionFormatString {
+	return &envoy_config_core_v3.SubstitutionFormatString{
+		Format: &envoy_config_core_v3.SubstitutionFormatString_TextFormatSource{
+			TextFormatSource: &envoy_config_core_v3.DataSource{
+				Specifier: &envoy_config_core_v3.DataSource_InlineString{InlineString: format},
+			},
+		},
+		ContentType: contentType,
+	}
+}
+
+func TestListenerLocalReply(t *testing.T) {
+	jsonformat, err := structpb.NewStruct(map[string]interface{}{
+		"code":    "%RESPONSE_CODE%",
+		"message": "%LOCAL_REPLY_BODY%",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]struct {
+		configs []string
+		want    *envoy_extensions_filters_network_http_connection_manager_v3.LocalReplyConfig
+	}{
+		"json default body": {
+			configs: []string{`{"default_response_body":{"json_format":{"code":"%RESPONSE_CODE%","message":"%LOCAL_REPLY_BODY%"}}}`},
+			want: &envoy_extensions_filters_network_http_connection_manager_v3.LocalReplyConfig{
+				BodyFormat: &envoy_config_core_v3.SubstitutionFormatString{
+					Format: &envoy_config_core_v3.SubstitutionFormatString_JsonFormat{
+						JsonFormat: jsonformat,
+					},
+					ContentType: "application/json",
+				},
+			},
+		},
+		"status code override": {
+			configs: []string{`{"custom_response_override":[{"match_status_code":503,"update_status_code":502,
+				"update_response_body":{"text_format":"<h1>%RESPONSE_CODE%</h1>","content_type":"text/html"}}]}`},
+			want: &envoy_extensions_filters_network_http_connection_manager_v3.LocalReplyConfig{
+				Mappers: []*envoy_extensions_filters_network_http_connection_manager_v3.ResponseMapper{{
+					Filter:             statusCodeFilter(503),
+					StatusCode:         protobuf.UInt32(502),
+					BodyFormatOverride: textBodyFormat("<h1>%RESPONSE_CODE%</h1>", "text/html"),
+				}},
+			},
+		},
+		"configs in order": {
+			configs: []string{
+				`{"custom_response_override":[{"match_status_code":404}],"default_response_body":{"text_format":"first"}}`,
+				`{"custom_response_override":[{"match_status_code":503}],"default_response_body":{"text_format":"second"}}`,
+			},
+			want: &envoy_extensions_filters_network_http_connection_manager_v3.LocalReplyConfig{
+				Mappers: []*envoy_extensions_filters_network_http_connection_manager_v3.ResponseMapper{{
+					Filter: statusCodeFilter(404),
+				}, {
+					Filter: statusCodeFilter(503),
+				}},
+				BodyFormat: textBodyFormat("first", "text/plain"),
+			},
+		},
+		"other listener": {
+			configs: []string{`{"listener":"ingress_https","default_response_body":{"text_format":"oops"}}`},
+			want:    nil,
+		},
+		"invalid config": {
+			configs: []string{
+				`{"custom_response_override":[{"match_headers":[{"exact":"no name"}]}]}`,
+				`{"default_response_body":{"content_type":"application/json"}}`,
+			},
+			want: nil,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := ListenerLocalReply(tc.configs, "ingress_http")
+			if diff := cmp.Diff(tc.want, got, protocmp.Transform()); diff != "" {
+				t.Fatal(diff)
+			}
+		})
+	}
+}
+
+func TestAddVirtualHostLocalReplies(t *testing.T) {
+	localReply := func(l *envoy_config_listener_v3.Listener) *envoy_extensions_filters_network_http_connection_manager_v3.LocalReplyConfig {
+		hcm := &envoy_extensions_filters_network_http_connection_manager_v3.HttpConnectionManager{}
+		if err := types.UnmarshalAny(l.FilterChains[0].Filters[0].GetTypedConfig(), hcm); err != nil {
+			t.Fatal(err)
+		}
+		return hcm.LocalReplyConfig
+	}
+	vh := &dag.VirtualHost{
+		Name: "www.example.com",
+		HttpFilters: []*dag.HttpFilter{{
+			Filter: dag.Filter{
+				Filter_name: "errors",
+				Filter_type: cfg.FILTER_TYPE_CUSTOM_RESPONSE,
+				Filter_config: `{"custom_response_override":[{"match_status_code":404,"match_path_prefix":"/api",
+					"match_headers":[{"name":"accept","exact":"text/html"}],"update_response_body":{"text_format":"not found"}}]}`,
+			},
+		}},
+	}
+	listenerDefault := &envoy_extensions_filters_network_http_connection_manager_v3.LocalReplyConfig{
+		Mappers: []*envoy_extensions_filters_network_http_connection_manager_v3.ResponseMapper{{
+			Filter:     statusCodeFilter(503),
+			StatusCode: protobuf.UInt32(502),
+		}},
+		BodyFormat: textBodyFormat("oops", "text/plain"),
+	}
+	matchers := []*envoy_config_accesslog_v3.AccessLogFilter{
+		statusCodeFilter(404),
+		headerAccessLogFilter(&envoy_config_route_v3.HeaderMatcher{
+			Name:                 ":path",
+			HeaderMatchSpecifier: &envoy_config_route_v3.HeaderMatcher_PrefixMatch{PrefixMatch: "/api"},
+		}),
+		headerAccessLogFilter(&envoy_config_route_v3.HeaderMatcher{
+			Name:                 "accept",
+			HeaderMatchSpecifier: &envoy_config_route_v3.HeaderMatcher_ExactMatch{ExactMatch: "text/html"},
+		}),
+	}
+
+	tests := map[string]struct {
+		listener *envoy_config_listener_v3.Listener
+		want     *envoy_extensions_filters_network_http_connection_manager_v3.LocalReplyConfig
+	}{
+		"shared listener": {
+			listener: func() *envoy_config_listener_v3.Listener {
+				l := Listener("ingress_http", "0.0.0.0", 8080, nil, HTTPConnectionManager("ingress_http", "/dev/stdout", nil))
+				SetLocalReply(l, listenerDefault)
+				return l
+			}(),
+			want: &envoy_extensions_filters_network_http_connection_manager_v3.LocalReplyConfig{
+				Mappers: []*envoy_extensions_filters_network_http_connection_manager_v3.ResponseMapper{{
+					Filter: andAccessLogFilter(append([]*envoy_config_accesslog_v3.AccessLogFilter{
+						authorityAccessLogFilter("www.example.com"),
+					}, matchers...)),
+					BodyFormatOverride: textBodyFormat("not found", "text/plain"),
+				}, listenerDefault.Mappers[0]},
+				BodyFormat: listenerDefault.BodyFormat,
+			},
+		},
+		"filter chain of the virtual host": {
+			listener: &envoy_config_listener_v3.Listener{
+				FilterChains: []*envoy_config_listener_v3.FilterChain{{
+					FilterChainMatch: &envoy_config_listener_v3.FilterChainMatch{
+						ServerNames: []string{"www.example.com"},
+					},
+					Filters: Filters(HTTPConnectionManager("ingress_https", "/dev/stdout", nil)),
+				}},
+			},
+			want: &envoy_extensions_filters_network_http_connection_manager_v3.LocalReplyConfig{
+				Mappers: []*envoy_extensions_filters_network_http_connection_manager_v3.ResponseMapper{{
+					Filter:             andAccessLogFilter(matchers),
+					BodyFormatOverride: textBodyFormat("not found", "text/plain"),
+				}},
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			AddVirtualHostLocalReplies(tc.listener, vh)
+			if diff := cmp.Diff(tc.want, localReply(tc.listener), protocmp.Transform()); diff != "" {
+				t.Fatal(diff)
+			}
+		})
+	}
+}
@@ -25,6 +25,8 @@ import (
 
 	envoy_config_core_v3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
 	envoy_config_route_v3 "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	envoy_type_matcher_v3 "github.com/envoyproxy/go-control-plane/envoy/type/matcher/v3"
+	envoy_type_v3 "github.com/envoyproxy/go-control-plane/envoy/type/v3"
 
 	"github.com/golang/protobuf/ptypes/duration"
 	"github.com/saarasio/enroute/enroute-dp/internal/dag"
@@ -36,10 +38,11 @@ import (
 // weighted cluster.
 func RouteRoute(r *dag.Route) *envoy_config_route_v3.Route_Route {
 	ra := envoy_config_route_v3.RouteAction{
-		RetryPolicy:   retryPolicy(r),
-		Timeout:       responseTimeout(r),
-		PrefixRewrite: r.PrefixRewrite,
-		HashPolicy:    hashPolicy(r),
+		RetryPolicy:           retryPolicy(r),
+		Timeout:               responseTimeout(r),
+		PrefixRewrite:         r.PrefixRewrite,
+		HashPolicy:            hashPolicy(r),
+		RequestMirrorPolicies: requestMirrorPolicies(r),
 	}
 
 	ProcessRouteFilters(r, &ra)
@@ -86,6 +89,32 @@ func hashPolicy(r *dag.Route) []*envoy_config_route_v3.RouteAction_HashPolicy {
 	return nil
 }
 
+// requestMirrorPolicies returns a mirror policy for each cluster the
+// requests of the route are mirrored to.
+func requestMirrorPolicies(r *dag.Route) []*envoy_config_route_v3.RouteAction_RequestMirrorPolicy {
+	if r.MirrorPolicy == nil {
+		return nil
+	}
+
+	var policies []*envoy_config_route_v3.RouteAction_RequestMirrorPolicy
+	for _, c := range r.MirrorPolicy.Clusters {
+		policy := &envoy_config_route_v3.RouteAction_RequestMirrorPolicy{
+			Cluster: Clustername(c),
+		}
+		// all requests are mirrored when the fraction is not set
+		if r.MirrorPolicy.Percent < 100 {
+			policy.RuntimeFraction = &envoy_config_core_v3.RuntimeFractionalPercent{
+				DefaultValue: &envoy_type_v3.FractionalPercent{
+					Numerator:   r.MirrorPolicy.Percent,
+					Denominator: envoy_type_v3.FractionalPercent_HUNDRED,
+				},
+			}
+		}
+		policies = append(policies, policy)
+	}
+	return policies
+}
+
 func responseTimeout(r *dag.Route) *duration.Duration {
 	if r.TimeoutPolicy == nil {
 		return nil
@@ -194,29 +223,26 @@ func RouteMatch(path string) *envoy_config_route_v3.RouteMatch {
 
 // RouteMatch creates a *envoy_config_route_v3.RouteMatch for the supplied *dag.Route.
 func RouteMatchNew(route *dag.Route) *envoy_config_route_v3.RouteMatch {
+	rm := &envoy_config_route_v3.RouteMatch{
+		QueryParameters: queryParamMatcher(route.QueryParamConditions),
+		Headers:         append(headerMatcher(route.HeaderConditions), queryParamAbsentMatcher(route.QueryParamConditions)...),
+	}
+
 	switch c := route.PathCondition.(type) {
 	case *dag.RegexCondition:
-		return &envoy_config_route_v3.RouteMatch{
-			PathSpecifier: &envoy_config_route_v3.RouteMatch_SafeRegex{
-				SafeRegex: SafeRegexMatch(c.Regex),
-			},
-			QueryParameters: nil,
-			Headers:         headerMatcher(route.HeaderConditions),
+		rm.PathSpecifier = &envoy_config_route_v3.RouteMatch_SafeRegex{
+			SafeRegex: SafeRegexMatch(c.Regex),
 		}
-	case *dag.PrefixCondition:
-		return &envoy_config_route_v3.RouteMatch{
-			PathSpecifier: &envoy_config_route_v3.RouteMatch_Prefix{
-				Prefix: c.Prefix,
-			},
-			QueryParameters: nil,
-			Headers:         headerMatcher(route.HeaderConditions),
+	case *dag.ExactCondition:
+		rm.PathSpecifier = &envoy_config_route_v3.RouteMatch_Path{
+			Path: c.Path,
 		}
-	default:
-		return &envoy_config_route_v3.RouteMatch{
-			QueryParameters: nil,
-			Headers:         headerMatcher(route.HeaderConditions),
+	case *dag.PrefixCondition:
+		rm.PathSpecifier = &envoy_config_route_v3.RouteMatch_Prefix{
+			Prefix: c.Prefix,
 		}
 	}
+	return rm
 }
 
 // VirtualHost creates a new route.VirtualHost.
@@ -279,9 +305,42 @@ func headerMatcher(headers []dag.HeaderCondition) []*envoy_config_route_v3.Heade
 
 		switch h.MatchType {
 		case "exact":
+			if h.IgnoreCase {
+				header.HeaderMatchSpecifier = headerStringMatch(&envoy_type_matcher_v3.StringMatcher{
+					MatchPattern: &envoy_type_matcher_v3.StringMatcher_Exact{Exact: h.Value},
+					IgnoreCase:   true,
+				})
+				break
+			}
 			header.HeaderMatchSpecifier = &envoy_config_route_v3.HeaderMatcher_ExactMatch{ExactMatch: h.Value}
 		case "contains":
+			if h.IgnoreCase {
+				header.HeaderMatchSpecifier = headerStringMatch(&envoy_type_matcher_v3.StringMatcher{
+					MatchPattern: &envoy_type_matcher_v3.StringMatcher_Contains{Contains: h.Value},
+					IgnoreCase:   true,
+				})
+				break
+			}
 			header.HeaderMatchSpecifier = containsMatch(h.Value)
+		case "prefix":
+			header.HeaderMatchSpecifier = headerStringMatch(&envoy_type_matcher_v3.StringMatcher{
+				MatchPattern: &envoy_type_matcher_v3.StringMatcher_Prefix{Prefix: h.Value},
+				IgnoreCase:   h.IgnoreCase,
+			})
+		case "suffix":
+			header.HeaderMatchSpecifier = headerStringMatch(&envoy_type_matcher_v3.StringMatcher{
+				MatchPattern: &envoy_type_matcher_v3.StringMatcher_Suffix{Suffix: h.Value},
+				IgnoreCase:   h.IgnoreCase,
+			})
+		case "regex":
+			regex := h.Value
+			if h.IgnoreCase {
+				// Envoy ignores ignore_case for regex matches
+				regex = "(?i)" + regex
+			}
+			header.HeaderMatchSpecifier = &envoy_config_route_v3.HeaderMatcher_SafeRegexMatch{
+				SafeRegexMatch: SafeRegexMatch(regex),
+			}
 		case "present":
 			header.HeaderMatchSpecifier = &envoy_config_route_v3.HeaderMatcher_PresentMatch{PresentMatch: true}
 		}
@@ -290,6 +349,10 @@ func headerMatcher(headers []dag.HeaderCondition) []*envoy_config_route_v3.Heade
 	return envoyHeaders
 }
 
+func headerStringMatch(sm *envoy_type_matcher_v3.StringMatcher) *envoy_config_route_v3.HeaderMatcher_StringMatch {
+	return &envoy_config_route_v3.HeaderMatcher_StringMatch{StringMatch: sm}
+}
+
 // containsMatch returns a HeaderMatchSpecifier which will match the
 // supplied substring
 func containsMatch(s string) *envoy_config_route_v3.HeaderMatcher_SafeRegexMatch {
@@ -302,3 +365,63 @@ func containsMatch(s string) *envoy_config_route_v3.HeaderMatcher_SafeRegexMatch
 		SafeRegexMatch: SafeRegexMatch(regex),
 	}
 }
+
+func queryParamMatcher(params []dag.QueryParamsCondition) []*envoy_config_route_v3.QueryParameterMatcher {
+	var envoyParams []*envoy_config_route_v3.QueryParameterMatcher
+
+	for _, q := range params {
+		param := &envoy_config_route_v3.QueryParameterMatcher{
+			Name: q.Key,
+		}
+
+		switch q.MatchType {
+		case "exact":
+			param.QueryParameterMatchSpecifier = stringMatch(&envoy_type_matcher_v3.StringMatcher{
+				MatchPattern: &envoy_type_matcher_v3.StringMatcher_Exact{Exact: q.Value},
+			})
+		case "prefix":
+			param.QueryParameterMatchSpecifier = stringMatch(&envoy_type_matcher_v3.StringMatcher{
+				MatchPattern: &envoy_type_matcher_v3.StringMatcher_Prefix{Prefix: q.Value},
+			})
+		case "regex":
+			param.QueryParameterMatchSpecifier = stringMatch(&envoy_type_matcher_v3.StringMatcher{
+				MatchPattern: &envoy_type_matcher_v3.StringMatcher_SafeRegex{SafeRegex: SafeRegexMatch(q.Value)},
+			})
+		case "present":
+			if q.Invert {
+				// matched by queryParamAbsentMatcher
+				continue
+			}
+			param.QueryParameterMatchSpecifier = &envoy_config_route_v3.QueryParameterMatcher_PresentMatch{PresentMatch: true}
+		default:
+			continue
+		}
+		envoyParams = append(envoyParams, param)
+	}
+	return envoyParams
+}
+
+func stringMatch(sm *envoy_type_matcher_v3.StringMatcher) *envoy_config_route_v3.QueryParameterMatcher_StringMatch {
+	return &envoy_config_route_v3.QueryParameterMatcher_StringMatch{StringMatch: sm}
+}
+
+// queryParamAbsentMatcher returns a HeaderMatcher for each query parameter
+// that must not be present. Envoy cannot invert a QueryParameterMatcher, so
+// the query string of the :path header is matched instead.
+func queryParamAbsentMatcher(params []dag.QueryParamsCondition) []*envoy_config_route_v3.HeaderMatcher {
+	var envoyHeaders []*envoy_config_route_v3.HeaderMatcher
+
+	for _, q := range params {
+		if q.MatchType != "present" || !q.Invert {
+			continue
+		}
+		envoyHeaders = append(envoyHeaders, &envoy_config_route_v3.HeaderMatcher{
+			Name:        ":path",
+			InvertMatch: true,
+			HeaderMatchSpecifier: &envoy_config_route_v3.HeaderMatcher_SafeRegexMatch{
+				SafeRegexMatch: SafeRegexMatch(fmt.Sprintf(".*[?&]%s([=&].*)?", regexp.QuoteMeta(q.Key))),
+			},
+		})
+	}
+	return envoyHeaders
+}
@@ -0,0 +1,82 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright(c) 2018-2023 Saaras Inc.
+
+package envoy
+
+import (
+	"regexp"
+	"strings"
+
+	envoy_config_core_v3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	envoy_config_route_v3 "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	"github.com/saarasio/enroute/enroute-dp/internal/dag"
+	"github.com/saarasio/enroute/enroute-dp/internal/protobuf"
+)
+
+// commandOperatorRegex matches the command operators of Envoy, e.g.
+// %DOWNSTREAM_REMOTE_ADDRESS_WITHOUT_PORT% or %START_TIME(%s)%.
+var commandOperatorRegex = regexp.MustCompile(`%[A-Z0-9_]+(\([^)]*\))?(:[0-9]+)?%`)
+
+// escapeHeaderValue escapes each % of v that is not part of a command
+// operator, so that Envoy sends it as is.
+func escapeHeaderValue(v string) string {
+	var b strings.Builder
+	last := 0
+	for _, loc := range commandOperatorRegex.FindAllStringIndex(v, -1) {
+		b.WriteString(strings.ReplaceAll(v[last:loc[0]], "%", "%%"))
+		b.WriteString(v[loc[0]:loc[1]])
+		last = loc[1]
+	}
+	b.WriteString(strings.ReplaceAll(v[last:], "%", "%%"))
+	return b.String()
+}
+
+// headersToAdd returns the headers set and added by hp.
+func headersToAdd(hp *dag.HeadersPolicy) []*envoy_config_core_v3.HeaderValueOption {
+	if hp == nil {
+		return nil
+	}
+
+	var headers []*envoy_config_core_v3.HeaderValueOption
+	for _, h := range hp.Set {
+		headers = append(headers, &envoy_config_core_v3.HeaderValueOption{
+			Header: &envoy_config_core_v3.HeaderValue{
+				Key:   h.Name,
+				Value: escapeHeaderValue(h.Value),
+			},
+			Append: protobuf.Bool(false),
+		})
+	}
+	for _, h := range hp.Add {
+		headers = append(headers, AppendHeader(h.Name, escapeHeaderValue(h.Value)))
+	}
+	return headers
+}
+
+// headersToRemove returns the headers removed by hp.
+func headersToRemove(hp *dag.HeadersPolicy) []string {
+	if hp == nil {
+		return nil
+	}
+	return hp.Remove
+}
+
+// SetupVirtualHostHeaders sets the headers managed by the headers policies
+// of vh on vhost.
+func SetupVirtualHostHeaders(vh *dag.VirtualHost, vhost *envoy_config_route_v3.VirtualHost) {
+	vhost.RequestHeadersToAdd = headersToAdd(vh.RequestHeadersPolicy)
+	vhost.RequestHeadersToRemove = headersToRemove(vh.RequestHeadersPolicy)
+	vhost.ResponseHeadersToAdd = headersToAdd(vh.ResponseHeadersPolicy)
+	vhost.ResponseHeadersToRemove = headersToRemove(vh.ResponseHeadersPolicy)
+}
+
+// SetupRouteHeaders adds the headers managed by the headers policies of r
+// to rr. Request headers are only managed on routes forwarding requests.
+func SetupRouteHeaders(r *dag.Route, rr *envoy_config_route_v3.Route) {
+	if rr.GetRoute() != nil {
+		rr.RequestHeadersToAdd = append(rr.RequestHeadersToAdd, headersToAdd(r.RequestHeadersPolicy)...)
+		rr.RequestHeadersToRemove = headersToRemove(r.RequestHeadersPolicy)
+	}
+	rr.ResponseHeadersToAdd = headersToAdd(r.ResponseHeadersPolicy)
+	rr.ResponseHeadersToRemove = headersToRemove(r.ResponseHeadersPolicy)
+}
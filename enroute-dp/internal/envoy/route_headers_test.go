@@ -0,0 +1,115 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright(c) 2018-2023 Saaras Inc.
+
+package envoy
+
+import (
+	"testing"
+
+	envoy_config_core_v3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	envoy_config_route_v3 "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	"github.com/saarasio/enroute/enroute-dp/internal/assert"
+	"github.com/saarasio/enroute/enroute-dp/internal/dag"
+	"github.com/saarasio/enroute/enroute-dp/internal/protobuf"
+)
+
+func TestEscapeHeaderValue(t *testing.T) {
+	tests := map[string]string{
+		"":                        "",
+		"no percent":              "no percent",
+		"50%":                     "50%%",
+		"%REQUESTED_SERVER_NAME%": "%REQUESTED_SERVER_NAME%",
+		"ip=%DOWNSTREAM_REMOTE_ADDRESS_WITHOUT_PORT%; 100%": "ip=%DOWNSTREAM_REMOTE_ADDRESS_WITHOUT_PORT%; 100%%",
+		"t=%START_TIME(%s.%3f)%":                            "t=%START_TIME(%s.%3f)%",
+		"%REQ(x-request-id)%":                               "%REQ(x-request-id)%",
+		"%REQ(X-REQUEST-ID):10%":                            "%REQ(X-REQUEST-ID):10%",
+	}
+
+	for value, want := range tests {
+		t.Run(value, func(t *testing.T) {
+			assert.Equal(t, want, escapeHeaderValue(value))
+		})
+	}
+}
+
+func TestSetupRouteHeaders(t *testing.T) {
+	setHeader := func(key, value string) *envoy_config_core_v3.HeaderValueOption {
+		return &envoy_config_core_v3.HeaderValueOption{
+			Header: &envoy_config_core_v3.HeaderValue{
+				Key:   key,
+				Value: value,
+			},
+			Append: protobuf.Bool(false),
+		}
+	}
+	route := &dag.Route{
+		RequestHeadersPolicy: &dag.HeadersPolicy{
+			Set:    []dag.HeaderValue{{Name: "x-client-ip", Value: "%DOWNSTREAM_REMOTE_ADDRESS_WITHOUT_PORT%"}},
+			Add:    []dag.HeaderValue{{Name: "x-discount", Value: "10%"}},
+			Remove: []string{"x-internal"},
+		},
+		ResponseHeadersPolicy: &dag.HeadersPolicy{
+			Set:    []dag.HeaderValue{{Name: "x-sni", Value: "%REQUESTED_SERVER_NAME%"}},
+			Remove: []string{"server"},
+		},
+	}
+
+	tests := map[string]struct {
+		rr   *envoy_config_route_v3.Route
+		want *envoy_config_route_v3.Route
+	}{
+		"route": {
+			rr: &envoy_config_route_v3.Route{
+				Action:              &envoy_config_route_v3.Route_Route{Route: &envoy_config_route_v3.RouteAction{}},
+				RequestHeadersToAdd: RouteHeaders(),
+			},
+			want: &envoy_config_route_v3.Route{
+				Action: &envoy_config_route_v3.Route_Route{Route: &envoy_config_route_v3.RouteAction{}},
+				RequestHeadersToAdd: append(RouteHeaders(),
+					setHeader("x-client-ip", "%DOWNSTREAM_REMOTE_ADDRESS_WITHOUT_PORT%"),
+					AppendHeader("x-discount", "10%%"),
+				),
+				RequestHeadersToRemove:  []string{"x-internal"},
+				ResponseHeadersToAdd:    Headers(setHeader("x-sni", "%REQUESTED_SERVER_NAME%")),
+				ResponseHeadersToRemove: []string{"server"},
+			},
+		},
+		"https upgrade": {
+			rr: &envoy_config_route_v3.Route{
+				Action: UpgradeHTTPS(),
+			},
+			want: &envoy_config_route_v3.Route{
+				Action:                  UpgradeHTTPS(),
+				ResponseHeadersToAdd:    Headers(setHeader("x-sni", "%REQUESTED_SERVER_NAME%")),
+				ResponseHeadersToRemove: []string{"server"},
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			SetupRouteHeaders(route, tc.rr)
+			assert.Equal(t, tc.want, tc.rr)
+		})
+	}
+}
+
+func TestSetupVirtualHostHeaders(t *testing.T) {
+	vh := &dag.VirtualHost{
+		Name: "www.example.com",
+		RequestHeadersPolicy: &dag.HeadersPolicy{
+			Add: []dag.HeaderValue{{Name: "x-tenant", Value: "acme"}},
+		},
+		ResponseHeadersPolicy: &dag.HeadersPolicy{
+			Remove: []string{"x-powered-by"},
+		},
+	}
+
+	vhost := VirtualHost(vh.Name)
+	SetupVirtualHostHeaders(vh, vhost)
+
+	want := VirtualHost(vh.Name)
+	want.RequestHeadersToAdd = Headers(AppendHeader("x-tenant", "acme"))
+	want.ResponseHeadersToRemove = []string{"x-powered-by"}
+	assert.Equal(t, want, vhost)
+}
@@ -81,6 +81,36 @@ func rateLimitActionGenericKey(descriptor_value string) *envoy_config_route_v3.R
 	}
 }
 
+// rateLimitActions returns the actions producing the descriptor entries of
+// descriptors.
+func rateLimitActions(descriptors []saarasconfig.Descriptors) []*envoy_config_route_v3.RateLimit_Action {
+	var rla []*envoy_config_route_v3.RateLimit_Action
+	for _, oneRouteActionDescriptor := range descriptors {
+		if oneRouteActionDescriptor.GenericKey != nil &&
+			len(oneRouteActionDescriptor.GenericKey.DescriptorValue) > 0 {
+
+			rla = append(rla,
+				rateLimitActionGenericKey(oneRouteActionDescriptor.GenericKey.DescriptorValue))
+
+		} else if oneRouteActionDescriptor.RequestHeaders != nil &&
+			len(oneRouteActionDescriptor.RequestHeaders.HeaderName) > 0 {
+
+			rla = append(rla,
+				rateLimitActionRequestHeaders(
+					oneRouteActionDescriptor.RequestHeaders.HeaderName,
+					oneRouteActionDescriptor.RequestHeaders.DescriptorKey))
+
+		} else if len(oneRouteActionDescriptor.SourceCluster) > 0 {
+			rla = append(rla, rateLimitActionSourceCluster())
+		} else if len(oneRouteActionDescriptor.DestinationCluster) > 0 {
+			rla = append(rla, rateLimitActionDestinationCluster())
+		} else if len(oneRouteActionDescriptor.RemoteAddress) > 0 {
+			rla = append(rla, rateLimitActionRemoteAddress())
+		}
+	}
+	return rla
+}
+
 func rateLimits(rl_filters []*dag.RouteFilter) []*envoy_config_route_v3.RateLimit {
 
 	var rad saarasconfig.RouteActionDescriptors
@@ -103,29 +133,7 @@ func rateLimits(rl_filters []*dag.RouteFilter) []*envoy_config_route_v3.RateLimi
 				return nil
 			}
 
-			for _, oneRouteActionDescriptor := range rad.Descriptors {
-				if oneRouteActionDescriptor.GenericKey != nil &&
-					len(oneRouteActionDescriptor.GenericKey.DescriptorValue) > 0 {
-
-					rla = append(rla,
-						rateLimitActionGenericKey(oneRouteActionDescriptor.GenericKey.DescriptorValue))
-
-				} else if oneRouteActionDescriptor.RequestHeaders != nil &&
-					len(oneRouteActionDescriptor.RequestHeaders.HeaderName) > 0 {
-
-					rla = append(rla,
-						rateLimitActionRequestHeaders(
-							oneRouteActionDescriptor.RequestHeaders.HeaderName,
-							oneRouteActionDescriptor.RequestHeaders.DescriptorKey))
-
-				} else if len(oneRouteActionDescriptor.SourceCluster) > 0 {
-					rla = append(rla, rateLimitActionSourceCluster())
-				} else if len(oneRouteActionDescriptor.DestinationCluster) > 0 {
-					rla = append(rla, rateLimitActionDestinationCluster())
-				} else if len(oneRouteActionDescriptor.RemoteAddress) > 0 {
-					rla = append(rla, rateLimitActionRemoteAddress())
-				}
-			}
+			rla = append(rla, rateLimitActions(rad.Descriptors)...)
 
 			rrl := envoy_config_route_v3.RateLimit{
 				Stage:   u32nil(0),
@@ -153,29 +161,7 @@ func dagToEnvoyRateLimit(f *dag.RouteFilter, ra *envoy_config_route_v3.RouteActi
 		return
 	}
 
-	for _, oneRouteActionDescriptor := range rad.Descriptors {
-		if oneRouteActionDescriptor.GenericKey != nil &&
-			len(oneRouteActionDescriptor.GenericKey.DescriptorValue) > 0 {
-
-			rla = append(rla,
-				rateLimitActionGenericKey(oneRouteActionDescriptor.GenericKey.DescriptorValue))
-
-		} else if oneRouteActionDescriptor.RequestHeaders != nil &&
-			len(oneRouteActionDescriptor.RequestHeaders.HeaderName) > 0 {
-
-			rla = append(rla,
-				rateLimitActionRequestHeaders(
-					oneRouteActionDescriptor.RequestHeaders.HeaderName,
-					oneRouteActionDescriptor.RequestHeaders.DescriptorKey))
-
-		} else if len(oneRouteActionDescriptor.SourceCluster) > 0 {
-			rla = append(rla, rateLimitActionSourceCluster())
-		} else if len(oneRouteActionDescriptor.DestinationCluster) > 0 {
-			rla = append(rla, rateLimitActionDestinationCluster())
-		} else if len(oneRouteActionDescriptor.RemoteAddress) > 0 {
-			rla = append(rla, rateLimitActionRemoteAddress())
-		}
-	}
+	rla = append(rla, rateLimitActions(rad.Descriptors)...)
 
 	rrl := envoy_config_route_v3.RateLimit{
 		Stage:   u32nil(0),
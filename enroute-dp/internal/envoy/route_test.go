@@ -23,6 +23,7 @@ import (
 	v31 "github.com/envoyproxy/go-control-plane/envoy/type/matcher/v3"
 	envoy_config_core_v3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
 	envoy_config_route_v3 "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	envoy_type_v3 "github.com/envoyproxy/go-control-plane/envoy/type/v3"
 	"github.com/saarasio/enroute/enroute-dp/internal/assert"
 	"github.com/saarasio/enroute/enroute-dp/internal/dag"
 	"github.com/saarasio/enroute/enroute-dp/internal/protobuf"
@@ -62,6 +63,20 @@ func TestRouteRoute(t *testing.T) {
 		},
 		LoadBalancerStrategy: "Cookie",
 	}
+	c3 := &dag.Cluster{
+		Upstream: &dag.TCPService{
+			Name:        "kuard-v2",
+			Namespace:   s1.Namespace,
+			ServicePort: &s1.Spec.Ports[0],
+		},
+	}
+	c4 := &dag.Cluster{
+		Upstream: &dag.TCPService{
+			Name:        "kuard-v3",
+			Namespace:   s1.Namespace,
+			ServicePort: &s1.Spec.Ports[0],
+		},
+	}
 
 	tests := map[string]struct {
 		route *dag.Route
@@ -411,6 +426,58 @@ func TestRouteRoute(t *testing.T) {
 				},
 			},
 		},
+		"mirror": {
+			route: &dag.Route{
+				Clusters: []*dag.Cluster{c1},
+				MirrorPolicy: &dag.MirrorPolicy{
+					Clusters: []*dag.Cluster{c3},
+					Percent:  100,
+				},
+			},
+			want: &envoy_config_route_v3.Route_Route{
+				Route: &envoy_config_route_v3.RouteAction{
+					ClusterSpecifier: &envoy_config_route_v3.RouteAction_Cluster{
+						Cluster: "default/kuard/8080/da39a3ee5e",
+					},
+					RequestMirrorPolicies: []*envoy_config_route_v3.RouteAction_RequestMirrorPolicy{{
+						Cluster: "default/kuard-v2/8080/da39a3ee5e",
+					}},
+				},
+			},
+		},
+		"mirror fraction to two services": {
+			route: &dag.Route{
+				Clusters: []*dag.Cluster{c1},
+				MirrorPolicy: &dag.MirrorPolicy{
+					Clusters: []*dag.Cluster{c3, c4},
+					Percent:  25,
+				},
+			},
+			want: &envoy_config_route_v3.Route_Route{
+				Route: &envoy_config_route_v3.RouteAction{
+					ClusterSpecifier: &envoy_config_route_v3.RouteAction_Cluster{
+						Cluster: "default/kuard/8080/da39a3ee5e",
+					},
+					RequestMirrorPolicies: []*envoy_config_route_v3.RouteAction_RequestMirrorPolicy{{
+						Cluster: "default/kuard-v2/8080/da39a3ee5e",
+						RuntimeFraction: &envoy_config_core_v3.RuntimeFractionalPercent{
+							DefaultValue: &envoy_type_v3.FractionalPercent{
+								Numerator:   25,
+								Denominator: envoy_type_v3.FractionalPercent_HUNDRED,
+							},
+						},
+					}, {
+						Cluster: "default/kuard-v3/8080/da39a3ee5e",
+						RuntimeFraction: &envoy_config_core_v3.RuntimeFractionalPercent{
+							DefaultValue: &envoy_type_v3.FractionalPercent{
+								Numerator:   25,
+								Denominator: envoy_type_v3.FractionalPercent_HUNDRED,
+							},
+						},
+					}},
+				},
+			},
+		},
 	}
 
 	for name, tc := range tests {
@@ -756,6 +823,92 @@ func TestRouteMatchNew(t *testing.T) {
 				}},
 			},
 		},
+		"prefix suffix and regex matches": {
+			route: &dag.Route{
+				HeaderConditions: []dag.HeaderCondition{{
+					Name:      "x-tenant",
+					Value:     ".eu",
+					MatchType: "suffix",
+				}, {
+					Name:       "user-agent",
+					Value:      "mozilla/",
+					MatchType:  "prefix",
+					IgnoreCase: true,
+				}, {
+					Name:       "x-tenant-id",
+					Value:      "t-[0-9]+",
+					MatchType:  "regex",
+					IgnoreCase: true,
+				}},
+			},
+			want: &envoy_config_route_v3.RouteMatch{
+				Headers: []*envoy_config_route_v3.HeaderMatcher{{
+					Name: "x-tenant",
+					HeaderMatchSpecifier: headerStringMatch(&v31.StringMatcher{
+						MatchPattern: &v31.StringMatcher_Suffix{Suffix: ".eu"},
+					}),
+				}, {
+					Name: "user-agent",
+					HeaderMatchSpecifier: headerStringMatch(&v31.StringMatcher{
+						MatchPattern: &v31.StringMatcher_Prefix{Prefix: "mozilla/"},
+						IgnoreCase:   true,
+					}),
+				}, {
+					Name: "x-tenant-id",
+					HeaderMatchSpecifier: &envoy_config_route_v3.HeaderMatcher_SafeRegexMatch{
+						SafeRegexMatch: SafeRegexMatch("(?i)t-[0-9]+"),
+					},
+				}},
+			},
+		},
+		"exact and contains ignoring case": {
+			route: &dag.Route{
+				HeaderConditions: []dag.HeaderCondition{{
+					Name:       "x-tenant",
+					Value:      "acme",
+					MatchType:  "exact",
+					IgnoreCase: true,
+				}, {
+					Name:       "user-agent",
+					Value:      "bot",
+					MatchType:  "contains",
+					Invert:     true,
+					IgnoreCase: true,
+				}},
+			},
+			want: &envoy_config_route_v3.RouteMatch{
+				Headers: []*envoy_config_route_v3.HeaderMatcher{{
+					Name: "x-tenant",
+					HeaderMatchSpecifier: headerStringMatch(&v31.StringMatcher{
+						MatchPattern: &v31.StringMatcher_Exact{Exact: "acme"},
+						IgnoreCase:   true,
+					}),
+				}, {
+					Name:        "user-agent",
+					InvertMatch: true,
+					HeaderMatchSpecifier: headerStringMatch(&v31.StringMatcher{
+						MatchPattern: &v31.StringMatcher_Contains{Contains: "bot"},
+						IgnoreCase:   true,
+					}),
+				}},
+			},
+		},
+		"not present": {
+			route: &dag.Route{
+				HeaderConditions: []dag.HeaderCondition{{
+					Name:      "x-debug",
+					MatchType: "present",
+					Invert:    true,
+				}},
+			},
+			want: &envoy_config_route_v3.RouteMatch{
+				Headers: []*envoy_config_route_v3.HeaderMatcher{{
+					Name:                 "x-debug",
+					InvertMatch:          true,
+					HeaderMatchSpecifier: &envoy_config_route_v3.HeaderMatcher_PresentMatch{PresentMatch: true},
+				}},
+			},
+		},
 		"path prefix": {
 			route: &dag.Route{
 				PathCondition: &dag.PrefixCondition{
@@ -783,6 +936,85 @@ func TestRouteMatchNew(t *testing.T) {
 				},
 			},
 		},
+		"exact path and method": {
+			route: &dag.Route{
+				PathCondition: &dag.ExactCondition{
+					Path: "/healthz",
+				},
+				HeaderConditions: []dag.HeaderCondition{{
+					Name:      ":method",
+					Value:     "GET",
+					MatchType: "exact",
+				}},
+			},
+			want: &envoy_config_route_v3.RouteMatch{
+				PathSpecifier: &envoy_config_route_v3.RouteMatch_Path{
+					Path: "/healthz",
+				},
+				Headers: []*envoy_config_route_v3.HeaderMatcher{{
+					Name:                 ":method",
+					HeaderMatchSpecifier: &envoy_config_route_v3.HeaderMatcher_ExactMatch{ExactMatch: "GET"},
+				}},
+			},
+		},
+		"query parameters": {
+			route: &dag.Route{
+				PathCondition: &dag.PrefixCondition{
+					Prefix: "/api",
+				},
+				QueryParamConditions: []dag.QueryParamsCondition{{
+					Key:       "version",
+					Value:     "2",
+					MatchType: "exact",
+				}, {
+					Key:       "region",
+					Value:     "us-",
+					MatchType: "prefix",
+				}, {
+					Key:       "id",
+					Value:     "[0-9]+",
+					MatchType: "regex",
+				}, {
+					Key:       "beta",
+					MatchType: "present",
+				}, {
+					Key:       "debug.v2",
+					MatchType: "present",
+					Invert:    true,
+				}},
+			},
+			want: &envoy_config_route_v3.RouteMatch{
+				PathSpecifier: &envoy_config_route_v3.RouteMatch_Prefix{
+					Prefix: "/api",
+				},
+				QueryParameters: []*envoy_config_route_v3.QueryParameterMatcher{{
+					Name: "version",
+					QueryParameterMatchSpecifier: stringMatch(&v31.StringMatcher{
+						MatchPattern: &v31.StringMatcher_Exact{Exact: "2"},
+					}),
+				}, {
+					Name: "region",
+					QueryParameterMatchSpecifier: stringMatch(&v31.StringMatcher{
+						MatchPattern: &v31.StringMatcher_Prefix{Prefix: "us-"},
+					}),
+				}, {
+					Name: "id",
+					QueryParameterMatchSpecifier: stringMatch(&v31.StringMatcher{
+						MatchPattern: &v31.StringMatcher_SafeRegex{SafeRegex: SafeRegexMatch("[0-9]+")},
+					}),
+				}, {
+					Name:                         "beta",
+					QueryParameterMatchSpecifier: &envoy_config_route_v3.QueryParameterMatcher_PresentMatch{PresentMatch: true},
+				}},
+				Headers: []*envoy_config_route_v3.HeaderMatcher{{
+					Name:        ":path",
+					InvertMatch: true,
+					HeaderMatchSpecifier: &envoy_config_route_v3.HeaderMatcher_SafeRegexMatch{
+						SafeRegexMatch: SafeRegexMatch(`.*[?&]debug\.v2([=&].*)?`),
+					},
+				}},
+			},
+		},
 	}
 
 	for name, tc := range tests {
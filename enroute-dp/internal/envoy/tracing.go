@@ -0,0 +1,255 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright(c) 2018-2023 Saaras Inc.
+
+package envoy
+
+import (
+	"time"
+
+	envoy_config_cluster_v3 "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
+	envoy_config_core_v3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	envoy_config_endpoint_v3 "github.com/envoyproxy/go-control-plane/envoy/config/endpoint/v3"
+	envoy_config_listener_v3 "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
+	envoy_config_route_v3 "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	envoy_config_trace_v3 "github.com/envoyproxy/go-control-plane/envoy/config/trace/v3"
+	envoy_extensions_filters_network_http_connection_manager_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+	envoy_type_tracing_v3 "github.com/envoyproxy/go-control-plane/envoy/type/tracing/v3"
+	envoy_type_v3 "github.com/envoyproxy/go-control-plane/envoy/type/v3"
+	"github.com/saarasio/enroute/enroute-dp/internal/dag"
+	"github.com/saarasio/enroute/enroute-dp/internal/logger"
+	"github.com/saarasio/enroute/enroute-dp/internal/protobuf"
+	cfg "github.com/saarasio/enroute/enroute-dp/saarasconfig"
+)
+
+const (
+	TracingProviderZipkin        = "zipkin"
+	TracingProviderOpenTelemetry = "opentelemetry"
+
+	// TracingCluster is the collector cluster the tracing GlobalConfig
+	// sends spans to when it has an address.
+	TracingCluster = "enroute_tracing"
+
+	defaultZipkinCollectorEndpoint = "/api/v2/spans"
+	defaultTracingServiceName      = "enroute"
+	defaultMaxPathTagLength        = 256
+)
+
+// BootstrapTracingCluster is the collector cluster of the Envoy bootstrap,
+// the tracing GlobalConfig sends spans to it when it has no address.
+const BootstrapTracingCluster = cfg.JAEGER_TRACING_CLUSTER
+
+// tracingClusterName returns the collector cluster spans of tc are sent to.
+func tracingClusterName(tc cfg.TracingConfig) string {
+	if tc.Address != "" {
+		return TracingCluster
+	}
+	return BootstrapTracingCluster
+}
+
+func unmarshalTracingConfig(config string) (cfg.TracingConfig, bool) {
+	tc, err := cfg.UnmarshalTracingConfig(config)
+	if err != nil {
+		if logger.EL.ELogger != nil {
+			logger.EL.ELogger.Errorf("internal:envoy:tracing:unmarshalTracingConfig() failed to decode config [%s]\n", err)
+		}
+		return tc, false
+	}
+	switch tc.Provider {
+	case "", TracingProviderZipkin, TracingProviderOpenTelemetry:
+		return tc, true
+	default:
+		if logger.EL.ELogger != nil {
+			logger.EL.ELogger.Errorf("internal:envoy:tracing:unmarshalTracingConfig() unsupported provider [%s]\n", tc.Provider)
+		}
+		return tc, false
+	}
+}
+
+// ListenerTracing returns the tracing of HTTP connection managers configured
+// by a tracing GlobalConfig, nil if its config is invalid.
+func ListenerTracing(config string) *envoy_extensions_filters_network_http_connection_manager_v3.HttpConnectionManager_Tracing {
+	tc, ok := unmarshalTracingConfig(config)
+	if !ok {
+		return nil
+	}
+
+	maxPathTagLength := tc.MaxPathTagLength
+	if maxPathTagLength == 0 {
+		maxPathTagLength = defaultMaxPathTagLength
+	}
+	return &envoy_extensions_filters_network_http_connection_manager_v3.HttpConnectionManager_Tracing{
+		ClientSampling:   tracingPercent(tc.ClientSampling),
+		RandomSampling:   tracingPercent(tc.RandomSampling),
+		OverallSampling:  tracingPercent(tc.OverallSampling),
+		CustomTags:       tracingCustomTags(tc.CustomTags),
+		MaxPathTagLength: protobuf.UInt32(maxPathTagLength),
+		Provider:         tracingProvider(tc),
+	}
+}
+
+func tracingProvider(tc cfg.TracingConfig) *envoy_config_trace_v3.Tracing_Http {
+	cluster := tracingClusterName(tc)
+	if tc.Provider == TracingProviderOpenTelemetry {
+		serviceName := tc.ServiceName
+		if serviceName == "" {
+			serviceName = defaultTracingServiceName
+		}
+		return &envoy_config_trace_v3.Tracing_Http{
+			Name: "envoy.tracers.opentelemetry",
+			ConfigType: &envoy_config_trace_v3.Tracing_Http_TypedConfig{
+				TypedConfig: toAny(&envoy_config_trace_v3.OpenTelemetryConfig{
+					GrpcService: &envoy_config_core_v3.GrpcService{
+						TargetSpecifier: &envoy_config_core_v3.GrpcService_EnvoyGrpc_{
+							EnvoyGrpc: &envoy_config_core_v3.GrpcService_EnvoyGrpc{
+								ClusterName: cluster,
+							},
+						},
+					},
+					ServiceName: serviceName,
+				}),
+			},
+		}
+	}
+
+	endpoint := tc.CollectorEndpoint
+	if endpoint == "" {
+		endpoint = defaultZipkinCollectorEndpoint
+	}
+	return &envoy_config_trace_v3.Tracing_Http{
+		Name: "envoy.tracers.zipkin",
+		ConfigType: &envoy_config_trace_v3.Tracing_Http_TypedConfig{
+			TypedConfig: toAny(&envoy_config_trace_v3.ZipkinConfig{
+				CollectorCluster:         cluster,
+				CollectorEndpoint:        endpoint,
+				CollectorEndpointVersion: envoy_config_trace_v3.ZipkinConfig_HTTP_JSON,
+				SharedSpanContext:        protobuf.Bool(false),
+			}),
+		},
+	}
+}
+
+// tracingPercent returns p as a percentage, nil to use Envoy's default of
+// 100 if p is nil.
+func tracingPercent(p *float64) *envoy_type_v3.Percent {
+	if p == nil {
+		return nil
+	}
+	return &envoy_type_v3.Percent{Value: *p}
+}
+
+// tracingFractionalPercent returns p as a fraction of a million, nil if p
+// is nil.
+func tracingFractionalPercent(p *float64) *envoy_type_v3.FractionalPercent {
+	if p == nil {
+		return nil
+	}
+	return &envoy_type_v3.FractionalPercent{
+		Numerator:   uint32(*p * 10000),
+		Denominator: envoy_type_v3.FractionalPercent_MILLION,
+	}
+}
+
+func tracingCustomTags(tags []cfg.TracingCustomTag) []*envoy_type_tracing_v3.CustomTag {
+	var customTags []*envoy_type_tracing_v3.CustomTag
+	for _, t := range tags {
+		ct := &envoy_type_tracing_v3.CustomTag{Tag: t.Tag}
+		switch {
+		case t.Header != "":
+			ct.Type = &envoy_type_tracing_v3.CustomTag_RequestHeader{
+				RequestHeader: &envoy_type_tracing_v3.CustomTag_Header{
+					Name:         t.Header,
+					DefaultValue: t.Default,
+				},
+			}
+		case t.Environment != "":
+			ct.Type = &envoy_type_tracing_v3.CustomTag_Environment_{
+				Environment: &envoy_type_tracing_v3.CustomTag_Environment{
+					Name:         t.Environment,
+					DefaultValue: t.Default,
+				},
+			}
+		default:
+			ct.Type = &envoy_type_tracing_v3.CustomTag_Literal_{
+				Literal: &envoy_type_tracing_v3.CustomTag_Literal{Value: t.Literal},
+			}
+		}
+		customTags = append(customTags, ct)
+	}
+	return customTags
+}
+
+// SetTracing sets the tracing of each HTTP connection manager of l.
+func SetTracing(l *envoy_config_listener_v3.Listener, tracing *envoy_extensions_filters_network_http_connection_manager_v3.HttpConnectionManager_Tracing) {
+	updateHttpConnectionManagers(l, "", func(hcm *envoy_extensions_filters_network_http_connection_manager_v3.HttpConnectionManager, _ bool) {
+		hcm.Tracing = tracing
+	})
+}
+
+// tracingCollectorCluster returns a cluster named name for the collector
+// at address and port. OpenTelemetry collectors are reached over gRPC.
+func tracingCollectorCluster(name, address string, port int, grpc bool) *envoy_config_cluster_v3.Cluster {
+	c := &envoy_config_cluster_v3.Cluster{
+		Name:                 name,
+		ConnectTimeout:       protobuf.Duration(5 * time.Second),
+		ClusterDiscoveryType: ClusterDiscoveryType(envoy_config_cluster_v3.Cluster_STRICT_DNS),
+		LbPolicy:             envoy_config_cluster_v3.Cluster_ROUND_ROBIN,
+		LoadAssignment: &envoy_config_endpoint_v3.ClusterLoadAssignment{
+			ClusterName: name,
+			Endpoints:   Endpoints(SocketAddress(address, port)),
+		},
+	}
+	if grpc {
+		c.Http2ProtocolOptions = new(envoy_config_core_v3.Http2ProtocolOptions) // enables http2
+	}
+	return c
+}
+
+// TracingCollectorCluster returns the collector cluster of a tracing
+// GlobalConfig, nil if its config is invalid or has no address.
+func TracingCollectorCluster(config string) *envoy_config_cluster_v3.Cluster {
+	tc, ok := unmarshalTracingConfig(config)
+	if !ok || tc.Address == "" {
+		return nil
+	}
+	port := int(tc.Port)
+	if port == 0 {
+		port = 9411
+		if tc.Provider == TracingProviderOpenTelemetry {
+			port = 4317
+		}
+	}
+	return tracingCollectorCluster(TracingCluster, tc.Address, port, tc.Provider == TracingProviderOpenTelemetry)
+}
+
+// RouteTracing returns the tracing of route r set by its tracing route
+// filter, nil if it has none or its config is invalid.
+func RouteTracing(r *dag.Route) *envoy_config_route_v3.Tracing {
+	for _, f := range r.RouteFilters {
+		if f.Filter.Filter_type != cfg.FILTER_TYPE_RT_TRACING {
+			continue
+		}
+		rtc, err := cfg.UnmarshalRouteTracingConfig(f.Filter.Filter_config)
+		if err != nil {
+			if logger.EL.ELogger != nil {
+				logger.EL.ELogger.Errorf("internal:envoy:tracing:RouteTracing() Filter [%s] failed to decode config [%s]\n",
+					f.Filter.Filter_name, err)
+			}
+			return nil
+		}
+		return &envoy_config_route_v3.Tracing{
+			ClientSampling:  tracingFractionalPercent(rtc.ClientSampling),
+			RandomSampling:  tracingFractionalPercent(rtc.RandomSampling),
+			OverallSampling: tracingFractionalPercent(rtc.OverallSampling),
+			CustomTags:      tracingCustomTags(rtc.CustomTags),
+		}
+	}
+	return nil
+}
+
+// SetupRouteTracing overrides the tracing of rr with that of the tracing
+// route filter of r.
+func SetupRouteTracing(r *dag.Route, rr *envoy_config_route_v3.Route) {
+	if t := RouteTracing(r); t != nil {
+		rr.Tracing = t
+	}
+}
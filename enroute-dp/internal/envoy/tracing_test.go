@@ -0,0 +1,212 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright(c) 2018-2023 Saaras Inc.
+
+package envoy
+
+import (
+	"testing"
+	"time"
+
+	envoy_config_cluster_v3 "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
+	envoy_config_core_v3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	envoy_config_endpoint_v3 "github.com/envoyproxy/go-control-plane/envoy/config/endpoint/v3"
+	envoy_config_route_v3 "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	envoy_config_trace_v3 "github.com/envoyproxy/go-control-plane/envoy/config/trace/v3"
+	envoy_extensions_filters_network_http_connection_manager_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+	envoy_type_tracing_v3 "github.com/envoyproxy/go-control-plane/envoy/type/tracing/v3"
+	envoy_type_v3 "github.com/envoyproxy/go-control-plane/envoy/type/v3"
+	"github.com/saarasio/enroute/enroute-dp/internal/assert"
+	"github.com/saarasio/enroute/enroute-dp/internal/dag"
+	"github.com/saarasio/enroute/enroute-dp/internal/protobuf"
+	cfg "github.com/saarasio/enroute/enroute-dp/saarasconfig"
+)
+
+func TestListenerTracing(t *testing.T) {
+	zipkin := func(cluster string) *envoy_config_trace_v3.Tracing_Http {
+		return &envoy_config_trace_v3.Tracing_Http{
+			Name: "envoy.tracers.zipkin",
+			ConfigType: &envoy_config_trace_v3.Tracing_Http_TypedConfig{
+				TypedConfig: toAny(&envoy_config_trace_v3.ZipkinConfig{
+					CollectorCluster:         cluster,
+					CollectorEndpoint:        "/api/v2/spans",
+					CollectorEndpointVersion: envoy_config_trace_v3.ZipkinConfig_HTTP_JSON,
+					SharedSpanContext:        protobuf.Bool(false),
+				}),
+			},
+		}
+	}
+
+	tests := map[string]struct {
+		config string
+		want   *envoy_extensions_filters_network_http_connection_manager_v3.HttpConnectionManager_Tracing
+	}{
+		"zipkin to the bootstrap collector": {
+			config: `{}`,
+			want: &envoy_extensions_filters_network_http_connection_manager_v3.HttpConnectionManager_Tracing{
+				MaxPathTagLength: protobuf.UInt32(256),
+				Provider:         zipkin("jaeger-trace"),
+			},
+		},
+		"zipkin sampling and tags": {
+			config: `{"provider":"zipkin","address":"zipkin.tracing","random_sampling":10,"overall_sampling":50,
+				"custom_tags":[{"tag":"cluster","literal":"us-west"},{"tag":"tenant","header":"x-tenant","default":"none"},{"tag":"pod","environment":"POD_NAME"}]}`,
+			want: &envoy_extensions_filters_network_http_connection_manager_v3.HttpConnectionManager_Tracing{
+				RandomSampling:  &envoy_type_v3.Percent{Value: 10},
+				OverallSampling: &envoy_type_v3.Percent{Value: 50},
+				CustomTags: []*envoy_type_tracing_v3.CustomTag{{
+					Tag: "cluster",
+					Type: &envoy_type_tracing_v3.CustomTag_Literal_{
+						Literal: &envoy_type_tracing_v3.CustomTag_Literal{Value: "us-west"},
+					},
+				}, {
+					Tag: "tenant",
+					Type: &envoy_type_tracing_v3.CustomTag_RequestHeader{
+						RequestHeader: &envoy_type_tracing_v3.CustomTag_Header{Name: "x-tenant", DefaultValue: "none"},
+					},
+				}, {
+					Tag: "pod",
+					Type: &envoy_type_tracing_v3.CustomTag_Environment_{
+						Environment: &envoy_type_tracing_v3.CustomTag_Environment{Name: "POD_NAME"},
+					},
+				}},
+				MaxPathTagLength: protobuf.UInt32(256),
+				Provider:         zipkin("enroute_tracing"),
+			},
+		},
+		"opentelemetry": {
+			config: `{"provider":"opentelemetry","address":"otel-collector.tracing","service_name":"edge","max_path_tag_length":64}`,
+			want: &envoy_extensions_filters_network_http_connection_manager_v3.HttpConnectionManager_Tracing{
+				MaxPathTagLength: protobuf.UInt32(64),
+				Provider: &envoy_config_trace_v3.Tracing_Http{
+					Name: "envoy.tracers.opentelemetry",
+					ConfigType: &envoy_config_trace_v3.Tracing_Http_TypedConfig{
+						TypedConfig: toAny(&envoy_config_trace_v3.OpenTelemetryConfig{
+							GrpcService: &envoy_config_core_v3.GrpcService{
+								TargetSpecifier: &envoy_config_core_v3.GrpcService_EnvoyGrpc_{
+									EnvoyGrpc: &envoy_config_core_v3.GrpcService_EnvoyGrpc{
+										ClusterName: "enroute_tracing",
+									},
+								},
+							},
+							ServiceName: "edge",
+						}),
+					},
+				},
+			},
+		},
+		"unsupported provider": {
+			config: `{"provider":"datadog"}`,
+			want:   nil,
+		},
+		"invalid config": {
+			config: `{"provider":`,
+			want:   nil,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := ListenerTracing(tc.config)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestTracingCollectorCluster(t *testing.T) {
+	collector := func(address string, port uint32) *envoy_config_cluster_v3.Cluster {
+		return &envoy_config_cluster_v3.Cluster{
+			Name:                 "enroute_tracing",
+			ConnectTimeout:       protobuf.Duration(5 * time.Second),
+			ClusterDiscoveryType: ClusterDiscoveryType(envoy_config_cluster_v3.Cluster_STRICT_DNS),
+			LbPolicy:             envoy_config_cluster_v3.Cluster_ROUND_ROBIN,
+			LoadAssignment: &envoy_config_endpoint_v3.ClusterLoadAssignment{
+				ClusterName: "enroute_tracing",
+				Endpoints:   Endpoints(SocketAddress(address, int(port))),
+			},
+		}
+	}
+	grpc := func(c *envoy_config_cluster_v3.Cluster) *envoy_config_cluster_v3.Cluster {
+		c.Http2ProtocolOptions = new(envoy_config_core_v3.Http2ProtocolOptions)
+		return c
+	}
+
+	tests := map[string]struct {
+		config string
+		want   *envoy_config_cluster_v3.Cluster
+	}{
+		"zipkin default port": {
+			config: `{"address":"zipkin.tracing"}`,
+			want:   collector("zipkin.tracing", 9411),
+		},
+		"opentelemetry over grpc": {
+			config: `{"provider":"opentelemetry","address":"otel-collector.tracing"}`,
+			want:   grpc(collector("otel-collector.tracing", 4317)),
+		},
+		"port": {
+			config: `{"address":"jaeger.tracing","port":9412}`,
+			want:   collector("jaeger.tracing", 9412),
+		},
+		"bootstrap collector": {
+			config: `{"provider":"zipkin"}`,
+			want:   nil,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := TracingCollectorCluster(tc.config)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestSetupRouteTracing(t *testing.T) {
+	tracing := func(config string) *dag.RouteFilter {
+		return &dag.RouteFilter{
+			Filter: dag.Filter{
+				Filter_name:   "tracing",
+				Filter_type:   cfg.FILTER_TYPE_RT_TRACING,
+				Filter_config: config,
+			},
+		}
+	}
+
+	tests := map[string]struct {
+		route *dag.Route
+		want  *envoy_config_route_v3.Tracing
+	}{
+		"sampling override": {
+			route: &dag.Route{RouteFilters: []*dag.RouteFilter{
+				tracing(`{"random_sampling":0.5,"custom_tags":[{"tag":"route","literal":"checkout"}]}`),
+			}},
+			want: &envoy_config_route_v3.Tracing{
+				RandomSampling: &envoy_type_v3.FractionalPercent{
+					Numerator:   5000,
+					Denominator: envoy_type_v3.FractionalPercent_MILLION,
+				},
+				CustomTags: []*envoy_type_tracing_v3.CustomTag{{
+					Tag: "route",
+					Type: &envoy_type_tracing_v3.CustomTag_Literal_{
+						Literal: &envoy_type_tracing_v3.CustomTag_Literal{Value: "checkout"},
+					},
+				}},
+			},
+		},
+		"no tracing filter": {
+			route: &dag.Route{},
+			want:  nil,
+		},
+		"invalid config": {
+			route: &dag.Route{RouteFilters: []*dag.RouteFilter{tracing(`{"random_sampling":"all"}`)}},
+			want:  nil,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			rr := &envoy_config_route_v3.Route{}
+			SetupRouteTracing(tc.route, rr)
+			assert.Equal(t, &envoy_config_route_v3.Route{Tracing: tc.want}, rr)
+		})
+	}
+}
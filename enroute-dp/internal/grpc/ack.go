@@ -0,0 +1,281 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright(c) 2018-2023 Saaras Inc.
+
+package grpc
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	envoy_service_discovery_v3 "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	"github.com/saarasio/enroute/enroute-dp/internal/metrics"
+	"google.golang.org/genproto/googleapis/rpc/status"
+)
+
+// AckTracker follows the xDS ACK/NACK protocol on each stream. For every
+// connection and type URL it records the last response sent, the last
+// version Envoy acknowledged and the last response Envoy rejected.
+type AckTracker struct {
+	// Metrics, if set, counts ACKs and NACKs and tracks the number of
+	// streams whose last response was rejected.
+	*metrics.Metrics
+
+	// OnRejectionChange, if set, is called by Start when the set of
+	// errors returned by RejectedErrors changes. Changes made while it
+	// runs are coalesced into one more call.
+	OnRejectionChange func()
+
+	// changes signals Start that the rejected errors changed.
+	changes chan struct{}
+
+	mu      sync.Mutex
+	streams map[streamKey]*StreamStatus
+}
+
+type streamKey struct {
+	connection uint64
+	typeURL    string
+}
+
+// StreamStatus is the ACK/NACK state of one type URL on one connection.
+type StreamStatus struct {
+	Connection   uint64    `json:"connection"`
+	Node         string    `json:"node,omitempty"`
+	TypeURL      string    `json:"type_url"`
+	SentVersion  string    `json:"sent_version,omitempty"`
+	SentNonce    string    `json:"sent_nonce,omitempty"`
+	AckedVersion string    `json:"acked_version,omitempty"`
+	LastAckTime  time.Time `json:"last_ack_time"`
+	Nack         *Nack     `json:"nack,omitempty"`
+}
+
+// Nack is a response rejected by Envoy.
+type Nack struct {
+	Version string    `json:"version"`
+	Nonce   string    `json:"nonce"`
+	Error   string    `json:"error"`
+	Time    time.Time `json:"time"`
+}
+
+// NewAckTracker returns an AckTracker with no streams.
+func NewAckTracker() *AckTracker {
+	return &AckTracker{
+		changes: make(chan struct{}, 1),
+		streams: make(map[streamKey]*StreamStatus),
+	}
+}
+
+// Start calls OnRejectionChange for the changes to the rejected errors
+// until stop is closed, so that the xDS streams recording them do not
+// wait for it.
+func (t *AckTracker) Start(stop <-chan struct{}) error {
+	for {
+		select {
+		case <-t.changes:
+			if t.OnRejectionChange != nil {
+				t.OnRejectionChange()
+			}
+		case <-stop:
+			return nil
+		}
+	}
+}
+
+// rejectionChanged signals Start without waiting for it.
+func (t *AckTracker) rejectionChanged() {
+	select {
+	case t.changes <- struct{}{}:
+	default:
+		// Start has yet to pick up an earlier change.
+	}
+}
+
+func (t *AckTracker) stream(connection uint64, typeURL string) *StreamStatus {
+	k := streamKey{connection: connection, typeURL: typeURL}
+	s, ok := t.streams[k]
+	if !ok {
+		s = &StreamStatus{Connection: connection, TypeURL: typeURL}
+		t.streams[k] = s
+	}
+	return s
+}
+
+// request records a DiscoveryRequest received on connection. A request
+// whose nonce matches the last response sent ACKs that response, or
+// NACKs it if ErrorDetail is set. Requests carrying an older nonce have
+// been superseded and are ignored.
+func (t *AckTracker) request(connection uint64, node string, req *envoy_service_discovery_v3.DiscoveryRequest) {
+	t.record(connection, node, req.TypeUrl, req.ResponseNonce, req.VersionInfo, req.ErrorDetail)
+}
+
+// deltaRequest records a DeltaDiscoveryRequest received on connection.
+// Delta requests carry no version, an ACK acknowledges the system version
+// of the response it refers to.
+func (t *AckTracker) deltaRequest(connection uint64, node string, req *envoy_service_discovery_v3.DeltaDiscoveryRequest) {
+	t.record(connection, node, req.TypeUrl, req.ResponseNonce, "", req.ErrorDetail)
+}
+
+// deltaResponse records a DeltaDiscoveryResponse sent on connection.
+func (t *AckTracker) deltaResponse(connection uint64, resp *envoy_service_discovery_v3.DeltaDiscoveryResponse) {
+	t.response(connection, &envoy_service_discovery_v3.DiscoveryResponse{
+		TypeUrl:     resp.TypeUrl,
+		VersionInfo: resp.SystemVersionInfo,
+		Nonce:       resp.Nonce,
+	})
+}
+
+func (t *AckTracker) record(connection uint64, node, typeURL, nonce, version string, errorDetail *status.Status) {
+	if t == nil {
+		return
+	}
+
+	t.mu.Lock()
+	s := t.stream(connection, typeURL)
+	if node != "" {
+		s.Node = node
+	}
+	if nonce == "" || nonce != s.SentNonce {
+		t.mu.Unlock()
+		return
+	}
+
+	changed := false
+	if errorDetail != nil {
+		msg := errorDetail.GetMessage()
+		changed = s.Nack == nil || s.Nack.Error != msg
+		s.Nack = &Nack{
+			Version: s.SentVersion,
+			Nonce:   nonce,
+			Error:   msg,
+			Time:    time.Now(),
+		}
+		if t.Metrics != nil {
+			t.Metrics.ObserveXDSNack(typeURL)
+		}
+	} else {
+		if version == "" {
+			version = s.SentVersion
+		}
+		changed = s.Nack != nil
+		s.AckedVersion = version
+		s.LastAckTime = time.Now()
+		s.Nack = nil
+		if t.Metrics != nil {
+			t.Metrics.ObserveXDSAck(typeURL)
+		}
+	}
+	t.updateRejected(typeURL)
+	t.mu.Unlock()
+
+	if changed {
+		t.rejectionChanged()
+	}
+}
+
+// response records a DiscoveryResponse sent on connection.
+func (t *AckTracker) response(connection uint64, resp *envoy_service_discovery_v3.DiscoveryResponse) {
+	if t == nil {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	s := t.stream(connection, resp.TypeUrl)
+	s.SentVersion = resp.VersionInfo
+	s.SentNonce = resp.Nonce
+}
+
+// closed forgets all streams of connection.
+func (t *AckTracker) closed(connection uint64) {
+	if t == nil {
+		return
+	}
+
+	t.mu.Lock()
+	changed := false
+	typeURLs := make(map[string]bool)
+	for k, s := range t.streams {
+		if k.connection == connection {
+			changed = changed || s.Nack != nil
+			typeURLs[k.typeURL] = true
+			delete(t.streams, k)
+		}
+	}
+	for typeURL := range typeURLs {
+		t.updateRejected(typeURL)
+	}
+	t.mu.Unlock()
+
+	if changed {
+		t.rejectionChanged()
+	}
+}
+
+// updateRejected sets the number of streams of typeURL whose last
+// response was rejected. t.mu must be held.
+func (t *AckTracker) updateRejected(typeURL string) {
+	if t.Metrics == nil {
+		return
+	}
+	n := 0
+	for k, s := range t.streams {
+		if k.typeURL == typeURL && s.Nack != nil {
+			n++
+		}
+	}
+	t.Metrics.SetXDSRejected(typeURL, n)
+}
+
+// Streams returns the status of all streams ordered by connection and type URL.
+func (t *AckTracker) Streams() []StreamStatus {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var streams []StreamStatus
+	for _, s := range t.streams {
+		st := *s
+		if s.Nack != nil {
+			nack := *s.Nack
+			st.Nack = &nack
+		}
+		streams = append(streams, st)
+	}
+	sort.Slice(streams, func(i, j int) bool {
+		if streams[i].Connection != streams[j].Connection {
+			return streams[i].Connection < streams[j].Connection
+		}
+		return streams[i].TypeURL < streams[j].TypeURL
+	})
+	return streams
+}
+
+// RejectedErrors returns the distinct errors of all responses currently
+// rejected by Envoy, sorted.
+func (t *AckTracker) RejectedErrors() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	seen := make(map[string]bool)
+	var errs []string
+	for _, s := range t.streams {
+		if s.Nack != nil && !seen[s.Nack.Error] {
+			seen[s.Nack.Error] = true
+			errs = append(errs, s.Nack.Error)
+		}
+	}
+	sort.Strings(errs)
+	return errs
+}
+
+// ServeHTTP writes the status of all streams as JSON.
+func (t *AckTracker) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(t.Streams()); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
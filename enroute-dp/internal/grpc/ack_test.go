@@ -0,0 +1,247 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright(c) 2018-2023 Saaras Inc.
+
+package grpc
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+
+	envoy_config_core_v3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	envoy_config_endpoint_v3 "github.com/envoyproxy/go-control-plane/envoy/config/endpoint/v3"
+	envoy_service_discovery_v3 "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	"github.com/golang/protobuf/proto"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/saarasio/enroute/enroute-dp/internal/metrics"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/genproto/googleapis/rpc/status"
+)
+
+const testTypeURL = "type.googleapis.com/envoy.config.listener.v3.Listener"
+
+func discoveryRequest(version, nonce, errorDetail string) *envoy_service_discovery_v3.DiscoveryRequest {
+	req := &envoy_service_discovery_v3.DiscoveryRequest{
+		TypeUrl:       testTypeURL,
+		VersionInfo:   version,
+		ResponseNonce: nonce,
+	}
+	if errorDetail != "" {
+		req.ErrorDetail = &status.Status{Message: errorDetail}
+	}
+	return req
+}
+
+func sent(t *AckTracker, connection uint64, version string) {
+	t.response(connection, &envoy_service_discovery_v3.DiscoveryResponse{
+		TypeUrl:     testTypeURL,
+		VersionInfo: version,
+		Nonce:       version,
+	})
+}
+
+// rejectionChanged returns true if t signalled a change of the rejected
+// errors since it was last called.
+func rejectionChanged(t *AckTracker) bool {
+	select {
+	case <-t.changes:
+		return true
+	default:
+		return false
+	}
+}
+
+func TestAckTrackerStart(t *testing.T) {
+	tracker := NewAckTracker()
+	called := make(chan []string)
+	tracker.OnRejectionChange = func() {
+		called <- tracker.RejectedErrors()
+	}
+	stop := make(chan struct{})
+	done := make(chan error, 1)
+	go func() { done <- tracker.Start(stop) }()
+
+	// the request is recorded without waiting for OnRejectionChange
+	sent(tracker, 1, "1")
+	tracker.request(1, "envoy-1", discoveryRequest("", "1", "bad listener"))
+	select {
+	case errs := <-called:
+		if diff := cmp.Diff([]string{"bad listener"}, errs); diff != "" {
+			t.Fatal(diff)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for OnRejectionChange")
+	}
+
+	close(stop)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAckTracker(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	m := metrics.NewMetrics(registry)
+
+	tracker := NewAckTracker()
+	tracker.Metrics = m
+
+	ignoreTimes := cmpopts.IgnoreFields(StreamStatus{}, "LastAckTime")
+	ignoreNackTimes := cmpopts.IgnoreFields(Nack{}, "Time")
+
+	// initial request
+	tracker.request(1, "envoy-1", discoveryRequest("", "", ""))
+	sent(tracker, 1, "1")
+	tracker.request(1, "", discoveryRequest("1", "1", ""))
+
+	want := []StreamStatus{{
+		Connection:   1,
+		Node:         "envoy-1",
+		TypeURL:      testTypeURL,
+		SentVersion:  "1",
+		SentNonce:    "1",
+		AckedVersion: "1",
+	}}
+	if diff := cmp.Diff(want, tracker.Streams(), ignoreTimes); diff != "" {
+		t.Fatal(diff)
+	}
+
+	// version 2 is rejected, Envoy keeps version 1
+	sent(tracker, 1, "2")
+	tracker.request(1, "", discoveryRequest("1", "2", "bad listener"))
+	want[0].SentVersion = "2"
+	want[0].SentNonce = "2"
+	want[0].Nack = &Nack{Version: "2", Nonce: "2", Error: "bad listener"}
+	if diff := cmp.Diff(want, tracker.Streams(), ignoreTimes, ignoreNackTimes); diff != "" {
+		t.Fatal(diff)
+	}
+	if diff := cmp.Diff([]string{"bad listener"}, tracker.RejectedErrors()); diff != "" {
+		t.Fatal(diff)
+	}
+	if !rejectionChanged(tracker) {
+		t.Fatal("expected a rejection change")
+	}
+
+	// a stale nonce is ignored
+	tracker.request(1, "", discoveryRequest("1", "1", ""))
+	if diff := cmp.Diff([]string{"bad listener"}, tracker.RejectedErrors()); diff != "" {
+		t.Fatal(diff)
+	}
+	if rejectionChanged(tracker) {
+		t.Fatal("unexpected rejection change")
+	}
+
+	// the same rejection of a new version is not a change
+	sent(tracker, 1, "3")
+	tracker.request(1, "", discoveryRequest("1", "3", "bad listener"))
+	if rejectionChanged(tracker) {
+		t.Fatal("unexpected rejection change")
+	}
+
+	// version 4 is accepted
+	sent(tracker, 1, "4")
+	tracker.request(1, "", discoveryRequest("4", "4", ""))
+	if errs := tracker.RejectedErrors(); len(errs) != 0 {
+		t.Fatalf("expected no rejections, got %v", errs)
+	}
+	if !rejectionChanged(tracker) {
+		t.Fatal("expected a rejection change")
+	}
+
+	// closing a connection with a rejection is a change
+	sent(tracker, 2, "4")
+	tracker.request(2, "", discoveryRequest("", "4", "bad listener"))
+	rejectionChanged(tracker)
+	tracker.closed(2)
+	if !rejectionChanged(tracker) {
+		t.Fatal("expected a rejection change")
+	}
+	if n := len(tracker.Streams()); n != 1 {
+		t.Fatalf("expected 1 stream, got %d", n)
+	}
+
+	expected := `
+# HELP enroute_xds_ack_total Total number of xDS responses acknowledged by Envoy
+# TYPE enroute_xds_ack_total counter
+enroute_xds_ack_total{type_url="type.googleapis.com/envoy.config.listener.v3.Listener"} 2
+# HELP enroute_xds_nack_total Total number of xDS responses rejected by Envoy
+# TYPE enroute_xds_nack_total counter
+enroute_xds_nack_total{type_url="type.googleapis.com/envoy.config.listener.v3.Listener"} 3
+# HELP enroute_xds_rejected_streams Number of xDS streams whose last response was rejected by Envoy
+# TYPE enroute_xds_rejected_streams gauge
+enroute_xds_rejected_streams{type_url="type.googleapis.com/envoy.config.listener.v3.Listener"} 0
+`
+	if err := testutil.GatherAndCompare(registry, strings.NewReader(expected), metrics.XDSAckTotal, metrics.XDSNackTotal, metrics.XDSRejectedGauge); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestXDSHandlerStreamNack(t *testing.T) {
+	log := logrus.New()
+	log.SetOutput(ioutil.Discard)
+
+	tracker := NewAckTracker()
+	var rejected []string
+	var streams []StreamStatus
+
+	requests := []*envoy_service_discovery_v3.DiscoveryRequest{
+		{TypeUrl: testTypeURL, Node: &envoy_config_core_v3.Node{Id: "envoy-1"}},
+		{TypeUrl: testTypeURL, ResponseNonce: "0", ErrorDetail: &status.Status{Message: "bad listener"}},
+	}
+	xh := xdsHandler{
+		FieldLogger: log,
+		acks:        tracker,
+		resources: map[string]Resource{
+			testTypeURL: &mockResource{
+				register: func(ch chan int, last int) {
+					ch <- last + 1
+				},
+				contents: func() []proto.Message {
+					return []proto.Message{new(envoy_config_endpoint_v3.ClusterLoadAssignment)}
+				},
+				typeurl: func() string { return testTypeURL },
+			},
+		},
+	}
+	stream := &mockStream{
+		context: context.Background,
+		recv: func() (*envoy_service_discovery_v3.DiscoveryRequest, error) {
+			if len(requests) == 0 {
+				rejected = tracker.RejectedErrors()
+				streams = tracker.Streams()
+				return nil, io.EOF
+			}
+			req := requests[0]
+			requests = requests[1:]
+			return req, nil
+		},
+		send: func(*envoy_service_discovery_v3.DiscoveryResponse) error { return nil },
+	}
+
+	if err := xh.stream(stream); err != io.EOF {
+		t.Fatalf("expected EOF, got %v", err)
+	}
+
+	// the NACK is recorded, then cleared when the stream terminates.
+	if diff := cmp.Diff([]string{"bad listener"}, rejected); diff != "" {
+		t.Fatal(diff)
+	}
+	if len(streams) != 1 || streams[0].Node != "envoy-1" || streams[0].Nack == nil || streams[0].Nack.Version != streams[0].SentVersion {
+		t.Fatalf("unexpected streams: %+v", streams)
+	}
+	if !rejectionChanged(tracker) {
+		t.Fatal("expected a rejection change")
+	}
+	if errs := tracker.RejectedErrors(); len(errs) != 0 {
+		t.Fatalf("expected no rejections, got %v", errs)
+	}
+	if n := len(tracker.Streams()); n != 0 {
+		t.Fatalf("expected no streams, got %d", n)
+	}
+}
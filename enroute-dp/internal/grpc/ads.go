@@ -0,0 +1,422 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright(c) 2018-2023 Saaras Inc.
+
+package grpc
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+
+	envoy_config_core_v3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	envoy_service_discovery_v3 "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	resource "github.com/envoyproxy/go-control-plane/pkg/resource/v3"
+	"github.com/golang/protobuf/proto"
+	"github.com/saarasio/enroute/enroute-dp/internal/protobuf"
+	protov2 "google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+// adsOrder is the order in which the resources of an aggregated stream
+// are sent when several types changed together. Secrets and clusters are
+// made before the listeners and routes that refer to them, and clusters
+// removed are only broken once the client acknowledged everything else.
+var adsOrder = []string{
+	resource.SecretType,
+	resource.ClusterType,
+	resource.EndpointType,
+	resource.ListenerType,
+	resource.RouteType,
+}
+
+// typeOrder sorts typeURLs in adsOrder, followed by any other type in
+// lexical order.
+func typeOrder(typeURLs []string) []string {
+	rank := func(typeURL string) int {
+		for i, t := range adsOrder {
+			if t == typeURL {
+				return i
+			}
+		}
+		return len(adsOrder)
+	}
+	sort.Slice(typeURLs, func(i, j int) bool {
+		ri, rj := rank(typeURLs[i]), rank(typeURLs[j])
+		if ri != rj {
+			return ri < rj
+		}
+		return typeURLs[i] < typeURLs[j]
+	})
+	return typeURLs
+}
+
+// xdsCluster is the name of the bootstrap cluster of the xDS server.
+const xdsCluster = "enroute"
+
+// notification is a change to the resources of typeURL.
+type notification struct {
+	typeURL string
+	last    int
+}
+
+// watch forwards each change to r to notify until ctx is done. The first
+// notification is sent immediately.
+func watch(ctx context.Context, r Resource, notify chan<- notification) {
+	ch := make(chan int, 1)
+	last := -1
+	for {
+		r.Register(ch, last)
+		select {
+		case last = <-ch:
+			select {
+			case notify <- notification{typeURL: r.TypeURL(), last: last}:
+			case <-ctx.Done():
+				return
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// adsType is the state of one resource type on an aggregated stream.
+type adsType struct {
+	r Resource
+
+	// names holds the resource names of the last request.
+	names []string
+
+	// version is the version the client has.
+	version string
+
+	// nonce is the nonce of the last response.
+	nonce string
+
+	// requested is true if the client awaits a response.
+	requested bool
+
+	// acked is true if the client accepted the last response.
+	acked bool
+
+	// pending is true if the resources changed since the last response.
+	pending bool
+
+	// sent holds the resources of the last response by name.
+	sent map[string]proto.Message
+
+	// draining is true if the last response still held resources
+	// removed from r.
+	draining bool
+}
+
+// streamAggregated processes a stream of DiscoveryRequests for all resource
+// types. Changes notified together are sent in adsOrder, and clusters
+// removed are sent once more until the client acknowledged the listeners
+// and routes that may still refer to them.
+func (xh *xdsHandler) streamAggregated(st grpcStream) (err error) {
+	connection := xh.connections.next()
+	log := xh.WithField("connection", connection).WithField("protocol", "ads")
+	defer xh.acks.closed(connection)
+
+	defer func() {
+		if err != nil {
+			log.WithError(err).Error("stream terminated")
+		} else {
+			log.Info("stream terminated")
+		}
+	}()
+
+	ctx, cancel := context.WithCancel(st.Context())
+	defer cancel()
+
+	reqs := make(chan *envoy_service_discovery_v3.DiscoveryRequest)
+	errs := make(chan error, 1)
+	go func() {
+		for {
+			req, err := st.Recv()
+			if err != nil {
+				errs <- err
+				return
+			}
+			select {
+			case reqs <- req:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	var (
+		types  = make(map[string]*adsType)
+		order  []string
+		notify = make(chan notification)
+		nonce  counter
+	)
+
+	respond := func(t *adsType, keep bool) error {
+		var resources []proto.Message
+		switch len(t.names) {
+		case 0:
+			resources = t.r.Contents()
+		default:
+			resources = t.r.Query(t.names)
+		}
+
+		current := make(map[string]proto.Message, len(resources))
+		for _, r := range resources {
+			current[resourceName(r)] = r
+		}
+		t.draining = false
+		if keep {
+			var removed []string
+			for name := range t.sent {
+				if _, ok := current[name]; !ok {
+					removed = append(removed, name)
+				}
+			}
+			sort.Strings(removed)
+			for _, name := range removed {
+				current[name] = t.sent[name]
+				resources = append(resources, t.sent[name])
+			}
+			t.draining = len(removed) > 0
+		}
+
+		values, err := adsResources(resources)
+		if err != nil {
+			return err
+		}
+		any, err := toAny(t.r.TypeURL(), values)
+		if err != nil {
+			return err
+		}
+		t.sent = current
+		t.pending = false
+		version := contentVersion(any)
+		if version == t.version {
+			// the client has these resources already.
+			return nil
+		}
+		resp := &envoy_service_discovery_v3.DiscoveryResponse{
+			VersionInfo: version,
+			Resources:   any,
+			TypeUrl:     t.r.TypeURL(),
+			Nonce:       strconv.FormatUint(nonce.next(), 10),
+		}
+		if err := st.Send(resp); err != nil {
+			return err
+		}
+		xh.acks.response(connection, resp)
+		t.nonce = resp.Nonce
+		t.requested = false
+		t.acked = false
+		log.WithField("type_url", resp.TypeUrl).WithField("version_info", resp.VersionInfo).WithField("count", len(any)).WithField("draining", t.draining).Info("response")
+		return nil
+	}
+
+	flush := func() error {
+		pending := false
+		for _, typeURL := range order {
+			t := types[typeURL]
+			if !t.pending {
+				continue
+			}
+			if !t.requested {
+				// wait for the client before sending anything
+				// that may refer to these resources.
+				pending = true
+				break
+			}
+			if err := respond(t, typeURL == resource.ClusterType); err != nil {
+				return err
+			}
+		}
+		if pending {
+			// do not break anything until everything referring to
+			// it has been updated.
+			return nil
+		}
+		for _, typeURL := range order {
+			if !types[typeURL].acked {
+				// the client may still refer to the removed
+				// resources until it accepted the update.
+				return nil
+			}
+		}
+		for _, typeURL := range order {
+			if t := types[typeURL]; t.draining && t.requested {
+				if err := respond(t, false); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+
+	for {
+		select {
+		case req := <-reqs:
+			xh.acks.request(connection, req.GetNode().GetId(), req)
+			if req.ErrorDetail != nil {
+				log.WithField("type_url", req.TypeUrl).WithField("response_nonce", req.ResponseNonce).
+					WithField("error_detail", req.ErrorDetail.GetMessage()).Error("response rejected")
+			}
+
+			t, ok := types[req.TypeUrl]
+			switch {
+			case !ok:
+				r, ok := xh.resources[req.TypeUrl]
+				if !ok {
+					return fmt.Errorf("no resource registered for typeURL %q", req.TypeUrl)
+				}
+				t = &adsType{r: r}
+				types[req.TypeUrl] = t
+				order = typeOrder(append(order, req.TypeUrl))
+				go watch(ctx, r, notify)
+			case req.ResponseNonce != t.nonce:
+				// the request was sent before our last response
+				// was received, another will follow.
+				continue
+			case !sameNames(t.names, req.ResourceNames):
+				// send the resources now subscribed to rather
+				// than waiting for the next change.
+				t.pending = true
+			}
+			t.names = req.ResourceNames
+			t.version = req.VersionInfo
+			t.requested = true
+			t.acked = req.ErrorDetail == nil
+		case n := <-notify:
+			// pick up the changes notified at the same time so that
+			// they are sent in order.
+			for more := true; more; {
+				types[n.typeURL].pending = true
+				select {
+				case n = <-notify:
+				default:
+					more = false
+				}
+			}
+		case err := <-errs:
+			return err
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+
+		if err := flush(); err != nil {
+			return err
+		}
+	}
+}
+
+// sameNames returns true if a and b hold the same names in any order.
+func sameNames(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	a = append([]string(nil), a...)
+	b = append([]string(nil), b...)
+	sort.Strings(a)
+	sort.Strings(b)
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// adsResources returns values with every config source that refers to
+// the xDS cluster replaced by the aggregated stream, so that the EDS,
+// RDS and SDS resources a client on an aggregated stream discovers are
+// sent on that stream too. values is not modified.
+func adsResources(values []proto.Message) ([]proto.Message, error) {
+	result := make([]proto.Message, 0, len(values))
+	for _, v := range values {
+		m := protov2.Clone(proto.MessageV2(v))
+		changed, err := adsConfigSources(m.ProtoReflect())
+		if err != nil {
+			return nil, err
+		}
+		if !changed {
+			result = append(result, v)
+			continue
+		}
+		result = append(result, proto.MessageV1(m))
+	}
+	return result, nil
+}
+
+// adsConfigSources replaces the config sources referring to the xDS
+// cluster in m, including those in typed configs, and returns true if any
+// was replaced.
+func adsConfigSources(m protoreflect.Message) (bool, error) {
+	switch v := m.Interface().(type) {
+	case *envoy_config_core_v3.ConfigSource:
+		if !isXDSConfigSource(v) {
+			return false, nil
+		}
+		v.ConfigSourceSpecifier = &envoy_config_core_v3.ConfigSource_Ads{
+			Ads: &envoy_config_core_v3.AggregatedConfigSource{},
+		}
+		return true, nil
+	case *anypb.Any:
+		inner, err := v.UnmarshalNew()
+		if err != nil {
+			// a type this binary does not know cannot refer to
+			// the xDS cluster.
+			return false, nil
+		}
+		changed, err := adsConfigSources(inner.ProtoReflect())
+		if err != nil || !changed {
+			return false, err
+		}
+		v.Value, err = protobuf.Marshal(proto.MessageV1(inner))
+		return err == nil, err
+	}
+
+	changed := false
+	var err error
+	walk := func(m protoreflect.Message) bool {
+		var c bool
+		c, err = adsConfigSources(m)
+		changed = changed || c
+		return err == nil
+	}
+	m.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		switch {
+		case fd.IsList() && fd.Message() != nil:
+			l := v.List()
+			for i := 0; i < l.Len(); i++ {
+				if !walk(l.Get(i).Message()) {
+					return false
+				}
+			}
+		case fd.IsMap() && fd.MapValue().Message() != nil:
+			v.Map().Range(func(_ protoreflect.MapKey, v protoreflect.Value) bool {
+				return walk(v.Message())
+			})
+		case fd.Message() != nil && !fd.IsMap():
+			return walk(v.Message())
+		}
+		return err == nil
+	})
+	return changed, err
+}
+
+// isXDSConfigSource returns true if cs fetches resources from the xDS
+// cluster over its own gRPC stream.
+func isXDSConfigSource(cs *envoy_config_core_v3.ConfigSource) bool {
+	api := cs.GetApiConfigSource()
+	if api.GetApiType() != envoy_config_core_v3.ApiConfigSource_GRPC {
+		return false
+	}
+	for _, svc := range api.GetGrpcServices() {
+		if svc.GetEnvoyGrpc().GetClusterName() == xdsCluster {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,377 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright(c) 2018-2023 Saaras Inc.
+
+package grpc
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	envoy_config_cluster_v3 "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
+	envoy_config_core_v3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	envoy_config_endpoint_v3 "github.com/envoyproxy/go-control-plane/envoy/config/endpoint/v3"
+	envoy_config_listener_v3 "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
+	envoy_config_route_v3 "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	envoy_extensions_filters_network_http_connection_manager_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+	envoy_service_discovery_v3 "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	resource "github.com/envoyproxy/go-control-plane/pkg/resource/v3"
+	"github.com/golang/protobuf/proto"
+	"github.com/google/go-cmp/cmp"
+	"github.com/saarasio/enroute/enroute-dp/internal/envoy"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/genproto/googleapis/rpc/status"
+	protov2 "google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/testing/protocmp"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+type mockADSStream struct {
+	ctx  context.Context
+	reqs chan *envoy_service_discovery_v3.DiscoveryRequest
+	resp chan *envoy_service_discovery_v3.DiscoveryResponse
+}
+
+func (m *mockADSStream) Context() context.Context { return m.ctx }
+func (m *mockADSStream) Send(resp *envoy_service_discovery_v3.DiscoveryResponse) error {
+	m.resp <- resp
+	return nil
+}
+func (m *mockADSStream) Recv() (*envoy_service_discovery_v3.DiscoveryRequest, error) {
+	req, ok := <-m.reqs
+	if !ok {
+		return nil, io.EOF
+	}
+	return req, nil
+}
+
+func startADS(t *testing.T, rs ...Resource) (*mockADSStream, chan error) {
+	t.Helper()
+	log := logrus.New()
+	log.SetOutput(ioutil.Discard)
+	xh := &xdsHandler{
+		FieldLogger: log,
+		resources:   make(map[string]Resource),
+	}
+	for _, r := range rs {
+		xh.resources[r.TypeURL()] = r
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	st := &mockADSStream{
+		ctx:  ctx,
+		reqs: make(chan *envoy_service_discovery_v3.DiscoveryRequest),
+		resp: make(chan *envoy_service_discovery_v3.DiscoveryResponse, 10),
+	}
+	done := make(chan error, 1)
+	go func() { done <- xh.streamAggregated(st) }()
+	return st, done
+}
+
+// request sends a request for typeURL acknowledging the response with nonce.
+func (m *mockADSStream) request(typeURL, nonce string, names ...string) {
+	m.reqs <- &envoy_service_discovery_v3.DiscoveryRequest{
+		TypeUrl:       typeURL,
+		ResponseNonce: nonce,
+		ResourceNames: names,
+	}
+}
+
+// flush returns once all previous requests have been processed.
+func (m *mockADSStream) flush(typeURL string) {
+	m.request(typeURL, "stale")
+	m.request(typeURL, "stale")
+}
+
+func (m *mockADSStream) expect(t *testing.T, typeURL string, names ...string) *envoy_service_discovery_v3.DiscoveryResponse {
+	t.Helper()
+	select {
+	case resp := <-m.resp:
+		if resp.TypeUrl != typeURL {
+			t.Fatalf("expected %s, got %s", typeURL, resp.TypeUrl)
+		}
+		var got []string
+		for _, a := range resp.Resources {
+			m, err := a.UnmarshalNew()
+			if err != nil {
+				t.Fatal(err)
+			}
+			got = append(got, resourceName(proto.MessageV1(m)))
+		}
+		if diff := cmp.Diff(names, got); diff != "" {
+			t.Fatal(diff)
+		}
+		return resp
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timeout waiting for %s %v", typeURL, names)
+		return nil
+	}
+}
+
+func (m *mockADSStream) expectNothing(t *testing.T) {
+	t.Helper()
+	select {
+	case resp := <-m.resp:
+		t.Fatalf("unexpected response: %v", resp)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func listener(name string, port uint32) *envoy_config_listener_v3.Listener {
+	return &envoy_config_listener_v3.Listener{
+		Name:    name,
+		Address: envoy.SocketAddress("0.0.0.0", int(port)),
+	}
+}
+
+// routeConfiguration returns a route configuration sending everything to
+// cluster.
+func routeConfiguration(name, cluster string) *envoy_config_route_v3.RouteConfiguration {
+	return &envoy_config_route_v3.RouteConfiguration{
+		Name: name,
+		VirtualHosts: []*envoy_config_route_v3.VirtualHost{{
+			Name:    "*",
+			Domains: []string{"*"},
+			Routes: []*envoy_config_route_v3.Route{{
+				Match: &envoy_config_route_v3.RouteMatch{
+					PathSpecifier: &envoy_config_route_v3.RouteMatch_Prefix{Prefix: "/"},
+				},
+				Action: &envoy_config_route_v3.Route_Route{
+					Route: &envoy_config_route_v3.RouteAction{
+						ClusterSpecifier: &envoy_config_route_v3.RouteAction_Cluster{Cluster: cluster},
+					},
+				},
+			}},
+		}},
+	}
+}
+
+func TestStreamAggregatedMakeBeforeBreak(t *testing.T) {
+	clusters := &testResource{typeURL: resource.ClusterType}
+	clusters.set(cluster("a", 0))
+	listeners := &testResource{typeURL: resource.ListenerType}
+	listeners.set(listener("http", 8080))
+
+	st, done := startADS(t, clusters, listeners)
+	st.request(resource.ClusterType, "")
+	cds := st.expect(t, resource.ClusterType, "a")
+	st.request(resource.ListenerType, "")
+	lds := st.expect(t, resource.ListenerType, "http")
+	st.request(resource.ClusterType, cds.Nonce)
+	st.request(resource.ListenerType, lds.Nonce)
+	st.flush(resource.ListenerType)
+
+	// the removed cluster is kept while the listeners are updated
+	clusters.set(cluster("b", 0))
+	cds = st.expect(t, resource.ClusterType, "b", "a")
+	listeners.set(listener("http", 8081))
+	lds = st.expect(t, resource.ListenerType, "http")
+	st.request(resource.ListenerType, lds.Nonce)
+	st.expectNothing(t)
+
+	// and removed once the client has the new clusters
+	st.request(resource.ClusterType, cds.Nonce)
+	st.expect(t, resource.ClusterType, "b")
+
+	close(st.reqs)
+	if err := <-done; err != io.EOF {
+		t.Fatalf("expected EOF, got %v", err)
+	}
+}
+
+func TestStreamAggregatedOrder(t *testing.T) {
+	clusters := &testResource{typeURL: resource.ClusterType}
+	clusters.set(cluster("a", 0))
+	listeners := &testResource{typeURL: resource.ListenerType}
+	listeners.set(listener("http", 8080))
+
+	st, _ := startADS(t, clusters, listeners)
+	st.request(resource.ClusterType, "")
+	cds := st.expect(t, resource.ClusterType, "a")
+	st.request(resource.ListenerType, "")
+	lds := st.expect(t, resource.ListenerType, "http")
+
+	// the client acknowledged the listeners but not yet the clusters
+	st.request(resource.ListenerType, lds.Nonce)
+	st.flush(resource.ListenerType)
+
+	clusters.set(cluster("b", 0))
+	// let the stream see the cluster change before the listener change
+	time.Sleep(100 * time.Millisecond)
+	listeners.set(listener("http", 8081))
+
+	// the listeners wait for the clusters they may refer to
+	st.expectNothing(t)
+	st.request(resource.ClusterType, cds.Nonce)
+	cds = st.expect(t, resource.ClusterType, "b", "a")
+	lds = st.expect(t, resource.ListenerType, "http")
+
+	st.request(resource.ClusterType, cds.Nonce)
+	st.request(resource.ListenerType, lds.Nonce)
+	st.expect(t, resource.ClusterType, "b")
+}
+
+func TestStreamAggregatedBreakAfterRoutes(t *testing.T) {
+	clusters := &testResource{typeURL: resource.ClusterType}
+	clusters.set(cluster("a", 0))
+	routes := &testResource{typeURL: resource.RouteType}
+	routes.set(routeConfiguration("http", "a"))
+
+	st, _ := startADS(t, clusters, routes)
+	st.request(resource.ClusterType, "")
+	cds := st.expect(t, resource.ClusterType, "a")
+	st.request(resource.RouteType, "", "http")
+	rds := st.expect(t, resource.RouteType, "http")
+	st.request(resource.ClusterType, cds.Nonce)
+	st.request(resource.RouteType, rds.Nonce, "http")
+	st.flush(resource.RouteType)
+
+	clusters.set(cluster("b", 0))
+	cds = st.expect(t, resource.ClusterType, "b", "a")
+	routes.set(routeConfiguration("http", "b"))
+	rds = st.expect(t, resource.RouteType, "http")
+
+	// the routes may still refer to a until the client accepted them
+	st.request(resource.ClusterType, cds.Nonce)
+	st.expectNothing(t)
+
+	// a rejected update leaves the old routes in place
+	st.reqs <- &envoy_service_discovery_v3.DiscoveryRequest{
+		TypeUrl:       resource.RouteType,
+		ResponseNonce: rds.Nonce,
+		ResourceNames: []string{"http"},
+		ErrorDetail:   &status.Status{Message: "rejected"},
+	}
+	st.expectNothing(t)
+
+	// until the routes are sent again and accepted
+	routes.set(routeConfiguration("http", "b"))
+	rds = st.expect(t, resource.RouteType, "http")
+	st.request(resource.RouteType, rds.Nonce, "http")
+	st.expect(t, resource.ClusterType, "b")
+}
+
+func TestStreamAggregatedResourceNames(t *testing.T) {
+	endpoints := &testResource{typeURL: resource.EndpointType}
+	endpoints.set(
+		&envoy_config_endpoint_v3.ClusterLoadAssignment{ClusterName: "default/a"},
+		&envoy_config_endpoint_v3.ClusterLoadAssignment{ClusterName: "default/b"},
+	)
+
+	st, _ := startADS(t, endpoints)
+	st.request(resource.EndpointType, "", "default/a")
+	resp := st.expect(t, resource.EndpointType, "default/a")
+
+	// a new cluster is requested without waiting for a change
+	st.request(resource.EndpointType, resp.Nonce, "default/a", "default/b")
+	resp = st.expect(t, resource.EndpointType, "default/a", "default/b")
+
+	// an ACK with the same names waits
+	st.request(resource.EndpointType, resp.Nonce, "default/b", "default/a")
+	st.expectNothing(t)
+}
+
+func TestStreamAggregatedUnknownType(t *testing.T) {
+	st, done := startADS(t)
+	st.request(resource.ClusterType, "")
+	if err := <-done; err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestDeltaStreamAggregated(t *testing.T) {
+	clusters := &testResource{typeURL: resource.ClusterType}
+	clusters.set(cluster("a", 0))
+	listeners := &testResource{typeURL: resource.ListenerType}
+	listeners.set(listener("http", 8080))
+
+	st, _ := startDelta(t, true, clusters, listeners)
+	st.request(&envoy_service_discovery_v3.DeltaDiscoveryRequest{TypeUrl: resource.ClusterType})
+	st.expect(t, delta{Names: []string{"a"}})
+	st.request(&envoy_service_discovery_v3.DeltaDiscoveryRequest{TypeUrl: resource.ListenerType})
+	st.expect(t, delta{Names: []string{"http"}})
+
+	// the removal of a is only sent once the client acknowledged b
+	// and the listeners that may have referred to a
+	clusters.set(cluster("b", 0))
+	cds := st.expect(t, delta{Names: []string{"b"}})
+	listeners.set(listener("http", 8081))
+	lds := st.expect(t, delta{Names: []string{"http"}})
+	st.expectNothing(t)
+
+	st.request(&envoy_service_discovery_v3.DeltaDiscoveryRequest{TypeUrl: resource.ClusterType, ResponseNonce: cds.Nonce})
+	st.expectNothing(t)
+
+	st.request(&envoy_service_discovery_v3.DeltaDiscoveryRequest{TypeUrl: resource.ListenerType, ResponseNonce: lds.Nonce})
+	resp := st.expect(t, delta{Removed: []string{"a"}})
+	if resp.TypeUrl != resource.ClusterType {
+		t.Fatalf("expected %s, got %s", resource.ClusterType, resp.TypeUrl)
+	}
+}
+
+func TestADSResources(t *testing.T) {
+	hcm, err := anypb.New(&envoy_extensions_filters_network_http_connection_manager_v3.HttpConnectionManager{
+		StatPrefix: "http",
+		RouteSpecifier: &envoy_extensions_filters_network_http_connection_manager_v3.HttpConnectionManager_Rds{
+			Rds: &envoy_extensions_filters_network_http_connection_manager_v3.Rds{
+				RouteConfigName: "http",
+				ConfigSource:    envoy.ConfigSource("enroute"),
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := &envoy_config_listener_v3.Listener{
+		Name: "http",
+		FilterChains: []*envoy_config_listener_v3.FilterChain{{
+			Filters: []*envoy_config_listener_v3.Filter{{
+				Name:       "envoy.filters.network.http_connection_manager",
+				ConfigType: &envoy_config_listener_v3.Filter_TypedConfig{TypedConfig: hcm},
+			}},
+		}},
+	}
+	eds := &envoy_config_cluster_v3.Cluster{
+		Name: "default/a/80",
+		EdsClusterConfig: &envoy_config_cluster_v3.Cluster_EdsClusterConfig{
+			EdsConfig:   envoy.ConfigSource("enroute"),
+			ServiceName: "default/a",
+		},
+	}
+	other := &envoy_config_cluster_v3.Cluster{
+		Name: "default/b/80",
+		EdsClusterConfig: &envoy_config_cluster_v3.Cluster_EdsClusterConfig{
+			EdsConfig: envoy.ConfigSource("other"),
+		},
+	}
+	before := protov2.Clone(proto.MessageV2(l))
+
+	got, err := adsResources([]proto.Message{l, eds, other})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if diff := cmp.Diff(before, proto.MessageV2(l), protocmp.Transform()); diff != "" {
+		t.Fatalf("input modified: %s", diff)
+	}
+
+	gotHCM := new(envoy_extensions_filters_network_http_connection_manager_v3.HttpConnectionManager)
+	if err := got[0].(*envoy_config_listener_v3.Listener).FilterChains[0].Filters[0].GetTypedConfig().UnmarshalTo(gotHCM); err != nil {
+		t.Fatal(err)
+	}
+	ads := envoy.ADSConfigSource()
+	if diff := cmp.Diff(ads, gotHCM.GetRds().ConfigSource, protocmp.Transform()); diff != "" {
+		t.Fatal(diff)
+	}
+	if diff := cmp.Diff(ads, got[1].(*envoy_config_cluster_v3.Cluster).EdsClusterConfig.EdsConfig, protocmp.Transform()); diff != "" {
+		t.Fatal(diff)
+	}
+	if got[2] != other {
+		t.Fatalf("expected config source of other cluster to be kept, got %v", got[2])
+	}
+	if _, ok := got[2].(*envoy_config_cluster_v3.Cluster).EdsClusterConfig.EdsConfig.ConfigSourceSpecifier.(*envoy_config_core_v3.ConfigSource_ApiConfigSource); !ok {
+		t.Fatal("expected api config source")
+	}
+}
@@ -0,0 +1,334 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright(c) 2018-2023 Saaras Inc.
+
+package grpc
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	envoy_config_core_v3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	envoy_data_accesslog_v3 "github.com/envoyproxy/go-control-plane/envoy/data/accesslog/v3"
+	envoy_service_accesslog_v3 "github.com/envoyproxy/go-control-plane/envoy/service/accesslog/v3"
+	"github.com/saarasio/enroute/enroute-dp/internal/dag"
+	"github.com/saarasio/enroute/enroute-dp/internal/metrics"
+	"github.com/sirupsen/logrus"
+)
+
+// accessLogQueueSize is the number of batches of records received from
+// Envoy queued for the sinks, batches received while it is full are
+// dropped.
+const accessLogQueueSize = 1024
+
+// AccessLogs receives the HTTP access log entries Envoy streams over the
+// Access Log Service, attributes them to the GatewayHost and route that
+// served the request and queues them for its sinks. The records are
+// written to the sinks by Start so a slow sink does not hold up Envoy.
+type AccessLogs struct {
+	Sinks []AccessLogSink
+
+	// FieldLogger, if set, logs the errors of the sinks.
+	logrus.FieldLogger
+
+	// Metrics, if set, counts the records dropped as the queue is full.
+	*metrics.Metrics
+
+	queue chan []AccessLogRecord
+
+	mu     sync.RWMutex
+	routes map[string][]accessLogRoute // routes of each fqdn, in the order Envoy matches them
+}
+
+// AccessLogRecord is an HTTP access log entry received from Envoy.
+type AccessLogRecord struct {
+	StartTime               time.Time `json:"start_time"`
+	Node                    string    `json:"node,omitempty"`
+	LogName                 string    `json:"log_name,omitempty"`
+	GatewayHost             string    `json:"gatewayhost,omitempty"`
+	Route                   string    `json:"route,omitempty"`
+	Authority               string    `json:"authority,omitempty"`
+	Method                  string    `json:"method,omitempty"`
+	Path                    string    `json:"path,omitempty"`
+	Protocol                string    `json:"protocol,omitempty"`
+	ResponseCode            uint32    `json:"response_code"`
+	ResponseCodeDetails     string    `json:"response_code_details,omitempty"`
+	DurationMs              int64     `json:"duration_ms"`
+	BytesReceived           uint64    `json:"bytes_received"`
+	BytesSent               uint64    `json:"bytes_sent"`
+	UpstreamCluster         string    `json:"upstream_cluster,omitempty"`
+	UpstreamHost            string    `json:"upstream_host,omitempty"`
+	DownstreamRemoteAddress string    `json:"downstream_remote_address,omitempty"`
+	UserAgent               string    `json:"user_agent,omitempty"`
+	RequestID               string    `json:"request_id,omitempty"`
+}
+
+// accessLogRoute is a route of a GatewayHost, requests to its fqdn whose
+// path matches its path condition are attributed to it.
+type accessLogRoute struct {
+	cond        dag.Condition
+	regex       *regexp.Regexp
+	gatewayHost string
+}
+
+func (r accessLogRoute) matches(path string) bool {
+	switch c := r.cond.(type) {
+	case *dag.ExactCondition:
+		return path == c.Path
+	case *dag.RegexCondition:
+		return r.regex.MatchString(path)
+	case *dag.PrefixCondition:
+		return strings.HasPrefix(path, c.Prefix)
+	default:
+		return false
+	}
+}
+
+// path returns the path, regex or prefix the route matches.
+func (r accessLogRoute) path() string {
+	switch c := r.cond.(type) {
+	case *dag.ExactCondition:
+		return c.Path
+	case *dag.RegexCondition:
+		return c.Regex
+	case *dag.PrefixCondition:
+		return c.Prefix
+	default:
+		return ""
+	}
+}
+
+// rank orders routes as Envoy matches them, exact paths first, then
+// regexes and prefixes.
+func (r accessLogRoute) rank() int {
+	switch r.cond.(type) {
+	case *dag.ExactCondition:
+		return 0
+	case *dag.RegexCondition:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// NewAccessLogs returns an AccessLogs writing to sinks.
+func NewAccessLogs(sinks ...AccessLogSink) *AccessLogs {
+	return &AccessLogs{
+		Sinks: sinks,
+		queue: make(chan []AccessLogRecord, accessLogQueueSize),
+	}
+}
+
+// SetRoutes replaces the routes entries are attributed to with those of
+// the valid GatewayHosts in statuses.
+func (al *AccessLogs) SetRoutes(statuses []dag.Status) {
+	routes := make(map[string][]accessLogRoute)
+	for _, st := range statuses {
+		if st.Status != dag.StatusValid || st.Object == nil || st.Vhost == "" {
+			continue
+		}
+		name := st.Object.Namespace + "/" + st.Object.Name
+		for _, route := range st.Object.Spec.Routes {
+			if len(route.Services) == 0 {
+				continue
+			}
+			r := accessLogRoute{cond: dag.MergePathConditions(route.Conditions), gatewayHost: name}
+			if c, ok := r.cond.(*dag.RegexCondition); ok {
+				// Envoy matches the whole path
+				re, err := regexp.Compile("^(?:" + c.Regex + ")$")
+				if err != nil {
+					continue
+				}
+				r.regex = re
+			}
+			host := strings.ToLower(st.Vhost)
+			routes[host] = append(routes[host], r)
+		}
+	}
+	for _, rs := range routes {
+		sort.Slice(rs, func(i, j int) bool {
+			if rs[i].rank() != rs[j].rank() {
+				return rs[i].rank() < rs[j].rank()
+			}
+			pi, pj := rs[i].path(), rs[j].path()
+			if len(pi) != len(pj) {
+				return len(pi) > len(pj)
+			}
+			if pi != pj {
+				return pi < pj
+			}
+			return rs[i].gatewayHost < rs[j].gatewayHost
+		})
+	}
+
+	al.mu.Lock()
+	defer al.mu.Unlock()
+	al.routes = routes
+}
+
+// route returns the route a request for path on authority is attributed
+// to, false if there is none.
+func (al *AccessLogs) route(authority, path string) (accessLogRoute, bool) {
+	host := strings.ToLower(authority)
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+
+	al.mu.RLock()
+	defer al.mu.RUnlock()
+	for _, r := range al.routes[host] {
+		if r.matches(path) {
+			return r, true
+		}
+	}
+	return accessLogRoute{}, false
+}
+
+// socketAddress returns address as host:port, empty if it is not a socket
+// address.
+func socketAddress(address *envoy_config_core_v3.Address) string {
+	sa := address.GetSocketAddress()
+	if sa == nil {
+		return ""
+	}
+	return net.JoinHostPort(sa.Address, fmt.Sprint(sa.GetPortValue()))
+}
+
+// record returns the record of entry, attributed to the route of the
+// GatewayHost that served it. The route Envoy reports, if routes are
+// named, is preferred to its path condition.
+func (al *AccessLogs) record(id *envoy_service_accesslog_v3.StreamAccessLogsMessage_Identifier, entry *envoy_data_accesslog_v3.HTTPAccessLogEntry) AccessLogRecord {
+	common := entry.GetCommonProperties()
+	req := entry.GetRequest()
+	resp := entry.GetResponse()
+
+	rec := AccessLogRecord{
+		Node:                    id.GetNode().GetId(),
+		LogName:                 id.GetLogName(),
+		Route:                   common.GetRouteName(),
+		Authority:               req.GetAuthority(),
+		Path:                    req.GetPath(),
+		ResponseCode:            resp.GetResponseCode().GetValue(),
+		ResponseCodeDetails:     resp.GetResponseCodeDetails(),
+		DurationMs:              common.GetTimeToLastDownstreamTxByte().AsDuration().Milliseconds(),
+		BytesReceived:           req.GetRequestHeadersBytes() + req.GetRequestBodyBytes(),
+		BytesSent:               resp.GetResponseHeadersBytes() + resp.GetResponseBodyBytes(),
+		UpstreamCluster:         common.GetUpstreamCluster(),
+		UpstreamHost:            socketAddress(common.GetUpstreamRemoteAddress()),
+		DownstreamRemoteAddress: socketAddress(common.GetDownstreamRemoteAddress()),
+		UserAgent:               req.GetUserAgent(),
+		RequestID:               req.GetRequestId(),
+	}
+	if common.GetStartTime() != nil {
+		rec.StartTime = common.GetStartTime().AsTime()
+	}
+	if m := req.GetRequestMethod(); m != envoy_config_core_v3.RequestMethod_METHOD_UNSPECIFIED {
+		rec.Method = m.String()
+	}
+	if p := entry.GetProtocolVersion(); p != envoy_data_accesslog_v3.HTTPAccessLogEntry_PROTOCOL_UNSPECIFIED {
+		rec.Protocol = p.String()
+	}
+
+	// match the path without its query string
+	path := rec.Path
+	if i := strings.IndexByte(path, '?'); i >= 0 {
+		path = path[:i]
+	}
+	if r, ok := al.route(rec.Authority, path); ok {
+		rec.GatewayHost = r.gatewayHost
+		if rec.Route == "" {
+			rec.Route = r.path()
+		}
+	}
+	return rec
+}
+
+// write writes records to every sink, returning the errors of the sinks
+// that failed.
+func (al *AccessLogs) write(records []AccessLogRecord) []error {
+	var errs []error
+	for _, s := range al.Sinks {
+		if err := s.WriteRecords(records); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+// enqueue queues records for the sinks, dropping them if the queue is
+// full.
+func (al *AccessLogs) enqueue(records []AccessLogRecord) {
+	select {
+	case al.queue <- records:
+	default:
+		if al.Metrics != nil {
+			al.Metrics.ObserveDroppedAccessLogRecords(len(records))
+		}
+	}
+}
+
+// Start writes the queued records to the sinks until stop is closed.
+func (al *AccessLogs) Start(stop <-chan struct{}) error {
+	for {
+		select {
+		case records := <-al.queue:
+			for _, err := range al.write(records) {
+				if al.FieldLogger != nil {
+					al.WithError(err).Error("failed to write access logs")
+				}
+			}
+		case <-stop:
+			return nil
+		}
+	}
+}
+
+type accessLogStream interface {
+	Context() context.Context
+	Recv() (*envoy_service_accesslog_v3.StreamAccessLogsMessage, error)
+}
+
+// streamAccessLogs processes a stream of access log entries. The first
+// message of the stream identifies the node and the log, it is not sent
+// again.
+func (xh *xdsHandler) streamAccessLogs(st accessLogStream) (err error) {
+	connection := xh.connections.next()
+	log := xh.WithField("connection", connection).WithField("protocol", "als")
+
+	defer func() {
+		if err != nil {
+			log.WithError(err).Error("stream terminated")
+		} else {
+			log.Info("stream terminated")
+		}
+	}()
+
+	var id *envoy_service_accesslog_v3.StreamAccessLogsMessage_Identifier
+	for {
+		msg, err := st.Recv()
+		if err != nil {
+			return err
+		}
+		if msg.Identifier != nil {
+			id = msg.Identifier
+			log = log.WithField("node", id.GetNode().GetId()).WithField("log_name", id.GetLogName())
+			log.Info("access log stream started")
+		}
+
+		entries := msg.GetHttpLogs().GetLogEntry()
+		if len(entries) == 0 {
+			// TCP access logs are not collected.
+			continue
+		}
+		records := make([]AccessLogRecord, 0, len(entries))
+		for _, e := range entries {
+			records = append(records, xh.als.record(id, e))
+		}
+		xh.als.enqueue(records)
+	}
+}
@@ -0,0 +1,198 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright(c) 2018-2023 Saaras Inc.
+
+package grpc
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// AccessLogSink writes the access log records received by AccessLogs.
+type AccessLogSink interface {
+	WriteRecords([]AccessLogRecord) error
+}
+
+// JSONSink writes access log records to a writer, one JSON object per
+// line.
+type JSONSink struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewJSONSink returns a JSONSink writing to w.
+func NewJSONSink(w io.Writer) *JSONSink {
+	return &JSONSink{w: w}
+}
+
+// WriteRecords writes records to the writer of s.
+func (s *JSONSink) WriteRecords(records []AccessLogRecord) error {
+	buf, err := encodeRecords(records)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err = s.w.Write(buf)
+	return err
+}
+
+func encodeRecords(records []AccessLogRecord) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, r := range records {
+		if err := enc.Encode(r); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// RotatingFileSink writes access log records to a file, one JSON object
+// per line. Once the file reaches MaxSize bytes it is renamed with the
+// suffix .1, older files are shifted to .2 and so on, up to MaxBackups.
+type RotatingFileSink struct {
+	Path       string
+	MaxSize    int64
+	MaxBackups int
+
+	mu   sync.Mutex
+	f    *os.File
+	size int64
+}
+
+// NewRotatingFileSink returns a RotatingFileSink appending to path.
+func NewRotatingFileSink(path string, maxSize int64, maxBackups int) (*RotatingFileSink, error) {
+	s := &RotatingFileSink{
+		Path:       path,
+		MaxSize:    maxSize,
+		MaxBackups: maxBackups,
+	}
+	if err := s.open(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *RotatingFileSink) open() error {
+	f, err := os.OpenFile(s.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	s.f = f
+	s.size = fi.Size()
+	return nil
+}
+
+// rotate closes the file, shifts the backups and opens a new file. If the
+// file cannot be shifted it is opened again, to be rotated on the next
+// write. s.mu must be held.
+func (s *RotatingFileSink) rotate() error {
+	err := s.f.Close()
+	s.f = nil
+	if err == nil {
+		err = s.shift()
+	}
+	if oerr := s.open(); err == nil {
+		err = oerr
+	}
+	return err
+}
+
+// shift renames the file and its backups, or removes the file if there
+// are no backups.
+func (s *RotatingFileSink) shift() error {
+	if s.MaxBackups <= 0 {
+		return os.Remove(s.Path)
+	}
+	for i := s.MaxBackups - 1; i > 0; i-- {
+		// older backups may not exist yet
+		os.Rename(fmt.Sprintf("%s.%d", s.Path, i), fmt.Sprintf("%s.%d", s.Path, i+1))
+	}
+	return os.Rename(s.Path, s.Path+".1")
+}
+
+// WriteRecords appends records to the file of s, rotating it first if
+// they would take it past MaxSize. A file that could not be opened is
+// opened again.
+func (s *RotatingFileSink) WriteRecords(records []AccessLogRecord) error {
+	buf, err := encodeRecords(records)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.f == nil {
+		if err := s.open(); err != nil {
+			return err
+		}
+	}
+	if s.MaxSize > 0 && s.size > 0 && s.size+int64(len(buf)) > s.MaxSize {
+		if err := s.rotate(); err != nil {
+			return err
+		}
+	}
+	n, err := s.f.Write(buf)
+	s.size += int64(n)
+	return err
+}
+
+// Close closes the file of s.
+func (s *RotatingFileSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.f == nil {
+		return nil
+	}
+	err := s.f.Close()
+	s.f = nil
+	return err
+}
+
+// defaultWebhookTimeout bounds the requests of a WebhookSink unless its
+// Client is set.
+const defaultWebhookTimeout = 10 * time.Second
+
+// WebhookSink posts access log records to a URL as a JSON array.
+type WebhookSink struct {
+	URL string
+
+	// Client sends the requests, if not set a client with a
+	// 10 second timeout is used.
+	Client *http.Client
+}
+
+// WriteRecords posts records to the URL of s.
+func (s *WebhookSink) WriteRecords(records []AccessLogRecord) error {
+	buf, err := json.Marshal(records)
+	if err != nil {
+		return err
+	}
+	client := s.Client
+	if client == nil {
+		client = &http.Client{Timeout: defaultWebhookTimeout}
+	}
+	resp, err := client.Post(s.URL, "application/json", bytes.NewReader(buf))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook %s: unexpected status %s", s.URL, resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,409 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright(c) 2018-2023 Saaras Inc.
+
+package grpc
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	envoy_config_core_v3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	envoy_data_accesslog_v3 "github.com/envoyproxy/go-control-plane/envoy/data/accesslog/v3"
+	envoy_service_accesslog_v3 "github.com/envoyproxy/go-control-plane/envoy/service/accesslog/v3"
+	"github.com/golang/protobuf/ptypes/wrappers"
+	"github.com/google/go-cmp/cmp"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	gatewayhostv1 "github.com/saarasio/enroute/enroute-dp/apis/enroute/v1"
+	"github.com/saarasio/enroute/enroute-dp/internal/dag"
+	"github.com/saarasio/enroute/enroute-dp/internal/metrics"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func gatewayHostStatus(name, status, vhost string, prefixes ...string) dag.Status {
+	gh := &gatewayhostv1.GatewayHost{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+	}
+	for _, p := range prefixes {
+		gh.Spec.Routes = append(gh.Spec.Routes, gatewayhostv1.Route{
+			Conditions: []gatewayhostv1.Condition{{Prefix: p}},
+			Services:   []gatewayhostv1.Service{{Name: name, Port: 80}},
+		})
+	}
+	return dag.Status{Object: gh, Status: status, Vhost: vhost}
+}
+
+func gatewayHostConditionStatus(name, vhost string, cond gatewayhostv1.Condition) dag.Status {
+	st := gatewayHostStatus(name, dag.StatusValid, vhost)
+	st.Object.Spec.Routes = []gatewayhostv1.Route{{
+		Conditions: []gatewayhostv1.Condition{cond},
+		Services:   []gatewayhostv1.Service{{Name: name, Port: 80}},
+	}}
+	return st
+}
+
+func httpLogEntry(authority, path string, code uint32) *envoy_data_accesslog_v3.HTTPAccessLogEntry {
+	return &envoy_data_accesslog_v3.HTTPAccessLogEntry{
+		CommonProperties: &envoy_data_accesslog_v3.AccessLogCommon{
+			StartTime:                  timestamppb.New(time.Date(2023, 5, 1, 10, 0, 0, 0, time.UTC)),
+			TimeToLastDownstreamTxByte: durationpb.New(25 * time.Millisecond),
+			UpstreamCluster:            "default/httpbin/80/da39a3ee5e",
+			UpstreamRemoteAddress: &envoy_config_core_v3.Address{
+				Address: &envoy_config_core_v3.Address_SocketAddress{
+					SocketAddress: &envoy_config_core_v3.SocketAddress{
+						Address:       "10.0.0.5",
+						PortSpecifier: &envoy_config_core_v3.SocketAddress_PortValue{PortValue: 8080},
+					},
+				},
+			},
+		},
+		ProtocolVersion: envoy_data_accesslog_v3.HTTPAccessLogEntry_HTTP11,
+		Request: &envoy_data_accesslog_v3.HTTPRequestProperties{
+			RequestMethod:       envoy_config_core_v3.RequestMethod_GET,
+			Authority:           authority,
+			Path:                path,
+			RequestHeadersBytes: 100,
+		},
+		Response: &envoy_data_accesslog_v3.HTTPResponseProperties{
+			ResponseCode:         &wrappers.UInt32Value{Value: code},
+			ResponseHeadersBytes: 50,
+			ResponseBodyBytes:    200,
+		},
+	}
+}
+
+func accessLogRecord(gatewayHost, route, authority, path string, code uint32) AccessLogRecord {
+	return AccessLogRecord{
+		StartTime:       time.Date(2023, 5, 1, 10, 0, 0, 0, time.UTC),
+		Node:            "envoy-1",
+		LogName:         "enroute",
+		GatewayHost:     gatewayHost,
+		Route:           route,
+		Authority:       authority,
+		Method:          "GET",
+		Path:            path,
+		Protocol:        "HTTP11",
+		ResponseCode:    code,
+		DurationMs:      25,
+		BytesReceived:   100,
+		BytesSent:       250,
+		UpstreamCluster: "default/httpbin/80/da39a3ee5e",
+		UpstreamHost:    "10.0.0.5:8080",
+	}
+}
+
+func TestAccessLogRecord(t *testing.T) {
+	al := NewAccessLogs()
+	al.SetRoutes([]dag.Status{
+		gatewayHostStatus("httpbin", dag.StatusValid, "www.example.com", "/", "/api"),
+		gatewayHostStatus("blog", dag.StatusValid, "www.example.com", "/blog"),
+		gatewayHostStatus("search", dag.StatusValid, "www.example.com", "/search/[0-9]+"),
+		gatewayHostConditionStatus("health", "www.example.com", gatewayhostv1.Condition{Exact: "/healthz"}),
+		gatewayHostConditionStatus("items", "www.example.com", gatewayhostv1.Condition{Regex: "/items/[a-z]+"}),
+		gatewayHostStatus("broken", dag.StatusInvalid, "broken.example.com", "/"),
+	})
+	id := &envoy_service_accesslog_v3.StreamAccessLogsMessage_Identifier{
+		Node:    &envoy_config_core_v3.Node{Id: "envoy-1"},
+		LogName: "enroute",
+	}
+
+	tests := map[string]struct {
+		entry *envoy_data_accesslog_v3.HTTPAccessLogEntry
+		want  AccessLogRecord
+	}{
+		"root route": {
+			entry: httpLogEntry("www.example.com", "/index.html", 200),
+			want:  accessLogRecord("default/httpbin", "/", "www.example.com", "/index.html", 200),
+		},
+		"longest prefix with port and query": {
+			entry: httpLogEntry("WWW.example.com:8080", "/api/v1?q=/blog", 404),
+			want:  accessLogRecord("default/httpbin", "/api", "WWW.example.com:8080", "/api/v1?q=/blog", 404),
+		},
+		"delegated route": {
+			entry: httpLogEntry("www.example.com", "/blog/2023", 200),
+			want:  accessLogRecord("default/blog", "/blog", "www.example.com", "/blog/2023", 200),
+		},
+		"regex route": {
+			entry: httpLogEntry("www.example.com", "/search/42", 200),
+			want:  accessLogRecord("default/search", "/search/[0-9]+", "www.example.com", "/search/42", 200),
+		},
+		"exact route": {
+			entry: httpLogEntry("www.example.com", "/healthz?verbose=1", 200),
+			want:  accessLogRecord("default/health", "/healthz", "www.example.com", "/healthz?verbose=1", 200),
+		},
+		"path under an exact route": {
+			entry: httpLogEntry("www.example.com", "/healthz/live", 200),
+			want:  accessLogRecord("default/httpbin", "/", "www.example.com", "/healthz/live", 200),
+		},
+		"regex condition": {
+			entry: httpLogEntry("www.example.com", "/items/abc", 200),
+			want:  accessLogRecord("default/items", "/items/[a-z]+", "www.example.com", "/items/abc", 200),
+		},
+		"path partly matching a regex condition": {
+			entry: httpLogEntry("www.example.com", "/items/abc/42", 200),
+			want:  accessLogRecord("default/httpbin", "/", "www.example.com", "/items/abc/42", 200),
+		},
+		"invalid gatewayhost": {
+			entry: httpLogEntry("broken.example.com", "/", 503),
+			want:  accessLogRecord("", "", "broken.example.com", "/", 503),
+		},
+		"route named by envoy": {
+			entry: func() *envoy_data_accesslog_v3.HTTPAccessLogEntry {
+				e := httpLogEntry("www.example.com", "/", 200)
+				e.CommonProperties.RouteName = "home"
+				return e
+			}(),
+			want: accessLogRecord("default/httpbin", "home", "www.example.com", "/", 200),
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := al.record(id, tc.entry)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Fatal(diff)
+			}
+		})
+	}
+}
+
+type recordingSink struct {
+	records chan []AccessLogRecord
+}
+
+func (s *recordingSink) WriteRecords(records []AccessLogRecord) error {
+	s.records <- records
+	return nil
+}
+
+type mockAccessLogStream struct {
+	ctx  context.Context
+	msgs chan *envoy_service_accesslog_v3.StreamAccessLogsMessage
+}
+
+func (m *mockAccessLogStream) Context() context.Context { return m.ctx }
+func (m *mockAccessLogStream) Recv() (*envoy_service_accesslog_v3.StreamAccessLogsMessage, error) {
+	msg, ok := <-m.msgs
+	if !ok {
+		return nil, io.EOF
+	}
+	return msg, nil
+}
+
+func httpLogs(entries ...*envoy_data_accesslog_v3.HTTPAccessLogEntry) *envoy_service_accesslog_v3.StreamAccessLogsMessage_HttpLogs {
+	return &envoy_service_accesslog_v3.StreamAccessLogsMessage_HttpLogs{
+		HttpLogs: &envoy_service_accesslog_v3.StreamAccessLogsMessage_HTTPAccessLogEntries{
+			LogEntry: entries,
+		},
+	}
+}
+
+func TestStreamAccessLogs(t *testing.T) {
+	log := logrus.New()
+	log.SetOutput(ioutil.Discard)
+	sink := &recordingSink{records: make(chan []AccessLogRecord, 2)}
+	al := NewAccessLogs(sink)
+	al.SetRoutes([]dag.Status{
+		gatewayHostStatus("httpbin", dag.StatusValid, "www.example.com", "/"),
+	})
+	xh := &xdsHandler{
+		FieldLogger: log,
+		als:         al,
+	}
+	st := &mockAccessLogStream{
+		ctx:  context.Background(),
+		msgs: make(chan *envoy_service_accesslog_v3.StreamAccessLogsMessage, 2),
+	}
+	stop := make(chan struct{})
+	defer close(stop)
+	go al.Start(stop)
+
+	// only the first message identifies the node.
+	st.msgs <- &envoy_service_accesslog_v3.StreamAccessLogsMessage{
+		Identifier: &envoy_service_accesslog_v3.StreamAccessLogsMessage_Identifier{
+			Node:    &envoy_config_core_v3.Node{Id: "envoy-1"},
+			LogName: "enroute",
+		},
+		LogEntries: httpLogs(httpLogEntry("www.example.com", "/", 200)),
+	}
+	st.msgs <- &envoy_service_accesslog_v3.StreamAccessLogsMessage{
+		LogEntries: httpLogs(httpLogEntry("www.example.com", "/status", 500)),
+	}
+	close(st.msgs)
+	if err := xh.streamAccessLogs(st); err != io.EOF {
+		t.Fatalf("expected %v, got %v", io.EOF, err)
+	}
+
+	want := [][]AccessLogRecord{
+		{accessLogRecord("default/httpbin", "/", "www.example.com", "/", 200)},
+		{accessLogRecord("default/httpbin", "/", "www.example.com", "/status", 500)},
+	}
+	for _, w := range want {
+		if diff := cmp.Diff(w, <-sink.records); diff != "" {
+			t.Fatal(diff)
+		}
+	}
+}
+
+func TestAccessLogsQueueFull(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	al := NewAccessLogs(&recordingSink{records: make(chan []AccessLogRecord)})
+	al.Metrics = metrics.NewMetrics(registry)
+
+	record := accessLogRecord("default/httpbin", "/", "www.example.com", "/", 200)
+	// the queue is not drained as Start is not running.
+	for i := 0; i < accessLogQueueSize; i++ {
+		al.enqueue([]AccessLogRecord{record})
+	}
+	al.enqueue([]AccessLogRecord{record, record})
+
+	expected := `
+# HELP enroute_als_dropped_records_total Total number of access log records dropped as the queue of the sinks was full
+# TYPE enroute_als_dropped_records_total counter
+enroute_als_dropped_records_total 2
+`
+	if err := testutil.GatherAndCompare(registry, strings.NewReader(expected), metrics.ALSDroppedRecordsTotal); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRotatingFileSink(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "access.log")
+	record := accessLogRecord("default/httpbin", "/", "www.example.com", "/", 200)
+	line, err := json.Marshal(record)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// room for two records per file
+	s, err := NewRotatingFileSink(path, int64(2*(len(line)+1)), 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 7; i++ {
+		if err := s.WriteRecords([]AccessLogRecord{record}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := func(name string) int {
+		buf, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return strings.Count(string(buf), "\n")
+	}
+	got := map[string]int{}
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range files {
+		got[f.Name()] = lines(f.Name())
+	}
+	want := map[string]int{
+		"access.log":   1,
+		"access.log.1": 2,
+		"access.log.2": 2,
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Fatal(diff)
+	}
+}
+
+func TestRotatingFileSinkRotateError(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "access.log")
+	record := accessLogRecord("default/httpbin", "/", "www.example.com", "/", 200)
+	line, err := json.Marshal(record)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := NewRotatingFileSink(path, int64(2*(len(line)+1)), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	write := func() error {
+		return s.WriteRecords([]AccessLogRecord{record})
+	}
+	for i := 0; i < 2; i++ {
+		if err := write(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the file cannot be renamed over a directory
+	backup := path + ".1"
+	if err := os.MkdirAll(filepath.Join(backup, "dir"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := write(); err == nil {
+		t.Fatal("expected rotation to fail")
+	}
+
+	// the next write rotates the file once it can be renamed
+	if err := os.RemoveAll(backup); err != nil {
+		t.Fatal(err)
+	}
+	if err := write(); err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range map[string]int{"access.log": 1, "access.log.1": 2} {
+		buf, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := strings.Count(string(buf), "\n"); got != want {
+			t.Fatalf("%s: expected %d records, got %d", name, want, got)
+		}
+	}
+}
+
+func TestWebhookSink(t *testing.T) {
+	record := accessLogRecord("default/httpbin", "/", "www.example.com", "/", 200)
+
+	received := make(chan []AccessLogRecord, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/fail" {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		var records []AccessLogRecord
+		if err := json.NewDecoder(r.Body).Decode(&records); err != nil {
+			t.Error(err)
+		}
+		received <- records
+	}))
+	defer srv.Close()
+
+	s := &WebhookSink{URL: srv.URL + "/logs"}
+	if err := s.WriteRecords([]AccessLogRecord{record}); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]AccessLogRecord{record}, <-received); diff != "" {
+		t.Fatal(diff)
+	}
+
+	s = &WebhookSink{URL: srv.URL + "/fail"}
+	if err := s.WriteRecords([]AccessLogRecord{record}); err == nil {
+		t.Fatal("expected an error for a 503 response")
+	}
+}
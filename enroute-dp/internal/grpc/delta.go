@@ -0,0 +1,356 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright(c) 2018-2023 Saaras Inc.
+
+package grpc
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+
+	envoy_config_cluster_v3 "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
+	envoy_config_endpoint_v3 "github.com/envoyproxy/go-control-plane/envoy/config/endpoint/v3"
+	envoy_config_listener_v3 "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
+	envoy_config_route_v3 "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	envoy_extensions_transport_sockets_tls_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/transport_sockets/tls/v3"
+	envoy_service_discovery_v3 "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	resource "github.com/envoyproxy/go-control-plane/pkg/resource/v3"
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes/any"
+	"github.com/saarasio/enroute/enroute-dp/internal/protobuf"
+)
+
+// wildcard is the resource name subscribing to all resources of a type.
+const wildcard = "*"
+
+type deltaGrpcStream interface {
+	Context() context.Context
+	Send(*envoy_service_discovery_v3.DeltaDiscoveryResponse) error
+	Recv() (*envoy_service_discovery_v3.DeltaDiscoveryRequest, error)
+}
+
+// deltaState is the state of the resources of one type on a delta stream.
+type deltaState struct {
+	typeURL string
+
+	// wildcard is true if the client subscribed to all resources.
+	wildcard bool
+
+	// subscribed holds the names the client subscribed to explicitly.
+	subscribed map[string]bool
+
+	// known maps the name of each resource the client has to its version.
+	known map[string]string
+
+	// responded is true once a response has been sent on this stream.
+	responded bool
+
+	// aggregated is true if the resources are sent on an aggregated
+	// stream.
+	aggregated bool
+
+	// draining is true if resources removed were not yet reported.
+	draining bool
+}
+
+func newDeltaState(typeURL string) *deltaState {
+	return &deltaState{
+		typeURL:    typeURL,
+		subscribed: make(map[string]bool),
+		known:      make(map[string]string),
+	}
+}
+
+// apply updates the subscriptions with a request from the client. The
+// first request of a stream without any subscription subscribes to all
+// resources, as do requests subscribing to "*".
+func (ds *deltaState) apply(req *envoy_service_discovery_v3.DeltaDiscoveryRequest, first bool) {
+	if first {
+		ds.wildcard = len(req.ResourceNamesSubscribe) == 0
+		for name, version := range req.InitialResourceVersions {
+			ds.known[name] = version
+		}
+	}
+	for _, name := range req.ResourceNamesSubscribe {
+		if name == wildcard {
+			ds.wildcard = true
+			continue
+		}
+		if ds.subscribed[name] {
+			// a client subscribing again expects the resource to be
+			// sent even if it has not changed. Names subscribed for
+			// the first time keep the versions the client resumed
+			// with.
+			delete(ds.known, name)
+		}
+		ds.subscribed[name] = true
+	}
+	for _, name := range req.ResourceNamesUnsubscribe {
+		if name == wildcard {
+			ds.wildcard = false
+			continue
+		}
+		delete(ds.subscribed, name)
+		if !ds.wildcard {
+			// no need to tell the client a resource it unsubscribed
+			// from has been removed.
+			delete(ds.known, name)
+		}
+	}
+}
+
+// diff returns the resources of r that changed since they were last sent
+// and the names of the resources that were removed, and records them as
+// sent. If keep is true removed resources are not reported yet.
+func (ds *deltaState) diff(r Resource, keep bool) ([]*envoy_service_discovery_v3.Resource, []string, error) {
+	var values []proto.Message
+	if ds.wildcard {
+		values = r.Contents()
+	} else {
+		names := make([]string, 0, len(ds.subscribed))
+		for name := range ds.subscribed {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		values = r.Query(names)
+	}
+	if ds.aggregated {
+		var err error
+		if values, err = adsResources(values); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	var resources []*envoy_service_discovery_v3.Resource
+	current := make(map[string]bool)
+	for _, v := range values {
+		name := resourceName(v)
+		current[name] = true
+		b, err := protobuf.Marshal(v)
+		if err != nil {
+			return nil, nil, err
+		}
+		version := protobuf.Hash(b)
+		if ds.known[name] == version {
+			continue
+		}
+		ds.known[name] = version
+		resources = append(resources, &envoy_service_discovery_v3.Resource{
+			Name:     name,
+			Version:  version,
+			Resource: &any.Any{TypeUrl: r.TypeURL(), Value: b},
+		})
+	}
+
+	var removed []string
+	ds.draining = false
+	for name := range ds.known {
+		if current[name] {
+			continue
+		}
+		if keep {
+			ds.draining = true
+			continue
+		}
+		removed = append(removed, name)
+		delete(ds.known, name)
+	}
+	sort.Strings(removed)
+	return resources, removed, nil
+}
+
+// resourceName returns the name of an xDS resource.
+func resourceName(m proto.Message) string {
+	switch v := m.(type) {
+	case *envoy_config_cluster_v3.Cluster:
+		return v.Name
+	case *envoy_config_endpoint_v3.ClusterLoadAssignment:
+		return v.ClusterName
+	case *envoy_config_listener_v3.Listener:
+		return v.Name
+	case *envoy_config_route_v3.RouteConfiguration:
+		return v.Name
+	case *envoy_extensions_transport_sockets_tls_v3.Secret:
+		return v.Name
+	default:
+		return ""
+	}
+}
+
+// deltaType is the state of one resource type on a delta stream.
+type deltaType struct {
+	r     Resource
+	state *deltaState
+
+	// last is the version of the last change notified.
+	last int
+
+	// pending is true if the resources may have changed since the last
+	// response.
+	pending bool
+
+	// nonce is the nonce of the last response.
+	nonce string
+
+	// acked is true if the client acknowledged the last response.
+	acked bool
+}
+
+// deltaStream processes a stream of DeltaDiscoveryRequests. Unlike the
+// state of the world protocol, the client may change its subscriptions at
+// any time, so requests are received on their own goroutine. If aggregated
+// is false all requests must be for the same type, otherwise changes
+// notified together are sent in adsOrder.
+func (xh *xdsHandler) deltaStream(st deltaGrpcStream, aggregated bool) (err error) {
+	connection := xh.connections.next()
+	log := xh.WithField("connection", connection).WithField("protocol", "delta")
+	defer xh.acks.closed(connection)
+
+	defer func() {
+		if err != nil {
+			log.WithError(err).Error("stream terminated")
+		} else {
+			log.Info("stream terminated")
+		}
+	}()
+
+	ctx, cancel := context.WithCancel(st.Context())
+	defer cancel()
+
+	reqs := make(chan *envoy_service_discovery_v3.DeltaDiscoveryRequest)
+	errs := make(chan error, 1)
+	go func() {
+		for {
+			req, err := st.Recv()
+			if err != nil {
+				errs <- err
+				return
+			}
+			select {
+			case reqs <- req:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	var (
+		types  = make(map[string]*deltaType)
+		order  []string
+		notify = make(chan notification)
+		nonce  counter
+	)
+
+	respond := func(t *deltaType, keep bool) error {
+		t.pending = false
+		resources, removed, err := t.state.diff(t.r, keep)
+		if err != nil {
+			return err
+		}
+		if len(resources) == 0 && len(removed) == 0 && t.state.responded {
+			return nil
+		}
+		t.state.responded = true
+		resp := &envoy_service_discovery_v3.DeltaDiscoveryResponse{
+			SystemVersionInfo: strconv.Itoa(t.last),
+			Resources:         resources,
+			RemovedResources:  removed,
+			TypeUrl:           t.r.TypeURL(),
+			Nonce:             strconv.FormatUint(nonce.next(), 10),
+		}
+		if err := st.Send(resp); err != nil {
+			return err
+		}
+		xh.acks.deltaResponse(connection, resp)
+		t.nonce = resp.Nonce
+		t.acked = false
+		log.WithField("type_url", resp.TypeUrl).WithField("count", len(resources)).WithField("removed", len(removed)).Info("response")
+		return nil
+	}
+
+	flush := func() error {
+		for _, typeURL := range order {
+			if t := types[typeURL]; t.pending {
+				keep := aggregated && typeURL == resource.ClusterType
+				if err := respond(t, keep); err != nil {
+					return err
+				}
+			}
+		}
+		// once the client accepted everything that may have referred
+		// to removed resources they can be broken.
+		for _, typeURL := range order {
+			if t := types[typeURL]; !t.acked || t.pending {
+				return nil
+			}
+		}
+		for _, typeURL := range order {
+			if t := types[typeURL]; t.state.draining {
+				if err := respond(t, false); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+
+	for {
+		select {
+		case req := <-reqs:
+			xh.acks.deltaRequest(connection, req.GetNode().GetId(), req)
+			if req.ErrorDetail != nil {
+				log.WithField("type_url", req.TypeUrl).WithField("response_nonce", req.ResponseNonce).
+					WithField("error_detail", req.ErrorDetail.GetMessage()).Error("response rejected")
+			}
+
+			t, ok := types[req.TypeUrl]
+			if !ok {
+				if !aggregated && len(types) > 0 {
+					return fmt.Errorf("unexpected typeURL %q on %q stream", req.TypeUrl, order[0])
+				}
+				r, ok := xh.resources[req.TypeUrl]
+				if !ok {
+					return fmt.Errorf("no resource registered for typeURL %q", req.TypeUrl)
+				}
+				t = &deltaType{r: r, state: newDeltaState(req.TypeUrl), last: -1}
+				t.state.aggregated = aggregated
+				t.state.apply(req, true)
+				types[req.TypeUrl] = t
+				order = typeOrder(append(order, req.TypeUrl))
+				go watch(ctx, r, notify)
+				continue
+			}
+			if req.ResponseNonce == t.nonce && req.ErrorDetail == nil {
+				t.acked = true
+			}
+			t.state.apply(req, false)
+			if len(req.ResourceNamesSubscribe) > 0 {
+				// send newly subscribed resources now rather than
+				// waiting for the next change.
+				t.pending = true
+			}
+		case n := <-notify:
+			// pick up the changes notified at the same time so that
+			// they are sent in order.
+			for more := true; more; {
+				t := types[n.typeURL]
+				t.last = n.last
+				t.pending = true
+				select {
+				case n = <-notify:
+				default:
+					more = false
+				}
+			}
+		case err := <-errs:
+			return err
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+
+		if err := flush(); err != nil {
+			return err
+		}
+	}
+}
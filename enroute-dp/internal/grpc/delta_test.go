@@ -0,0 +1,272 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright(c) 2018-2023 Saaras Inc.
+
+package grpc
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	envoy_config_cluster_v3 "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
+	envoy_service_discovery_v3 "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	resource "github.com/envoyproxy/go-control-plane/pkg/resource/v3"
+	"github.com/golang/protobuf/proto"
+	"github.com/google/go-cmp/cmp"
+	"github.com/saarasio/enroute/enroute-dp/internal/contour"
+	"github.com/sirupsen/logrus"
+)
+
+// testResource is a Resource whose contents can be changed by the test.
+type testResource struct {
+	contour.Cond
+	typeURL string
+	mu      sync.Mutex
+	values  map[string]proto.Message
+}
+
+func (r *testResource) set(values ...proto.Message) {
+	r.mu.Lock()
+	r.values = make(map[string]proto.Message)
+	for _, v := range values {
+		r.values[resourceName(v)] = v
+	}
+	r.mu.Unlock()
+	r.Notify()
+}
+
+func (r *testResource) Contents() []proto.Message {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var names []string
+	for name := range r.values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var values []proto.Message
+	for _, name := range names {
+		values = append(values, r.values[name])
+	}
+	return values
+}
+
+func (r *testResource) Query(names []string) []proto.Message {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var values []proto.Message
+	for _, name := range names {
+		if v, ok := r.values[name]; ok {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+func (r *testResource) TypeURL() string { return r.typeURL }
+
+type mockDeltaStream struct {
+	ctx  context.Context
+	reqs chan *envoy_service_discovery_v3.DeltaDiscoveryRequest
+	resp chan *envoy_service_discovery_v3.DeltaDiscoveryResponse
+}
+
+func (m *mockDeltaStream) Context() context.Context { return m.ctx }
+func (m *mockDeltaStream) Send(resp *envoy_service_discovery_v3.DeltaDiscoveryResponse) error {
+	m.resp <- resp
+	return nil
+}
+func (m *mockDeltaStream) Recv() (*envoy_service_discovery_v3.DeltaDiscoveryRequest, error) {
+	req, ok := <-m.reqs
+	if !ok {
+		return nil, io.EOF
+	}
+	return req, nil
+}
+
+// delta is the content of a DeltaDiscoveryResponse that tests compare.
+type delta struct {
+	Names   []string
+	Removed []string
+}
+
+func startDeltaStream(t *testing.T, r Resource) (*mockDeltaStream, chan error) {
+	t.Helper()
+	return startDelta(t, false, r)
+}
+
+func startDelta(t *testing.T, aggregated bool, rs ...Resource) (*mockDeltaStream, chan error) {
+	t.Helper()
+	log := logrus.New()
+	log.SetOutput(ioutil.Discard)
+	xh := &xdsHandler{
+		FieldLogger: log,
+		resources:   make(map[string]Resource),
+	}
+	for _, r := range rs {
+		xh.resources[r.TypeURL()] = r
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	st := &mockDeltaStream{
+		ctx:  ctx,
+		reqs: make(chan *envoy_service_discovery_v3.DeltaDiscoveryRequest),
+		resp: make(chan *envoy_service_discovery_v3.DeltaDiscoveryResponse, 10),
+	}
+	done := make(chan error, 1)
+	go func() { done <- xh.deltaStream(st, aggregated) }()
+	return st, done
+}
+
+func (m *mockDeltaStream) request(req *envoy_service_discovery_v3.DeltaDiscoveryRequest) {
+	if req.TypeUrl == "" {
+		req.TypeUrl = resource.ClusterType
+	}
+	m.reqs <- req
+}
+
+func (m *mockDeltaStream) expect(t *testing.T, want delta) *envoy_service_discovery_v3.DeltaDiscoveryResponse {
+	t.Helper()
+	select {
+	case resp := <-m.resp:
+		got := delta{Removed: resp.RemovedResources}
+		for _, r := range resp.Resources {
+			got.Names = append(got.Names, r.Name)
+		}
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Fatal(diff)
+		}
+		return resp
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timeout waiting for %v", want)
+		return nil
+	}
+}
+
+func (m *mockDeltaStream) expectNothing(t *testing.T) {
+	t.Helper()
+	select {
+	case resp := <-m.resp:
+		t.Fatalf("unexpected response: %v", resp)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+// flush returns once all previous requests have been processed. The
+// stream forwards a request only after the previous one was taken, so
+// two further requests flush the first.
+func (m *mockDeltaStream) flush() {
+	m.request(&envoy_service_discovery_v3.DeltaDiscoveryRequest{})
+	m.request(&envoy_service_discovery_v3.DeltaDiscoveryRequest{})
+}
+
+// cluster returns a cluster whose content differs with lbPolicy.
+func cluster(name string, lbPolicy int32) *envoy_config_cluster_v3.Cluster {
+	return &envoy_config_cluster_v3.Cluster{
+		Name:     name,
+		LbPolicy: envoy_config_cluster_v3.Cluster_LbPolicy(lbPolicy),
+	}
+}
+
+func TestDeltaStreamWildcard(t *testing.T) {
+	r := &testResource{typeURL: resource.ClusterType}
+	r.set(cluster("a", 0), cluster("b", 0))
+
+	st, done := startDeltaStream(t, r)
+	st.request(&envoy_service_discovery_v3.DeltaDiscoveryRequest{})
+	resp := st.expect(t, delta{Names: []string{"a", "b"}})
+	st.request(&envoy_service_discovery_v3.DeltaDiscoveryRequest{ResponseNonce: resp.Nonce})
+	st.flush()
+
+	// only the changed and added clusters are sent
+	r.set(cluster("a", 0), cluster("b", 1), cluster("c", 0))
+	resp = st.expect(t, delta{Names: []string{"b", "c"}})
+	st.request(&envoy_service_discovery_v3.DeltaDiscoveryRequest{ResponseNonce: resp.Nonce})
+
+	// a no-op update sends nothing
+	r.set(cluster("a", 0), cluster("b", 1), cluster("c", 0))
+	st.expectNothing(t)
+
+	// removed clusters are named
+	r.set(cluster("b", 1))
+	st.expect(t, delta{Removed: []string{"a", "c"}})
+
+	close(st.reqs)
+	if err := <-done; err != io.EOF {
+		t.Fatalf("expected EOF, got %v", err)
+	}
+}
+
+func TestDeltaStreamSubscriptions(t *testing.T) {
+	r := &testResource{typeURL: resource.ClusterType}
+	r.set(cluster("a", 0), cluster("b", 0), cluster("c", 0))
+
+	st, _ := startDeltaStream(t, r)
+	st.request(&envoy_service_discovery_v3.DeltaDiscoveryRequest{ResourceNamesSubscribe: []string{"a"}})
+	st.expect(t, delta{Names: []string{"a"}})
+
+	// newly subscribed resources are sent immediately
+	st.request(&envoy_service_discovery_v3.DeltaDiscoveryRequest{ResourceNamesSubscribe: []string{"b"}})
+	st.expect(t, delta{Names: []string{"b"}})
+
+	// changes to unsubscribed resources are not sent
+	st.request(&envoy_service_discovery_v3.DeltaDiscoveryRequest{ResourceNamesUnsubscribe: []string{"a"}})
+	st.flush()
+	r.set(cluster("a", 1), cluster("b", 0), cluster("c", 1))
+	st.expectNothing(t)
+
+	r.set(cluster("a", 1), cluster("b", 1), cluster("c", 1))
+	st.expect(t, delta{Names: []string{"b"}})
+}
+
+func TestDeltaStreamInitialResourceVersions(t *testing.T) {
+	r := &testResource{typeURL: resource.ClusterType}
+	r.set(cluster("a", 0), cluster("b", 0))
+
+	// learn the version of a from a first stream
+	st, _ := startDeltaStream(t, r)
+	st.request(&envoy_service_discovery_v3.DeltaDiscoveryRequest{})
+	resp := st.expect(t, delta{Names: []string{"a", "b"}})
+	versions := make(map[string]string)
+	for _, res := range resp.Resources {
+		versions[res.Name] = res.Version
+	}
+	versions["gone"] = "1"
+
+	// a reconnecting client that already has a and b is only told
+	// about the cluster that no longer exists
+	st, _ = startDeltaStream(t, r)
+	st.request(&envoy_service_discovery_v3.DeltaDiscoveryRequest{InitialResourceVersions: versions})
+	st.expect(t, delta{Removed: []string{"gone"}})
+}
+
+func TestDeltaStreamInitialResourceVersionsSubscribed(t *testing.T) {
+	r := &testResource{typeURL: resource.ClusterType}
+	r.set(cluster("a", 0), cluster("b", 0))
+
+	st, _ := startDeltaStream(t, r)
+	st.request(&envoy_service_discovery_v3.DeltaDiscoveryRequest{ResourceNamesSubscribe: []string{"a", "b"}})
+	resp := st.expect(t, delta{Names: []string{"a", "b"}})
+	versions := make(map[string]string)
+	for _, res := range resp.Resources {
+		versions[res.Name] = res.Version
+	}
+
+	// a reconnecting client subscribing to the resources it already
+	// has is only sent the ones that changed
+	r.set(cluster("a", 0), cluster("b", 1))
+	st, _ = startDeltaStream(t, r)
+	st.request(&envoy_service_discovery_v3.DeltaDiscoveryRequest{
+		ResourceNamesSubscribe:  []string{"a", "b"},
+		InitialResourceVersions: versions,
+	})
+	resp = st.expect(t, delta{Names: []string{"b"}})
+
+	// subscribing again resends the resource
+	st.request(&envoy_service_discovery_v3.DeltaDiscoveryRequest{ResponseNonce: resp.Nonce})
+	st.request(&envoy_service_discovery_v3.DeltaDiscoveryRequest{ResourceNamesSubscribe: []string{"a"}})
+	st.expect(t, delta{Names: []string{"a"}})
+}
@@ -0,0 +1,382 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright(c) 2018-2023 Saaras Inc.
+
+package grpc
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	envoy_config_core_v3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	envoy_config_endpoint_v3 "github.com/envoyproxy/go-control-plane/envoy/config/endpoint/v3"
+	envoy_service_load_stats_v3 "github.com/envoyproxy/go-control-plane/envoy/service/load_stats/v3"
+	"github.com/saarasio/enroute/enroute-dp/internal/metrics"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// defaultLoadReportingInterval is how often Envoy reports load unless
+// LoadStats.Interval is set.
+const defaultLoadReportingInterval = 10 * time.Second
+
+// LoadStats aggregates the load Envoy reports over the Load Reporting
+// Service per cluster, locality and endpoint. Reports carry request counts
+// and the named load metrics upstreams attach to responses. The protocol
+// has no field for request latency, so latencies are only known when an
+// upstream reports them as a load metric; the latency of the reports
+// themselves is the interval each of them covers.
+type LoadStats struct {
+	// Metrics, if set, exposes the aggregated load.
+	*metrics.Metrics
+
+	// Interval is how often Envoy is asked to report load.
+	// Defaults to 10 seconds.
+	Interval time.Duration
+
+	mu       sync.Mutex
+	clusters map[string]*clusterLoad
+
+	// inProgress holds the requests in progress last reported on
+	// each connection.
+	inProgress map[uint64]map[localityKey]uint64
+}
+
+type clusterLoad struct {
+	dropped    uint64
+	interval   time.Duration
+	localities map[string]*LocalityLoad
+
+	// endpoints holds the load of the endpoints of each locality by
+	// address.
+	endpoints map[string]map[string]*EndpointLoad
+}
+
+type localityKey struct {
+	cluster, locality string
+}
+
+// ClusterLoad is the load reported for a cluster by all Envoys. The load
+// report interval is the one covered by the last report.
+type ClusterLoad struct {
+	Cluster                   string         `json:"cluster"`
+	DroppedRequests           uint64         `json:"dropped_requests"`
+	LoadReportIntervalSeconds float64        `json:"load_report_interval_seconds,omitempty"`
+	Localities                []LocalityLoad `json:"localities"`
+}
+
+// LocalityLoad is the load reported for the endpoints of a cluster in one
+// locality. Request counts are totals since enroute started, requests in
+// progress are the sum of the last report of each connected Envoy.
+type LocalityLoad struct {
+	Locality           string                `json:"locality,omitempty"`
+	IssuedRequests     uint64                `json:"issued_requests"`
+	SuccessfulRequests uint64                `json:"successful_requests"`
+	ErrorRequests      uint64                `json:"error_requests"`
+	RequestsInProgress uint64                `json:"requests_in_progress"`
+	LoadMetrics        map[string]LoadMetric `json:"load_metrics,omitempty"`
+	Endpoints          []EndpointLoad        `json:"endpoints,omitempty"`
+	LastReport         time.Time             `json:"last_report"`
+}
+
+// EndpointLoad is the load reported for one endpoint of a locality. Envoy
+// only reports endpoints if asked for endpoint granularity, their counts
+// are totals since enroute started.
+type EndpointLoad struct {
+	Address            string                `json:"address"`
+	IssuedRequests     uint64                `json:"issued_requests"`
+	SuccessfulRequests uint64                `json:"successful_requests"`
+	ErrorRequests      uint64                `json:"error_requests"`
+	LoadMetrics        map[string]LoadMetric `json:"load_metrics,omitempty"`
+}
+
+// LoadMetric is the total of a named load metric and the number of
+// requests that reported it.
+type LoadMetric struct {
+	Requests uint64  `json:"requests"`
+	Total    float64 `json:"total"`
+}
+
+// NewLoadStats returns a LoadStats with no load reported.
+func NewLoadStats() *LoadStats {
+	return &LoadStats{
+		clusters:   make(map[string]*clusterLoad),
+		inProgress: make(map[uint64]map[localityKey]uint64),
+	}
+}
+
+func (ls *LoadStats) interval() time.Duration {
+	if ls.Interval <= 0 {
+		return defaultLoadReportingInterval
+	}
+	return ls.Interval
+}
+
+// locality returns the name of l, region, zone and sub zone joined by
+// slashes.
+func locality(l *envoy_config_core_v3.Locality) string {
+	return strings.TrimRight(strings.Join([]string{l.GetRegion(), l.GetZone(), l.GetSubZone()}, "/"), "/")
+}
+
+func (ls *LoadStats) cluster(name string) *clusterLoad {
+	c, ok := ls.clusters[name]
+	if !ok {
+		c = &clusterLoad{
+			localities: make(map[string]*LocalityLoad),
+			endpoints:  make(map[string]map[string]*EndpointLoad),
+		}
+		ls.clusters[name] = c
+	}
+	return c
+}
+
+func (ls *LoadStats) locality(k localityKey) *LocalityLoad {
+	c := ls.cluster(k.cluster)
+	l, ok := c.localities[k.locality]
+	if !ok {
+		l = &LocalityLoad{Locality: k.locality}
+		c.localities[k.locality] = l
+	}
+	return l
+}
+
+func (ls *LoadStats) endpoint(k localityKey, address string) *EndpointLoad {
+	c := ls.cluster(k.cluster)
+	endpoints, ok := c.endpoints[k.locality]
+	if !ok {
+		endpoints = make(map[string]*EndpointLoad)
+		c.endpoints[k.locality] = endpoints
+	}
+	e, ok := endpoints[address]
+	if !ok {
+		e = &EndpointLoad{Address: address}
+		endpoints[address] = e
+	}
+	return e
+}
+
+// endpointAddress returns the address of an endpoint, host and port or the
+// path of a pipe.
+func endpointAddress(a *envoy_config_core_v3.Address) string {
+	if p := a.GetPipe(); p != nil {
+		return p.Path
+	}
+	sa := a.GetSocketAddress()
+	return net.JoinHostPort(sa.GetAddress(), strconv.FormatUint(uint64(sa.GetPortValue()), 10))
+}
+
+// addLoadMetrics adds stats to the load metrics in m, which is allocated
+// if nil.
+func addLoadMetrics(m map[string]LoadMetric, stats []*envoy_config_endpoint_v3.EndpointLoadMetricStats) map[string]LoadMetric {
+	for _, s := range stats {
+		if m == nil {
+			m = make(map[string]LoadMetric)
+		}
+		lm := m[s.MetricName]
+		lm.Requests += s.NumRequestsFinishedWithMetric
+		lm.Total += s.TotalMetricValue
+		m[s.MetricName] = lm
+	}
+	return m
+}
+
+// copyLoadMetrics returns a copy of m.
+func copyLoadMetrics(m map[string]LoadMetric) map[string]LoadMetric {
+	if m == nil {
+		return nil
+	}
+	c := make(map[string]LoadMetric, len(m))
+	for name, lm := range m {
+		c[name] = lm
+	}
+	return c
+}
+
+// report adds a load report received on connection. Apart from the
+// requests in progress, the counts of a report are those since the
+// previous report.
+func (ls *LoadStats) report(connection uint64, req *envoy_service_load_stats_v3.LoadStatsRequest) {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	now := time.Now()
+	inProgress := make(map[localityKey]uint64)
+	for _, cs := range req.ClusterStats {
+		for _, us := range cs.UpstreamLocalityStats {
+			k := localityKey{cluster: cs.ClusterName, locality: locality(us.Locality)}
+			l := ls.locality(k)
+			l.IssuedRequests += us.TotalIssuedRequests
+			l.SuccessfulRequests += us.TotalSuccessfulRequests
+			l.ErrorRequests += us.TotalErrorRequests
+			l.LastReport = now
+			inProgress[k] += us.TotalRequestsInProgress
+			if ls.Metrics != nil {
+				ls.Metrics.ObserveLoad(k.cluster, k.locality, us.TotalIssuedRequests, us.TotalSuccessfulRequests, us.TotalErrorRequests)
+			}
+
+			l.LoadMetrics = addLoadMetrics(l.LoadMetrics, us.LoadMetricStats)
+			if ls.Metrics != nil {
+				for _, m := range us.LoadMetricStats {
+					ls.Metrics.ObserveLoadMetric(k.cluster, k.locality, m.MetricName, m.NumRequestsFinishedWithMetric, m.TotalMetricValue)
+				}
+			}
+
+			// the locality counts include those of its endpoints,
+			// endpoints are only kept for /debug/lrs.
+			for _, es := range us.UpstreamEndpointStats {
+				e := ls.endpoint(k, endpointAddress(es.Address))
+				e.IssuedRequests += es.TotalIssuedRequests
+				e.SuccessfulRequests += es.TotalSuccessfulRequests
+				e.ErrorRequests += es.TotalErrorRequests
+				e.LoadMetrics = addLoadMetrics(e.LoadMetrics, es.LoadMetricStats)
+			}
+		}
+
+		if d := cs.LoadReportInterval; d != nil {
+			ls.cluster(cs.ClusterName).interval = d.AsDuration()
+			if ls.Metrics != nil {
+				ls.Metrics.SetLoadReportInterval(cs.ClusterName, d.AsDuration())
+			}
+		}
+
+		if cs.TotalDroppedRequests > 0 {
+			ls.cluster(cs.ClusterName).dropped += cs.TotalDroppedRequests
+			if ls.Metrics != nil {
+				ls.Metrics.ObserveDroppedRequests(cs.ClusterName, cs.TotalDroppedRequests)
+			}
+		}
+	}
+
+	// requests in progress are not a count since the last report, the
+	// last report of each connection replaces the previous one.
+	changed := ls.inProgress[connection]
+	ls.inProgress[connection] = inProgress
+	ls.updateInProgress(changed, inProgress)
+}
+
+// closed forgets the requests in progress last reported on connection.
+func (ls *LoadStats) closed(connection uint64) {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	prev := ls.inProgress[connection]
+	delete(ls.inProgress, connection)
+	ls.updateInProgress(prev, nil)
+}
+
+// updateInProgress recomputes the requests in progress of the localities
+// in prev and next. ls.mu must be held.
+func (ls *LoadStats) updateInProgress(prev, next map[localityKey]uint64) {
+	keys := make(map[localityKey]bool)
+	for k := range prev {
+		keys[k] = true
+	}
+	for k := range next {
+		keys[k] = true
+	}
+	for k := range keys {
+		var n uint64
+		for _, conn := range ls.inProgress {
+			n += conn[k]
+		}
+		ls.locality(k).RequestsInProgress = n
+		if ls.Metrics != nil {
+			ls.Metrics.SetRequestsInProgress(k.cluster, k.locality, n)
+		}
+	}
+}
+
+// Clusters returns the load reported for each cluster ordered by cluster
+// and locality.
+func (ls *LoadStats) Clusters() []ClusterLoad {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	var clusters []ClusterLoad
+	for name, c := range ls.clusters {
+		cl := ClusterLoad{
+			Cluster:                   name,
+			DroppedRequests:           c.dropped,
+			LoadReportIntervalSeconds: c.interval.Seconds(),
+		}
+		for _, l := range c.localities {
+			ll := *l
+			ll.LoadMetrics = copyLoadMetrics(l.LoadMetrics)
+			for _, e := range c.endpoints[l.Locality] {
+				ee := *e
+				ee.LoadMetrics = copyLoadMetrics(e.LoadMetrics)
+				ll.Endpoints = append(ll.Endpoints, ee)
+			}
+			sort.Slice(ll.Endpoints, func(i, j int) bool {
+				return ll.Endpoints[i].Address < ll.Endpoints[j].Address
+			})
+			cl.Localities = append(cl.Localities, ll)
+		}
+		sort.Slice(cl.Localities, func(i, j int) bool {
+			return cl.Localities[i].Locality < cl.Localities[j].Locality
+		})
+		clusters = append(clusters, cl)
+	}
+	sort.Slice(clusters, func(i, j int) bool {
+		return clusters[i].Cluster < clusters[j].Cluster
+	})
+	return clusters
+}
+
+// ServeHTTP writes the load reported for each cluster as JSON.
+func (ls *LoadStats) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(ls.Clusters()); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+type loadStatsStream interface {
+	Context() context.Context
+	Send(*envoy_service_load_stats_v3.LoadStatsResponse) error
+	Recv() (*envoy_service_load_stats_v3.LoadStatsRequest, error)
+}
+
+// streamLoadStats processes a stream of load reports. The first request
+// of the stream is answered with the clusters to report on, all of them,
+// and how often.
+func (xh *xdsHandler) streamLoadStats(st loadStatsStream) (err error) {
+	connection := xh.connections.next()
+	log := xh.WithField("connection", connection).WithField("protocol", "lrs")
+	defer xh.lrs.closed(connection)
+
+	defer func() {
+		if err != nil {
+			log.WithError(err).Error("stream terminated")
+		} else {
+			log.Info("stream terminated")
+		}
+	}()
+
+	for first := true; ; first = false {
+		req, err := st.Recv()
+		if err != nil {
+			return err
+		}
+		if first {
+			log = log.WithField("node", req.GetNode().GetId())
+			resp := &envoy_service_load_stats_v3.LoadStatsResponse{
+				SendAllClusters:       true,
+				LoadReportingInterval: durationpb.New(xh.lrs.interval()),
+			}
+			if err := st.Send(resp); err != nil {
+				return err
+			}
+			log.WithField("interval", xh.lrs.interval()).Info("load reporting started")
+		}
+		xh.lrs.report(connection, req)
+		log.WithField("clusters", len(req.ClusterStats)).Debug("load report")
+	}
+}
@@ -0,0 +1,373 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright(c) 2018-2023 Saaras Inc.
+
+package grpc
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+
+	envoy_config_core_v3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	envoy_config_endpoint_v3 "github.com/envoyproxy/go-control-plane/envoy/config/endpoint/v3"
+	envoy_service_load_stats_v3 "github.com/envoyproxy/go-control-plane/envoy/service/load_stats/v3"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/saarasio/enroute/enroute-dp/internal/envoy"
+	"github.com/saarasio/enroute/enroute-dp/internal/metrics"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+type report struct {
+	connection uint64
+	stats      []*envoy_config_endpoint_v3.ClusterStats
+}
+
+func clusterStats(cluster string, dropped uint64, localities ...*envoy_config_endpoint_v3.UpstreamLocalityStats) *envoy_config_endpoint_v3.ClusterStats {
+	return &envoy_config_endpoint_v3.ClusterStats{
+		ClusterName:           cluster,
+		TotalDroppedRequests:  dropped,
+		UpstreamLocalityStats: localities,
+	}
+}
+
+func localityStats(zone string, issued, success, errors, inProgress uint64, metrics ...*envoy_config_endpoint_v3.EndpointLoadMetricStats) *envoy_config_endpoint_v3.UpstreamLocalityStats {
+	return &envoy_config_endpoint_v3.UpstreamLocalityStats{
+		Locality:                &envoy_config_core_v3.Locality{Region: "us-west1", Zone: zone},
+		TotalIssuedRequests:     issued,
+		TotalSuccessfulRequests: success,
+		TotalErrorRequests:      errors,
+		TotalRequestsInProgress: inProgress,
+		LoadMetricStats:         metrics,
+	}
+}
+
+func endpointStats(address string, requests uint64, metrics ...*envoy_config_endpoint_v3.EndpointLoadMetricStats) *envoy_config_endpoint_v3.UpstreamEndpointStats {
+	return &envoy_config_endpoint_v3.UpstreamEndpointStats{
+		Address:                 envoy.SocketAddress(address, 8080),
+		TotalIssuedRequests:     requests,
+		TotalSuccessfulRequests: requests,
+		LoadMetricStats:         metrics,
+	}
+}
+
+func TestLoadStats(t *testing.T) {
+	tests := map[string]struct {
+		reports []report
+		closed  []uint64
+		want    []ClusterLoad
+	}{
+		"no reports": {},
+		"counts are added": {
+			reports: []report{{
+				connection: 1,
+				stats: []*envoy_config_endpoint_v3.ClusterStats{
+					clusterStats("default/kuard/80", 0, localityStats("a", 10, 8, 1, 1)),
+				},
+			}, {
+				connection: 1,
+				stats: []*envoy_config_endpoint_v3.ClusterStats{
+					clusterStats("default/kuard/80", 2, localityStats("a", 5, 5, 0, 0)),
+				},
+			}},
+			want: []ClusterLoad{{
+				Cluster:         "default/kuard/80",
+				DroppedRequests: 2,
+				Localities: []LocalityLoad{{
+					Locality:           "us-west1/a",
+					IssuedRequests:     15,
+					SuccessfulRequests: 13,
+					ErrorRequests:      1,
+				}},
+			}},
+		},
+		"localities and clusters are sorted": {
+			reports: []report{{
+				connection: 1,
+				stats: []*envoy_config_endpoint_v3.ClusterStats{
+					clusterStats("default/kuard/80", 0, localityStats("b", 1, 1, 0, 0), localityStats("a", 2, 2, 0, 0)),
+					clusterStats("default/httpbin/80", 0, localityStats("a", 3, 3, 0, 0)),
+				},
+			}},
+			want: []ClusterLoad{{
+				Cluster: "default/httpbin/80",
+				Localities: []LocalityLoad{{
+					Locality: "us-west1/a", IssuedRequests: 3, SuccessfulRequests: 3,
+				}},
+			}, {
+				Cluster: "default/kuard/80",
+				Localities: []LocalityLoad{{
+					Locality: "us-west1/a", IssuedRequests: 2, SuccessfulRequests: 2,
+				}, {
+					Locality: "us-west1/b", IssuedRequests: 1, SuccessfulRequests: 1,
+				}},
+			}},
+		},
+		"requests in progress are summed across connections": {
+			reports: []report{{
+				connection: 1,
+				stats: []*envoy_config_endpoint_v3.ClusterStats{
+					clusterStats("default/kuard/80", 0, localityStats("a", 0, 0, 0, 4)),
+				},
+			}, {
+				connection: 2,
+				stats: []*envoy_config_endpoint_v3.ClusterStats{
+					clusterStats("default/kuard/80", 0, localityStats("a", 0, 0, 0, 3)),
+				},
+			}, {
+				// replaces the previous report of connection 1.
+				connection: 1,
+				stats: []*envoy_config_endpoint_v3.ClusterStats{
+					clusterStats("default/kuard/80", 0, localityStats("a", 0, 0, 0, 1)),
+				},
+			}},
+			want: []ClusterLoad{{
+				Cluster: "default/kuard/80",
+				Localities: []LocalityLoad{{
+					Locality: "us-west1/a", RequestsInProgress: 4,
+				}},
+			}},
+		},
+		"requests in progress of closed connections are removed": {
+			reports: []report{{
+				connection: 1,
+				stats: []*envoy_config_endpoint_v3.ClusterStats{
+					clusterStats("default/kuard/80", 0, localityStats("a", 2, 0, 0, 2)),
+				},
+			}, {
+				connection: 2,
+				stats: []*envoy_config_endpoint_v3.ClusterStats{
+					clusterStats("default/kuard/80", 0, localityStats("a", 3, 0, 0, 3)),
+				},
+			}},
+			closed: []uint64{1},
+			want: []ClusterLoad{{
+				Cluster: "default/kuard/80",
+				Localities: []LocalityLoad{{
+					Locality: "us-west1/a", IssuedRequests: 5, RequestsInProgress: 3,
+				}},
+			}},
+		},
+		"load metrics": {
+			reports: []report{{
+				connection: 1,
+				stats: []*envoy_config_endpoint_v3.ClusterStats{
+					clusterStats("default/kuard/80", 0, localityStats("a", 4, 4, 0, 0, &envoy_config_endpoint_v3.EndpointLoadMetricStats{
+						MetricName:                    "latency_ms",
+						NumRequestsFinishedWithMetric: 4,
+						TotalMetricValue:              100,
+					})),
+				},
+			}, {
+				connection: 1,
+				stats: []*envoy_config_endpoint_v3.ClusterStats{
+					clusterStats("default/kuard/80", 0, localityStats("a", 1, 1, 0, 0, &envoy_config_endpoint_v3.EndpointLoadMetricStats{
+						MetricName:                    "latency_ms",
+						NumRequestsFinishedWithMetric: 1,
+						TotalMetricValue:              50,
+					})),
+				},
+			}},
+			want: []ClusterLoad{{
+				Cluster: "default/kuard/80",
+				Localities: []LocalityLoad{{
+					Locality:           "us-west1/a",
+					IssuedRequests:     5,
+					SuccessfulRequests: 5,
+					LoadMetrics: map[string]LoadMetric{
+						"latency_ms": {Requests: 5, Total: 150},
+					},
+				}},
+			}},
+		},
+		"endpoints and report interval": {
+			reports: []report{{
+				connection: 1,
+				stats: []*envoy_config_endpoint_v3.ClusterStats{{
+					ClusterName:        "default/kuard/80",
+					LoadReportInterval: durationpb.New(10 * time.Second),
+					UpstreamLocalityStats: []*envoy_config_endpoint_v3.UpstreamLocalityStats{{
+						Locality:                &envoy_config_core_v3.Locality{Region: "us-west1", Zone: "a"},
+						TotalIssuedRequests:     3,
+						TotalSuccessfulRequests: 3,
+						UpstreamEndpointStats: []*envoy_config_endpoint_v3.UpstreamEndpointStats{
+							endpointStats("10.0.0.2", 1, &envoy_config_endpoint_v3.EndpointLoadMetricStats{
+								MetricName:                    "latency_ms",
+								NumRequestsFinishedWithMetric: 1,
+								TotalMetricValue:              20,
+							}),
+							endpointStats("10.0.0.1", 2),
+						},
+					}},
+				}},
+			}, {
+				connection: 2,
+				stats: []*envoy_config_endpoint_v3.ClusterStats{{
+					ClusterName:        "default/kuard/80",
+					LoadReportInterval: durationpb.New(9 * time.Second),
+					UpstreamLocalityStats: []*envoy_config_endpoint_v3.UpstreamLocalityStats{{
+						Locality:                &envoy_config_core_v3.Locality{Region: "us-west1", Zone: "a"},
+						TotalIssuedRequests:     1,
+						TotalSuccessfulRequests: 1,
+						UpstreamEndpointStats: []*envoy_config_endpoint_v3.UpstreamEndpointStats{
+							endpointStats("10.0.0.2", 1, &envoy_config_endpoint_v3.EndpointLoadMetricStats{
+								MetricName:                    "latency_ms",
+								NumRequestsFinishedWithMetric: 1,
+								TotalMetricValue:              30,
+							}),
+						},
+					}},
+				}},
+			}},
+			want: []ClusterLoad{{
+				Cluster:                   "default/kuard/80",
+				LoadReportIntervalSeconds: 9,
+				Localities: []LocalityLoad{{
+					Locality:           "us-west1/a",
+					IssuedRequests:     4,
+					SuccessfulRequests: 4,
+					Endpoints: []EndpointLoad{{
+						Address:            "10.0.0.1:8080",
+						IssuedRequests:     2,
+						SuccessfulRequests: 2,
+					}, {
+						Address:            "10.0.0.2:8080",
+						IssuedRequests:     2,
+						SuccessfulRequests: 2,
+						LoadMetrics: map[string]LoadMetric{
+							"latency_ms": {Requests: 2, Total: 50},
+						},
+					}},
+				}},
+			}},
+		},
+	}
+
+	ignoreTimes := cmpopts.IgnoreFields(LocalityLoad{}, "LastReport")
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			ls := NewLoadStats()
+			for _, r := range tc.reports {
+				ls.report(r.connection, &envoy_service_load_stats_v3.LoadStatsRequest{ClusterStats: r.stats})
+			}
+			for _, c := range tc.closed {
+				ls.closed(c)
+			}
+			if diff := cmp.Diff(tc.want, ls.Clusters(), ignoreTimes); diff != "" {
+				t.Fatal(diff)
+			}
+		})
+	}
+}
+
+type mockLoadStatsStream struct {
+	ctx  context.Context
+	reqs chan *envoy_service_load_stats_v3.LoadStatsRequest
+	resp chan *envoy_service_load_stats_v3.LoadStatsResponse
+}
+
+func (m *mockLoadStatsStream) Context() context.Context { return m.ctx }
+func (m *mockLoadStatsStream) Send(resp *envoy_service_load_stats_v3.LoadStatsResponse) error {
+	m.resp <- resp
+	return nil
+}
+func (m *mockLoadStatsStream) Recv() (*envoy_service_load_stats_v3.LoadStatsRequest, error) {
+	req, ok := <-m.reqs
+	if !ok {
+		return nil, io.EOF
+	}
+	return req, nil
+}
+
+func TestStreamLoadStats(t *testing.T) {
+	log := logrus.New()
+	log.SetOutput(ioutil.Discard)
+	registry := prometheus.NewRegistry()
+	ls := NewLoadStats()
+	ls.Metrics = metrics.NewMetrics(registry)
+	ls.Interval = 30 * time.Second
+	xh := &xdsHandler{
+		FieldLogger: log,
+		lrs:         ls,
+	}
+	st := &mockLoadStatsStream{
+		ctx:  context.Background(),
+		reqs: make(chan *envoy_service_load_stats_v3.LoadStatsRequest),
+		resp: make(chan *envoy_service_load_stats_v3.LoadStatsResponse, 1),
+	}
+	done := make(chan error, 1)
+	go func() { done <- xh.streamLoadStats(st) }()
+
+	// the first request only identifies the node.
+	st.reqs <- &envoy_service_load_stats_v3.LoadStatsRequest{
+		Node: &envoy_config_core_v3.Node{Id: "envoy-1"},
+	}
+	select {
+	case resp := <-st.resp:
+		if !resp.SendAllClusters {
+			t.Fatal("expected send_all_clusters")
+		}
+		if got := resp.LoadReportingInterval.AsDuration(); got != ls.Interval {
+			t.Fatalf("expected interval %v, got %v", ls.Interval, got)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for LoadStatsResponse")
+	}
+
+	cs := clusterStats("default/kuard/80", 1, localityStats("a", 10, 7, 3, 2))
+	cs.LoadReportInterval = durationpb.New(30 * time.Second)
+	st.reqs <- &envoy_service_load_stats_v3.LoadStatsRequest{
+		ClusterStats: []*envoy_config_endpoint_v3.ClusterStats{cs},
+	}
+	close(st.reqs)
+	if err := <-done; err != io.EOF {
+		t.Fatalf("expected %v, got %v", io.EOF, err)
+	}
+
+	want := []ClusterLoad{{
+		Cluster:                   "default/kuard/80",
+		DroppedRequests:           1,
+		LoadReportIntervalSeconds: 30,
+		Localities: []LocalityLoad{{
+			Locality:           "us-west1/a",
+			IssuedRequests:     10,
+			SuccessfulRequests: 7,
+			ErrorRequests:      3,
+		}},
+	}}
+	if diff := cmp.Diff(want, ls.Clusters(), cmpopts.IgnoreFields(LocalityLoad{}, "LastReport")); diff != "" {
+		t.Fatal(diff)
+	}
+
+	expected := `
+# HELP enroute_lrs_issued_requests_total Total number of upstream requests issued reported by Envoy
+# TYPE enroute_lrs_issued_requests_total counter
+enroute_lrs_issued_requests_total{cluster="default/kuard/80",locality="us-west1/a"} 10
+# HELP enroute_lrs_successful_requests_total Total number of upstream requests completed successfully reported by Envoy
+# TYPE enroute_lrs_successful_requests_total counter
+enroute_lrs_successful_requests_total{cluster="default/kuard/80",locality="us-west1/a"} 7
+# HELP enroute_lrs_error_requests_total Total number of upstream requests completed with an error reported by Envoy
+# TYPE enroute_lrs_error_requests_total counter
+enroute_lrs_error_requests_total{cluster="default/kuard/80",locality="us-west1/a"} 3
+# HELP enroute_lrs_requests_in_progress Number of upstream requests in progress last reported by Envoy
+# TYPE enroute_lrs_requests_in_progress gauge
+enroute_lrs_requests_in_progress{cluster="default/kuard/80",locality="us-west1/a"} 0
+# HELP enroute_lrs_dropped_requests_total Total number of requests dropped by Envoy before reaching an upstream
+# TYPE enroute_lrs_dropped_requests_total counter
+enroute_lrs_dropped_requests_total{cluster="default/kuard/80"} 1
+# HELP enroute_lrs_load_report_interval_seconds Interval covered by the last load report of a cluster
+# TYPE enroute_lrs_load_report_interval_seconds gauge
+enroute_lrs_load_report_interval_seconds{cluster="default/kuard/80"} 30
+`
+	if err := testutil.GatherAndCompare(registry, strings.NewReader(expected),
+		metrics.LRSIssuedTotal, metrics.LRSSuccessTotal, metrics.LRSErrorTotal,
+		metrics.LRSInProgressGauge, metrics.LRSDroppedTotal, metrics.LRSReportIntervalGauge); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -22,6 +22,7 @@ import (
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 
+	envoy_service_accesslog_v3 "github.com/envoyproxy/go-control-plane/envoy/service/accesslog/v3"
 	envoy_service_cluster_v3 "github.com/envoyproxy/go-control-plane/envoy/service/cluster/v3"
 	envoy_service_discovery_v3 "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
 	envoy_service_endpoint_v3 "github.com/envoyproxy/go-control-plane/envoy/service/endpoint/v3"
@@ -39,7 +40,10 @@ const (
 )
 
 // NewAPI returns a *grpc.Server which responds to the Envoy v2 xDS gRPC API.
-func NewAPI(log logrus.FieldLogger, resources map[string]Resource) *grpc.Server {
+// If acks is not nil, it is updated with the ACKs and NACKs received.
+// If lrs is not nil, load reports are accepted and aggregated in it.
+// If als is not nil, access logs are accepted and written to its sinks.
+func NewAPI(log logrus.FieldLogger, resources map[string]Resource, acks *AckTracker, lrs *LoadStats, als *AccessLogs) *grpc.Server {
 	opts := []grpc.ServerOption{
 		// By default the Go grpc library defaults to a value of ~100 streams per
 		// connection. This number is likely derived from the HTTP/2 spec:
@@ -54,6 +58,9 @@ func NewAPI(log logrus.FieldLogger, resources map[string]Resource) *grpc.Server
 		xdsHandler{
 			FieldLogger: log,
 			resources:   resources,
+			acks:        acks,
+			lrs:         lrs,
+			als:         als,
 		},
 	}
 
@@ -62,11 +69,14 @@ func NewAPI(log logrus.FieldLogger, resources map[string]Resource) *grpc.Server
 	envoy_service_listener_v3.RegisterListenerDiscoveryServiceServer(g, s)
 	envoy_service_route_v3.RegisterRouteDiscoveryServiceServer(g, s)
 	envoy_service_secret_v3.RegisterSecretDiscoveryServiceServer(g, s)
+	envoy_service_discovery_v3.RegisterAggregatedDiscoveryServiceServer(g, s)
+	envoy_service_load_stats_v3.RegisterLoadReportingServiceServer(g, s)
+	envoy_service_accesslog_v3.RegisterAccessLogServiceServer(g, s)
 
 	return g
 }
 
-// grpcServer implements the LDS, RDS, CDS, and EDS, gRPC endpoints.
+// grpcServer implements the LDS, RDS, CDS, EDS, SDS, ADS, LRS and ALS gRPC endpoints.
 type grpcServer struct {
 	xdsHandler
 }
@@ -79,16 +89,16 @@ func (s *grpcServer) FetchEndpoints(_ context.Context, req *envoy_service_discov
 	return nil, status.Errorf(codes.Unimplemented, "FetchEndpoints unimplemented")
 }
 
-func (s *grpcServer) DeltaEndpoints(envoy_service_endpoint_v3.EndpointDiscoveryService_DeltaEndpointsServer) error {
-	return status.Errorf(codes.Unimplemented, "DeltaEndpoints unimplemented")
+func (s *grpcServer) DeltaEndpoints(srv envoy_service_endpoint_v3.EndpointDiscoveryService_DeltaEndpointsServer) error {
+	return s.deltaStream(srv, false)
 }
 
 func (s *grpcServer) FetchListeners(_ context.Context, req *envoy_service_discovery_v3.DiscoveryRequest) (*envoy_service_discovery_v3.DiscoveryResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "FetchListeners unimplemented")
 }
 
-func (s *grpcServer) DeltaListeners(envoy_service_listener_v3.ListenerDiscoveryService_DeltaListenersServer) error {
-	return status.Errorf(codes.Unimplemented, "DeltaListeners unimplemented")
+func (s *grpcServer) DeltaListeners(srv envoy_service_listener_v3.ListenerDiscoveryService_DeltaListenersServer) error {
+	return s.deltaStream(srv, false)
 }
 
 func (s *grpcServer) FetchRoutes(_ context.Context, req *envoy_service_discovery_v3.DiscoveryRequest) (*envoy_service_discovery_v3.DiscoveryResponse, error) {
@@ -99,8 +109,8 @@ func (s *grpcServer) FetchSecrets(_ context.Context, req *envoy_service_discover
 	return nil, status.Errorf(codes.Unimplemented, "FetchSecrets unimplemented")
 }
 
-func (s *grpcServer) DeltaSecrets(envoy_service_secret_v3.SecretDiscoveryService_DeltaSecretsServer) error {
-	return status.Errorf(codes.Unimplemented, "DeltaSecrets unimplemented")
+func (s *grpcServer) DeltaSecrets(srv envoy_service_secret_v3.SecretDiscoveryService_DeltaSecretsServer) error {
+	return s.deltaStream(srv, false)
 }
 
 func (s *grpcServer) StreamClusters(srv envoy_service_cluster_v3.ClusterDiscoveryService_StreamClustersServer) error {
@@ -112,15 +122,25 @@ func (s *grpcServer) StreamEndpoints(srv envoy_service_endpoint_v3.EndpointDisco
 }
 
 func (s *grpcServer) StreamLoadStats(srv envoy_service_load_stats_v3.LoadReportingService_StreamLoadStatsServer) error {
-	return status.Errorf(codes.Unimplemented, "StreamLoadStats unimplemented")
+	if s.lrs == nil {
+		return status.Errorf(codes.Unimplemented, "StreamLoadStats unimplemented")
+	}
+	return s.streamLoadStats(srv)
+}
+
+func (s *grpcServer) StreamAccessLogs(srv envoy_service_accesslog_v3.AccessLogService_StreamAccessLogsServer) error {
+	if s.als == nil {
+		return status.Errorf(codes.Unimplemented, "StreamAccessLogs unimplemented")
+	}
+	return s.streamAccessLogs(srv)
 }
 
-func (s *grpcServer) DeltaClusters(envoy_service_cluster_v3.ClusterDiscoveryService_DeltaClustersServer) error {
-	return status.Errorf(codes.Unimplemented, "IncrementalClusters unimplemented")
+func (s *grpcServer) DeltaClusters(srv envoy_service_cluster_v3.ClusterDiscoveryService_DeltaClustersServer) error {
+	return s.deltaStream(srv, false)
 }
 
-func (s *grpcServer) DeltaRoutes(envoy_service_route_v3.RouteDiscoveryService_DeltaRoutesServer) error {
-	return status.Errorf(codes.Unimplemented, "IncrementalRoutes unimplemented")
+func (s *grpcServer) DeltaRoutes(srv envoy_service_route_v3.RouteDiscoveryService_DeltaRoutesServer) error {
+	return s.deltaStream(srv, false)
 }
 
 func (s *grpcServer) StreamListeners(srv envoy_service_listener_v3.ListenerDiscoveryService_StreamListenersServer) error {
@@ -134,3 +154,11 @@ func (s *grpcServer) StreamRoutes(srv envoy_service_route_v3.RouteDiscoveryServi
 func (s *grpcServer) StreamSecrets(srv envoy_service_secret_v3.SecretDiscoveryService_StreamSecretsServer) error {
 	return s.stream(srv)
 }
+
+func (s *grpcServer) StreamAggregatedResources(srv envoy_service_discovery_v3.AggregatedDiscoveryService_StreamAggregatedResourcesServer) error {
+	return s.streamAggregated(srv)
+}
+
+func (s *grpcServer) DeltaAggregatedResources(srv envoy_service_discovery_v3.AggregatedDiscoveryService_DeltaAggregatedResourcesServer) error {
+	return s.deltaStream(srv, true)
+}
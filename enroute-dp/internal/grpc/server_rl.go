@@ -5,14 +5,20 @@ package grpc
 
 import (
 	"context"
+	"strings"
 
+	"github.com/golang/protobuf/ptypes"
 	"google.golang.org/grpc"
 
+	rlv3common "github.com/envoyproxy/go-control-plane/envoy/extensions/common/ratelimit/v3"
 	rl "github.com/envoyproxy/go-control-plane/envoy/service/ratelimit/v3"
+	"github.com/saarasio/enroute/enroute-dp/ratelim"
 	"github.com/sirupsen/logrus"
 )
 
-func NewAPIRateLimit(log logrus.FieldLogger, c chan string) *grpc.Server {
+// NewAPIRateLimit returns a rate limit service that enforces limits using
+// limiter. The limiter is reconfigured with the configs received on c.
+func NewAPIRateLimit(log logrus.FieldLogger, c chan string, limiter *ratelim.Limiter) *grpc.Server {
 	opts := []grpc.ServerOption{
 		// By default the Go grpc library defaults to a value of ~100 streams per
 		// connection. This number is likely derived from the HTTP/2 spec:
@@ -23,23 +29,68 @@ func NewAPIRateLimit(log logrus.FieldLogger, c chan string) *grpc.Server {
 		grpc.MaxConcurrentStreams(grpcMaxConcurrentStreams),
 	}
 	g := grpc.NewServer(opts...)
-	rls := &ratelimitServer{FieldLogger: log}
+	rls := &ratelimitServer{FieldLogger: log, limiter: limiter}
 	rl.RegisterRateLimitServiceServer(g, rls)
+	if c != nil {
+		go rls.watchConfig(c)
+	}
 	return g
 }
 
 type ratelimitServer struct {
 	rl.RateLimitServiceServer
 	logrus.FieldLogger
+	limiter *ratelim.Limiter
 }
 
-func (s *ratelimitServer) getRateLimit(requestsPerUnit uint32, unit rl.RateLimitResponse_RateLimit_Unit) *rl.RateLimitResponse_RateLimit {
-	return &rl.RateLimitResponse_RateLimit{RequestsPerUnit: requestsPerUnit, Unit: unit}
+// watchConfig reloads the limiter with each set of rate limit configs
+// received on c, until c is closed.
+func (s *ratelimitServer) watchConfig(c chan string) {
+	for config := range c {
+		cfgs, err := ratelim.UnmarshalRateLimitGlobalConfigs(config)
+		if err != nil {
+			s.Errorf("Failed to decode ratelimit config: %v", err)
+			continue
+		}
+		s.Infof("Updating ratelimit config, %d domain(s)", len(cfgs))
+		s.limiter.SetConfig(cfgs)
+	}
 }
 
-func (s *ratelimitServer) rateLimitDescriptor() *rl.RateLimitResponse_DescriptorStatus {
-	l := s.getRateLimit(10, rl.RateLimitResponse_RateLimit_SECOND)
-	return &rl.RateLimitResponse_DescriptorStatus{Code: rl.RateLimitResponse_OK, CurrentLimit: l, LimitRemaining: 5}
+func rateLimitUnit(unit string) rl.RateLimitResponse_RateLimit_Unit {
+	if u, ok := rl.RateLimitResponse_RateLimit_Unit_value[strings.ToUpper(unit)]; ok {
+		return rl.RateLimitResponse_RateLimit_Unit(u)
+	}
+	return rl.RateLimitResponse_RateLimit_UNKNOWN
+}
+
+func (s *ratelimitServer) rateLimitDescriptor(ctx context.Context, domain string, d *rlv3common.RateLimitDescriptor, hits uint32) (*rl.RateLimitResponse_DescriptorStatus, error) {
+	entries := make([]ratelim.Entry, 0, len(d.GetEntries()))
+	for _, e := range d.GetEntries() {
+		entries = append(entries, ratelim.Entry{Key: e.GetKey(), Value: e.GetValue()})
+	}
+
+	st, err := s.limiter.ShouldRateLimit(ctx, domain, entries, hits)
+	if err != nil {
+		return nil, err
+	}
+	if st.Limit == nil {
+		return &rl.RateLimitResponse_DescriptorStatus{Code: rl.RateLimitResponse_OK}, nil
+	}
+
+	ds := &rl.RateLimitResponse_DescriptorStatus{
+		Code: rl.RateLimitResponse_OK,
+		CurrentLimit: &rl.RateLimitResponse_RateLimit{
+			RequestsPerUnit: st.Limit.RequestsPerUnit,
+			Unit:            rateLimitUnit(st.Limit.Unit),
+		},
+		LimitRemaining:     st.Remaining,
+		DurationUntilReset: ptypes.DurationProto(st.DurationUntilReset),
+	}
+	if st.OverLimit {
+		ds.Code = rl.RateLimitResponse_OVER_LIMIT
+	}
+	return ds, nil
 }
 
 func (s *ratelimitServer) ShouldRateLimit(c context.Context, req *rl.RateLimitRequest) (*rl.RateLimitResponse, error) {
@@ -47,8 +98,14 @@ func (s *ratelimitServer) ShouldRateLimit(c context.Context, req *rl.RateLimitRe
 	response := &rl.RateLimitResponse{}
 	response.Statuses = make([]*rl.RateLimitResponse_DescriptorStatus, len(req.Descriptors))
 	finalCode := rl.RateLimitResponse_OK
-	for i := range req.Descriptors {
-		descriptorStatus := s.rateLimitDescriptor()
+	for i, d := range req.Descriptors {
+		// errors are returned to Envoy, which applies the failure mode
+		// of its ratelimit filter.
+		descriptorStatus, err := s.rateLimitDescriptor(c, req.Domain, d, req.HitsAddend)
+		if err != nil {
+			s.Errorf("Failed to rate limit descriptor %v: %v", d, err)
+			return nil, err
+		}
 		response.Statuses[i] = descriptorStatus
 		if descriptorStatus.Code == rl.RateLimitResponse_OVER_LIMIT {
 			finalCode = descriptorStatus.Code
@@ -0,0 +1,68 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright(c) 2018-2023 Saaras Inc.
+
+package grpc
+
+import (
+	"context"
+	"io/ioutil"
+	"testing"
+
+	rlv3common "github.com/envoyproxy/go-control-plane/envoy/extensions/common/ratelimit/v3"
+	rl "github.com/envoyproxy/go-control-plane/envoy/service/ratelimit/v3"
+	"github.com/saarasio/enroute/enroute-dp/ratelim"
+	"github.com/sirupsen/logrus"
+)
+
+func TestRateLimitServer(t *testing.T) {
+	log := logrus.New()
+	log.SetOutput(ioutil.Discard)
+
+	cfgs, err := ratelim.UnmarshalRateLimitGlobalConfigs(`[{"domain":"enroute","descriptors":[{"key":"remote_address","rate_limit":{"unit":"minute","requests_per_unit":1}}]}]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &ratelimitServer{FieldLogger: log, limiter: ratelim.NewLimiter(nil, 1)}
+	s.limiter.SetConfig(cfgs)
+
+	req := &rl.RateLimitRequest{
+		Domain: "enroute",
+		Descriptors: []*rlv3common.RateLimitDescriptor{{
+			Entries: []*rlv3common.RateLimitDescriptor_Entry{{Key: "remote_address", Value: "10.0.0.1"}},
+		}, {
+			Entries: []*rlv3common.RateLimitDescriptor_Entry{{Key: "generic_key", Value: "unlimited"}},
+		}},
+	}
+
+	resp, err := s.ShouldRateLimit(context.TODO(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.OverallCode != rl.RateLimitResponse_OK {
+		t.Fatalf("expected OK, got %v", resp.OverallCode)
+	}
+	st := resp.Statuses[0]
+	if st.CurrentLimit.GetUnit() != rl.RateLimitResponse_RateLimit_MINUTE || st.CurrentLimit.GetRequestsPerUnit() != 1 {
+		t.Fatalf("unexpected limit: %v", st.CurrentLimit)
+	}
+	if st.LimitRemaining != 0 {
+		t.Fatalf("expected 0 remaining, got %d", st.LimitRemaining)
+	}
+	if d := st.DurationUntilReset.AsDuration(); d <= 0 {
+		t.Fatalf("expected positive duration until reset, got %v", d)
+	}
+	if resp.Statuses[1].CurrentLimit != nil {
+		t.Fatalf("expected no limit, got %v", resp.Statuses[1].CurrentLimit)
+	}
+
+	resp, err = s.ShouldRateLimit(context.TODO(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.OverallCode != rl.RateLimitResponse_OVER_LIMIT {
+		t.Fatalf("expected OVER_LIMIT, got %v", resp.OverallCode)
+	}
+	if resp.Statuses[1].Code != rl.RateLimitResponse_OK {
+		t.Fatalf("expected OK for unlimited descriptor, got %v", resp.Statuses[1].Code)
+	}
+}
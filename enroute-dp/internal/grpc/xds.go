@@ -25,6 +25,7 @@ import (
 	envoy_service_discovery_v3 "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
 	"github.com/golang/protobuf/proto"
 	"github.com/golang/protobuf/ptypes/any"
+	"github.com/saarasio/enroute/enroute-dp/internal/protobuf"
 	"github.com/sirupsen/logrus"
 )
 
@@ -49,6 +50,9 @@ type xdsHandler struct {
 	logrus.FieldLogger
 	connections counter
 	resources   map[string]Resource // registered resource types
+	acks        *AckTracker         // may be nil
+	lrs         *LoadStats          // may be nil
+	als         *AccessLogs         // may be nil
 }
 
 type grpcStream interface {
@@ -60,7 +64,9 @@ type grpcStream interface {
 // stream processes a stream of DiscoveryRequests.
 func (xh *xdsHandler) stream(st grpcStream) (err error) {
 	// bump connection counter and set it as a field on the logger
-	log := xh.WithField("connection", xh.connections.next())
+	connection := xh.connections.next()
+	log := xh.WithField("connection", connection)
+	defer xh.acks.closed(connection)
 
 	// set up some nice function exit handling which notifies if the
 	// stream terminated on error or not.
@@ -89,7 +95,13 @@ func (xh *xdsHandler) stream(st grpcStream) (err error) {
 			return err
 		}
 
-		// TODO(dfc) issue 1176: handle xDS ACK/NACK
+		// a request carrying the nonce of our last response ACKs it, or
+		// NACKs it if Envoy rejected the response.
+		xh.acks.request(connection, req.GetNode().GetId(), req)
+		if req.ErrorDetail != nil {
+			log.WithField("type_url", req.TypeUrl).WithField("response_nonce", req.ResponseNonce).
+				WithField("error_detail", req.ErrorDetail.GetMessage()).Error("response rejected")
+		}
 
 		// from the request we derive the resource to stream which have
 		// been registered according to the typeURL.
@@ -106,13 +118,27 @@ func (xh *xdsHandler) stream(st grpcStream) (err error) {
 
 		// now we wait for a notification, if this is the first request received on this
 		// connection last will be less than zero and that will trigger a response immediately.
-		r.Register(ch, last)
-		select {
-		case last = <-ch:
-			// boom, something in the cache has changed.
-			// TODO(dfc) the thing that has changed may not be in the scope of the filter
-			// so we're going to be sending an update that is a no-op. See #426
+		resp, err := waitResponse(ctx, r, ch, &last, req)
+		if err != nil {
+			return err
+		}
+		if err := st.Send(resp); err != nil {
+			return err
+		}
+		xh.acks.response(connection, resp)
+		log.WithField("count", len(resp.Resources)).Info("response")
+	}
+}
 
+// waitResponse waits for a change to r and returns the response to req.
+// Versions are derived from the content of the response, changes that do
+// not alter the resources req asked for, or responses the client already
+// has from a previous stream, are not sent.
+func waitResponse(ctx context.Context, r Resource, ch chan int, last *int, req *envoy_service_discovery_v3.DiscoveryRequest) (*envoy_service_discovery_v3.DiscoveryResponse, error) {
+	for {
+		r.Register(ch, *last)
+		select {
+		case *last = <-ch:
 			var resources []proto.Message
 			switch len(req.ResourceNames) {
 			case 0:
@@ -126,31 +152,41 @@ func (xh *xdsHandler) stream(st grpcStream) (err error) {
 
 			any, err := toAny(r.TypeURL(), resources)
 			if err != nil {
-				return err
+				return nil, err
 			}
 
-			resp := &envoy_service_discovery_v3.DiscoveryResponse{
-				VersionInfo: strconv.Itoa(last),
+			version := contentVersion(any)
+			if version == req.VersionInfo {
+				continue
+			}
+			return &envoy_service_discovery_v3.DiscoveryResponse{
+				VersionInfo: version,
 				Resources:   any,
 				TypeUrl:     r.TypeURL(),
-				Nonce:       strconv.Itoa(last),
-			}
-			if err := st.Send(resp); err != nil {
-				return err
-			}
-			log.WithField("count", len(resources)).Info("response")
+				Nonce:       strconv.Itoa(*last),
+			}, nil
 		case <-ctx.Done():
-			return ctx.Err()
+			return nil, ctx.Err()
 		}
 	}
 }
 
+// contentVersion returns the version of a response holding resources.
+// Equal contents have equal versions, also across restarts.
+func contentVersion(resources []*any.Any) string {
+	b := make([][]byte, 0, len(resources))
+	for _, r := range resources {
+		b = append(b, r.Value)
+	}
+	return protobuf.Hash(b...)
+}
+
 // toAny converts the contents of a resourcer's Values to the
 // respective slice of *any.Any.
 func toAny(typeURL string, values []proto.Message) ([]*any.Any, error) {
 	var resources []*any.Any
 	for _, value := range values {
-		v, err := proto.Marshal(value)
+		v, err := protobuf.Marshal(value)
 		if err != nil {
 			return nil, err
 		}
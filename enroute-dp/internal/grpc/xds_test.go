@@ -154,6 +154,60 @@ func TestXDSHandlerStream(t *testing.T) {
 	}
 }
 
+func TestXDSHandlerStreamUnchanged(t *testing.T) {
+	log := logrus.New()
+	log.SetOutput(ioutil.Discard)
+
+	contents := []proto.Message{&envoy_config_endpoint_v3.ClusterLoadAssignment{ClusterName: "default/kuard"}}
+	any, err := toAny("com.heptio.potato", contents)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	registrations := 0
+	xh := xdsHandler{
+		FieldLogger: log,
+		resources: map[string]Resource{
+			"com.heptio.potato": &mockResource{
+				register: func(ch chan int, i int) {
+					registrations++
+					switch registrations {
+					case 1, 2:
+						ch <- i + 1
+					default:
+						cancel()
+					}
+				},
+				contents: func() []proto.Message { return contents },
+				typeurl:  func() string { return "com.heptio.potato" },
+			},
+		},
+	}
+	stream := &mockStream{
+		context: func() context.Context { return ctx },
+		recv: func() (*envoy_service_discovery_v3.DiscoveryRequest, error) {
+			// a client reconnecting with the content it already has
+			return &envoy_service_discovery_v3.DiscoveryRequest{
+				TypeUrl:     "com.heptio.potato",
+				VersionInfo: contentVersion(any),
+			}, nil
+		},
+		send: func(resp *envoy_service_discovery_v3.DiscoveryResponse) error {
+			t.Fatalf("unexpected response: %v", resp)
+			return nil
+		},
+	}
+
+	if err := xh.stream(stream); err != context.Canceled {
+		t.Fatalf("expected %v, got %v", context.Canceled, err)
+	}
+	if registrations != 3 {
+		t.Fatalf("expected 3 registrations, got %d", registrations)
+	}
+}
+
 type mockStream struct {
 	context func() context.Context
 	send    func(*envoy_service_discovery_v3.DiscoveryResponse) error
@@ -0,0 +1,131 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright(c) 2018-2023 Saaras Inc.
+
+package k8s
+
+import (
+	"context"
+	"encoding/json"
+
+	jsonpatch "github.com/evanphx/json-patch"
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/meta"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	gwapi_v1 "sigs.k8s.io/gateway-api/apis/v1"
+	gwapi_v1beta1 "sigs.k8s.io/gateway-api/apis/v1beta1"
+)
+
+// SetGatewayClassStatus merges conditions into the status of a GatewayClass
+// and patches the status subresource if anything changed.
+func (irs *GatewayHostStatus) SetGatewayClassStatus(conditions []meta_v1.Condition, existing *gwapi_v1beta1.GatewayClass) error {
+	updated := existing.DeepCopy()
+	mergeConditions(&updated.Status.Conditions, conditions)
+	if apiequality.Semantic.DeepEqual(existing.Status, updated.Status) {
+		return nil
+	}
+
+	patchBytes, err := statusMergePatch(existing, updated)
+	if err != nil || irs == nil || irs.GatewayClient == nil {
+		return err
+	}
+	_, err = irs.GatewayClient.GatewayV1beta1().GatewayClasses().Patch(context.TODO(), existing.GetName(),
+		types.MergePatchType, patchBytes, meta_v1.PatchOptions{}, "status")
+	return err
+}
+
+// SetGatewayStatus merges conditions and listener status into the status of
+// a Gateway and patches the status subresource if anything changed.
+func (irs *GatewayHostStatus) SetGatewayStatus(conditions []meta_v1.Condition, listeners []gwapi_v1.ListenerStatus, existing *gwapi_v1beta1.Gateway) error {
+	updated := existing.DeepCopy()
+	mergeConditions(&updated.Status.Conditions, conditions)
+
+	// listener status is replaced wholesale, but transition times are
+	// carried over from the existing listener of the same name.
+	var ls []gwapi_v1.ListenerStatus
+	for _, l := range listeners {
+		l := *l.DeepCopy()
+		var conds []meta_v1.Condition
+		for _, el := range existing.Status.Listeners {
+			if el.Name == l.Name {
+				conds = append(conds, el.Conditions...)
+			}
+		}
+		mergeConditions(&conds, l.Conditions)
+		l.Conditions = conds
+		ls = append(ls, l)
+	}
+	updated.Status.Listeners = ls
+
+	if apiequality.Semantic.DeepEqual(existing.Status, updated.Status) {
+		return nil
+	}
+
+	patchBytes, err := statusMergePatch(existing, updated)
+	if err != nil || irs == nil || irs.GatewayClient == nil {
+		return err
+	}
+	_, err = irs.GatewayClient.GatewayV1beta1().Gateways(existing.GetNamespace()).Patch(context.TODO(), existing.GetName(),
+		types.MergePatchType, patchBytes, meta_v1.PatchOptions{}, "status")
+	return err
+}
+
+// SetHTTPRouteStatus replaces the parent status entries owned by controller
+// with the supplied parents and patches the status subresource if anything
+// changed. Entries written by other controllers are preserved.
+func (irs *GatewayHostStatus) SetHTTPRouteStatus(controller string, parents []gwapi_v1.RouteParentStatus, existing *gwapi_v1beta1.HTTPRoute) error {
+	updated := existing.DeepCopy()
+
+	var ps []gwapi_v1.RouteParentStatus
+	for _, p := range existing.Status.Parents {
+		if string(p.ControllerName) != controller {
+			ps = append(ps, p)
+		}
+	}
+	for _, p := range parents {
+		p := *p.DeepCopy()
+		var conds []meta_v1.Condition
+		for _, ep := range existing.Status.Parents {
+			if string(ep.ControllerName) == controller && apiequality.Semantic.DeepEqual(ep.ParentRef, p.ParentRef) {
+				conds = append(conds, ep.Conditions...)
+			}
+		}
+		mergeConditions(&conds, p.Conditions)
+		p.Conditions = conds
+		ps = append(ps, p)
+	}
+	updated.Status.Parents = ps
+
+	if apiequality.Semantic.DeepEqual(existing.Status, updated.Status) {
+		return nil
+	}
+
+	patchBytes, err := statusMergePatch(existing, updated)
+	if err != nil || irs == nil || irs.GatewayClient == nil {
+		return err
+	}
+	_, err = irs.GatewayClient.GatewayV1beta1().HTTPRoutes(existing.GetNamespace()).Patch(context.TODO(), existing.GetName(),
+		types.MergePatchType, patchBytes, meta_v1.PatchOptions{}, "status")
+	return err
+}
+
+// mergeConditions sets each of conditions on conds. Transition times of
+// conditions whose status did not change are preserved.
+func mergeConditions(conds *[]meta_v1.Condition, conditions []meta_v1.Condition) {
+	for _, c := range conditions {
+		meta.SetStatusCondition(conds, c)
+	}
+}
+
+// statusMergePatch returns a merge patch that transforms existing to updated.
+func statusMergePatch(existing, updated interface{}) ([]byte, error) {
+	existingBytes, err := json.Marshal(existing)
+	if err != nil {
+		return nil, err
+	}
+	updatedBytes, err := json.Marshal(updated)
+	if err != nil {
+		return nil, err
+	}
+	return jsonpatch.CreateMergePatch(existingBytes, updatedBytes)
+}
@@ -0,0 +1,152 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright(c) 2018-2023 Saaras Inc.
+
+package k8s
+
+import (
+	"fmt"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8stesting "k8s.io/client-go/testing"
+	gwapi_v1 "sigs.k8s.io/gateway-api/apis/v1"
+	gwapi_v1beta1 "sigs.k8s.io/gateway-api/apis/v1beta1"
+	"sigs.k8s.io/gateway-api/pkg/client/clientset/versioned/fake"
+)
+
+func TestSetHTTPRouteStatus(t *testing.T) {
+	ltt := metav1.Unix(1000, 0)
+
+	accepted := metav1.Condition{
+		Type:   string(gwapi_v1.RouteConditionAccepted),
+		Status: metav1.ConditionTrue,
+		Reason: string(gwapi_v1.RouteReasonAccepted),
+	}
+	acceptedWithTime := accepted
+	acceptedWithTime.LastTransitionTime = ltt
+
+	parentRef := gwapi_v1.ParentReference{Name: "gw"}
+
+	tests := map[string]struct {
+		existing      *gwapi_v1beta1.HTTPRoute
+		parents       []gwapi_v1.RouteParentStatus
+		expectedPatch string
+		expectedVerbs []string
+	}{
+		"no update": {
+			existing: &gwapi_v1beta1.HTTPRoute{
+				ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"},
+				Status: gwapi_v1beta1.HTTPRouteStatus{
+					RouteStatus: gwapi_v1.RouteStatus{
+						Parents: []gwapi_v1.RouteParentStatus{{
+							ParentRef:      parentRef,
+							ControllerName: "enroute",
+							Conditions:     []metav1.Condition{acceptedWithTime},
+						}},
+					},
+				},
+			},
+			parents: []gwapi_v1.RouteParentStatus{{
+				ParentRef:      parentRef,
+				ControllerName: "enroute",
+				Conditions:     []metav1.Condition{accepted},
+			}},
+			expectedPatch: ``,
+			expectedVerbs: []string{},
+		},
+		"other controllers are preserved": {
+			existing: &gwapi_v1beta1.HTTPRoute{
+				ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"},
+				Status: gwapi_v1beta1.HTTPRouteStatus{
+					RouteStatus: gwapi_v1.RouteStatus{
+						Parents: []gwapi_v1.RouteParentStatus{{
+							ParentRef:      gwapi_v1.ParentReference{Name: "other"},
+							ControllerName: "other",
+							Conditions:     []metav1.Condition{acceptedWithTime},
+						}},
+					},
+				},
+			},
+			parents: []gwapi_v1.RouteParentStatus{{
+				ParentRef:      parentRef,
+				ControllerName: "enroute",
+				Conditions:     []metav1.Condition{acceptedWithTime},
+			}},
+			expectedPatch: `{"status":{"parents":[{"conditions":[{"lastTransitionTime":"1970-01-01T00:16:40Z","message":"","reason":"Accepted","status":"True","type":"Accepted"}],"controllerName":"other","parentRef":{"name":"other"}},{"conditions":[{"lastTransitionTime":"1970-01-01T00:16:40Z","message":"","reason":"Accepted","status":"True","type":"Accepted"}],"controllerName":"enroute","parentRef":{"name":"gw"}}]}}`,
+			expectedVerbs: []string{"patch"},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			var gotPatchBytes []byte
+			var gotSubresource string
+			client := fake.NewSimpleClientset(tc.existing)
+			client.PrependReactor("patch", "httproutes", func(action k8stesting.Action) (bool, runtime.Object, error) {
+				switch patchAction := action.(type) {
+				default:
+					return true, nil, fmt.Errorf("got unexpected action of type: %T", action)
+				case k8stesting.PatchActionImpl:
+					gotPatchBytes = patchAction.GetPatch()
+					gotSubresource = patchAction.GetSubresource()
+					return true, tc.existing, nil
+				}
+			})
+			irs := GatewayHostStatus{
+				GatewayClient: client,
+			}
+			if err := irs.SetHTTPRouteStatus("enroute", tc.parents, tc.existing); err != nil {
+				t.Fatal(err)
+			}
+
+			if len(client.Actions()) != len(tc.expectedVerbs) {
+				t.Fatalf("Expected verbs mismatch: want: %d, got: %d", len(tc.expectedVerbs), len(client.Actions()))
+			}
+
+			if tc.expectedPatch != string(gotPatchBytes) {
+				t.Fatalf("expected patch: %s, got: %s", tc.expectedPatch, string(gotPatchBytes))
+			}
+
+			if len(tc.expectedVerbs) > 0 && gotSubresource != "status" {
+				t.Fatalf("expected status subresource, got: %q", gotSubresource)
+			}
+		})
+	}
+}
+
+func TestSetGatewayStatus(t *testing.T) {
+	existing := &gwapi_v1beta1.Gateway{
+		ObjectMeta: metav1.ObjectMeta{Name: "gw", Namespace: "default"},
+	}
+
+	var gotPatchBytes []byte
+	client := fake.NewSimpleClientset(existing)
+	client.PrependReactor("patch", "gateways", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		gotPatchBytes = action.(k8stesting.PatchActionImpl).GetPatch()
+		return true, existing, nil
+	})
+
+	irs := GatewayHostStatus{
+		GatewayClient: client,
+	}
+	programmed := metav1.Condition{
+		Type:               string(gwapi_v1.GatewayConditionProgrammed),
+		Status:             metav1.ConditionTrue,
+		Reason:             string(gwapi_v1.GatewayReasonProgrammed),
+		LastTransitionTime: metav1.Unix(1000, 0),
+	}
+	listeners := []gwapi_v1.ListenerStatus{{
+		Name:           "http",
+		AttachedRoutes: 2,
+		SupportedKinds: []gwapi_v1.RouteGroupKind{{Kind: "HTTPRoute"}},
+	}}
+	if err := irs.SetGatewayStatus([]metav1.Condition{programmed}, listeners, existing); err != nil {
+		t.Fatal(err)
+	}
+
+	want := `{"status":{"conditions":[{"lastTransitionTime":"1970-01-01T00:16:40Z","message":"","reason":"Programmed","status":"True","type":"Programmed"}],"listeners":[{"attachedRoutes":2,"conditions":null,"name":"http","supportedKinds":[{"kind":"HTTPRoute"}]}]}}`
+	if want != string(gotPatchBytes) {
+		t.Fatalf("expected patch: %s, got: %s", want, string(gotPatchBytes))
+	}
+}
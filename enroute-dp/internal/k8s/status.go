@@ -31,8 +31,8 @@ import (
 
 // GatewayHostStatus allows for updating the object's Status field
 type GatewayHostStatus struct {
-	Client clientset.Interface
-	GatewayClient *gwclientset.Clientset
+	Client        clientset.Interface
+	GatewayClient gwclientset.Interface
 }
 
 // SetStatus sets the GatewayHost status field to an Valid or Invalid status
@@ -41,6 +41,21 @@ type Metrics struct {
 	CacheHandlerOnUpdateSummary prometheus.Summary
 	ResourceEventHandlerSummary *prometheus.SummaryVec
 
+	xdsAckTotal      *prometheus.CounterVec
+	xdsNackTotal     *prometheus.CounterVec
+	xdsRejectedGauge *prometheus.GaugeVec
+
+	lrsIssuedTotal             *prometheus.CounterVec
+	lrsSuccessTotal            *prometheus.CounterVec
+	lrsErrorTotal              *prometheus.CounterVec
+	lrsInProgressGauge         *prometheus.GaugeVec
+	lrsDroppedTotal            *prometheus.CounterVec
+	lrsLoadMetricTotal         *prometheus.CounterVec
+	lrsLoadMetricRequestsTotal *prometheus.CounterVec
+	lrsReportIntervalGauge     *prometheus.GaugeVec
+
+	alsDroppedRecordsTotal prometheus.Counter
+
 	// Keep a local cache of metrics for comparison on updates
 	metricCache *GatewayHostMetric
 }
@@ -69,6 +84,21 @@ const (
 
 	cacheHandlerOnUpdateSummary = "enroute_cachehandler_onupdate_duration_seconds"
 	resourceEventHandlerSummary = "enroute_resourceeventhandler_duration_seconds"
+
+	XDSAckTotal      = "enroute_xds_ack_total"
+	XDSNackTotal     = "enroute_xds_nack_total"
+	XDSRejectedGauge = "enroute_xds_rejected_streams"
+
+	LRSIssuedTotal             = "enroute_lrs_issued_requests_total"
+	LRSSuccessTotal            = "enroute_lrs_successful_requests_total"
+	LRSErrorTotal              = "enroute_lrs_error_requests_total"
+	LRSInProgressGauge         = "enroute_lrs_requests_in_progress"
+	LRSDroppedTotal            = "enroute_lrs_dropped_requests_total"
+	LRSLoadMetricTotal         = "enroute_lrs_load_metric_total"
+	LRSLoadMetricRequestsTotal = "enroute_lrs_load_metric_requests_total"
+	LRSReportIntervalGauge     = "enroute_lrs_load_report_interval_seconds"
+
+	ALSDroppedRecordsTotal = "enroute_als_dropped_records_total"
 )
 
 // NewMetrics creates a new set of metrics and registers them with
@@ -130,6 +160,89 @@ func NewMetrics(registry *prometheus.Registry) *Metrics {
 		},
 			[]string{"op"},
 		),
+		xdsAckTotal: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: XDSAckTotal,
+				Help: "Total number of xDS responses acknowledged by Envoy",
+			},
+			[]string{"type_url"},
+		),
+		xdsNackTotal: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: XDSNackTotal,
+				Help: "Total number of xDS responses rejected by Envoy",
+			},
+			[]string{"type_url"},
+		),
+		xdsRejectedGauge: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: XDSRejectedGauge,
+				Help: "Number of xDS streams whose last response was rejected by Envoy",
+			},
+			[]string{"type_url"},
+		),
+		lrsIssuedTotal: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: LRSIssuedTotal,
+				Help: "Total number of upstream requests issued reported by Envoy",
+			},
+			[]string{"cluster", "locality"},
+		),
+		lrsSuccessTotal: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: LRSSuccessTotal,
+				Help: "Total number of upstream requests completed successfully reported by Envoy",
+			},
+			[]string{"cluster", "locality"},
+		),
+		lrsErrorTotal: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: LRSErrorTotal,
+				Help: "Total number of upstream requests completed with an error reported by Envoy",
+			},
+			[]string{"cluster", "locality"},
+		),
+		lrsInProgressGauge: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: LRSInProgressGauge,
+				Help: "Number of upstream requests in progress last reported by Envoy",
+			},
+			[]string{"cluster", "locality"},
+		),
+		lrsDroppedTotal: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: LRSDroppedTotal,
+				Help: "Total number of requests dropped by Envoy before reaching an upstream",
+			},
+			[]string{"cluster"},
+		),
+		lrsLoadMetricTotal: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: LRSLoadMetricTotal,
+				Help: "Sum of the named load metrics reported by upstreams",
+			},
+			[]string{"cluster", "locality", "metric"},
+		),
+		lrsLoadMetricRequestsTotal: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: LRSLoadMetricRequestsTotal,
+				Help: "Total number of upstream requests that reported a named load metric",
+			},
+			[]string{"cluster", "locality", "metric"},
+		),
+		lrsReportIntervalGauge: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: LRSReportIntervalGauge,
+				Help: "Interval covered by the last load report of a cluster",
+			},
+			[]string{"cluster"},
+		),
+		alsDroppedRecordsTotal: prometheus.NewCounter(
+			prometheus.CounterOpts{
+				Name: ALSDroppedRecordsTotal,
+				Help: "Total number of access log records dropped as the queue of the sinks was full",
+			},
+		),
 	}
 	m.register(registry)
 	return &m
@@ -146,9 +259,77 @@ func (m *Metrics) register(registry *prometheus.Registry) {
 		m.gatewayHostDAGRebuildGauge,
 		m.CacheHandlerOnUpdateSummary,
 		m.ResourceEventHandlerSummary,
+		m.xdsAckTotal,
+		m.xdsNackTotal,
+		m.xdsRejectedGauge,
+		m.lrsIssuedTotal,
+		m.lrsSuccessTotal,
+		m.lrsErrorTotal,
+		m.lrsInProgressGauge,
+		m.lrsDroppedTotal,
+		m.lrsLoadMetricTotal,
+		m.lrsLoadMetricRequestsTotal,
+		m.lrsReportIntervalGauge,
+		m.alsDroppedRecordsTotal,
 	)
 }
 
+// ObserveXDSAck counts a response of typeURL acknowledged by Envoy.
+func (m *Metrics) ObserveXDSAck(typeURL string) {
+	m.xdsAckTotal.WithLabelValues(typeURL).Inc()
+}
+
+// ObserveXDSNack counts a response of typeURL rejected by Envoy.
+func (m *Metrics) ObserveXDSNack(typeURL string) {
+	m.xdsNackTotal.WithLabelValues(typeURL).Inc()
+}
+
+// SetXDSRejected sets the number of streams of typeURL whose last
+// response was rejected by Envoy.
+func (m *Metrics) SetXDSRejected(typeURL string, n int) {
+	m.xdsRejectedGauge.WithLabelValues(typeURL).Set(float64(n))
+}
+
+// ObserveLoad counts the upstream requests of cluster in locality issued,
+// completed successfully and completed with an error since Envoy last
+// reported load.
+func (m *Metrics) ObserveLoad(cluster, locality string, issued, success, errors uint64) {
+	m.lrsIssuedTotal.WithLabelValues(cluster, locality).Add(float64(issued))
+	m.lrsSuccessTotal.WithLabelValues(cluster, locality).Add(float64(success))
+	m.lrsErrorTotal.WithLabelValues(cluster, locality).Add(float64(errors))
+}
+
+// SetRequestsInProgress sets the number of upstream requests of cluster in
+// locality in progress.
+func (m *Metrics) SetRequestsInProgress(cluster, locality string, n uint64) {
+	m.lrsInProgressGauge.WithLabelValues(cluster, locality).Set(float64(n))
+}
+
+// ObserveDroppedRequests counts the requests to cluster dropped since
+// Envoy last reported load.
+func (m *Metrics) ObserveDroppedRequests(cluster string, n uint64) {
+	m.lrsDroppedTotal.WithLabelValues(cluster).Add(float64(n))
+}
+
+// ObserveLoadMetric adds the total of the named load metric reported by
+// upstream requests of cluster in locality.
+func (m *Metrics) ObserveLoadMetric(cluster, locality, metric string, requests uint64, total float64) {
+	m.lrsLoadMetricRequestsTotal.WithLabelValues(cluster, locality, metric).Add(float64(requests))
+	m.lrsLoadMetricTotal.WithLabelValues(cluster, locality, metric).Add(total)
+}
+
+// SetLoadReportInterval sets the interval covered by the last load report
+// of cluster.
+func (m *Metrics) SetLoadReportInterval(cluster string, d time.Duration) {
+	m.lrsReportIntervalGauge.WithLabelValues(cluster).Set(d.Seconds())
+}
+
+// ObserveDroppedAccessLogRecords counts n access log records dropped as
+// the queue of the sinks was full.
+func (m *Metrics) ObserveDroppedAccessLogRecords(n int) {
+	m.alsDroppedRecordsTotal.Add(float64(n))
+}
+
 // SetDAGLastRebuilt records the last time the DAG was rebuilt.
 func (m *Metrics) SetDAGLastRebuilt(ts time.Time) {
 	m.gatewayHostDAGRebuildGauge.WithLabelValues().Set(float64(ts.Unix()))
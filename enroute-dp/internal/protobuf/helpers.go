@@ -15,10 +15,16 @@
 package protobuf
 
 import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
 	"time"
 
+	"github.com/golang/protobuf/proto"
 	"github.com/golang/protobuf/ptypes/duration"
 	"github.com/golang/protobuf/ptypes/wrappers"
+	protov2 "google.golang.org/protobuf/proto"
 )
 
 // Duration converts a time.Duration to a pointer to a duration.Duration.
@@ -42,3 +48,24 @@ func Bool(val bool) *wrappers.BoolValue {
 		Value: val,
 	}
 }
+
+// Marshal returns the wire encoding of m. Unlike proto.Marshal the
+// encoding is deterministic, equal messages have equal encodings.
+func Marshal(m proto.Message) ([]byte, error) {
+	if m == nil {
+		return nil, errors.New("proto: Marshal called with nil")
+	}
+	return protov2.MarshalOptions{Deterministic: true}.Marshal(proto.MessageV2(m))
+}
+
+// Hash returns a short hash of the sequence of encodings b.
+func Hash(b ...[]byte) string {
+	h := sha256.New()
+	for _, b := range b {
+		var n [8]byte
+		binary.BigEndian.PutUint64(n[:], uint64(len(b)))
+		h.Write(n[:])
+		h.Write(b)
+	}
+	return hex.EncodeToString(h.Sum(nil)[:8])
+}
@@ -0,0 +1,227 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright(c) 2018-2023 Saaras Inc.
+
+package ratelim
+
+import (
+	"context"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Entry is one key/value pair of a descriptor sent by Envoy.
+type Entry struct {
+	Key   string
+	Value string
+}
+
+// Status is the result of checking one descriptor against the configured limits.
+type Status struct {
+	// OverLimit is true if the request exceeds the limit.
+	OverLimit bool
+
+	// Limit is the limit that matched the descriptor, or nil if no limit matched.
+	Limit *RateLimitPolicy
+
+	// Remaining is the number of requests left in the bucket.
+	Remaining uint32
+
+	// DurationUntilReset is the time until the bucket has refilled completely.
+	DurationUntilReset time.Duration
+}
+
+// Backend stores the token buckets of a Limiter.
+type Backend interface {
+	// Take refills the bucket at key according to policy and removes hits
+	// tokens from it if enough are available. A bucket that does not exist
+	// yet starts full. Limit is not set on the returned Status.
+	Take(ctx context.Context, key string, policy RateLimitPolicy, hits uint32, now time.Time) (Status, error)
+}
+
+// Limiter is a token-bucket rate limiter. Each distinct descriptor value
+// that matches a configured limit gets its own bucket holding up to
+// RequestsPerUnit tokens, refilled at RequestsPerUnit per unit.
+type Limiter struct {
+	mu      sync.RWMutex
+	domains map[string]*descriptorNode
+
+	backend  Backend
+	replicas uint32
+
+	// now returns the current time, it is replaced in tests.
+	now func() time.Time
+}
+
+type descriptorNode struct {
+	limit    *RateLimitPolicy
+	children map[string]*descriptorNode
+}
+
+// NewLimiter returns a Limiter with no configured limits that keeps its
+// buckets in backend, or in memory if backend is nil.
+//
+// Buckets kept in memory are local to this process. When several replicas
+// share the load without a shared backend, replicas should be set to their
+// number so that each replica enforces its share of every limit; the
+// limit reported back to Envoy is still the configured one.
+func NewLimiter(backend Backend, replicas uint32) *Limiter {
+	if backend == nil {
+		backend = NewMemoryBackend()
+	}
+	if replicas == 0 {
+		replicas = 1
+	}
+	return &Limiter{
+		domains:  make(map[string]*descriptorNode),
+		backend:  backend,
+		replicas: replicas,
+		now:      time.Now,
+	}
+}
+
+// SetConfig replaces the configured limits. Buckets whose limit did not
+// change keep their state.
+func (l *Limiter) SetConfig(cfgs []RateLimitGlobalConfig) {
+	domains := make(map[string]*descriptorNode)
+	for _, cfg := range cfgs {
+		root, ok := domains[cfg.Domain]
+		if !ok {
+			root = &descriptorNode{}
+			domains[cfg.Domain] = root
+		}
+		addDescriptors(root, cfg.Descriptors)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.domains = domains
+}
+
+func addDescriptors(parent *descriptorNode, descriptors []RateLimitDescriptor) {
+	for _, d := range descriptors {
+		if parent.children == nil {
+			parent.children = make(map[string]*descriptorNode)
+		}
+		k := nodeKey(d.Key, d.Value)
+		n, ok := parent.children[k]
+		if !ok {
+			n = &descriptorNode{}
+			parent.children[k] = n
+		}
+		if d.RateLimit != nil {
+			rl := *d.RateLimit
+			n.limit = &rl
+		}
+		addDescriptors(n, d.Descriptors)
+	}
+}
+
+func nodeKey(key, value string) string {
+	return key + "\x00" + value
+}
+
+// lookup walks the descriptor tree of domain. An entry matches a node with
+// the same key and value first, then a node with the same key and no value.
+func (l *Limiter) lookup(domain string, entries []Entry) *RateLimitPolicy {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	n, ok := l.domains[domain]
+	if !ok || len(entries) == 0 {
+		return nil
+	}
+	for _, e := range entries {
+		next, ok := n.children[nodeKey(e.Key, e.Value)]
+		if !ok {
+			next, ok = n.children[nodeKey(e.Key, "")]
+		}
+		if !ok {
+			return nil
+		}
+		n = next
+	}
+	return n.limit
+}
+
+// ShouldRateLimit checks a descriptor of domain against the configured
+// limits and, if the request is allowed, takes hits tokens from its bucket.
+func (l *Limiter) ShouldRateLimit(ctx context.Context, domain string, entries []Entry, hits uint32) (Status, error) {
+	if hits == 0 {
+		hits = 1
+	}
+
+	policy := l.lookup(domain, entries)
+	if policy == nil {
+		return Status{}, nil
+	}
+
+	effective := *policy
+	if l.replicas > 1 {
+		effective.RequestsPerUnit /= l.replicas
+		if effective.RequestsPerUnit == 0 {
+			effective.RequestsPerUnit = 1
+		}
+	}
+
+	st, err := l.backend.Take(ctx, bucketKey(domain, entries, effective), effective, hits, l.now())
+	if err != nil {
+		return Status{}, err
+	}
+	st.Limit = policy
+	return st, nil
+}
+
+// bucketKey identifies the bucket of a descriptor. The limit is part of
+// the key so that a changed limit starts with a new, full bucket.
+func bucketKey(domain string, entries []Entry, policy RateLimitPolicy) string {
+	var sb strings.Builder
+	sb.WriteString(domain)
+	for _, e := range entries {
+		sb.WriteString("\x00")
+		sb.WriteString(e.Key)
+		sb.WriteString("\x00")
+		sb.WriteString(e.Value)
+	}
+	sb.WriteString("\x00")
+	sb.WriteString(strings.ToLower(policy.Unit))
+	sb.WriteString("\x00")
+	sb.WriteString(strconv.FormatUint(uint64(policy.RequestsPerUnit), 10))
+	return sb.String()
+}
+
+// rate returns the refill rate of policy in tokens per nanosecond.
+func rate(policy RateLimitPolicy) float64 {
+	d, _ := unitDuration(policy.Unit)
+	return float64(policy.RequestsPerUnit) / float64(d)
+}
+
+// bucketStatus returns the status of a bucket holding tokens.
+func bucketStatus(policy RateLimitPolicy, tokens float64, overLimit bool) Status {
+	st := Status{
+		OverLimit: overLimit,
+		Remaining: uint32(math.Floor(tokens)),
+	}
+	if missing := float64(policy.RequestsPerUnit) - tokens; missing > 0 {
+		st.DurationUntilReset = time.Duration(math.Ceil(missing / rate(policy)))
+	}
+	return st
+}
+
+// unitDuration returns the duration of a rate limit unit.
+func unitDuration(unit string) (time.Duration, bool) {
+	switch strings.ToLower(unit) {
+	case "second":
+		return time.Second, true
+	case "minute":
+		return time.Minute, true
+	case "hour":
+		return time.Hour, true
+	case "day":
+		return 24 * time.Hour, true
+	default:
+		return 0, false
+	}
+}
@@ -0,0 +1,283 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright(c) 2018-2023 Saaras Inc.
+
+package ratelim
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/google/go-cmp/cmp"
+	"github.com/redis/go-redis/v9"
+)
+
+const testConfig = `{
+  "domain": "enroute",
+  "descriptors": [
+    {
+      "key": "remote_address",
+      "rate_limit": {"unit": "second", "requests_per_unit": 2}
+    },
+    {
+      "key": "generic_key",
+      "value": "default_route",
+      "rate_limit": {"unit": "minute", "requests_per_unit": 60},
+      "descriptors": [
+        {"key": "x-forwarded-proto", "value": "http", "rate_limit": {"unit": "hour", "requests_per_unit": 1}}
+      ]
+    }
+  ]
+}`
+
+func newTestLimiter(t *testing.T, now *time.Time) *Limiter {
+	t.Helper()
+	return newTestLimiterWithBackend(t, now, nil, 1)
+}
+
+func newTestLimiterWithBackend(t *testing.T, now *time.Time, backend Backend, replicas uint32) *Limiter {
+	t.Helper()
+	cfg, err := UnmarshalRateLimitGlobalConfig(testConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := NewLimiter(backend, replicas)
+	l.now = func() time.Time { return *now }
+	l.SetConfig([]RateLimitGlobalConfig{cfg})
+	return l
+}
+
+func TestLimiterLookup(t *testing.T) {
+	now := time.Unix(1000, 0)
+	l := newTestLimiter(t, &now)
+
+	tests := map[string]struct {
+		domain  string
+		entries []Entry
+		want    *RateLimitPolicy
+	}{
+		"wildcard value": {
+			domain:  "enroute",
+			entries: []Entry{{Key: "remote_address", Value: "10.0.0.1"}},
+			want:    &RateLimitPolicy{Unit: "second", RequestsPerUnit: 2},
+		},
+		"exact value": {
+			domain:  "enroute",
+			entries: []Entry{{Key: "generic_key", Value: "default_route"}},
+			want:    &RateLimitPolicy{Unit: "minute", RequestsPerUnit: 60},
+		},
+		"nested": {
+			domain:  "enroute",
+			entries: []Entry{{Key: "generic_key", Value: "default_route"}, {Key: "x-forwarded-proto", Value: "http"}},
+			want:    &RateLimitPolicy{Unit: "hour", RequestsPerUnit: 1},
+		},
+		"value mismatch": {
+			domain:  "enroute",
+			entries: []Entry{{Key: "generic_key", Value: "other"}},
+		},
+		"unknown domain": {
+			domain:  "other",
+			entries: []Entry{{Key: "remote_address", Value: "10.0.0.1"}},
+		},
+		"longer than config": {
+			domain:  "enroute",
+			entries: []Entry{{Key: "remote_address", Value: "10.0.0.1"}, {Key: "path", Value: "/"}},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			st, err := l.ShouldRateLimit(context.TODO(), tc.domain, tc.entries, 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := st.Limit
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Fatalf("expected:\n%v\ngot:\n%v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestLimiterTokenBucket(t *testing.T) {
+	mr := miniredis.RunT(t)
+
+	backends := map[string]Backend{
+		"memory": NewMemoryBackend(),
+		"redis":  NewRedisBackend(redis.NewClient(&redis.Options{Addr: mr.Addr()})),
+	}
+
+	for name, backend := range backends {
+		t.Run(name, func(t *testing.T) {
+			now := time.Unix(1000, 0)
+			l := newTestLimiterWithBackend(t, &now, backend, 1)
+
+			client1 := []Entry{{Key: "remote_address", Value: "10.0.0.1"}}
+			client2 := []Entry{{Key: "remote_address", Value: "10.0.0.2"}}
+
+			check := func(entries []Entry, want result) {
+				t.Helper()
+				checkLimit(t, l, entries, want)
+			}
+
+			check(client1, result{false, 1, 500 * time.Millisecond})
+			check(client1, result{false, 0, time.Second})
+			check(client1, result{true, 0, time.Second})
+
+			// each value has its own bucket
+			check(client2, result{false, 1, 500 * time.Millisecond})
+
+			// half a second refills one token
+			now = now.Add(500 * time.Millisecond)
+			check(client1, result{false, 0, time.Second})
+			check(client1, result{true, 0, time.Second})
+
+			// a bucket never holds more than requests_per_unit tokens
+			now = now.Add(time.Hour)
+			check(client1, result{false, 1, 500 * time.Millisecond})
+		})
+	}
+}
+
+type result struct {
+	OverLimit          bool
+	Remaining          uint32
+	DurationUntilReset time.Duration
+}
+
+func checkLimit(t *testing.T, l *Limiter, entries []Entry, want result) {
+	t.Helper()
+	st, err := l.ShouldRateLimit(context.TODO(), "enroute", entries, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := result{st.OverLimit, st.Remaining, st.DurationUntilReset}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Fatal(diff)
+	}
+}
+
+func TestLimiterSharedRedis(t *testing.T) {
+	mr := miniredis.RunT(t)
+	now := time.Unix(1000, 0)
+
+	// two replicas sharing a Redis server share their buckets
+	replica1 := newTestLimiterWithBackend(t, &now, NewRedisBackend(redis.NewClient(&redis.Options{Addr: mr.Addr()})), 1)
+	replica2 := newTestLimiterWithBackend(t, &now, NewRedisBackend(redis.NewClient(&redis.Options{Addr: mr.Addr()})), 1)
+
+	entries := []Entry{{Key: "remote_address", Value: "10.0.0.1"}}
+	checkLimit(t, replica1, entries, result{false, 1, 500 * time.Millisecond})
+	checkLimit(t, replica2, entries, result{false, 0, time.Second})
+	checkLimit(t, replica1, entries, result{true, 0, time.Second})
+
+	// buckets expire once they are full again
+	if ttl := mr.TTL(redisKeyPrefix + bucketKey("enroute", entries, RateLimitPolicy{Unit: "second", RequestsPerUnit: 2})); ttl != time.Second {
+		t.Fatalf("expected ttl of 1s, got %v", ttl)
+	}
+
+	mr.Close()
+	if _, err := replica1.ShouldRateLimit(context.TODO(), "enroute", entries, 1); err == nil {
+		t.Fatal("expected error with redis down")
+	}
+}
+
+func TestRedisBackendNoRefill(t *testing.T) {
+	mr := miniredis.RunT(t)
+	backend := NewRedisBackend(redis.NewClient(&redis.Options{Addr: mr.Addr()}))
+
+	policy := RateLimitPolicy{Unit: "second", RequestsPerUnit: 0}
+	st, err := backend.Take(context.TODO(), "k", policy, 1, time.Unix(1000, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !st.OverLimit || st.Remaining != 0 || st.DurationUntilReset != 0 {
+		t.Fatalf("expected over limit without reset, got %+v", st)
+	}
+	if mr.Exists(redisKeyPrefix + "k") {
+		t.Fatal("expected no bucket to be stored")
+	}
+}
+
+func TestLimiterReplicas(t *testing.T) {
+	now := time.Unix(1000, 0)
+
+	// the per minute limit of 60 is split between 3 replicas
+	l := newTestLimiterWithBackend(t, &now, nil, 3)
+	entries := []Entry{{Key: "generic_key", Value: "default_route"}}
+	for i := 0; i < 20; i++ {
+		st, err := l.ShouldRateLimit(context.TODO(), "enroute", entries, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if st.OverLimit {
+			t.Fatalf("request %d: unexpected over limit", i)
+		}
+		if st.Limit.RequestsPerUnit != 60 {
+			t.Fatalf("expected configured limit, got %v", st.Limit)
+		}
+	}
+	st, _ := l.ShouldRateLimit(context.TODO(), "enroute", entries, 1)
+	if !st.OverLimit {
+		t.Fatalf("expected over limit, got %+v", st)
+	}
+
+	// a replica never gets less than one request per unit
+	entries = []Entry{{Key: "generic_key", Value: "default_route"}, {Key: "x-forwarded-proto", Value: "http"}}
+	checkLimit(t, l, entries, result{false, 0, time.Hour})
+	checkLimit(t, l, entries, result{true, 0, time.Hour})
+}
+
+func TestLimiterSetConfig(t *testing.T) {
+	now := time.Unix(1000, 0)
+	l := newTestLimiter(t, &now)
+
+	entries := []Entry{{Key: "remote_address", Value: "10.0.0.1"}}
+	l.ShouldRateLimit(context.TODO(), "enroute", entries, 2)
+	if st, _ := l.ShouldRateLimit(context.TODO(), "enroute", entries, 1); !st.OverLimit {
+		t.Fatalf("expected over limit, got %+v", st)
+	}
+
+	// an unchanged limit keeps its bucket
+	cfg, _ := UnmarshalRateLimitGlobalConfig(testConfig)
+	l.SetConfig([]RateLimitGlobalConfig{cfg})
+	if st, _ := l.ShouldRateLimit(context.TODO(), "enroute", entries, 1); !st.OverLimit {
+		t.Fatalf("expected over limit, got %+v", st)
+	}
+
+	// a changed limit starts with a full bucket
+	cfg.Descriptors[0].RateLimit = &RateLimitPolicy{Unit: "second", RequestsPerUnit: 5}
+	l.SetConfig([]RateLimitGlobalConfig{cfg})
+	if st, _ := l.ShouldRateLimit(context.TODO(), "enroute", entries, 1); st.OverLimit || st.Remaining != 4 {
+		t.Fatalf("expected 4 remaining, got %+v", st)
+	}
+
+	// removing the config removes the limit
+	l.SetConfig(nil)
+	if st, _ := l.ShouldRateLimit(context.TODO(), "enroute", entries, 1); st.Limit != nil {
+		t.Fatalf("expected no limit, got %+v", st)
+	}
+}
+
+func TestUnmarshalRateLimitGlobalConfig(t *testing.T) {
+	tests := map[string]struct {
+		config  string
+		wantErr bool
+	}{
+		"valid":              {config: testConfig},
+		"invalid":            {config: `{"domain":`, wantErr: true},
+		"bad unit":           {config: `{"domain":"d","descriptors":[{"key":"k","rate_limit":{"unit":"week","requests_per_unit":1}}]}`, wantErr: true},
+		"missing key":        {config: `{"domain":"d","descriptors":[{"value":"v"}]}`, wantErr: true},
+		"no requests":        {config: `{"domain":"d","descriptors":[{"key":"k","rate_limit":{"unit":"second","requests_per_unit":0}}]}`, wantErr: true},
+		"nested no requests": {config: `{"domain":"d","descriptors":[{"key":"k","descriptors":[{"key":"n","rate_limit":{"unit":"second"}}]}]}`, wantErr: true},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := UnmarshalRateLimitGlobalConfig(tc.config)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("expected error: %v, got: %v", tc.wantErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,78 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright(c) 2018-2023 Saaras Inc.
+
+package ratelim
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// sweepInterval is how often buckets that have refilled completely are
+// dropped, so that per-value buckets (e.g. one per client address) do
+// not accumulate forever.
+const sweepInterval = time.Minute
+
+// MemoryBackend keeps token buckets in process memory.
+type MemoryBackend struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+type bucket struct {
+	policy RateLimitPolicy
+	tokens float64
+	last   time.Time
+}
+
+// NewMemoryBackend returns an empty MemoryBackend.
+func NewMemoryBackend() *MemoryBackend {
+	return &MemoryBackend{
+		buckets: make(map[string]*bucket),
+	}
+}
+
+func (m *MemoryBackend) Take(ctx context.Context, key string, policy RateLimitPolicy, hits uint32, now time.Time) (Status, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.sweep(now)
+
+	b, ok := m.buckets[key]
+	if !ok {
+		b = &bucket{policy: policy, tokens: float64(policy.RequestsPerUnit), last: now}
+		m.buckets[key] = b
+	}
+	b.refill(now)
+
+	overLimit := b.tokens < float64(hits)
+	if !overLimit {
+		b.tokens -= float64(hits)
+	}
+	return bucketStatus(b.policy, b.tokens, overLimit), nil
+}
+
+// sweep drops buckets that would be full by now; a new full bucket is
+// created for them on the next request.
+func (m *MemoryBackend) sweep(now time.Time) {
+	if now.Sub(m.lastSweep) < sweepInterval {
+		return
+	}
+	m.lastSweep = now
+	for k, b := range m.buckets {
+		b.refill(now)
+		if b.tokens >= float64(b.policy.RequestsPerUnit) {
+			delete(m.buckets, k)
+		}
+	}
+}
+
+func (b *bucket) refill(now time.Time) {
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens = math.Min(float64(b.policy.RequestsPerUnit), b.tokens+float64(elapsed)*rate(b.policy))
+	}
+	b.last = now
+}
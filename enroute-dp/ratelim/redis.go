@@ -0,0 +1,99 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright(c) 2018-2023 Saaras Inc.
+
+package ratelim
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/redis/go-redis/v9"
+)
+
+// redisKeyPrefix is prepended to all bucket keys stored in Redis.
+const redisKeyPrefix = "enroute_ratelimit:"
+
+// takeScript refills and takes from a bucket atomically. Buckets are
+// hashes holding the number of tokens and the time of the last refill in
+// milliseconds. A bucket expires once it would be full again. A bucket
+// that is never refilled is always over the limit and is not stored.
+//
+// KEYS[1] bucket key
+// ARGV[1] capacity, ARGV[2] tokens per millisecond, ARGV[3] now in
+// milliseconds, ARGV[4] hits
+var takeScript = redis.NewScript(`
+local capacity = tonumber(ARGV[1])
+local rate = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local hits = tonumber(ARGV[4])
+
+if rate <= 0 then
+  return {1, '0'}
+end
+
+local b = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(b[1])
+local ts = tonumber(b[2])
+if tokens == nil or ts == nil then
+  tokens = capacity
+  ts = now
+end
+if now > ts then
+  tokens = math.min(capacity, tokens + (now - ts) * rate)
+  ts = now
+end
+
+local over = 0
+if tokens >= hits then
+  tokens = tokens - hits
+else
+  over = 1
+end
+
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', tostring(ts))
+local ttl = math.ceil((capacity - tokens) / rate)
+if ttl < 1 then
+  ttl = 1
+end
+redis.call('PEXPIRE', KEYS[1], ttl)
+
+return {over, tostring(tokens)}
+`)
+
+// RedisBackend keeps token buckets in Redis so that all replicas sharing
+// the Redis server enforce limits together. The time used to refill buckets
+// is the time of the replica, replica clocks are expected to be in sync.
+type RedisBackend struct {
+	client redis.UniversalClient
+}
+
+// NewRedisBackend returns a RedisBackend that stores buckets using client.
+func NewRedisBackend(client redis.UniversalClient) *RedisBackend {
+	return &RedisBackend{client: client}
+}
+
+func (r *RedisBackend) Take(ctx context.Context, key string, policy RateLimitPolicy, hits uint32, now time.Time) (Status, error) {
+	perMilli := rate(policy) * float64(time.Millisecond)
+	res, err := takeScript.Run(ctx, r.client, []string{redisKeyPrefix + key},
+		policy.RequestsPerUnit,
+		strconv.FormatFloat(perMilli, 'g', -1, 64),
+		now.UnixMilli(),
+		hits,
+	).Slice()
+	if err != nil {
+		return Status{}, errors.Wrap(err, "ratelimit redis")
+	}
+	if len(res) != 2 {
+		return Status{}, errors.Errorf("ratelimit redis: unexpected reply %v", res)
+	}
+
+	over, _ := res[0].(int64)
+	s, _ := res[1].(string)
+	tokens, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return Status{}, errors.Wrap(err, "ratelimit redis")
+	}
+	return bucketStatus(policy, tokens, over == 1), nil
+}
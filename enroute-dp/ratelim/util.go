@@ -1,10 +1,86 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright(c) 2018-2023 Saaras Inc.
+
 package ratelim
 
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// RateLimitGlobalConfig is the rate limit configuration carried by a
+// globalconfig_ratelimit GlobalConfig. It uses the same format as the
+// envoyproxy/ratelimit service configuration.
 type RateLimitGlobalConfig struct {
-	Domain string
+	Domain      string                `json:"domain"`
+	Descriptors []RateLimitDescriptor `json:"descriptors,omitempty"`
+}
+
+// RateLimitDescriptor matches one entry of a descriptor sent by Envoy.
+// A descriptor without a Value matches any value of Key.
+type RateLimitDescriptor struct {
+	Key         string                `json:"key"`
+	Value       string                `json:"value,omitempty"`
+	RateLimit   *RateLimitPolicy      `json:"rate_limit,omitempty"`
+	Descriptors []RateLimitDescriptor `json:"descriptors,omitempty"`
+}
+
+// RateLimitPolicy is the limit applied to a matching descriptor.
+type RateLimitPolicy struct {
+	Unit            string `json:"unit"`
+	RequestsPerUnit uint32 `json:"requests_per_unit"`
 }
 
 func UnmarshalRateLimitGlobalConfig(config_string string) (RateLimitGlobalConfig, error) {
 	var cfg RateLimitGlobalConfig
-	return cfg, nil
+	var err error
+
+	buf := strings.NewReader(config_string)
+	if err = json.NewDecoder(buf).Decode(&cfg); err != nil {
+		return cfg, errors.Wrap(err, "decoding ratelimit config")
+	}
+
+	return cfg, validateDescriptors(cfg.Descriptors)
+}
+
+// UnmarshalRateLimitGlobalConfigs decodes a JSON array of rate limit configs,
+// as sent by the GlobalConfigTranslator to the rate limit service.
+func UnmarshalRateLimitGlobalConfigs(config_string string) ([]RateLimitGlobalConfig, error) {
+	var cfgs []RateLimitGlobalConfig
+	var err error
+
+	buf := strings.NewReader(config_string)
+	if err = json.NewDecoder(buf).Decode(&cfgs); err != nil {
+		return nil, errors.Wrap(err, "decoding ratelimit configs")
+	}
+
+	for _, cfg := range cfgs {
+		if err = validateDescriptors(cfg.Descriptors); err != nil {
+			return nil, err
+		}
+	}
+
+	return cfgs, nil
+}
+
+func validateDescriptors(descriptors []RateLimitDescriptor) error {
+	for _, d := range descriptors {
+		if d.Key == "" {
+			return errors.New("ratelimit descriptor has no key")
+		}
+		if d.RateLimit != nil {
+			if _, ok := unitDuration(d.RateLimit.Unit); !ok {
+				return errors.Errorf("ratelimit descriptor %q has invalid unit %q", d.Key, d.RateLimit.Unit)
+			}
+			if d.RateLimit.RequestsPerUnit == 0 {
+				return errors.Errorf("ratelimit descriptor %q must allow at least one request per unit", d.Key)
+			}
+		}
+		if err := validateDescriptors(d.Descriptors); err != nil {
+			return err
+		}
+	}
+	return nil
 }
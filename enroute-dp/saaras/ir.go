@@ -454,17 +454,69 @@ func saaras_routecondition_to_v1b1_ir_routecondition(r SaarasRoute2) []v1.Condit
 		conds = append(conds, cond2)
 	}
 
+	if saarasRouteCond.Method != "" {
+		conds = append(conds, v1.Condition{Method: saarasRouteCond.Method})
+	}
+
+	for _, qp := range saarasRouteCond.QueryParams {
+		conds = append(conds, v1.Condition{
+			QueryParameter: &v1.QueryParameterCondition{
+				Name:       qp.Name,
+				Exact:      qp.Exact,
+				Prefix:     qp.Prefix,
+				Regex:      qp.Regex,
+				Present:    qp.Present,
+				NotPresent: qp.NotPresent,
+			},
+		})
+	}
+
 	return conds
 }
 
+func saaras_route_headers__to__v1b1_headers_policy(hc *cfg.RouteHeadersConfig) *v1.HeadersPolicy {
+	if hc == nil {
+		return nil
+	}
+
+	hp := &v1.HeadersPolicy{
+		Remove: hc.Remove,
+	}
+	for _, h := range hc.Set {
+		hp.Set = append(hp.Set, v1.HeaderValue{Name: h.Name, Value: h.Value})
+	}
+	for _, h := range hc.Add {
+		hp.Add = append(hp.Add, v1.HeaderValue{Name: h.Name, Value: h.Value})
+	}
+	return hp
+}
+
+// Headers to manage are provided in Route_config, even if Route_prefix is populated
+func saaras_route_config__to__v1b1_headers_policies(r SaarasRoute2) (*v1.HeadersPolicy, *v1.HeadersPolicy) {
+	if len(r.Route_config) == 0 {
+		return nil, nil
+	}
+
+	saarasRouteCond, err := cfg.UnmarshalRouteMatchCondition(r.Route_config)
+	if err != nil {
+		return nil, nil
+	}
+
+	return saaras_route_headers__to__v1b1_headers_policy(saarasRouteCond.RequestHeaders),
+		saaras_route_headers__to__v1b1_headers_policy(saarasRouteCond.ResponseHeaders)
+}
+
 func Saaras_ir__to__v1b1_ir2(sir *SaarasGatewayHostService) *v1.GatewayHost {
 	routes := make([]v1.Route, 0)
 	for _, oneRoute := range sir.Service.Routes {
+		requestHeadersPolicy, responseHeadersPolicy := saaras_route_config__to__v1b1_headers_policies(oneRoute)
 		routes = append(routes, v1.Route{
 
-			Conditions: saaras_routecondition_to_v1b1_ir_routecondition(oneRoute),
-			Services:   saaras_route_to_v1b1_service_slice2(sir, oneRoute),
-			Filters:    saaras_ir_route_filter__to__v1b1_route_filter(oneRoute),
+			Conditions:            saaras_routecondition_to_v1b1_ir_routecondition(oneRoute),
+			Services:              saaras_route_to_v1b1_service_slice2(sir, oneRoute),
+			Filters:               saaras_ir_route_filter__to__v1b1_route_filter(oneRoute),
+			RequestHeadersPolicy:  requestHeadersPolicy,
+			ResponseHeadersPolicy: responseHeadersPolicy,
 		})
 	}
 	return &v1.GatewayHost{
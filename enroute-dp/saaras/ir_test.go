@@ -30,6 +30,24 @@ func TestConvertPathRouteMatchToDagRouteMatch(t *testing.T) {
 	irCond4 := ir.Condition{Prefix: "/test4"}
 	irCond5 := ir.Condition{Header: &ir.HeaderCondition{Name: ":method", Exact: "GET"}}
 
+	saarasr5 := SaarasRoute2{Route_config: `{"prefix" : "/test5", "method" : "POST"}`}
+	irCond5b := ir.Condition{Prefix: "/test5"}
+	irCond5c := ir.Condition{Method: "POST"}
+
+	saarasr6 := SaarasRoute2{Route_config: `
+    {
+        "Prefix" : "/test6",
+        "query_params":
+        [
+          { "name": "version", "exact" : "2" },
+          { "name": "beta", "notpresent" : true }
+        ]
+    }
+    `}
+	irCond6 := ir.Condition{Prefix: "/test6"}
+	irCond7 := ir.Condition{QueryParameter: &ir.QueryParameterCondition{Name: "version", Exact: "2"}}
+	irCond8 := ir.Condition{QueryParameter: &ir.QueryParameterCondition{Name: "beta", NotPresent: true}}
+
 	tests := map[string]struct {
 		route SaarasRoute2
 		want  []ir.Condition
@@ -50,6 +68,14 @@ func TestConvertPathRouteMatchToDagRouteMatch(t *testing.T) {
 			route: saarasr4,
 			want:  []ir.Condition{irCond4, irCond5},
 		},
+		"Prefix and Method condition": {
+			route: saarasr5,
+			want:  []ir.Condition{irCond5b, irCond5c},
+		},
+		"Prefix and Query Parameters": {
+			route: saarasr6,
+			want:  []ir.Condition{irCond6, irCond7, irCond8},
+		},
 	}
 
 	for name, tc := range tests {
@@ -59,3 +85,50 @@ func TestConvertPathRouteMatchToDagRouteMatch(t *testing.T) {
 		})
 	}
 }
+
+func TestConvertRouteConfigToHeadersPolicies(t *testing.T) {
+	tests := map[string]struct {
+		route        SaarasRoute2
+		wantRequest  *ir.HeadersPolicy
+		wantResponse *ir.HeadersPolicy
+	}{
+		"No route config": {
+			route: SaarasRoute2{Route_prefix: "/"},
+		},
+		"Route config without headers": {
+			route: SaarasRoute2{Route_config: `{"Prefix" : "/"}`},
+		},
+		"Request and response headers": {
+			route: SaarasRoute2{Route_prefix: "/", Route_config: `
+    {
+        "request_headers":
+        {
+          "set": [ { "name": "x-env", "value": "prod" } ],
+          "add": [ { "name": "x-trace", "value": "%REQ(x-request-id)%" } ],
+          "remove": [ "x-debug" ]
+        },
+        "response_headers":
+        {
+          "remove": [ "server" ]
+        }
+    }
+    `},
+			wantRequest: &ir.HeadersPolicy{
+				Set:    []ir.HeaderValue{{Name: "x-env", Value: "prod"}},
+				Add:    []ir.HeaderValue{{Name: "x-trace", Value: "%REQ(x-request-id)%"}},
+				Remove: []string{"x-debug"},
+			},
+			wantResponse: &ir.HeadersPolicy{
+				Remove: []string{"server"},
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			gotRequest, gotResponse := saaras_route_config__to__v1b1_headers_policies(tc.route)
+			assert.Equal(t, tc.wantRequest, gotRequest)
+			assert.Equal(t, tc.wantResponse, gotResponse)
+		})
+	}
+}
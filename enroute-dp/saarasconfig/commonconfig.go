@@ -50,6 +50,7 @@ const FILTER_TYPE_CUSTOM_RESPONSE = "custom_response"
 const FILTER_TYPE_VH_LUA string = "vh_filter_lua"
 const FILTER_TYPE_VH_CORS string = "vh_filter_cors"
 const FILTER_TYPE_VH_RBAC string = "vh_filter_rbac"
+const FILTER_TYPE_VH_LOCAL_RATELIMIT string = "vh_filter_localratelimit"
 
 // Route Filters
 const FILTER_TYPE_RT_RATELIMIT string = "route_filter_ratelimit"
@@ -58,10 +59,14 @@ const FILTER_TYPE_RT_OUTLIERDETECTION string = "route_filter_outlierdetection"
 const FILTER_TYPE_RT_HOST_REWRITE string = "route_filter_host_rewrite"
 const FILTER_TYPE_RT_REDIRECT string = "route_filter_redirect"
 const FILTER_TYPE_RT_DIRECTRESPONSE string = "route_filter_directreponse"
+const FILTER_TYPE_RT_TRACING string = "route_filter_tracing"
+const FILTER_TYPE_RT_MIRROR string = "route_filter_mirror"
 
 const PROXY_CONFIG_RATELIMIT string = "globalconfig_ratelimit"
 const PROXY_CONFIG_ACCESSLOG string = "globalconfig_accesslog"
 const PROXY_CONFIG_GLOBALS string = "globalconfig_globals"
+const PROXY_CONFIG_TRACING string = "globalconfig_tracing"
+const PROXY_CONFIG_LOCALREPLY string = "globalconfig_localreply"
 
 const JAEGER_TRACING_CLUSTER string = "jaeger-trace"
 const EDS_CONFIG_CLUSTER string = "contour"
@@ -150,8 +155,63 @@ func UnmarshalRateLimitRouteFilterConfig(filter_config string) (RouteActionDescr
 }
 
 type RouteMatchConditions struct {
-	Prefix          string                `json:"prefix"`
-	MatchConditions []RouteMatchCondition `json:"header"`
+	Prefix          string                 `json:"prefix"`
+	MatchConditions []RouteMatchCondition  `json:"header"`
+	Method          string                 `json:"method,omitempty"`
+	QueryParams     []RouteMatchQueryParam `json:"query_params,omitempty"`
+	RequestHeaders  *RouteHeadersConfig    `json:"request_headers,omitempty"`
+	ResponseHeaders *RouteHeadersConfig    `json:"response_headers,omitempty"`
+}
+
+// RouteHeaderValue is a header name/value pair. Value can use the command
+// operators of Envoy, e.g. %DOWNSTREAM_REMOTE_ADDRESS_WITHOUT_PORT%.
+type RouteHeaderValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// RouteHeadersConfig defines how the headers of a route are managed during
+// forwarding. Set replaces the value of a header, Add appends a value to a
+// header and Remove removes headers.
+type RouteHeadersConfig struct {
+	Set    []RouteHeaderValue `json:"set,omitempty"`
+	Add    []RouteHeaderValue `json:"add,omitempty"`
+	Remove []string           `json:"remove,omitempty"`
+}
+
+// RouteMatchQueryParam matches a query parameter of a request. Only one of
+// Exact, Prefix, Regex, Present or NotPresent must be provided.
+type RouteMatchQueryParam struct {
+	// Name is the name of the query parameter to match on. Name is required.
+	Name string `json:"name"`
+
+	// Exact matches the value of the query parameter exactly.
+	// +optional
+	Exact string `json:"exact,omitempty"`
+
+	// Prefix matches the start of the value of the query parameter.
+	// +optional
+	Prefix string `json:"prefix,omitempty"`
+
+	// Regex matches the value of the query parameter by regular expression.
+	// +optional
+	Regex string `json:"regex,omitempty"`
+
+	// Present is true if the query parameter is present in the request.
+	// +optional
+	Present bool `json:"present,omitempty"`
+
+	// NotPresent is true if the query parameter is not present in the request.
+	// +optional
+	NotPresent bool `json:"notpresent,omitempty"`
+}
+
+type RouteMatchQueryParamsByName []RouteMatchQueryParam
+
+func (l RouteMatchQueryParamsByName) Len() int      { return len(l) }
+func (l RouteMatchQueryParamsByName) Swap(i, j int) { l[i], l[j] = l[j], l[i] }
+func (l RouteMatchQueryParamsByName) Less(i, j int) bool {
+	return l[i].Name+l[i].Exact+l[i].Prefix+l[i].Regex < l[j].Name+l[j].Exact+l[j].Prefix+l[j].Regex
 }
 
 type RouteMatchConditionsByHeaderNameVal []RouteMatchCondition
@@ -274,8 +334,385 @@ func UnmarshalOutlierDetection(cc_config string) (OutlierDetectionConfig, error)
 	return cbc, err
 }
 
+// JwtClaimToHeader copies the value of a claim of a verified JWT to a
+// request header.
+type JwtClaimToHeader struct {
+	HeaderName string `json:"header_name"`
+	ClaimName  string `json:"claim_name"`
+}
+
+// JwtRule selects the requests a JWT filter applies to by path. Requests
+// matching a rule with Bypass set are not verified by any JWT filter of
+// the virtual host.
+type JwtRule struct {
+	// +optional
+	Prefix string `json:"prefix,omitempty"`
+
+	// +optional
+	Path string `json:"path,omitempty"`
+
+	// +optional
+	Bypass bool `json:"bypass,omitempty"`
+}
+
+// https://www.envoyproxy.io/docs/envoy/latest/api-v3/extensions/filters/http/jwt_authn/v3/config.proto
+type JwtConfig struct {
+	// Name is the name of the JWT provider.
+	Name string `json:"name"`
+
+	// +optional
+	Issuer string `json:"issuer,omitempty"`
+
+	// Audience and Audiences are the audiences allowed to access,
+	// any audience is allowed if both are empty.
+	// +optional
+	Audience string `json:"audience,omitempty"`
+
+	// +optional
+	Audiences []string `json:"audiences,omitempty"`
+
+	// JwksUri is fetched from the service of the filter.
+	// +optional
+	JwksUri string `json:"jwks_uri,omitempty"`
+
+	// CacheDuration is how long, in seconds, a remote JWKS is cached.
+	// +optional
+	CacheDuration uint32 `json:"cache_duration,omitempty"`
+
+	// Timeout is how long, in seconds, to wait for a remote JWKS.
+	// +optional
+	Timeout uint32 `json:"timeout,omitempty"`
+
+	// LocalJwks is an inline JWKS, used instead of JwksUri.
+	// +optional
+	LocalJwks string `json:"local_jwks,omitempty"`
+
+	// Forward keeps the JWT in the request forwarded upstream.
+	// +optional
+	Forward bool `json:"forward,omitempty"`
+
+	// Jwt_forward_header_name is the header in which the payload of a
+	// verified JWT is forwarded upstream.
+	// +optional
+	Jwt_forward_header_name string `json:"jwt_forward_header_name,omitempty"`
+
+	// +optional
+	ClaimToHeaders []JwtClaimToHeader `json:"claim_to_headers,omitempty"`
+
+	// Route holds the rules selecting the requests to verify, all
+	// requests are verified if empty.
+	// +optional
+	Route []JwtRule `json:"route,omitempty"`
+}
+
+func UnmarshalJwtConfig(jwt_config string) (JwtConfig, error) {
+	var jc JwtConfig
+	var err error
+
+	buf := strings.NewReader(jwt_config)
+	if err = json.NewDecoder(buf).Decode(&jc); err != nil {
+		errors.Wrap(err, "error decoding response")
+	}
+
+	return jc, err
+}
+
+// HeaderMatch matches a request header, by one of Exact, Prefix, Regex
+// or Present.
+type HeaderMatch struct {
+	Name string `json:"name"`
+
+	// +optional
+	Exact string `json:"exact,omitempty"`
+
+	// +optional
+	Prefix string `json:"prefix,omitempty"`
+
+	// +optional
+	Regex string `json:"regex,omitempty"`
+
+	// +optional
+	Present bool `json:"present,omitempty"`
+
+	// Invert matches requests the header does not match.
+	// +optional
+	Invert bool `json:"invert,omitempty"`
+}
+
+// RbacPathMatch matches the path of a request by Prefix or exactly by Path.
+type RbacPathMatch struct {
+	// +optional
+	Prefix string `json:"prefix,omitempty"`
+
+	// +optional
+	Path string `json:"path,omitempty"`
+}
+
+// RbacJwtClaim matches a claim of a JWT verified by the JWT filter whose
+// provider is named Provider.
+type RbacJwtClaim struct {
+	Provider string `json:"provider"`
+	Claim    string `json:"claim"`
+	Value    string `json:"value"`
+}
+
+// RbacPolicy matches a request if every one of its matchers that is set
+// matches, a matcher holding a list matches if any item of it does.
+type RbacPolicy struct {
+	Name string `json:"name"`
+
+	// SourceCidrs match the client address, eg: 10.0.0.0/8 or 192.168.1.1
+	// +optional
+	SourceCidrs []string `json:"source_cidrs,omitempty"`
+
+	// Principals match the principal of an authenticated client
+	// certificate, its URI or DNS SAN or its subject.
+	// +optional
+	Principals []string `json:"principals,omitempty"`
+
+	// JwtClaims must all match.
+	// +optional
+	JwtClaims []RbacJwtClaim `json:"jwt_claims,omitempty"`
+
+	// Headers must all match.
+	// +optional
+	Headers []HeaderMatch `json:"headers,omitempty"`
+
+	// +optional
+	Paths []RbacPathMatch `json:"paths,omitempty"`
+
+	// +optional
+	Methods []string `json:"methods,omitempty"`
+}
+
+// RbacConfig is the config of an RBAC filter. With the ALLOW action only
+// requests matching a policy are allowed, with DENY requests matching a
+// policy are denied.
+// https://www.envoyproxy.io/docs/envoy/latest/api-v3/config/rbac/v3/rbac.proto
+type RbacConfig struct {
+	// Action is ALLOW or DENY, defaults to ALLOW.
+	// +optional
+	Action string `json:"action,omitempty"`
+
+	// Shadow only logs and counts the requests the policies would allow
+	// or deny without enforcing them.
+	// +optional
+	Shadow bool `json:"shadow,omitempty"`
+
+	// Disabled, on a route, turns off the RBAC filter of its virtual host.
+	// +optional
+	Disabled bool `json:"disabled,omitempty"`
+
+	// +optional
+	Policies []RbacPolicy `json:"policies,omitempty"`
+}
+
+func UnmarshalRbacConfig(rbac_config string) (RbacConfig, error) {
+	var rc RbacConfig
+	var err error
+
+	buf := strings.NewReader(rbac_config)
+	if err = json.NewDecoder(buf).Decode(&rc); err != nil {
+		errors.Wrap(err, "decoding response")
+	}
+
+	return rc, err
+}
+
+// LocalRateLimitTokenBucket holds up to MaxTokens tokens, TokensPerFill are
+// added every FillInterval. A request takes a token, it is rate limited if
+// there is none left.
+type LocalRateLimitTokenBucket struct {
+	MaxTokens uint32 `json:"max_tokens"`
+
+	// TokensPerFill defaults to MaxTokens.
+	// +optional
+	TokensPerFill uint32 `json:"tokens_per_fill,omitempty"`
+
+	// FillInterval is a duration, eg: 1s or 1m, defaults to 1s.
+	// +optional
+	FillInterval string `json:"fill_interval,omitempty"`
+}
+
+type LocalRateLimitDescriptorEntry struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// LocalRateLimitDescriptor is the token bucket of the requests whose
+// actions produce Entries.
+type LocalRateLimitDescriptor struct {
+	Entries []LocalRateLimitDescriptorEntry `json:"entries"`
+
+	LocalRateLimitTokenBucket
+}
+
+// LocalRateLimitConfig is the config of a local rate limit filter. Requests
+// are limited by Envoy, without the ratelimit service. Requests whose
+// actions produce the entries of a descriptor take tokens from its bucket,
+// the others from the default bucket.
+type LocalRateLimitConfig struct {
+	LocalRateLimitTokenBucket
+
+	// +optional
+	Descriptors []LocalRateLimitDescriptor `json:"descriptors,omitempty"`
+
+	// Actions produce the descriptor entries of a request, as the
+	// descriptors of a route_filter_ratelimit.
+	// +optional
+	Actions []Descriptors `json:"actions,omitempty"`
+
+	// StatusCode of rate limited requests, defaults to 429.
+	// +optional
+	StatusCode uint32 `json:"status_code,omitempty"`
+
+	// ResponseHeaders adds the x-ratelimit-limit, x-ratelimit-remaining
+	// and x-ratelimit-reset headers to responses.
+	// +optional
+	ResponseHeaders bool `json:"response_headers,omitempty"`
+
+	// ResponseBody of rate limited requests.
+	// +optional
+	ResponseBody *UpdateResponseBody `json:"response_body,omitempty"`
+
+	// Disabled, on a route, turns off the local rate limit of its virtual
+	// host.
+	// +optional
+	Disabled bool `json:"disabled,omitempty"`
+}
+
+func UnmarshalLocalRateLimitConfig(in_config string) (LocalRateLimitConfig, error) {
+	var lrl LocalRateLimitConfig
+	var err error
+
+	buf := strings.NewReader(in_config)
+	if err = json.NewDecoder(buf).Decode(&lrl); err != nil {
+		errors.Wrap(err, "decoding response")
+	}
+
+	return lrl, err
+}
+
+// AccessLogComparison compares a value of a request, eg: its status code,
+// with Value using Op, one of EQ, GE or LE.
+type AccessLogComparison struct {
+	Op    string `json:"op"`
+	Value uint32 `json:"value"`
+}
+
+// AccessLogFilterConfig selects the requests that are logged, a request is
+// logged if it matches every matcher that is set.
+type AccessLogFilterConfig struct {
+	// +optional
+	StatusCode *AccessLogComparison `json:"status_code,omitempty"`
+
+	// Duration of the request in milliseconds
+	// +optional
+	Duration *AccessLogComparison `json:"duration,omitempty"`
+
+	// NotHealthCheck skips requests answered by the health check filter.
+	// +optional
+	NotHealthCheck bool `json:"not_health_check,omitempty"`
+
+	// +optional
+	Headers []HeaderMatch `json:"headers,omitempty"`
+}
+
+// AccessLogConfig is the config of an access log, a globalconfig_accesslog
+// GlobalConfig or an http_filter_accesslog filter.
+// https://www.envoyproxy.io/docs/envoy/latest/api-v3/config/accesslog/v3/accesslog.proto
+type AccessLogConfig struct {
+	// Listener the GlobalConfig applies to, ingress_http or ingress_https,
+	// both if not set.
+	// +optional
+	Listener string `json:"listener,omitempty"`
+
+	// Sink is file or grpc, defaults to file.
+	// +optional
+	Sink string `json:"sink,omitempty"`
+
+	// Path of the file, defaults to the access log of the listener.
+	// +optional
+	Path string `json:"path,omitempty"`
+
+	// Format of the file, text or json, defaults to text.
+	// +optional
+	Format string `json:"format,omitempty"`
+
+	// TextFormat is a format string, defaults to Envoy's format.
+	// +optional
+	TextFormat string `json:"text_format,omitempty"`
+
+	// JsonFormat maps the fields of a log entry to format strings.
+	// +optional
+	JsonFormat map[string]interface{} `json:"json_format,omitempty"`
+
+	// LogName identifies the log to the gRPC access log service.
+	// +optional
+	LogName string `json:"log_name,omitempty"`
+
+	// Cluster of the gRPC access log service, defaults to the service of
+	// the filter or enroute.
+	// +optional
+	Cluster string `json:"cluster,omitempty"`
+
+	// +optional
+	Filter AccessLogFilterConfig `json:"filter,omitempty"`
+}
+
+func UnmarshalAccessLogConfig(accesslog_config string) (AccessLogConfig, error) {
+	var alc AccessLogConfig
+	var err error
+
+	buf := strings.NewReader(accesslog_config)
+	if err = json.NewDecoder(buf).Decode(&alc); err != nil {
+		errors.Wrap(err, "decoding response")
+	}
+
+	return alc, err
+}
+
+// WasmConfig is the config of a Wasm filter. The module is loaded from
+// exactly one of Filename, InlineCode or Url.
+// https://www.envoyproxy.io/docs/envoy/latest/api-v3/extensions/wasm/v3/wasm.proto
 type WasmConfig struct {
+	// Url is an http or https URL the module is fetched from, Sha256
+	// must be set with it.
 	Url string `protobuf:"bytes,2,opt,name=url,proto3" json:"url,omitempty"`
+
+	// Sha256 is the hex encoded SHA256 of a remote module.
+	// +optional
+	Sha256 string `json:"sha256,omitempty"`
+
+	// Filename is the path of the module on the Envoy host.
+	// +optional
+	Filename string `json:"filename,omitempty"`
+
+	// InlineCode is the module, base64 encoded.
+	// +optional
+	InlineCode string `json:"inline_code,omitempty"`
+
+	// Config is the JSON plugin configuration passed to the module.
+	// +optional
+	Config json.RawMessage `json:"config,omitempty"`
+
+	// +optional
+	RootId string `json:"root_id,omitempty"`
+
+	// +optional
+	VmId string `json:"vm_id,omitempty"`
+
+	// Runtime defaults to envoy.wasm.runtime.v8.
+	// +optional
+	Runtime string `json:"runtime,omitempty"`
+
+	// FailOpen lets requests through if the module fails.
+	// +optional
+	FailOpen bool `json:"fail_open,omitempty"`
+
+	// Timeout is how long, in seconds, to wait for a remote module.
+	// +optional
+	Timeout uint32 `json:"timeout,omitempty"`
 }
 
 func UnmarshalWasmConfig(wasm_config string) (WasmConfig, error) {
@@ -318,8 +755,41 @@ func UnmarshalExtAuthzFilterConfig(extauthz_config string) (ExtAuthzConfig, erro
 	return wc, err
 }
 
+// HealthCheckClusterMinHealthy names the cluster of a service, the health
+// check fails when fewer than MinHealthyPercent of its hosts are healthy.
+type HealthCheckClusterMinHealthy struct {
+	Service string `json:"service"`
+
+	// Namespace of the service, any namespace if not set
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+
+	// Port of the service, any port if not set
+	// +optional
+	Port int32 `json:"port,omitempty"`
+
+	MinHealthyPercent float64 `json:"min_healthy_percent"`
+}
+
+// HealthCheckConfig is the config of the health check filter, it responds
+// to health checks of the gateway on Path.
+// https://www.envoyproxy.io/docs/envoy/latest/api-v3/extensions/filters/http/health_check/v3/health_check.proto
 type HealthCheckConfig struct {
+	// Path defaults to /healthz
 	Path string `protobuf:"bytes,2,opt,name=path,proto3" json:"path,omitempty"`
+
+	// PassThrough passes health checks to the upstream of the route, the
+	// gateway fails them only when it is draining.
+	// +optional
+	PassThrough bool `json:"pass_through,omitempty"`
+
+	// CacheTime, in seconds, caches the response of the upstream when
+	// PassThrough is set.
+	// +optional
+	CacheTime int64 `json:"cache_time,omitempty"`
+
+	// +optional
+	ClusterMinHealthy []HealthCheckClusterMinHealthy `json:"cluster_min_healthy,omitempty"`
 }
 
 func UnmarshalHealthCheckConfig(healthcheck_config string) (HealthCheckConfig, error) {
@@ -329,9 +799,122 @@ func UnmarshalHealthCheckConfig(healthcheck_config string) (HealthCheckConfig, e
 	buf := strings.NewReader(healthcheck_config)
 	if err = json.NewDecoder(buf).Decode(&hcc); err != nil {
 		errors.Wrap(err, "error decoding response")
+		return hcc, err
+	}
+
+	for _, mh := range hcc.ClusterMinHealthy {
+		if mh.MinHealthyPercent < 0 || mh.MinHealthyPercent > 100 {
+			return hcc, errors.Errorf("min_healthy_percent of service %q must be between 0 and 100, got %v", mh.Service, mh.MinHealthyPercent)
+		}
+	}
+
+	return hcc, nil
+}
+
+// TracingCustomTag adds a tag to the spans of a request, with a literal
+// value or the value of a request header or environment variable.
+// https://www.envoyproxy.io/docs/envoy/latest/api-v3/type/tracing/v3/custom_tag.proto
+type TracingCustomTag struct {
+	Tag string `json:"tag"`
+
+	// +optional
+	Literal string `json:"literal,omitempty"`
+
+	// +optional
+	Header string `json:"header,omitempty"`
+
+	// +optional
+	Environment string `json:"environment,omitempty"`
+
+	// Default value of a header or environment tag
+	// +optional
+	Default string `json:"default,omitempty"`
+}
+
+// TracingSampling holds the percentages of requests traced.
+// https://www.envoyproxy.io/docs/envoy/latest/api-v3/extensions/filters/network/http_connection_manager/v3/http_connection_manager.proto#envoy-v3-api-msg-extensions-filters-network-http-connection-manager-v3-httpconnectionmanager-tracing
+type TracingSampling struct {
+	// ClientSampling of requests with the x-client-trace-id header,
+	// defaults to 100.
+	// +optional
+	ClientSampling *float64 `json:"client_sampling,omitempty"`
+
+	// RandomSampling of requests, defaults to 100.
+	// +optional
+	RandomSampling *float64 `json:"random_sampling,omitempty"`
+
+	// OverallSampling of requests after the other sampling, defaults
+	// to 100.
+	// +optional
+	OverallSampling *float64 `json:"overall_sampling,omitempty"`
+}
+
+// TracingConfig is the config of a globalconfig_tracing GlobalConfig,
+// spans are sent to a Zipkin or OpenTelemetry collector.
+type TracingConfig struct {
+	// Provider is zipkin or opentelemetry, defaults to zipkin.
+	// +optional
+	Provider string `json:"provider,omitempty"`
+
+	// Address and Port of the collector. If not set spans are sent to the
+	// collector of the Envoy bootstrap.
+	// +optional
+	Address string `json:"address,omitempty"`
+
+	// +optional
+	Port uint32 `json:"port,omitempty"`
+
+	// CollectorEndpoint is the path spans are posted to by zipkin,
+	// defaults to /api/v2/spans.
+	// +optional
+	CollectorEndpoint string `json:"collector_endpoint,omitempty"`
+
+	// ServiceName reported to opentelemetry, defaults to enroute.
+	// +optional
+	ServiceName string `json:"service_name,omitempty"`
+
+	TracingSampling
+
+	// +optional
+	CustomTags []TracingCustomTag `json:"custom_tags,omitempty"`
+
+	// MaxPathTagLength truncates the path tag of spans, defaults to 256.
+	// +optional
+	MaxPathTagLength uint32 `json:"max_path_tag_length,omitempty"`
+}
+
+func UnmarshalTracingConfig(tracing_config string) (TracingConfig, error) {
+	var tc TracingConfig
+	var err error
+
+	buf := strings.NewReader(tracing_config)
+	if err = json.NewDecoder(buf).Decode(&tc); err != nil {
+		errors.Wrap(err, "error decoding response")
+	}
+
+	return tc, err
+}
+
+// RouteTracingConfig is the config of a route_filter_tracing filter, it
+// overrides the sampling of the tracing GlobalConfig for a route and adds
+// tags to its spans.
+type RouteTracingConfig struct {
+	TracingSampling
+
+	// +optional
+	CustomTags []TracingCustomTag `json:"custom_tags,omitempty"`
+}
+
+func UnmarshalRouteTracingConfig(tracing_config string) (RouteTracingConfig, error) {
+	var rtc RouteTracingConfig
+	var err error
+
+	buf := strings.NewReader(tracing_config)
+	if err = json.NewDecoder(buf).Decode(&rtc); err != nil {
+		errors.Wrap(err, "error decoding response")
 	}
 
-	return hcc, err
+	return rtc, err
 }
 
 // If Pattern_regex is specified, extract group from Pattern_regex, use it to build Substitution
@@ -394,20 +977,77 @@ func UnmarshalDirectResponseConfig(in_config string) (DirectResponseConfig, erro
 	return cfg, err
 }
 
+// MirrorServiceConfig is an upstream requests are mirrored to
+type MirrorServiceConfig struct {
+	Name string `json:"name"`
+	Port uint32 `json:"port"`
+}
+
+// MirrorConfig is the config of a route_filter_mirror filter, a copy of
+// Percent of the requests of a route is sent to each of Services.
+// Responses of mirrored requests are discarded.
+type MirrorConfig struct {
+	Services []MirrorServiceConfig `json:"services"`
+
+	// Percent of requests mirrored, defaults to 100.
+	// +optional
+	Percent *uint32 `json:"percent,omitempty"`
+}
+
+func UnmarshalMirrorConfig(mirror_config string) (MirrorConfig, error) {
+	var mc MirrorConfig
+	var err error
+
+	buf := strings.NewReader(mirror_config)
+	if err = json.NewDecoder(buf).Decode(&mc); err != nil {
+		errors.Wrap(err, "error decoding response")
+	}
+
+	return mc, err
+}
+
+// UpdateResponseBody formats the body of a local reply. Formats may use
+// Envoy's substitution variables, e.g. %RESPONSE_CODE% or %LOCAL_REPLY_BODY%.
 type UpdateResponseBody struct {
 	TextFormat string `json:"text_format,omitempty"`
 	JsonFormat map[string]interface{}`json:"json_format,omitempty"`
+
+	// ContentType of the body, defaults to application/json for a
+	// json_format and to text/plain otherwise.
+	// +optional
 	ContentType string `json:"content_type,omitempty"`
 }
 
 type CustomResponse struct {
+	// MatchStatusCode of the local reply, any status code if not set.
+	// +optional
 	MatchStatusCode uint32 `json:"match_status_code,omitempty"`
+
+	// MatchHeaders the request must match.
+	// +optional
+	MatchHeaders []HeaderMatch `json:"match_headers,omitempty"`
+
+	// MatchPathPrefix restricts the response to the route of this prefix.
+	// +optional
+	MatchPathPrefix string `json:"match_path_prefix,omitempty"`
+
 	UpdateStatusCode uint32 `json:"update_status_code,omitempty"`
 	UpdateResponseBody UpdateResponseBody `json:"update_response_body,omitempty"`
 }
 
+// CustomResponseConfig is the config of a custom_response filter and of a
+// globalconfig_localreply GlobalConfig.
 type CustomResponseConfig struct {
+	// Listener a globalconfig_localreply applies to, ingress_http or
+	// ingress_https, both if not set.
+	// +optional
+	Listener string `json:"listener,omitempty"`
+
 	CustomResponseOverride []CustomResponse `json:"custom_response_override,omitempty"`
+
+	// DefaultResponseBody formats the local replies no override matches.
+	// +optional
+	DefaultResponseBody *UpdateResponseBody `json:"default_response_body,omitempty"`
 }
 
 func UnmarshalCustomResponse(in_config string) (CustomResponseConfig, error) {
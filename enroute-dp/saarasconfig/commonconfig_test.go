@@ -154,3 +154,60 @@ func TestRouteMatchUnmarshal(t *testing.T) {
 		})
 	}
 }
+
+func TestJwtConfigUnmarshal(t *testing.T) {
+	tests := map[string]struct {
+		jwt_config string
+		want       JwtConfig
+	}{
+		"remote jwks": {
+			jwt_config: `
+            {
+                "name" : "auth0",
+                "jwks_uri" : "https://saaras.auth0.com/.well-known/jwks.json",
+                "audience" : "api.saaras.io",
+                "issuer" : "https://saaras.auth0.com/",
+                "route" : [{"prefix" : "/"}],
+                "jwt_service_name" : "auth0",
+                "jwt_service_port" : "443",
+                "jwt_forward_header_name" : "x-jwt-payload"
+            }
+            `,
+			want: JwtConfig{
+				Name:                    "auth0",
+				JwksUri:                 "https://saaras.auth0.com/.well-known/jwks.json",
+				Audience:                "api.saaras.io",
+				Issuer:                  "https://saaras.auth0.com/",
+				Route:                   []JwtRule{{Prefix: "/"}},
+				Jwt_forward_header_name: "x-jwt-payload",
+			},
+		},
+		"local jwks with claims and bypass": {
+			jwt_config: `
+            {
+                "name" : "local",
+                "local_jwks" : "{\"keys\":[]}",
+                "audiences" : ["a", "b"],
+                "claim_to_headers" : [{"header_name" : "x-sub", "claim_name" : "sub"}],
+                "route" : [{"prefix" : "/api"}, {"path" : "/healthz", "bypass" : true}]
+            }
+            `,
+			want: JwtConfig{
+				Name:           "local",
+				LocalJwks:      `{"keys":[]}`,
+				Audiences:      []string{"a", "b"},
+				ClaimToHeaders: []JwtClaimToHeader{{HeaderName: "x-sub", ClaimName: "sub"}},
+				Route:          []JwtRule{{Prefix: "/api"}, {Path: "/healthz", Bypass: true}},
+			},
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := UnmarshalJwtConfig(tc.jwt_config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
@@ -6,6 +6,9 @@
 package saarasfilters
 
 import (
+	"sort"
+	"strings"
+
 	_ "github.com/davecgh/go-spew/spew"
 	envoy_extensions_filters_network_http_connection_manager_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
 	_ "github.com/saarasio/enroute/enroute-dp/internal/logger"
@@ -15,6 +18,51 @@ import (
 func SequenceFilters(m *map[string]*envoy_extensions_filters_network_http_connection_manager_v3.HttpFilter) []*envoy_extensions_filters_network_http_connection_manager_v3.HttpFilter {
 	http_filters := make([]*envoy_extensions_filters_network_http_connection_manager_v3.HttpFilter, 0)
 
+	// Health check, answered before any authentication
+	if hf, ok := (*m)["envoy.health_check"]; ok {
+		http_filters = append(http_filters, hf)
+	}
+
+	// Local rate limit, requests over the limit are rejected before any
+	// other work is done
+	if hf, ok := (*m)["envoy.filters.http.local_ratelimit"]; ok {
+		http_filters = append(http_filters, hf)
+	}
+
+	// JWT, verified before Lua can act on its claims
+	if hf, ok := (*m)["envoy.jwt_authn"]; ok {
+		http_filters = append(http_filters, hf)
+	}
+
+	// RBAC, after the JWT is verified so policies can match its claims
+	if hf, ok := (*m)["envoy.rbac"]; ok {
+		http_filters = append(http_filters, hf)
+	}
+
+	// External authorization, after the JWT is verified, in name order
+	var extAuthz []string
+	for name := range *m {
+		if strings.HasPrefix(name, "envoy.ext_authz.") {
+			extAuthz = append(extAuthz, name)
+		}
+	}
+	sort.Strings(extAuthz)
+	for _, name := range extAuthz {
+		http_filters = append(http_filters, (*m)[name])
+	}
+
+	// Wasm, in name order
+	var wasm []string
+	for name := range *m {
+		if strings.HasPrefix(name, "envoy.wasm.") {
+			wasm = append(wasm, name)
+		}
+	}
+	sort.Strings(wasm)
+	for _, name := range wasm {
+		http_filters = append(http_filters, (*m)[name])
+	}
+
 	// Lua
 	if hf, ok := (*m)["envoy.lua"]; ok {
 		http_filters = append(http_filters, hf)
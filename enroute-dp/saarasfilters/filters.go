@@ -43,6 +43,106 @@ func SetConfigJson(log *logrus.Entry, filter_type string, filter_config string,
 			(*args)["config_json"] = cors_config_filter
 			log.Errorf("Failed to decode CORS Config [%+v] \n", filter_config)
 		}
+	case saarasconfig.FILTER_TYPE_VH_RBAC:
+		cfg, err := saarasconfig.UnmarshalRbacConfig(filter_config)
+		if err == nil {
+			*parse_config_success = true
+			(*args)["config_json"] = cfg
+		} else {
+			var rbac_config saarasconfig.RbacConfig
+			(*args)["config_json"] = rbac_config
+			log.Errorf("Failed to decode RBAC Config [%+v] \n", filter_config)
+		}
+	case saarasconfig.FILTER_TYPE_HTTP_JWT:
+		cfg, err := saarasconfig.UnmarshalJwtConfig(filter_config)
+		if err == nil {
+			*parse_config_success = true
+			(*args)["config_json"] = cfg
+		} else {
+			var jwt_config saarasconfig.JwtConfig
+			(*args)["config_json"] = jwt_config
+			log.Errorf("Failed to decode JWT Config [%+v] \n", filter_config)
+		}
+	case saarasconfig.FILTER_TYPE_HTTP_EXTAUTHZ:
+		cfg, err := saarasconfig.UnmarshalExtAuthzFilterConfig(filter_config)
+		if err == nil {
+			*parse_config_success = true
+			(*args)["config_json"] = cfg
+		} else {
+			var extauthz_config saarasconfig.ExtAuthzConfig
+			(*args)["config_json"] = extauthz_config
+			log.Errorf("Failed to decode ExtAuthz Config [%+v] \n", filter_config)
+		}
+	case saarasconfig.FILTER_TYPE_HTTP_WASM:
+		cfg, err := saarasconfig.UnmarshalWasmConfig(filter_config)
+		if err == nil {
+			*parse_config_success = true
+			(*args)["config_json"] = cfg
+		} else {
+			var wasm_config saarasconfig.WasmConfig
+			(*args)["config_json"] = wasm_config
+			log.Errorf("Failed to decode Wasm Config [%+v] \n", filter_config)
+		}
+	case saarasconfig.FILTER_TYPE_HTTP_HEALTHCHECK:
+		cfg, err := saarasconfig.UnmarshalHealthCheckConfig(filter_config)
+		if err == nil {
+			*parse_config_success = true
+			(*args)["config_json"] = cfg
+		} else {
+			var healthcheck_config saarasconfig.HealthCheckConfig
+			(*args)["config_json"] = healthcheck_config
+			log.Errorf("Failed to decode HealthCheck Config [%+v] \n", filter_config)
+		}
+	case saarasconfig.FILTER_TYPE_HTTP_ACCESSLOG:
+		cfg, err := saarasconfig.UnmarshalAccessLogConfig(filter_config)
+		if err == nil {
+			*parse_config_success = true
+			(*args)["config_json"] = cfg
+		} else {
+			var accesslog_config saarasconfig.AccessLogConfig
+			(*args)["config_json"] = accesslog_config
+			log.Errorf("Failed to decode AccessLog Config [%+v] \n", filter_config)
+		}
+	case saarasconfig.FILTER_TYPE_RT_TRACING:
+		cfg, err := saarasconfig.UnmarshalRouteTracingConfig(filter_config)
+		if err == nil {
+			*parse_config_success = true
+			(*args)["config_json"] = cfg
+		} else {
+			var tracing_config saarasconfig.RouteTracingConfig
+			(*args)["config_json"] = tracing_config
+			log.Errorf("Failed to decode Tracing Config [%+v] \n", filter_config)
+		}
+	case saarasconfig.FILTER_TYPE_RT_MIRROR:
+		cfg, err := saarasconfig.UnmarshalMirrorConfig(filter_config)
+		if err == nil {
+			*parse_config_success = true
+			(*args)["config_json"] = cfg
+		} else {
+			var mirror_config saarasconfig.MirrorConfig
+			(*args)["config_json"] = mirror_config
+			log.Errorf("Failed to decode Mirror Config [%+v] \n", filter_config)
+		}
+	case saarasconfig.FILTER_TYPE_VH_LOCAL_RATELIMIT:
+		cfg, err := saarasconfig.UnmarshalLocalRateLimitConfig(filter_config)
+		if err == nil {
+			*parse_config_success = true
+			(*args)["config_json"] = cfg
+		} else {
+			var localratelimit_config saarasconfig.LocalRateLimitConfig
+			(*args)["config_json"] = localratelimit_config
+			log.Errorf("Failed to decode LocalRateLimit Config [%+v] \n", filter_config)
+		}
+	case saarasconfig.FILTER_TYPE_CUSTOM_RESPONSE:
+		cfg, err := saarasconfig.UnmarshalCustomResponse(filter_config)
+		if err == nil {
+			*parse_config_success = true
+			(*args)["config_json"] = cfg
+		} else {
+			var custom_response_config saarasconfig.CustomResponseConfig
+			(*args)["config_json"] = custom_response_config
+			log.Errorf("Failed to decode CustomResponse Config [%+v] \n", filter_config)
+		}
 
 	default:
 		// Unsupported filter
@@ -58,8 +158,28 @@ func IsFilterTypeValid(filter_type string) bool {
 		return true
 	case saarasconfig.FILTER_TYPE_VH_CORS:
 		return true
+	case saarasconfig.FILTER_TYPE_VH_RBAC:
+		return true
 	case saarasconfig.FILTER_TYPE_RT_RATELIMIT:
 		return true
+	case saarasconfig.FILTER_TYPE_HTTP_JWT:
+		return true
+	case saarasconfig.FILTER_TYPE_HTTP_EXTAUTHZ:
+		return true
+	case saarasconfig.FILTER_TYPE_HTTP_WASM:
+		return true
+	case saarasconfig.FILTER_TYPE_HTTP_HEALTHCHECK:
+		return true
+	case saarasconfig.FILTER_TYPE_HTTP_ACCESSLOG:
+		return true
+	case saarasconfig.FILTER_TYPE_RT_TRACING:
+		return true
+	case saarasconfig.FILTER_TYPE_RT_MIRROR:
+		return true
+	case saarasconfig.FILTER_TYPE_CUSTOM_RESPONSE:
+		return true
+	case saarasconfig.FILTER_TYPE_VH_LOCAL_RATELIMIT:
+		return true
 	default:
 		return false
 	}
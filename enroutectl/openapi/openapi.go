@@ -153,12 +153,7 @@ func routeMatchCondition(path, opName string) string {
 	if routeMatchConfig.MatchConditions == nil {
 		routeMatchConfig.MatchConditions = make([]saarasconfig.RouteMatchCondition, 0)
 	}
-
-	routeMatchConfig.MatchConditions = append(routeMatchConfig.MatchConditions,
-		saarasconfig.RouteMatchCondition{
-			Name:  ":method",
-			Exact: opName,
-		})
+	routeMatchConfig.Method = opName
 
 	match_json, err := JSONMarshal(routeMatchConfig)
 
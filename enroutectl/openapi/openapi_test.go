@@ -247,7 +247,7 @@ func TestWalkSpec(t *testing.T) {
 }
 `
 
-	var ps_json_want = `{"data":{"saaras_db_proxy":[{"proxy_name":"gw","proxy_services":[{"service":{"fqdn":"*","service_name":"openapi-petstore.swagger.io","routes":[{"route_config":"{\"prefix\":\"/v1/pets\",\"header\":[{\"name\":\":method\",\"exact\":\"POST\"}]}\n","route_name":"openapi-createPets-v1pets-c7baa9ec7f","route_upstreams":[{"upstream":{"upstream_ip":"petstore.swagger.io","upstream_name":"openapi-upstream-petstore.swagger.io","upstream_port":80}}]},{"route_config":"{\"prefix\":\"/v1/pets\",\"header\":[{\"name\":\":method\",\"exact\":\"GET\"}]}\n","route_name":"openapi-listPets-v1pets-ec51ad447d","route_upstreams":[{"upstream":{"upstream_ip":"petstore.swagger.io","upstream_name":"openapi-upstream-petstore.swagger.io","upstream_port":80}}]},{"route_config":"{\"prefix\":\"/v1/pets/(?P<petId>.*)/abcd/(?P<petId2>.*)/efgh/(?P<petId3>.*)$\",\"header\":[{\"name\":\":method\",\"exact\":\"GET\"}]}\n","route_name":"openapi-showPetById-f06a8d1fd1-dc96a9cc81","route_upstreams":[{"upstream":{"upstream_ip":"petstore.swagger.io","upstream_name":"openapi-upstream-petstore.swagger.io","upstream_port":80}}]}]}}]}]}}
+	var ps_json_want = `{"data":{"saaras_db_proxy":[{"proxy_name":"gw","proxy_services":[{"service":{"fqdn":"*","service_name":"openapi-petstore.swagger.io","routes":[{"route_config":"{\"prefix\":\"/v1/pets\",\"header\":[],\"method\":\"POST\"}\n","route_name":"openapi-createPets-v1pets-110c2d7fd5","route_upstreams":[{"upstream":{"upstream_ip":"petstore.swagger.io","upstream_name":"openapi-upstream-petstore.swagger.io","upstream_port":80}}]},{"route_config":"{\"prefix\":\"/v1/pets\",\"header\":[],\"method\":\"GET\"}\n","route_name":"openapi-listPets-v1pets-42619da1c6","route_upstreams":[{"upstream":{"upstream_ip":"petstore.swagger.io","upstream_name":"openapi-upstream-petstore.swagger.io","upstream_port":80}}]},{"route_config":"{\"prefix\":\"/v1/pets/(?P<petId>.*)/abcd/(?P<petId2>.*)/efgh/(?P<petId3>.*)$\",\"header\":[],\"method\":\"GET\"}\n","route_name":"openapi-showPetById-f06a8d1fd1-2d56294aac","route_upstreams":[{"upstream":{"upstream_ip":"petstore.swagger.io","upstream_name":"openapi-upstream-petstore.swagger.io","upstream_port":80}}]}]}}]}]}}
 `
 	tests := map[string]struct {
 		spec_in           string
@@ -288,8 +288,8 @@ func TestSwaggerPathSpecToSaarasRoute(t *testing.T) {
 			opname_in: "GET",
 			prefix_in: "/test",
 			want: config.Routes{
-				RouteConfig: "{\"prefix\":\"/test\",\"header\":[{\"name\":\":method\",\"exact\":\"GET\"}]}\n",
-				RouteName:   "openapi-test-test-89c114d0f6",
+				RouteConfig: "{\"prefix\":\"/test\",\"header\":[],\"method\":\"GET\"}\n",
+				RouteName:   "openapi-test-test-bc82ce1929",
 				RouteUpstreams: []config.RouteUpstreams{{
 					Upstream: config.Upstream{
 						UpstreamName: "openapi-upstream-testhost",
@@ -309,8 +309,8 @@ func TestSwaggerPathSpecToSaarasRoute(t *testing.T) {
 			opname_in: "GET",
 			prefix_in: "/test2",
 			want: config.Routes{
-				RouteConfig: "{\"prefix\":\"/test2\",\"header\":[{\"name\":\":method\",\"exact\":\"GET\"}]}\n",
-				RouteName:   "openapi-test2-test2-7a669d3de6",
+				RouteConfig: "{\"prefix\":\"/test2\",\"header\":[],\"method\":\"GET\"}\n",
+				RouteName:   "openapi-test2-test2-011d975e49",
 				RouteUpstreams: []config.RouteUpstreams{{
 					Upstream: config.Upstream{
 						UpstreamName: "openapi-upstream-testhost",
@@ -330,8 +330,8 @@ func TestSwaggerPathSpecToSaarasRoute(t *testing.T) {
 			opname_in: "PUT",
 			prefix_in: "/test",
 			want: config.Routes{
-				RouteConfig: "{\"prefix\":\"/test\",\"header\":[{\"name\":\":method\",\"exact\":\"PUT\"}]}\n",
-				RouteName:   "openapi-put-test-818f8f196d",
+				RouteConfig: "{\"prefix\":\"/test\",\"header\":[],\"method\":\"PUT\"}\n",
+				RouteName:   "openapi-put-test-334d36eb58",
 				RouteUpstreams: []config.RouteUpstreams{{
 					Upstream: config.Upstream{
 						UpstreamName: "openapi-upstream-testhost",
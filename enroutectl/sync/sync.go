@@ -142,9 +142,30 @@ func (s *EnrouteSync) SyncProxyServicesAdd(services_to, services_from []config.P
 // TODO: Needs test
 func routeMatchConditionsEqual(ra_mc, rb_mc saarasconfig.RouteMatchConditions) bool {
 	if ra_mc.Prefix == rb_mc.Prefix {
+		if ra_mc.Method != rb_mc.Method {
+			return false
+		}
+
 		sort.Stable(saarasconfig.RouteMatchConditionsByHeaderNameVal(ra_mc.MatchConditions))
 		sort.Stable(saarasconfig.RouteMatchConditionsByHeaderNameVal(rb_mc.MatchConditions))
 
+		sort.Stable(saarasconfig.RouteMatchQueryParamsByName(ra_mc.QueryParams))
+		sort.Stable(saarasconfig.RouteMatchQueryParamsByName(rb_mc.QueryParams))
+
+		if len(ra_mc.QueryParams) != len(rb_mc.QueryParams) {
+			return false
+		}
+		for idx, q := range ra_mc.QueryParams {
+			if q != rb_mc.QueryParams[idx] {
+				return false
+			}
+		}
+
+		if !routeHeadersConfigEqual(ra_mc.RequestHeaders, rb_mc.RequestHeaders) ||
+			!routeHeadersConfigEqual(ra_mc.ResponseHeaders, rb_mc.ResponseHeaders) {
+			return false
+		}
+
 		ra_cond := ra_mc.MatchConditions
 		rb_cond := rb_mc.MatchConditions
 
@@ -162,6 +183,37 @@ func routeMatchConditionsEqual(ra_mc, rb_mc saarasconfig.RouteMatchConditions) b
 	return true
 }
 
+// routeHeadersConfigEqual returns true if a and b manage headers the same
+// way, no config is the same as an empty one. Headers are added in order.
+func routeHeadersConfigEqual(a, b *saarasconfig.RouteHeadersConfig) bool {
+	if a == nil {
+		a = &saarasconfig.RouteHeadersConfig{}
+	}
+	if b == nil {
+		b = &saarasconfig.RouteHeadersConfig{}
+	}
+
+	if len(a.Set) != len(b.Set) || len(a.Add) != len(b.Add) || len(a.Remove) != len(b.Remove) {
+		return false
+	}
+	for idx, h := range a.Set {
+		if h != b.Set[idx] {
+			return false
+		}
+	}
+	for idx, h := range a.Add {
+		if h != b.Add[idx] {
+			return false
+		}
+	}
+	for idx, name := range a.Remove {
+		if name != b.Remove[idx] {
+			return false
+		}
+	}
+	return true
+}
+
 // TODO: Needs test
 func routesEqual(ra, rb *config.Routes) bool {
 	if ra.RouteName == rb.RouteName {
@@ -171,7 +223,7 @@ func routesEqual(ra, rb *config.Routes) bool {
 		}
 
 		ra_mc, err1 := saarasconfig.UnmarshalRouteMatchCondition(ra.RouteConfig)
-		rb_mc, err2 := saarasconfig.UnmarshalRouteMatchCondition(ra.RouteConfig)
+		rb_mc, err2 := saarasconfig.UnmarshalRouteMatchCondition(rb.RouteConfig)
 
 		if err1 != nil || err2 != nil {
 			return false
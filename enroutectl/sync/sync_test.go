@@ -8,6 +8,7 @@ import (
 	"github.com/golang/protobuf/ptypes/any"
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/saarasio/enroute/enroute-dp/saarasconfig"
 	"github.com/saarasio/enroute/enroutectl/config"
 	"testing"
 )
@@ -114,3 +115,57 @@ func TestUpstreamCreate(t *testing.T) {
 		})
 	}
 }
+
+func TestRouteMatchConditionsEqual(t *testing.T) {
+	tests := map[string]struct {
+		a, b saarasconfig.RouteMatchConditions
+		want bool
+	}{
+		"same headers": {
+			a: saarasconfig.RouteMatchConditions{
+				Prefix:         "/",
+				RequestHeaders: &saarasconfig.RouteHeadersConfig{Set: []saarasconfig.RouteHeaderValue{{Name: "x-env", Value: "prod"}}},
+			},
+			b: saarasconfig.RouteMatchConditions{
+				Prefix:         "/",
+				RequestHeaders: &saarasconfig.RouteHeadersConfig{Set: []saarasconfig.RouteHeaderValue{{Name: "x-env", Value: "prod"}}},
+			},
+			want: true,
+		},
+		"request header value changed": {
+			a: saarasconfig.RouteMatchConditions{
+				Prefix:         "/",
+				RequestHeaders: &saarasconfig.RouteHeadersConfig{Set: []saarasconfig.RouteHeaderValue{{Name: "x-env", Value: "prod"}}},
+			},
+			b: saarasconfig.RouteMatchConditions{
+				Prefix:         "/",
+				RequestHeaders: &saarasconfig.RouteHeadersConfig{Set: []saarasconfig.RouteHeaderValue{{Name: "x-env", Value: "dev"}}},
+			},
+			want: false,
+		},
+		"response header removed": {
+			a: saarasconfig.RouteMatchConditions{
+				Prefix:          "/",
+				ResponseHeaders: &saarasconfig.RouteHeadersConfig{Remove: []string{"server"}},
+			},
+			b:    saarasconfig.RouteMatchConditions{Prefix: "/"},
+			want: false,
+		},
+		"method changed": {
+			a:    saarasconfig.RouteMatchConditions{Prefix: "/", Method: "GET"},
+			b:    saarasconfig.RouteMatchConditions{Prefix: "/", Method: "POST"},
+			want: false,
+		},
+		"empty headers config": {
+			a:    saarasconfig.RouteMatchConditions{Prefix: "/", ResponseHeaders: &saarasconfig.RouteHeadersConfig{}},
+			b:    saarasconfig.RouteMatchConditions{Prefix: "/"},
+			want: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			Equal(t, tc.want, routeMatchConditionsEqual(tc.a, tc.b))
+		})
+	}
+}